@@ -13,6 +13,7 @@
 	"github.com/ehazlett/simplelog"
 	_ "github.com/rancher/norman/controller"
 	"github.com/rancher/norman/pkg/kwrapper/k8s"
+	"github.com/rancher/rancher/pkg/auth/audit"
 	"github.com/rancher/rancher/pkg/data/management"
 	"github.com/rancher/rancher/pkg/logserver"
 	"github.com/rancher/rancher/pkg/rancher"
@@ -30,6 +31,7 @@
 func main() {
 	management.RegisterPasswordResetCommand()
 	management.RegisterEnsureDefaultAdminCommand()
+	audit.RegisterVerifyCommand()
 	if reexec.Init() {
 		return
 	}
@@ -117,7 +119,7 @@ func main() {
 			Name:        "audit-log-path",
 			EnvVar:      "AUDIT_LOG_PATH",
 			Value:       "/var/log/auditlog/rancher-api-audit.log",
-			Usage:       "Log path for Rancher Server API. Default path is /var/log/auditlog/rancher-api-audit.log",
+			Usage:       "Log path for Rancher Server API. Default path is /var/log/auditlog/rancher-api-audit.log, set it to an empty string to only send records to the audit-log-sinks",
 			Destination: &config.AuditLogPath,
 		},
 		cli.IntFlag{
@@ -145,7 +147,7 @@ func main() {
 			Name:        "audit-level",
 			Value:       0,
 			EnvVar:      "AUDIT_LEVEL",
-			Usage:       "Audit log level: 0 - disable audit log, 1 - log event metadata, 2 - log event metadata and request body, 3 - log event metadata, request body and response body",
+			Usage:       "Audit log level: 0 - disable audit log, 1 - log event metadata, 2 - log event metadata and request body, 3 - log event metadata, request body and response body. The audit-log-policy setting can only change the level of requests if this is above 0",
 			Destination: &config.AuditLevel,
 		},
 		cli.StringFlag{
@@ -8,6 +8,7 @@
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/types"
 	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/rbac"
 	"github.com/rancher/rancher/pkg/types/config/dialer"
@@ -19,6 +20,7 @@ type Handler struct {
 	ClusterAlertRule v3.ClusterAlertRuleInterface
 	ProjectAlertRule v3.ProjectAlertRuleInterface
 	Notifiers        v3.NotifierInterface
+	Secrets          v1.SecretLister
 	DialerFactory    dialer.Factory
 }
 
@@ -3,7 +3,9 @@
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net/http"
 
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 
@@ -23,12 +25,14 @@
 func NotifierCollectionFormatter(apiContext *types.APIContext, collection *types.GenericCollection) {
 	if canCreateNotifier(apiContext, nil, "") {
 		collection.AddAction(apiContext, "send")
+		collection.AddAction(apiContext, "preview")
 	}
 }
 
 func NotifierFormatter(apiContext *types.APIContext, resource *types.RawResource) {
 	if canCreateNotifier(apiContext, resource, "") {
 		resource.AddAction(apiContext, "send")
+		resource.AddAction(apiContext, "preview")
 	}
 }
 
@@ -36,6 +40,8 @@ func (h *Handler) NotifierActionHandler(actionName string, action *types.Action,
 	switch actionName {
 	case "send":
 		return h.testNotifier(apiContext.Request.Context(), actionName, action, apiContext)
+	case "preview":
+		return h.previewNotification(actionName, action, apiContext)
 	}
 
 	return httperror.NewAPIError(httperror.InvalidAction, "invalid action: "+actionName)
@@ -66,6 +72,9 @@ func (h *Handler) testNotifier(ctx context.Context, actionName string, action *t
 	}
 
 	notifier := &v3.Notifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: clientNotifier.ClusterID,
+		},
 		Spec: input.NotifierSpec,
 	}
 	msg := input.Message
@@ -75,6 +84,14 @@ func (h *Handler) testNotifier(ctx context.Context, actionName string, action *t
 		if err != nil {
 			return err
 		}
+		// the template of the notification is tested instead of the one of the notifier
+		if input.Template != nil {
+			notifier = notifier.DeepCopy()
+			notifier.Spec.Template = input.Template
+		}
+	}
+	if err := notifiers.ValidateTemplate(notifier.Spec.Template); err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
 	}
 	notifierMessage := &notifiers.Message{
 		Content: msg,
@@ -87,7 +104,47 @@ func (h *Handler) testNotifier(ctx context.Context, actionName string, action *t
 	if err != nil {
 		return errors.Wrap(err, "error getting dialer")
 	}
-	return notifiers.SendMessage(ctx, notifier, "", notifierMessage, dialer)
+	return notifiers.SendMessage(ctx, notifier, "", notifierMessage, dialer, h.Secrets)
+}
+
+// previewNotification renders a template against a sample message without sending it. The
+// template of the notifier is used when the input has none.
+func (h *Handler) previewNotification(actionName string, action *types.Action, apiContext *types.APIContext) error {
+	data, err := ioutil.ReadAll(apiContext.Request.Body)
+	if err != nil {
+		return errors.Wrap(err, "reading request body error")
+	}
+	input := &v32.NotificationPreviewInput{}
+	if err = json.Unmarshal(data, input); err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, fmt.Sprintf("failed to parse input: %v", err))
+	}
+
+	tmpl := input.Template
+	if tmpl == nil && apiContext.ID != "" {
+		ns, id := ref.Parse(apiContext.ID)
+		notifier, err := h.Notifiers.GetNamespaced(ns, id, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		tmpl = notifier.Spec.Template
+	}
+
+	msg, err := notifiers.RenderMessage(tmpl, &notifiers.Message{
+		Title:       input.Title,
+		Content:     input.Content,
+		Labels:      input.Labels,
+		Annotations: input.Annotations,
+	})
+	if err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
+	}
+
+	apiContext.WriteResponse(http.StatusOK, map[string]interface{}{
+		"title": msg.Title,
+		"body":  msg.Content,
+		"type":  "notificationPreviewOutput",
+	})
+	return nil
 }
 
 func canCreateNotifier(apiContext *types.APIContext, resource *types.RawResource, clusterID string) bool {
@@ -10,6 +10,7 @@
 	"github.com/rancher/norman/types"
 	"github.com/rancher/norman/types/convert"
 	v3client "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	"github.com/rancher/rancher/pkg/notifiers"
 	"github.com/rancher/rancher/pkg/ref"
 )
 
@@ -75,3 +76,19 @@ func ProjectAlertRuleValidator(resquest *types.APIContext, schema *types.Schema,
 
 	return nil
 }
+
+func NotifierValidator(request *types.APIContext, schema *types.Schema, data map[string]interface{}) error {
+	var spec v32.NotifierSpec
+	if err := convert.ToObj(data, &spec); err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, fmt.Sprintf("%v", err))
+	}
+	if err := notifiers.ValidateTemplate(spec.Template); err != nil {
+		return httperror.NewFieldAPIError(httperror.InvalidFormat, "template", err.Error())
+	}
+	if spec.SMTPConfig != nil && spec.SMTPConfig.OAuth2 != nil {
+		if err := notifiers.ValidateTokenURL(spec.SMTPConfig.OAuth2.TokenURL); err != nil {
+			return httperror.NewFieldAPIError(httperror.InvalidFormat, "tokenUrl", err.Error())
+		}
+	}
+	return nil
+}
@@ -10,6 +10,7 @@
 	"github.com/rancher/rancher/pkg/catalog/manager"
 	mgmtclient "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	"github.com/rancher/rancher/pkg/clustermanager"
+	corev1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/user"
 	v1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
@@ -35,6 +36,7 @@ type ActionHandler struct {
 	CisConfigClient               v3.CisConfigInterface
 	CisConfigLister               v3.CisConfigLister
 	TokenClient                   v3.TokenInterface
+	SecretLister                  corev1.SecretLister
 }
 
 func (a ActionHandler) ClusterActionHandler(actionName string, action *types.Action, apiContext *types.APIContext) error {
@@ -94,6 +96,8 @@ func (a ActionHandler) ClusterActionHandler(actionName string, action *types.Act
 			return httperror.NewAPIError(httperror.PermissionDenied, "can not restore etcd backup")
 		}
 		return a.RestoreFromEtcdBackupHandler(actionName, action, apiContext)
+	case v32.ClusterActionPreviewEtcdRetention:
+		return a.PreviewEtcdRetentionHandler(actionName, action, apiContext)
 	case v32.ClusterActionRotateCertificates:
 		if !canUpdateCluster() {
 			return httperror.NewAPIError(httperror.PermissionDenied, "can not rotate certificates")
@@ -6,6 +6,7 @@
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,6 +14,7 @@
 	"github.com/rancher/norman/api/access"
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/types"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	"github.com/rancher/rancher/pkg/controllers/management/etcdbackup"
 	mgmtv3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
@@ -116,6 +118,12 @@ func (a ActionHandler) RestoreFromEtcdBackupHandler(actionName string, action *t
 				input.EtcdBackupID))
 	}
 
+	// a corrupted or modified backup must fail before the cluster is flagged for restore
+	if err := etcdbackup.VerifyBackup(apiContext.Request.Context(), cluster, backup, a.SecretLister); err != nil {
+		return httperror.WrapAPIError(err, httperror.InvalidState,
+			fmt.Sprintf("backup %s failed verification: %v", input.EtcdBackupID, err))
+	}
+
 	if input.RestoreRkeConfig != "" && backup.Status.ClusterObject == "" {
 		// attempting to restore rke config and the backup does not contain data, probably pre 2.4 backup
 		return httperror.NewAPIError(httperror.MethodNotAllowed,
@@ -128,7 +136,7 @@ func (a ActionHandler) RestoreFromEtcdBackupHandler(actionName string, action *t
 		// restore from copy stored inline to not have to decompress object
 		cluster.Spec.RancherKubernetesEngineConfig.Version = backup.Status.KubernetesVersion
 	case "all":
-		clusterBackup, err := etcdbackup.DecompressCluster(backup.Status.ClusterObject)
+		clusterBackup, err := etcdbackup.GetClusterObject(cluster, backup, a.SecretLister)
 		if err != nil {
 			response["message"] = "error decompressing cluster object"
 			apiContext.WriteResponse(http.StatusInternalServerError, response)
@@ -150,3 +158,75 @@ func (a ActionHandler) RestoreFromEtcdBackupHandler(actionName string, action *t
 	apiContext.WriteResponse(http.StatusCreated, response)
 	return nil
 }
+
+// PreviewEtcdRetentionHandler returns the recurring backups of the cluster that the retention
+// tiers of the input, or the retention of the cluster, keep and prune, newest first.
+func (a ActionHandler) PreviewEtcdRetentionHandler(actionName string, action *types.Action, apiContext *types.APIContext) error {
+	data, err := ioutil.ReadAll(apiContext.Request.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read request body")
+	}
+	input := client.PreviewEtcdRetentionInput{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &input); err != nil {
+			return httperror.WrapAPIError(err, httperror.InvalidBodyContent, "failed to parse request content")
+		}
+	}
+
+	var mgmtCluster client.Cluster
+	if err := access.ByID(apiContext, apiContext.Version, apiContext.Type, apiContext.ID, &mgmtCluster); err != nil {
+		return errors.Wrapf(err, "failed to get Cluster by ID %s", apiContext.ID)
+	}
+	cluster, err := a.ClusterClient.Get(apiContext.ID, v1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get Cluster by ID %s", apiContext.ID)
+	}
+
+	tiers := cluster.Spec.EtcdBackupRetention
+	if len(input.Tiers) > 0 {
+		tiers = nil
+		for _, tier := range input.Tiers {
+			if tier.IntervalHours < 1 || tier.Keep < 1 {
+				return httperror.NewAPIError(httperror.InvalidBodyContent, "intervalHours and keep of a retention tier must be at least 1")
+			}
+			tiers = append(tiers, v32.EtcdBackupRetentionTier{
+				IntervalHours: int(tier.IntervalHours),
+				Keep:          int(tier.Keep),
+			})
+		}
+	}
+
+	backupList, err := a.BackupClient.ListNamespaced(cluster.Name, v1.ListOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list etcd backups of cluster %s", cluster.Name)
+	}
+	var backups []*mgmtv3.EtcdBackup
+	for i := range backupList.Items {
+		if !backupList.Items[i].Spec.Manual {
+			backups = append(backups, &backupList.Items[i])
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return etcdbackup.GetBackupTime(backups[i]).After(etcdbackup.GetBackupTime(backups[j]))
+	})
+
+	expired := map[string]bool{}
+	for _, backup := range etcdbackup.GetExpiredBackups(cluster, tiers, backups) {
+		expired[backup.Name] = true
+	}
+	kept, pruned := []string{}, []string{}
+	for _, backup := range backups {
+		if expired[backup.Name] {
+			pruned = append(pruned, ref.Ref(backup))
+		} else {
+			kept = append(kept, ref.Ref(backup))
+		}
+	}
+
+	apiContext.WriteResponse(http.StatusOK, map[string]interface{}{
+		"type": client.PreviewEtcdRetentionOutputType,
+		client.PreviewEtcdRetentionOutputFieldKept:   kept,
+		client.PreviewEtcdRetentionOutputFieldPruned: pruned,
+	})
+	return nil
+}
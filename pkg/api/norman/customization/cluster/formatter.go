@@ -70,6 +70,7 @@ func (f *Formatter) Formatter(request *types.APIContext, resource *types.RawReso
 		if _, ok := values.GetValue(resource.Values, "rancherKubernetesEngineConfig", "services", "etcd", "backupConfig"); ok {
 			resource.AddAction(request, v32.ClusterActionBackupEtcd)
 			resource.AddAction(request, v32.ClusterActionRestoreFromEtcdBackup)
+			resource.AddAction(request, v32.ClusterActionPreviewEtcdRetention)
 		}
 		isActiveCluster := false
 		if resource.Values["state"] == "active" {
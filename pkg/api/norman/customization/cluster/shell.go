@@ -11,6 +11,7 @@
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/types"
 	"github.com/rancher/rancher/pkg/clustermanager"
+	"github.com/rancher/rancher/pkg/sessionrecording"
 	"github.com/rancher/rancher/pkg/settings"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,6 +21,7 @@
 type ShellLinkHandler struct {
 	Proxy          http.Handler
 	ClusterManager *clustermanager.Manager
+	Recordings     *sessionrecording.Recordings
 }
 
 func (s *ShellLinkHandler) LinkHandler(apiContext *types.APIContext, next types.RequestHandler) error {
@@ -72,7 +74,13 @@ func (s *ShellLinkHandler) LinkHandler(apiContext *types.APIContext, next types.
 			req.Header.Del(transport.ImpersonateGroupHeader)
 			req.Header.Del(transport.ImpersonateUserExtraHeaderPrefix)
 
-			s.Proxy.ServeHTTP(apiContext.Response, req)
+			recorder, err := s.Recordings.Start(apiContext.Response, req, "kubectl shell "+context.ClusterName, 80, 24)
+			if err != nil {
+				return err
+			}
+			defer recorder.Close()
+
+			s.Proxy.ServeHTTP(recorder.ExecResponseWriter(apiContext.Response), req)
 			return nil
 		}
 	}
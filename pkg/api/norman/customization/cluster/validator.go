@@ -68,6 +68,10 @@ func (v *Validator) Validator(request *types.APIContext, schema *types.Schema, d
 		return err
 	}
 
+	if err := validateEtcdRestoreDrill(&clientClusterSpec); err != nil {
+		return err
+	}
+
 	if err := v.validateGenericEngineConfig(request, &clusterSpec); err != nil {
 		return err
 	}
@@ -148,6 +152,20 @@ func validateScheduledClusterScan(spec *mgmtclient.Cluster) error {
 	return nil
 }
 
+// validateEtcdRestoreDrill rejects restore drills of clusters that aren't provisioned by RKE, the
+// drill reads the snapshot and runs etcd through the docker daemon of a node which RKE2 and K3s
+// nodes don't have. The drills of RKE2 clusters are configured in the etcd config of the
+// provisioning cluster instead.
+func validateEtcdRestoreDrill(spec *mgmtclient.Cluster) error {
+	if spec.ClusterTemplateRevisionID != "" || spec.EtcdRestoreDrill == nil || !spec.EtcdRestoreDrill.Enabled {
+		return nil
+	}
+	if spec.RancherKubernetesEngineConfig == nil {
+		return httperror.NewFieldAPIError(httperror.InvalidOption, "etcdRestoreDrill", "restore drills are only supported for RKE clusters, the drills of RKE2 clusters are set in rkeConfig.etcd.restoreDrill")
+	}
+	return nil
+}
+
 func (v *Validator) validateLocalClusterAuthEndpoint(request *types.APIContext, spec *v32.ClusterSpec) error {
 	if !spec.LocalClusterAuthEndpoint.Enabled {
 		return nil
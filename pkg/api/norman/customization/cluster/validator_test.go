@@ -191,3 +191,33 @@ func TestClusterTemplateValidateScheduledClusterScan(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestValidateEtcdRestoreDrill(t *testing.T) {
+	var clusterSpec mgmtclient.Cluster
+	err := json.Unmarshal([]byte(clusterSpecJSON), &clusterSpec)
+	if err != nil {
+		logrus.Errorf("error unmarshaling clusterspec: %v", err)
+		t.FailNow()
+	}
+
+	clusterSpec.EtcdRestoreDrill = &mgmtclient.EtcdRestoreDrillConfig{Enabled: true}
+	err = validateEtcdRestoreDrill(&clusterSpec)
+	if err != nil {
+		logrus.Errorf("not expecting error, got: %v", err)
+		t.FailNow()
+	}
+
+	clusterSpec.RancherKubernetesEngineConfig = nil
+	err = validateEtcdRestoreDrill(&clusterSpec)
+	if err == nil {
+		logrus.Errorf("expected error")
+		t.FailNow()
+	}
+
+	clusterSpec.EtcdRestoreDrill.Enabled = false
+	err = validateEtcdRestoreDrill(&clusterSpec)
+	if err != nil {
+		logrus.Errorf("not expecting error, got: %v", err)
+		t.FailNow()
+	}
+}
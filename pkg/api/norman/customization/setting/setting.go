@@ -10,11 +10,14 @@
 	"github.com/rancher/norman/types"
 	"github.com/rancher/norman/types/convert"
 	"github.com/rancher/norman/types/slice"
+	auditpolicy "github.com/rancher/rancher/pkg/auth/audit/policy"
 	"github.com/rancher/rancher/pkg/auth/providerrefresh"
 	"github.com/rancher/rancher/pkg/auth/tokens"
 	v3client "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	"github.com/rancher/rancher/pkg/encryptedstore"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/shellprofile"
 )
 
 var ReadOnlySettings = []string{
@@ -81,6 +84,12 @@ func Validator(request *types.APIContext, schema *types.Schema, data map[string]
 		_, err = providerrefresh.ParseMaxAge(newValueString)
 	case "auth-user-info-resync-cron":
 		_, err = providerrefresh.ParseCron(newValueString)
+	case "audit-log-policy":
+		err = auditpolicy.Validate(newValueString)
+	case "shell-profiles":
+		err = shellprofile.Validate(newValueString)
+	case "encryption-kms-endpoint":
+		err = encryptedstore.ValidateKMSEndpoint(newValueString)
 	case "kubeconfig-token-ttl-minutes":
 		generateToken := strings.EqualFold(settings.KubeconfigGenerateToken.Get(), "true")
 		if generateToken {
@@ -270,6 +270,7 @@ func Clusters(schemas *types.Schemas, managementContext *config.ScaledContext, c
 		ClusterTemplateRevisionClient: managementContext.Management.ClusterTemplateRevisions(""),
 		SubjectAccessReviewClient:     managementContext.K8sClient.AuthorizationV1().SubjectAccessReviews(),
 		TokenClient:                   managementContext.Management.Tokens(""),
+		SecretLister:                  managementContext.Core.Secrets("").Controller().Lister(),
 	}
 
 	clusterValidator := ccluster.Validator{
@@ -574,6 +575,7 @@ func Alert(schemas *types.Schemas, management *config.ScaledContext) {
 		ClusterAlertRule: management.Management.ClusterAlertRules(""),
 		ProjectAlertRule: management.Management.ProjectAlertRules(""),
 		Notifiers:        management.Management.Notifiers(""),
+		Secrets:          management.Core.Secrets("").Controller().Lister(),
 		DialerFactory:    management.Dialer,
 	}
 
@@ -581,6 +583,7 @@ func Alert(schemas *types.Schemas, management *config.ScaledContext) {
 	schema.CollectionFormatter = alert.NotifierCollectionFormatter
 	schema.Formatter = alert.NotifierFormatter
 	schema.ActionHandler = handler.NotifierActionHandler
+	schema.Validator = alert.NotifierValidator
 
 	schema = schemas.Schema(&managementschema.Version, client.ClusterAlertRuleType)
 	schema.Formatter = alert.RuleFormatter
@@ -36,6 +36,7 @@
 	nodehelper "github.com/rancher/rancher/pkg/node"
 	"github.com/rancher/rancher/pkg/ref"
 	managementschema "github.com/rancher/rancher/pkg/schemas/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/sessionrecording"
 	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/rancher/rancher/pkg/types/config/dialer"
@@ -127,6 +128,7 @@ func GetClusterStore(schema *types.Schema, mgmt *config.ScaledContext, clusterMa
 	linkHandler := &ccluster.ShellLinkHandler{
 		Proxy:          k8sProxy,
 		ClusterManager: clusterManager,
+		Recordings:     sessionrecording.New(mgmt.Wrangler.Core.Secret()),
 	}
 
 	s := &Store{
@@ -0,0 +1,179 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	types2 "github.com/rancher/rancher/pkg/api/steve/catalog/types"
+	v1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/catalogv2/bundle"
+	"github.com/rancher/rancher/pkg/catalogv2/content"
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
+	namespaces "github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/steve/pkg/client"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type bundleHandler struct {
+	contentManager *content.Manager
+	clientFactory  *client.Factory
+	secrets        corecontrollers.SecretController
+	configMaps     corecontrollers.ConfigMapClient
+}
+
+func (b *bundleHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiRequest := types.GetAPIContext(req.Context())
+
+	var err error
+	switch apiRequest.Action {
+	case "exportBundle":
+		err = b.exportBundle(apiRequest, rw, req)
+	case "importBundle":
+		err = b.importBundle(apiRequest, req)
+	}
+	if err != nil {
+		apiRequest.WriteError(err)
+	}
+}
+
+func (b *bundleHandler) exportBundle(apiRequest *types.APIRequest, rw http.ResponseWriter, req *http.Request) error {
+	input := &types2.BundleExportAction{}
+	if err := json.NewDecoder(req.Body).Decode(input); err != nil && err != io.EOF {
+		return apierror.WrapAPIError(err, validation.InvalidBodyContent, "failed to parse bundle export")
+	}
+
+	key, err := bundle.SigningKey(b.secrets)
+	if err != nil {
+		return err
+	}
+
+	// the bundle is written to a buffer first so a failure can still be reported
+	buf := &bytes.Buffer{}
+	namespace, name := nsAndName(apiRequest)
+	if err := b.contentManager.ExportBundle(namespace, name, input.Charts, key, buf); err != nil {
+		return err
+	}
+
+	rw.Header().Set("Content-Type", "application/gzip")
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-bundle.tgz\"", name))
+	_, err = io.Copy(rw, buf)
+	return err
+}
+
+// importBundle creates a ClusterRepo from the bundle archive in the body of the request. The
+// bundle must be signed by a key of the keyring secret given by the keyringSecretName and
+// keyringSecretNamespace query parameters, which the user must be able to read. The repo is
+// named after the exported repo unless the name query parameter is set.
+func (b *bundleHandler) importBundle(apiRequest *types.APIRequest, req *http.Request) error {
+	query := req.URL.Query()
+	keyringRef := &v1.SecretReference{
+		Name:      query.Get("keyringSecretName"),
+		Namespace: query.Get("keyringSecretNamespace"),
+	}
+	if keyringRef.Name == "" {
+		return apierror.NewFieldAPIError(validation.MissingRequired, "keyringSecretName", "a keyring is required to verify the bundle")
+	}
+	if keyringRef.Namespace == "" {
+		keyringRef.Namespace = namespaces.System
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(req.Body, bundle.MaxSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > bundle.MaxSize {
+		return apierror.NewAPIError(validation.InvalidBodyContent, fmt.Sprintf("bundle is larger than %d bytes", bundle.MaxSize))
+	}
+
+	keyringSecret, err := b.userSecret(apiRequest, keyringRef)
+	if err != nil {
+		return err
+	}
+	keyring, err := verify.KeyringFromSecret(keyringSecret)
+	if err != nil {
+		return err
+	}
+	manifest, err := bundle.Verify(data, keyring)
+	if err != nil {
+		return apierror.WrapAPIError(err, validation.InvalidBodyContent, err.Error())
+	}
+
+	name := query.Get("name")
+	if name == "" {
+		name = manifest.Repo
+	}
+	clusterRepo, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&v1.ClusterRepo{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1.SchemeGroupVersion.String(),
+			Kind:       "ClusterRepo",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.RepoSpec{
+			Bundle:        bundle.ConfigMapName(name),
+			KeyringSecret: keyringRef,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// the repo is created with the permissions of the user
+	repoClient, err := b.clientFactory.Client(apiRequest, apiRequest.Schema, "")
+	if err != nil {
+		return err
+	}
+	created, err := repoClient.Create(apiRequest.Context(), &unstructured.Unstructured{Object: clusterRepo}, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+
+	if err := bundle.Store(b.configMaps, metav1.OwnerReference{
+		APIVersion: created.GetAPIVersion(),
+		Kind:       created.GetKind(),
+		Name:       created.GetName(),
+		UID:        created.GetUID(),
+	}, data); err != nil {
+		// don't leave a repo without its bundle behind
+		_ = repoClient.Delete(apiRequest.Context(), name, metav1.DeleteOptions{})
+		return err
+	}
+
+	apiRequest.WriteResponse(http.StatusCreated, types.APIObject{
+		Type:   apiRequest.Type,
+		ID:     name,
+		Object: created,
+	})
+	return nil
+}
+
+// userSecret reads a secret with the permissions of the user, so importing a bundle can not
+// be used to probe secrets the user is not allowed to read.
+func (b *bundleHandler) userSecret(apiRequest *types.APIRequest, ref *v1.SecretReference) (*corev1.Secret, error) {
+	schema := apiRequest.Schemas.LookupSchema("secret")
+	if schema == nil {
+		return nil, apierror.NewAPIError(validation.PermissionDenied, fmt.Sprintf("can not read keyring secret %s/%s", ref.Namespace, ref.Name))
+	}
+	secretClient, err := b.clientFactory.Client(apiRequest, schema, ref.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := secretClient.Get(apiRequest.Context(), ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{}
+	return secret, runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, secret)
+}
@@ -13,13 +13,16 @@
 	"github.com/rancher/rancher/pkg/catalogv2/helmop"
 	schema2 "github.com/rancher/steve/pkg/schema"
 	steve "github.com/rancher/steve/pkg/server"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	schemas3 "github.com/rancher/wrangler/pkg/schemas"
 	"github.com/rancher/wrangler/pkg/schemas/validation"
 )
 
 func Register(ctx context.Context, server *steve.Server,
 	helmop *helmop.Operations,
-	contentManager *content.Manager) error {
+	contentManager *content.Manager,
+	secrets corecontrollers.SecretController,
+	configMaps corecontrollers.ConfigMapController) error {
 	ops := newOperation(helmop)
 	server.ClusterCache.OnAdd(ctx, ops.OnAdd)
 	server.ClusterCache.OnChange(ctx, ops.OnChange)
@@ -28,17 +31,27 @@ func Register(ctx context.Context, server *steve.Server,
 		contentManager: contentManager,
 	}
 
-	addSchemas(server, ops, index)
+	bundles := &bundleHandler{
+		contentManager: contentManager,
+		clientFactory:  server.ClientFactory,
+		secrets:        secrets,
+		configMaps:     configMaps,
+	}
+
+	addSchemas(server, ops, index, bundles)
 	return nil
 }
 
-func addSchemas(server *steve.Server, ops *operation, index http.Handler) {
+func addSchemas(server *steve.Server, ops *operation, index http.Handler, bundles http.Handler) {
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartUninstallAction{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartUpgradeAction{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartUpgrade{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartInstallAction{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartInstall{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartActionOutput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(types2.ChartDryRunOutput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(types2.BundleExportAction{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(types2.BundleChart{}, nil)
 
 	operationTemplate := schema2.Template{
 		Group: catalog.GroupName,
@@ -74,8 +87,11 @@ func addSchemas(server *steve.Server, ops *operation, index http.Handler) {
 		Kind:  "Repo",
 		Customize: func(apiSchema *types.APISchema) {
 			apiSchema.ActionHandlers = map[string]http.Handler{
-				"install": ops,
-				"upgrade": ops,
+				"install":      ops,
+				"upgrade":      ops,
+				"dryRun":       ops,
+				"exportBundle": bundles,
+				"importBundle": bundles,
 			}
 			apiSchema.ResourceActions = map[string]schemas3.Action{
 				"install": {
@@ -86,6 +102,21 @@ func addSchemas(server *steve.Server, ops *operation, index http.Handler) {
 					Input:  "chartUpgradeAction",
 					Output: "chartActionOutput",
 				},
+				"dryRun": {
+					Input:  "chartUpgradeAction",
+					Output: "chartDryRunOutput",
+				},
+				"exportBundle": {
+					Input: "bundleExportAction",
+				},
+			}
+			if isClusterRepo(apiSchema.ID) {
+				// the body of importBundle is the bundle archive
+				apiSchema.CollectionActions = map[string]schemas3.Action{
+					"importBundle": {
+						Output: "catalog.cattle.io.clusterrepo",
+					},
+				}
 			}
 			apiSchema.ByIDHandler = func(request *types.APIRequest) (types.APIObject, error) {
 				if request.Name == "index.yaml" {
@@ -46,6 +46,16 @@ func (o *operation) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		op, err = o.ops.Upgrade(apiRequest.Context(), user, ns, name, req.Body)
 	case "uninstall":
 		op, err = o.ops.Uninstall(apiRequest.Context(), user, ns, name, req.Body)
+	case "dryRun":
+		var output *catalogtypes.ChartDryRunOutput
+		output, err = o.ops.DryRun(apiRequest, ns, name, req.Body)
+		if err == nil {
+			apiRequest.WriteResponse(http.StatusOK, types.APIObject{
+				Type:   "chartDryRunOutput",
+				Object: output,
+			})
+			return
+		}
 	}
 
 	switch apiRequest.Link {
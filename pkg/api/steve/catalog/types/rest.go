@@ -70,3 +70,45 @@ type ChartActionOutput struct {
 	OperationName      string `json:"operationName,omitempty"`
 	OperationNamespace string `json:"operationNamespace,omitempty"`
 }
+
+type ChartDryRunOutput struct {
+	Charts []ChartDryRun `json:"charts,omitempty"`
+}
+
+type ChartDryRun struct {
+	ChartName      string         `json:"chartName,omitempty"`
+	Version        string         `json:"version,omitempty"`
+	ReleaseName    string         `json:"releaseName,omitempty"`
+	Namespace      string         `json:"namespace,omitempty"`
+	CurrentVersion string         `json:"currentVersion,omitempty"`
+	Resources      []ResourceDiff `json:"resources,omitempty"`
+	Values         []ValueDiff    `json:"values,omitempty"`
+}
+
+type ResourceDiff struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Change     string `json:"change,omitempty"`
+	// Patch is the JSON merge patch from the deployed to the rendered object of a changed resource
+	Patch string `json:"patch,omitempty"`
+}
+
+type ValueDiff struct {
+	Path   string      `json:"path,omitempty"`
+	Change string      `json:"change,omitempty"`
+	Old    interface{} `json:"old,omitempty"`
+	New    interface{} `json:"new,omitempty"`
+}
+
+type BundleExportAction struct {
+	// Charts are the chart versions to export, all versions of a chart are exported if the
+	// version is empty and all charts of the repo if no chart is given
+	Charts []BundleChart `json:"charts,omitempty"`
+}
+
+type BundleChart struct {
+	ChartName string `json:"chartName,omitempty"`
+	Version   string `json:"version,omitempty"`
+}
@@ -9,6 +9,7 @@
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/rancher/pkg/api/steve/norman"
 	normanv3 "github.com/rancher/rancher/pkg/schemas/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/sessionrecording"
 	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/rancher/pkg/wrangler"
 	"github.com/rancher/steve/pkg/podimpersonation"
@@ -22,10 +23,13 @@
 func Register(ctx context.Context, server *steve.Server, wrangler *wrangler.Context) error {
 	shell := &shell{
 		cg:           server.ClientFactory,
-		namespace:    "cattle-system",
+		namespace:    shellNamespace,
 		impersonator: podimpersonation.New("shell", server.ClientFactory, time.Hour, settings.FullShellImage),
+		recordings:   sessionrecording.New(wrangler.Core.Secret()),
 	}
 
+	go shell.sweepDetached(ctx)
+
 	server.ClusterCache.OnAdd(ctx, shell.impersonator.PurgeOldRoles)
 	server.ClusterCache.OnChange(ctx, func(gvk schema.GroupVersionKind, key string, obj, oldObj runtime.Object) error {
 		return shell.impersonator.PurgeOldRoles(gvk, key, obj)
@@ -0,0 +1,16 @@
+package clusters
+
+import (
+	"github.com/rancher/wrangler/pkg/name"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// homeVolumeClaimName is the name of the persistent volume claim of the home directory of a user.
+func homeVolumeClaimName(user user.Info) string {
+	return "shell-home-" + userHash(user)
+}
+
+// userHash is a label value that identifies a user.
+func userHash(user user.Info) string {
+	return name.Hex(user.GetName(), 16)
+}
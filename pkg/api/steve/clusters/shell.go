@@ -2,16 +2,25 @@
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httputil"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/rancher/pkg/sessionrecording"
+	"github.com/rancher/rancher/pkg/shellprofile"
 	"github.com/rancher/steve/pkg/podimpersonation"
 	"github.com/rancher/steve/pkg/stores/proxy"
 	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/ticker"
+	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/apiserver/pkg/endpoints/request"
@@ -20,10 +29,29 @@
 	"k8s.io/client-go/rest"
 )
 
+const (
+	shellUserLabel      = "shell.cattle.io/user"
+	shellUserAnnotation = "shell.cattle.io/user"
+	detachedAnnotation  = "shell.cattle.io/detached"
+	graceAnnotation     = "shell.cattle.io/reattach-grace-seconds"
+	shellNamespace      = "cattle-system"
+	shellHome           = "/home/shell"
+	// shellGroup is the group of the user of the shell image
+	shellGroup = int64(1000)
+	// sweepInterval is how often the detached pods whose grace period ended are deleted, in
+	// addition to the timer of the session that survives no restart
+	sweepInterval = time.Minute
+)
+
+// errHomeVolumeInUse fails a shell of a user whose home volume is mounted by another shell, the
+// volume can only be attached to one pod.
+var errHomeVolumeInUse = errors.New("another shell of the user is open, the home volume can only be used by one shell at a time")
+
 type shell struct {
 	namespace    string
 	impersonator *podimpersonation.PodImpersonation
 	cg           proxy.ClientGetter
+	recordings   *sessionrecording.Recordings
 }
 
 func (s *shell) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
@@ -33,37 +61,298 @@ func (s *shell) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	pod, err := s.impersonator.CreatePod(ctx, user, s.createPod(), &podimpersonation.PodOptions{
+	profile, err := shellprofile.Get(user)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pod, err := s.detachedPod(ctx, user, profile, client)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if pod == nil {
+		pod, err = s.newPod(ctx, user, profile, client)
+		if errors.Is(err, errHomeVolumeInUse) {
+			http.Error(rw, err.Error(), http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	defer s.release(pod, profile, client)
+
+	recorder, err := s.recordings.Start(rw, req, "cluster shell "+clusterName(req), 80, 24)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer recorder.Close()
+	s.proxyRequest(recorder.ExecResponseWriter(rw), req, pod, client, profile.ReattachGraceSeconds > 0)
+}
+
+func (s *shell) newPod(ctx context.Context, user user.Info, profile *shellprofile.Profile, client kubernetes.Interface) (*v1.Pod, error) {
+	if profile.HomeVolume != nil {
+		if err := s.checkHomeVolumeFree(ctx, user, client); err != nil {
+			return nil, err
+		}
+		if err := s.ensureHomeVolume(ctx, user, profile, client); err != nil {
+			return nil, err
+		}
+	}
+	return s.impersonator.CreatePod(ctx, user, s.createPod(user, profile), &podimpersonation.PodOptions{
 		Wait: true,
 	})
+}
+
+// detachedPod returns a running pod of a session of the user that was kept after a disconnect, the
+// pod is marked as attached again. It is nil if there is no session to reattach to.
+func (s *shell) detachedPod(ctx context.Context, user user.Info, profile *shellprofile.Profile, client kubernetes.Interface) (*v1.Pod, error) {
+	if profile.ReattachGraceSeconds <= 0 {
+		return nil, nil
+	}
+
+	pods, err := client.CoreV1().Pods(s.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: shellUserLabel + "=" + userHash(user),
+	})
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Annotations[shellUserAnnotation] != user.GetName() ||
+			pod.Annotations[detachedAnnotation] == "" ||
+			pod.DeletionTimestamp != nil ||
+			pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+
+		// the update conflicts if another session reattached to the pod first
+		delete(pod.Annotations, detachedAnnotation)
+		attached, err := client.CoreV1().Pods(pod.Namespace).Update(ctx, &pod, metav1.UpdateOptions{})
+		if apierrors.IsConflict(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return attached, nil
+	}
+
+	return nil, nil
+}
+
+// release deletes the pod of a session. If the profile has a grace period the pod is only deleted
+// if the user doesn't reattach to the session within the grace period.
+func (s *shell) release(pod *v1.Pod, profile *shellprofile.Profile, client kubernetes.Interface) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	if profile.ReattachGraceSeconds > 0 {
+		detached, err := s.detach(ctx, pod, profile, client)
+		if err == nil {
+			time.AfterFunc(time.Duration(profile.ReattachGraceSeconds)*time.Second, func() {
+				s.deleteDetached(pod, detached, client)
+			})
+			return
+		}
+		logrus.Debugf("failed to keep shell pod %s/%s for a reattach: %v", pod.Namespace, pod.Name, err)
+	}
+
+	_ = client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
+}
+
+// detach marks a running pod as detached and returns the mark.
+func (s *shell) detach(ctx context.Context, pod *v1.Pod, profile *shellprofile.Profile, client kubernetes.Interface) (string, error) {
+	pod, err := client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if pod.Status.Phase != v1.PodRunning {
+		return "", fmt.Errorf("shell has exited")
+	}
+
+	detached := time.Now().UTC().Format(time.RFC3339Nano)
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[detachedAnnotation] = detached
+	pod.Annotations[graceAnnotation] = strconv.Itoa(profile.ReattachGraceSeconds)
+	_, err = client.CoreV1().Pods(pod.Namespace).Update(ctx, pod, metav1.UpdateOptions{})
+	return detached, err
+}
+
+// deleteDetached deletes a pod that is still detached with the same mark after the grace period.
+func (s *shell) deleteDetached(pod *v1.Pod, detached string, client kubernetes.Interface) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	pod, err := client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil || pod.Annotations[detachedAnnotation] != detached {
 		return
 	}
+	_ = client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{
+			ResourceVersion: &pod.ResourceVersion,
+		},
+	})
+}
 
-	defer func() {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		defer cancel()
-		_ = client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
-	}()
-	s.proxyRequest(rw, req, pod, client)
+// sweepDetached deletes the detached pods whose grace period ended. The timers of the sessions that
+// delete them are lost if the server restarts.
+func (s *shell) sweepDetached(ctx context.Context) {
+	for range ticker.Context(ctx, sweepInterval) {
+		client, err := s.cg.AdminK8sInterface()
+		if err != nil {
+			logrus.Errorf("failed to sweep detached shell pods: %v", err)
+			continue
+		}
+		pods, err := client.CoreV1().Pods(s.namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: shellUserLabel,
+		})
+		if err != nil {
+			logrus.Errorf("failed to sweep detached shell pods: %v", err)
+			continue
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if detachExpired(pod, time.Now()) {
+				s.deleteDetached(pod, pod.Annotations[detachedAnnotation], client)
+			}
+		}
+	}
 }
 
-func (s *shell) proxyRequest(rw http.ResponseWriter, req *http.Request, pod *v1.Pod, client kubernetes.Interface) {
-	attachURL := client.CoreV1().RESTClient().
+// detachExpired returns if a pod is detached for longer than its grace period.
+func detachExpired(pod *v1.Pod, now time.Time) bool {
+	detached, err := time.Parse(time.RFC3339Nano, pod.Annotations[detachedAnnotation])
+	if err != nil {
+		return false
+	}
+	grace, err := strconv.Atoi(pod.Annotations[graceAnnotation])
+	if err != nil {
+		return false
+	}
+	return detached.Add(time.Duration(grace) * time.Second).Before(now)
+}
+
+// checkHomeVolumeFree fails if the user has a pod that is attached to a session. A detached pod is
+// reattached instead of starting a new one, a pod is left over for no longer than the impersonation
+// role that owns it.
+func (s *shell) checkHomeVolumeFree(ctx context.Context, user user.Info, client kubernetes.Interface) error {
+	pods, err := client.CoreV1().Pods(s.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: shellUserLabel + "=" + userHash(user),
+	})
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		if pod.Annotations[shellUserAnnotation] == user.GetName() &&
+			pod.DeletionTimestamp == nil &&
+			(pod.Status.Phase == v1.PodPending || pod.Status.Phase == v1.PodRunning) {
+			return errHomeVolumeInUse
+		}
+	}
+	return nil
+}
+
+// DeleteHomeVolumes deletes the persistent volume claims of the home directories of a user, they are
+// kept after the shells of the user ended.
+func DeleteHomeVolumes(ctx context.Context, client kubernetes.Interface, username string) error {
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(shellNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: shellUserLabel + "=" + userHash(&user.DefaultInfo{Name: username}),
+	})
+	if err != nil {
+		return err
+	}
+	for _, pvc := range pvcs.Items {
+		if pvc.Annotations[shellUserAnnotation] != username {
+			continue
+		}
+		err := client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Delete(ctx, pvc.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *shell) ensureHomeVolume(ctx context.Context, user user.Info, profile *shellprofile.Profile, client kubernetes.Interface) error {
+	name := homeVolumeClaimName(user)
+	_, err := client.CoreV1().PersistentVolumeClaims(s.namespace).Get(ctx, name, metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	size, err := resource.ParseQuantity(profile.HomeVolume.Size)
+	if err != nil {
+		return err
+	}
+
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: s.namespace,
+			Labels: map[string]string{
+				shellUserLabel: userHash(user),
+			},
+			Annotations: map[string]string{
+				shellUserAnnotation: user.GetName(),
+			},
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: size,
+				},
+			},
+		},
+	}
+	if profile.HomeVolume.StorageClassName != "" {
+		pvc.Spec.StorageClassName = &profile.HomeVolume.StorageClassName
+	}
+
+	_, err = client.CoreV1().PersistentVolumeClaims(s.namespace).Create(ctx, pvc, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// proxyRequest connects the request to the shell of the pod. The shell runs as the process of the
+// container if sessions can be reattached, the process keeps running when the request is detached.
+func (s *shell) proxyRequest(rw http.ResponseWriter, req *http.Request, pod *v1.Pod, client kubernetes.Interface, attach bool) {
+	request := client.CoreV1().RESTClient().
 		Get().
 		Namespace(pod.Namespace).
 		Resource("pods").
-		Name(pod.Name).
-		SubResource("exec").
-		VersionedParams(&v1.PodExecOptions{
-			Stdin:     true,
-			Stdout:    true,
-			Stderr:    true,
-			TTY:       true,
-			Container: "shell",
-			Command:   []string{"welcome"},
-		}, scheme.ParameterCodec).URL()
+		Name(pod.Name)
+	if attach {
+		request = request.
+			SubResource("attach").
+			VersionedParams(&v1.PodAttachOptions{
+				Stdin:     true,
+				Stdout:    true,
+				Stderr:    true,
+				TTY:       true,
+				Container: "shell",
+			}, scheme.ParameterCodec)
+	} else {
+		request = request.
+			SubResource("exec").
+			VersionedParams(&v1.PodExecOptions{
+				Stdin:     true,
+				Stdout:    true,
+				Stderr:    true,
+				TTY:       true,
+				Container: "shell",
+				Command:   []string{"welcome"},
+			}, scheme.ParameterCodec)
+	}
+	attachURL := request.URL()
 
 	httpClient := client.CoreV1().RESTClient().(*rest.RESTClient).Client
 	p := httputil.ReverseProxy{
@@ -87,6 +376,13 @@ func (s *shell) proxyRequest(rw http.ResponseWriter, req *http.Request, pod *v1.
 	p.ServeHTTP(rw, req)
 }
 
+func clusterName(req *http.Request) string {
+	if apiRequest := types.GetAPIContext(req.Context()); apiRequest != nil {
+		return apiRequest.Name
+	}
+	return req.URL.Path
+}
+
 func (s *shell) contextAndClient(req *http.Request) (context.Context, user.Info, kubernetes.Interface, error) {
 	ctx := req.Context()
 	client, err := s.cg.AdminK8sInterface()
@@ -102,11 +398,18 @@ func (s *shell) contextAndClient(req *http.Request) (context.Context, user.Info,
 	return ctx, user, client, nil
 }
 
-func (s *shell) createPod() *v1.Pod {
-	return &v1.Pod{
+func (s *shell) createPod(user user.Info, profile *shellprofile.Profile) *v1.Pod {
+	pod := &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: "dashboard-shell-",
 			Namespace:    s.namespace,
+			// the shells of a user are found by the label, to reattach or to check the home volume is free
+			Labels: map[string]string{
+				shellUserLabel: userHash(user),
+			},
+			Annotations: map[string]string{
+				shellUserAnnotation: user.GetName(),
+			},
 		},
 		Spec: v1.PodSpec{
 			TerminationGracePeriodSeconds: new(int64),
@@ -143,13 +446,75 @@ func (s *shell) createPod() *v1.Pod {
 					Env: []v1.EnvVar{
 						{
 							Name:  "KUBECONFIG",
-							Value: "/home/shell/.kube/config",
+							Value: shellHome + "/.kube/config",
 						},
 					},
-					Image:           settings.FullShellImage(),
+					Image:           profile.ShellImage(),
 					ImagePullPolicy: v1.PullIfNotPresent,
+					Resources:       profile.Resources,
 				},
 			},
 		},
 	}
+
+	container := &pod.Spec.Containers[0]
+	for _, env := range profile.Env {
+		// the kubeconfig is mounted at the path of the KUBECONFIG variable
+		if env.Name != "KUBECONFIG" {
+			container.Env = append(container.Env, env)
+		}
+	}
+
+	if len(profile.Plugins) > 0 {
+		mount := v1.VolumeMount{
+			Name:      "plugins",
+			MountPath: shellprofile.PluginsPath,
+		}
+		pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+			Name: "plugins",
+			VolumeSource: v1.VolumeSource{
+				EmptyDir: &v1.EmptyDirVolumeSource{},
+			},
+		})
+		pod.Spec.InitContainers = append(pod.Spec.InitContainers, v1.Container{
+			Name:            "plugins",
+			Image:           container.Image,
+			ImagePullPolicy: v1.PullIfNotPresent,
+			Command:         []string{"sh", "-c", profile.PluginsScript()},
+			Resources:       profile.Resources,
+			VolumeMounts:    []v1.VolumeMount{mount},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, mount)
+		container.Env = append(container.Env, v1.EnvVar{
+			Name:  "PATH",
+			Value: shellprofile.PluginsPath + ":/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		})
+	}
+
+	if profile.HomeVolume != nil {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+			Name: "home",
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: homeVolumeClaimName(user),
+				},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+			Name:      "home",
+			MountPath: shellHome,
+		})
+		fsGroup := shellGroup
+		pod.Spec.SecurityContext = &v1.PodSecurityContext{
+			FSGroup: &fsGroup,
+		}
+	}
+
+	if profile.ReattachGraceSeconds > 0 {
+		// the shell is the process of the container, it keeps running while no one is attached
+		container.StdinOnce = false
+		container.Command = []string{"welcome"}
+	}
+
+	return pod
 }
@@ -0,0 +1,95 @@
+package clusters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/rancher/pkg/shellprofile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDetachExpired(t *testing.T) {
+	now := time.Date(2021, 3, 17, 12, 30, 0, 0, time.UTC)
+	pod := func(annotations map[string]string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+	}
+	detached := func(ago time.Duration) string {
+		return now.Add(-ago).Format(time.RFC3339Nano)
+	}
+
+	assert.True(t, detachExpired(pod(map[string]string{detachedAnnotation: detached(2 * time.Minute), graceAnnotation: "60"}), now))
+	assert.False(t, detachExpired(pod(map[string]string{detachedAnnotation: detached(30 * time.Second), graceAnnotation: "60"}), now))
+	assert.False(t, detachExpired(pod(map[string]string{graceAnnotation: "60"}), now))
+	assert.False(t, detachExpired(pod(map[string]string{detachedAnnotation: detached(2 * time.Minute)}), now))
+}
+
+func homePVC(name, username string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   shellNamespace,
+			Labels:      map[string]string{shellUserLabel: userHash(&user.DefaultInfo{Name: username})},
+			Annotations: map[string]string{shellUserAnnotation: username},
+		},
+	}
+}
+
+func TestDeleteHomeVolumes(t *testing.T) {
+	client := fake.NewSimpleClientset(homePVC("shell-home-admin", "u-admin"), homePVC("shell-home-other", "u-other"))
+
+	require.NoError(t, DeleteHomeVolumes(context.Background(), client, "u-admin"))
+
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(shellNamespace).List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, pvcs.Items, 1)
+	assert.Equal(t, "shell-home-other", pvcs.Items[0].Name)
+}
+
+func TestCheckHomeVolumeFree(t *testing.T) {
+	admin := &user.DefaultInfo{Name: "u-admin"}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "dashboard-shell-abc",
+			Namespace:   shellNamespace,
+			Labels:      map[string]string{shellUserLabel: userHash(admin)},
+			Annotations: map[string]string{shellUserAnnotation: admin.Name},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+	s := &shell{namespace: shellNamespace}
+
+	assert.NoError(t, s.checkHomeVolumeFree(context.Background(), admin, fake.NewSimpleClientset()))
+	assert.ErrorIs(t, s.checkHomeVolumeFree(context.Background(), admin, fake.NewSimpleClientset(pod)), errHomeVolumeInUse)
+
+	pod.Status.Phase = v1.PodSucceeded
+	assert.NoError(t, s.checkHomeVolumeFree(context.Background(), admin, fake.NewSimpleClientset(pod)))
+}
+
+func TestCreatePodLabelsUser(t *testing.T) {
+	admin := &user.DefaultInfo{Name: "u-admin"}
+	s := &shell{namespace: shellNamespace}
+
+	for _, profile := range []*shellprofile.Profile{{}, {ReattachGraceSeconds: 60}} {
+		pod := s.createPod(admin, profile)
+		assert.Equal(t, map[string]string{shellUserLabel: userHash(admin)}, pod.Labels)
+		assert.Equal(t, map[string]string{shellUserAnnotation: admin.Name}, pod.Annotations)
+	}
+}
+
+func TestCheckHomeVolumeFreeCreatedPod(t *testing.T) {
+	admin := &user.DefaultInfo{Name: "u-admin"}
+	s := &shell{namespace: shellNamespace}
+
+	// a shell without a reattach grace period holds the home volume as well
+	pod := s.createPod(admin, &shellprofile.Profile{HomeVolume: &shellprofile.HomeVolume{Size: "1Gi"}})
+	pod.Name = "dashboard-shell-abc"
+	pod.Status.Phase = v1.PodRunning
+	assert.ErrorIs(t, s.checkHomeVolumeFree(context.Background(), admin, fake.NewSimpleClientset(pod)), errHomeVolumeInUse)
+	assert.NoError(t, s.checkHomeVolumeFree(context.Background(), &user.DefaultInfo{Name: "u-other"}, fake.NewSimpleClientset(pod)))
+}
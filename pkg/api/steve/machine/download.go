@@ -8,10 +8,16 @@
 	"strconv"
 
 	"github.com/rancher/apiserver/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func (s *sshClient) download(apiContext *types.APIRequest) error {
-	machineInfo, err := s.getSSHKey(apiContext.Namespace, apiContext.Name)
+	machine, err := s.machines.Get(apiContext.Namespace, apiContext.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	machineInfo, err := s.getSSHKey(machine)
 	if err != nil {
 		return err
 	}
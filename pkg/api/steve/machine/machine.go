@@ -4,6 +4,7 @@
 	"net/http"
 
 	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/rancher/pkg/sessionrecording"
 	"github.com/rancher/rancher/pkg/wrangler"
 	schema2 "github.com/rancher/steve/pkg/schema"
 	steve "github.com/rancher/steve/pkg/server"
@@ -11,8 +12,10 @@
 
 func Register(server *steve.Server, clients *wrangler.Context) {
 	sshClient := &sshClient{
-		machines: clients.CAPI.Machine(),
-		secrets:  clients.Core.Secret(),
+		machines:   clients.CAPI.Machine(),
+		secrets:    clients.Core.Secret(),
+		dynamic:    clients.Dynamic,
+		recordings: sessionrecording.New(clients.Core.Secret()),
 	}
 
 	server.SchemaFactory.AddTemplate(schema2.Template{
@@ -1,31 +1,33 @@
 package machine
 
 import (
-	"archive/tar"
-	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
-	"path/filepath"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/lasso/pkg/dynamic"
 	"github.com/rancher/rancher/pkg/controllers/provisioningv2/rke2/machineprovision"
 	capicontrollers "github.com/rancher/rancher/pkg/generated/controllers/cluster.x-k8s.io/v1alpha4"
+	"github.com/rancher/rancher/pkg/sessionrecording"
+	"github.com/rancher/wrangler/pkg/data"
 	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	"golang.org/x/crypto/ssh"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha4"
 )
 
 type sshClient struct {
-	secrets  corecontrollers.SecretClient
-	machines capicontrollers.MachineClient
+	secrets    corecontrollers.SecretClient
+	machines   capicontrollers.MachineClient
+	dynamic    *dynamic.Controller
+	recordings *sessionrecording.Recordings
 }
 
 var upgrader = websocket.Upgrader{
@@ -65,6 +67,13 @@ func (s *sshClient) shell(apiRequest *types.APIRequest) error {
 	ctx, cancel := context.WithCancel(apiRequest.Context())
 	defer cancel()
 
+	recorder, err := s.recordings.Start(apiRequest.Response, apiRequest.Request,
+		fmt.Sprintf("ssh %s/%s", apiRequest.Namespace, apiRequest.Name), 80, 20)
+	if err != nil {
+		return err
+	}
+	defer recorder.Close()
+
 	req := apiRequest.Request.WithContext(ctx)
 	conn, err := upgrader.Upgrade(apiRequest.Response, req, nil)
 	if err != nil {
@@ -72,25 +81,22 @@ func (s *sshClient) shell(apiRequest *types.APIRequest) error {
 	}
 
 	defer conn.Close()
-	machineInfo, err := s.getSSHKey(apiRequest.Namespace, apiRequest.Name)
+	machine, err := s.machines.Get(apiRequest.Namespace, apiRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	hostKeyCallback, err := s.getHostKeyCallback(machine)
 	if err != nil {
 		return err
 	}
 
-	signer, err := ssh.ParsePrivateKey(machineInfo.IDRSA)
+	machineInfo, err := s.getSSHKey(machine)
 	if err != nil {
 		return err
 	}
 
-	addr := fmt.Sprintf("%s:%d", machineInfo.Driver.IPAddress, machineInfo.Driver.SSHPort)
-	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
-		User: machineInfo.Driver.SSHUser,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         30 * time.Second,
-	})
+	client, err := machineInfo.Dial(hostKeyCallback, 30*time.Second)
 	if err != nil {
 		return err
 	}
@@ -121,7 +127,16 @@ func (s *sshClient) shell(apiRequest *types.APIRequest) error {
 	go func() {
 		defer cancel()
 		defer conn.Close()
-		io.Copy(&writer{conn: conn}, stdOut)
+		io.Copy(io.MultiWriter(&writer{conn: conn}, recorder), stdOut)
+	}()
+
+	go func() {
+		// the session is closed if it can't be recorded any longer
+		select {
+		case <-recorder.Done():
+			conn.Close()
+		case <-ctx.Done():
+		}
 	}()
 
 	for {
@@ -141,6 +156,7 @@ func (s *sshClient) shell(apiRequest *types.APIRequest) error {
 			if _, err := stdIn.Write(data); err != nil {
 				return err
 			}
+			recorder.Input(data)
 		} else if s[0:1] == "4" {
 			data, err := base64.StdEncoding.DecodeString(s[1:])
 			if err != nil {
@@ -153,6 +169,7 @@ func (s *sshClient) shell(apiRequest *types.APIRequest) error {
 			if err := session.WindowChange(resize.Height, resize.Width); err != nil {
 				return err
 			}
+			recorder.Resize(resize.Width, resize.Height)
 		}
 	}
 }
@@ -162,65 +179,31 @@ type resizeRequest struct {
 	Width  int
 }
 
-type machineInfo struct {
-	IDRSA    []byte
-	IDRSAPub []byte
-	Driver   machineConfig
-}
-
-type machineConfig struct {
-	IPAddress   string
-	SSHUser     string
-	SSHPort     int
-	MachineName string
-}
-
-func (s *sshClient) getSSHKey(machineNamespace, machineName string) (*machineInfo, error) {
-	result := &machineInfo{}
-	machine, err := s.machines.Get(machineNamespace, machineName, metav1.GetOptions{})
+// getHostKeyCallback returns a host key callback that refuses connections to hosts with another key
+// than the host key captured when the machine was provisioned.
+func (s *sshClient) getHostKeyCallback(machine *capi.Machine) (ssh.HostKeyCallback, error) {
+	ref := machine.Spec.InfrastructureRef
+	infraMachine, err := s.dynamic.Get(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind), machine.Namespace, ref.Name)
 	if err != nil {
 		return nil, err
 	}
 
-	secretName := machineprovision.MachineStateSecretName(machine.Spec.InfrastructureRef.Name)
-	secret, err := s.secrets.Get(machineNamespace, secretName, metav1.GetOptions{})
+	d, err := data.Convert(infraMachine)
 	if err != nil {
 		return nil, err
 	}
 
-	gz, err := gzip.NewReader(bytes.NewReader(secret.Data["extractedConfig"]))
+	return machineprovision.PinnedHostKey(machine.Name, d.String("status", "sshHostKey"))
+}
+
+func (s *sshClient) getSSHKey(machine *capi.Machine) (*machineprovision.SSHInfo, error) {
+	secretName := machineprovision.MachineStateSecretName(machine.Spec.InfrastructureRef.Name)
+	secret, err := s.secrets.Get(machine.Namespace, secretName, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
 
-	tar := tar.NewReader(gz)
-
-	for {
-		header, err := tar.Next()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return nil, err
-		}
-
-		data, err := ioutil.ReadAll(tar)
-		if err != nil {
-			return nil, err
-		}
-		switch filepath.Base(header.Name) {
-		case "id_rsa":
-			result.IDRSA = data
-		case "id_rsa.pub":
-			result.IDRSAPub = data
-		case "config.json":
-			err := json.Unmarshal(data, result)
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
-
-	return result, nil
+	return machineprovision.SSHInfoFromSecret(secret)
 }
 
 type writer struct {
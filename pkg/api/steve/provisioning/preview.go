@@ -0,0 +1,64 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	rancherv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkecontrollers "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/provisioningv2/rke2/planner"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+)
+
+type previewHandler struct {
+	previewer        *planner.Previewer
+	rkeControlPlanes rkecontrollers.RKEControlPlaneCache
+}
+
+func (p *previewHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiRequest := types.GetAPIContext(req.Context())
+	if err := apiRequest.AccessControl.CanUpdate(apiRequest, types.APIObject{}, apiRequest.Schema); err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	preview, err := p.preview(apiRequest, req)
+	if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+	apiRequest.WriteResponse(http.StatusOK, types.APIObject{
+		Type:   "planPreview",
+		Object: preview,
+	})
+}
+
+// preview computes the plans of the machines of the cluster for the spec of the cluster in the body
+// of the request, nothing is applied.
+func (p *previewHandler) preview(apiRequest *types.APIRequest, req *http.Request) (*planner.PlanPreview, error) {
+	cluster := &rancherv1.Cluster{}
+	if err := json.NewDecoder(req.Body).Decode(cluster); err != nil {
+		return nil, apierror.WrapAPIError(err, validation.InvalidBodyContent, "failed to parse cluster")
+	}
+
+	proposed := proposedControlPlane(cluster)
+	if proposed == nil {
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent, "plans can only be previewed for RKE clusters")
+	}
+
+	current, err := p.rkeControlPlanes.Get(apiRequest.Namespace, apiRequest.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := planner.ValidateConfig(proposed, current); err != nil {
+		return nil, apierror.WrapAPIError(err, validation.InvalidBodyContent, err.Error())
+	}
+	controlPlane := current.DeepCopy()
+	controlPlane.Spec.RKEClusterSpecCommon = proposed.Spec.RKEClusterSpecCommon
+	controlPlane.Spec.KubernetesVersion = proposed.Spec.KubernetesVersion
+	controlPlane.Spec.AgentEnvVars = proposed.Spec.AgentEnvVars
+
+	return p.previewer.Preview(controlPlane)
+}
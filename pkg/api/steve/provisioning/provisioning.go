@@ -0,0 +1,64 @@
+package provisioning
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/types"
+	rancherv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/provisioningv2/rke2/planner"
+	"github.com/rancher/rancher/pkg/wrangler"
+	schema2 "github.com/rancher/steve/pkg/schema"
+	steve "github.com/rancher/steve/pkg/server"
+	schemas3 "github.com/rancher/wrangler/pkg/schemas"
+)
+
+func Register(ctx context.Context, server *steve.Server, clients *wrangler.Context) {
+	preview := &previewHandler{
+		previewer:        planner.NewPreviewer(ctx, clients),
+		rkeControlPlanes: clients.RKE.RKEControlPlane().Cache(),
+	}
+
+	server.BaseSchemas.MustImportAndCustomize(planner.PlanPreview{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(planner.MachinePlanPreview{}, nil)
+
+	server.SchemaFactory.AddTemplate(schema2.Template{
+		Group: "provisioning.cattle.io",
+		Kind:  "Cluster",
+		Customize: func(apiSchema *types.APISchema) {
+			if apiSchema.ActionHandlers == nil {
+				apiSchema.ActionHandlers = map[string]http.Handler{}
+			}
+			if apiSchema.ResourceActions == nil {
+				apiSchema.ResourceActions = map[string]schemas3.Action{}
+			}
+			// the input of previewPlan is the cluster with the proposed spec
+			apiSchema.ActionHandlers["previewPlan"] = preview
+			apiSchema.ResourceActions["previewPlan"] = schemas3.Action{
+				Input:  "provisioning.cattle.io.cluster",
+				Output: "planPreview",
+			}
+		},
+		StoreFactory: func(innerStore types.Store) types.Store {
+			return &store{
+				Store: innerStore,
+			}
+		},
+	})
+}
+
+// proposedControlPlane returns the control plane the provisioning cluster would produce, it is nil
+// if the cluster is not provisioned by RKE.
+func proposedControlPlane(cluster *rancherv1.Cluster) *rkev1.RKEControlPlane {
+	if cluster.Spec.RKEConfig == nil {
+		return nil
+	}
+	return &rkev1.RKEControlPlane{
+		Spec: rkev1.RKEControlPlaneSpec{
+			RKEClusterSpecCommon: *cluster.Spec.RKEConfig.RKEClusterSpecCommon.DeepCopy(),
+			KubernetesVersion:    cluster.Spec.KubernetesVersion,
+			AgentEnvVars:         cluster.Spec.AgentEnvVars,
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package provisioning
+
+import (
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	rancherv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/provisioningv2/rke2/planner"
+	"github.com/rancher/wrangler/pkg/data/convert"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+)
+
+// store rejects clusters with machine config keys that are unknown to the runtime release of the
+// cluster, the planner would otherwise drop them silently. An update is only rejected for the keys
+// it adds or changes, see planner.ValidateConfig. Clusters written to the Kubernetes API directly,
+// e.g. with kubectl, don't pass this store and are not validated.
+type store struct {
+	types.Store
+}
+
+func (s *store) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	if err := validateConfig(data, nil); err != nil {
+		return types.APIObject{}, err
+	}
+	return s.Store.Create(apiOp, schema, data)
+}
+
+func (s *store) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	stored, err := s.Store.ByID(apiOp, schema, id)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	old, err := toControlPlane(stored)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	if err := validateConfig(data, old); err != nil {
+		return types.APIObject{}, err
+	}
+	return s.Store.Update(apiOp, schema, data, id)
+}
+
+func validateConfig(data types.APIObject, old *rkev1.RKEControlPlane) error {
+	controlPlane, err := toControlPlane(data)
+	if err != nil || controlPlane == nil {
+		return err
+	}
+	if err := planner.ValidateConfig(controlPlane, old); err != nil {
+		return apierror.WrapAPIError(err, validation.InvalidBodyContent, err.Error())
+	}
+	return nil
+}
+
+func toControlPlane(data types.APIObject) (*rkev1.RKEControlPlane, error) {
+	cluster := &rancherv1.Cluster{}
+	if err := convert.ToObj(data.Object, cluster); err != nil {
+		return nil, apierror.WrapAPIError(err, validation.InvalidBodyContent, "failed to parse cluster")
+	}
+	return proposedControlPlane(cluster), nil
+}
@@ -23,7 +23,7 @@ type SecretReference struct {
 }
 
 type RepoSpec struct {
-	// URL A http URL of the repo to connect to
+	// URL A http URL of the repo to connect to, or an oci:// URL of a registry path or chart repository
 	URL string `json:"url,omitempty"`
 
 	// GitRepo a git repo to clone and index as the helm repo
@@ -42,6 +42,7 @@ type RepoSpec struct {
 	// ClientSecretName is the client secret to be used to connect to the repo
 	// It is expected the secret be of type "kubernetes.io/basic-auth" or "kubernetes.io/tls" for Helm repos
 	// and "kubernetes.io/basic-auth" or "kubernetes.io/ssh-auth" for git repos.
+	// OCI repos also accept "kubernetes.io/dockerconfigjson" secrets.
 	// For a repo the Namespace file will be ignored
 	ClientSecret *SecretReference `json:"clientSecret,omitempty"`
 
@@ -61,6 +62,18 @@ type RepoSpec struct {
 
 	// If disabled the repo clone will not be updated or allowed to be installed from
 	Enabled *bool `json:"enabled,omitempty"`
+
+	// KeyringSecret is a secret with the public keys the charts of the repo must be signed with.
+	// When it is set charts are verified before they are installed or upgraded. The "keyring" key
+	// holds a GPG keyring to verify Helm provenance files, the "cosign.pub" key holds PEM encoded
+	// public keys to verify cosign signatures of charts in OCI repos.
+	// For a repo the Namespace file will be ignored
+	KeyringSecret *SecretReference `json:"keyringSecret,omitempty"`
+
+	// Bundle is the config map in the cattle-system namespace with the chart bundle the repo
+	// was imported from. A repo with a bundle serves the index, charts and icons of the bundle
+	// and doesn't download anything. Bundles are imported with the importBundle action.
+	Bundle string `json:"bundle,omitempty"`
 }
 
 type RepoCondition string
@@ -68,6 +81,7 @@ type RepoSpec struct {
 const (
 	RepoDownloaded         RepoCondition = "Downloaded"
 	FollowerRepoDownloaded RepoCondition = "FollowerDownloaded"
+	RepoVerified           RepoCondition = "Verified"
 )
 
 type RepoStatus struct {
@@ -90,6 +104,13 @@ type RepoStatus struct {
 	// The git commit used to generate the index
 	Commit string `json:"commit,omitempty"`
 
+	// IndexETag is the ETag of the last downloaded http index, the index is only downloaded
+	// again if it changed
+	IndexETag string `json:"indexETag,omitempty"`
+
+	// IndexLastModified is the Last-Modified time of the last downloaded http index
+	IndexLastModified string `json:"indexLastModified,omitempty"`
+
 	Conditions []genericcondition.GenericCondition `json:"conditions,omitempty"`
 }
 
@@ -424,6 +424,11 @@ func (in *RepoSpec) DeepCopyInto(out *RepoSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.KeyringSecret != nil {
+		in, out := &in.KeyringSecret, &out.KeyringSecret
+		*out = new(SecretReference)
+		**out = **in
+	}
 	return
 }
 
@@ -89,7 +89,7 @@ func (p *ProjectAlertSpec) ObjClusterName() string {
 type Recipient struct {
 	Recipient    string `json:"recipient,omitempty"`
 	NotifierName string `json:"notifierName,omitempty" norman:"required,type=reference[notifier]"`
-	NotifierType string `json:"notifierType,omitempty" norman:"required,options=slack|email|pagerduty|webhook|wechat|dingtalk|msteams"`
+	NotifierType string `json:"notifierType,omitempty" norman:"required,options=slack|email|pagerduty|webhook|wechat|dingtalk|msteams|mattermost|rocketchat|googlechat|matrix"`
 }
 
 type TargetNode struct {
@@ -214,13 +214,14 @@ func (c *ClusterAlertRule) ObjClusterName() string {
 
 type ClusterAlertRuleSpec struct {
 	CommonRuleField
-	ClusterName       string             `json:"clusterName" norman:"type=reference[cluster]"`
-	GroupName         string             `json:"groupName" norman:"type=reference[clusterAlertGroup]"`
-	NodeRule          *NodeRule          `json:"nodeRule,omitempty"`
-	EventRule         *EventRule         `json:"eventRule,omitempty"`
-	SystemServiceRule *SystemServiceRule `json:"systemServiceRule,omitempty"`
-	MetricRule        *MetricRule        `json:"metricRule,omitempty"`
-	ClusterScanRule   *ClusterScanRule   `json:"clusterScanRule,omitempty"`
+	ClusterName          string                `json:"clusterName" norman:"type=reference[cluster]"`
+	GroupName            string                `json:"groupName" norman:"type=reference[clusterAlertGroup]"`
+	NodeRule             *NodeRule             `json:"nodeRule,omitempty"`
+	EventRule            *EventRule            `json:"eventRule,omitempty"`
+	SystemServiceRule    *SystemServiceRule    `json:"systemServiceRule,omitempty"`
+	MetricRule           *MetricRule           `json:"metricRule,omitempty"`
+	ClusterScanRule      *ClusterScanRule      `json:"clusterScanRule,omitempty"`
+	EtcdRestoreDrillRule *EtcdRestoreDrillRule `json:"etcdRestoreDrillRule,omitempty"`
 }
 
 func (c *ClusterAlertRuleSpec) ObjClusterName() string {
@@ -282,6 +283,10 @@ type ClusterScanRule struct {
 	FailuresOnly bool               `json:"failuresOnly,omitempty"`
 }
 
+type EtcdRestoreDrillRule struct {
+	FailuresOnly bool `json:"failuresOnly,omitempty"`
+}
+
 type MetricRule struct {
 	Expression     string  `json:"expression,omitempty" norman:"required"`
 	Description    string  `json:"description,omitempty"`
@@ -350,16 +355,22 @@ func (n *Notifier) ObjClusterName() string {
 type NotifierSpec struct {
 	ClusterName string `json:"clusterName" norman:"type=reference[cluster]"`
 
-	DisplayName     string           `json:"displayName,omitempty" norman:"required"`
-	Description     string           `json:"description,omitempty"`
-	SendResolved    bool             `json:"sendResolved,omitempty"`
-	SMTPConfig      *SMTPConfig      `json:"smtpConfig,omitempty"`
-	SlackConfig     *SlackConfig     `json:"slackConfig,omitempty"`
-	PagerdutyConfig *PagerdutyConfig `json:"pagerdutyConfig,omitempty"`
-	WebhookConfig   *WebhookConfig   `json:"webhookConfig,omitempty"`
-	WechatConfig    *WechatConfig    `json:"wechatConfig,omitempty"`
-	DingtalkConfig  *DingtalkConfig  `json:"dingtalkConfig,omitempty"`
-	MSTeamsConfig   *MSTeamsConfig   `json:"msteamsConfig,omitempty"`
+	DisplayName      string                `json:"displayName,omitempty" norman:"required"`
+	Description      string                `json:"description,omitempty"`
+	SendResolved     bool                  `json:"sendResolved,omitempty"`
+	SMTPConfig       *SMTPConfig           `json:"smtpConfig,omitempty"`
+	SlackConfig      *SlackConfig          `json:"slackConfig,omitempty"`
+	PagerdutyConfig  *PagerdutyConfig      `json:"pagerdutyConfig,omitempty"`
+	WebhookConfig    *WebhookConfig        `json:"webhookConfig,omitempty"`
+	WechatConfig     *WechatConfig         `json:"wechatConfig,omitempty"`
+	DingtalkConfig   *DingtalkConfig       `json:"dingtalkConfig,omitempty"`
+	MSTeamsConfig    *MSTeamsConfig        `json:"msteamsConfig,omitempty"`
+	MattermostConfig *MattermostConfig     `json:"mattermostConfig,omitempty"`
+	RocketChatConfig *RocketChatConfig     `json:"rocketChatConfig,omitempty"`
+	GoogleChatConfig *GoogleChatConfig     `json:"googleChatConfig,omitempty"`
+	MatrixConfig     *MatrixConfig         `json:"matrixConfig,omitempty"`
+	DeliveryConfig   *DeliveryConfig       `json:"deliveryConfig,omitempty"`
+	Template         *NotificationTemplate `json:"template,omitempty"`
 }
 
 func (n *NotifierSpec) ObjClusterName() string {
@@ -367,14 +378,40 @@ func (n *NotifierSpec) ObjClusterName() string {
 }
 
 type Notification struct {
-	Message         string           `json:"message,omitempty"`
-	SMTPConfig      *SMTPConfig      `json:"smtpConfig,omitempty"`
-	SlackConfig     *SlackConfig     `json:"slackConfig,omitempty"`
-	PagerdutyConfig *PagerdutyConfig `json:"pagerdutyConfig,omitempty"`
-	WebhookConfig   *WebhookConfig   `json:"webhookConfig,omitempty"`
-	WechatConfig    *WechatConfig    `json:"wechatConfig,omitempty"`
-	DingtalkConfig  *DingtalkConfig  `json:"dingtalkConfig,omitempty"`
-	MSTeamsConfig   *MSTeamsConfig   `json:"msteamsConfig,omitempty"`
+	Message          string                `json:"message,omitempty"`
+	SMTPConfig       *SMTPConfig           `json:"smtpConfig,omitempty"`
+	SlackConfig      *SlackConfig          `json:"slackConfig,omitempty"`
+	PagerdutyConfig  *PagerdutyConfig      `json:"pagerdutyConfig,omitempty"`
+	WebhookConfig    *WebhookConfig        `json:"webhookConfig,omitempty"`
+	WechatConfig     *WechatConfig         `json:"wechatConfig,omitempty"`
+	DingtalkConfig   *DingtalkConfig       `json:"dingtalkConfig,omitempty"`
+	MSTeamsConfig    *MSTeamsConfig        `json:"msteamsConfig,omitempty"`
+	MattermostConfig *MattermostConfig     `json:"mattermostConfig,omitempty"`
+	RocketChatConfig *RocketChatConfig     `json:"rocketChatConfig,omitempty"`
+	GoogleChatConfig *GoogleChatConfig     `json:"googleChatConfig,omitempty"`
+	MatrixConfig     *MatrixConfig         `json:"matrixConfig,omitempty"`
+	Template         *NotificationTemplate `json:"template,omitempty"`
+}
+
+// NotificationTemplate holds Go text/templates that are rendered against a message
+// before it is sent. An empty template leaves that part of the message unchanged.
+type NotificationTemplate struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+// NotificationPreviewInput is a sample message a notification template is rendered against.
+type NotificationPreviewInput struct {
+	Template    *NotificationTemplate `json:"template,omitempty"`
+	Title       string                `json:"title,omitempty"`
+	Content     string                `json:"content,omitempty"`
+	Labels      map[string]string     `json:"labels,omitempty"`
+	Annotations map[string]string     `json:"annotations,omitempty"`
+}
+
+type NotificationPreviewOutput struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
 }
 
 type SMTPConfig struct {
@@ -385,6 +422,32 @@ type SMTPConfig struct {
 	Sender           string `json:"sender,omitempty" norman:"required"`
 	DefaultRecipient string `json:"defaultRecipient,omitempty" norman:"required"`
 	TLS              *bool  `json:"tls,omitempty" norman:"required,default=true"`
+	// TLSMode overrides TLS. implicit connects with TLS, starttls requires the STARTTLS
+	// extension and opportunistic only upgrades when the server advertises it. Without a
+	// TLSMode the certificate of a server on port 465 is not verified unless CACerts is
+	// set, as before TLSMode was added, set TLSMode to implicit to verify it.
+	TLSMode string `json:"tlsMode,omitempty" norman:"options=implicit|starttls|opportunistic|none"`
+	// CACerts is a PEM bundle used to verify the server instead of the system roots.
+	CACerts string            `json:"caCerts,omitempty"`
+	CC      []string          `json:"cc,omitempty"`
+	BCC     []string          `json:"bcc,omitempty"`
+	OAuth2  *SMTPOAuth2Config `json:"oauth2,omitempty"`
+}
+
+const (
+	SMTPTLSModeImplicit      = "implicit"
+	SMTPTLSModeStartTLS      = "starttls"
+	SMTPTLSModeOpportunistic = "opportunistic"
+	SMTPTLSModeNone          = "none"
+)
+
+// SMTPOAuth2Config authenticates with XOAUTH2. The secret in the namespace of the
+// notifier holds the clientId, clientSecret and refreshToken keys used to obtain
+// access tokens from the token URL.
+type SMTPOAuth2Config struct {
+	TokenURL   string   `json:"tokenUrl,omitempty" norman:"required"`
+	SecretName string   `json:"secretName,omitempty" norman:"required"`
+	Scopes     []string `json:"scopes,omitempty"`
 }
 
 type SlackConfig struct {
@@ -424,7 +487,47 @@ type MSTeamsConfig struct {
 	*HTTPClientConfig
 }
 
+type MattermostConfig struct {
+	URL              string `json:"url,omitempty" norman:"required"`
+	DefaultRecipient string `json:"defaultRecipient,omitempty"`
+	Username         string `json:"username,omitempty"`
+	*HTTPClientConfig
+}
+
+type RocketChatConfig struct {
+	URL              string `json:"url,omitempty" norman:"required"`
+	DefaultRecipient string `json:"defaultRecipient,omitempty"`
+	*HTTPClientConfig
+}
+
+type GoogleChatConfig struct {
+	URL string `json:"url,omitempty" norman:"required"`
+	*HTTPClientConfig
+}
+
+type MatrixConfig struct {
+	HomeserverURL    string `json:"homeserverUrl,omitempty" norman:"required"`
+	AccessToken      string `json:"accessToken,omitempty" norman:"type=password,required"`
+	DefaultRecipient string `json:"defaultRecipient,omitempty" norman:"required"`
+	*HTTPClientConfig
+}
+
+// DeliveryConfig controls how queued messages are delivered by a notifier.
+type DeliveryConfig struct {
+	// MaxRetries is the number of failed attempts after which a message is dropped.
+	MaxRetries int `json:"maxRetries,omitempty" norman:"min=0,default=10"`
+	// DedupWindowSeconds is the period in which identical messages are only sent once,
+	// a negative value disables deduplication.
+	DedupWindowSeconds int `json:"dedupWindowSeconds,omitempty" norman:"min=-1,default=300"`
+	// RateLimitPerMinute is the maximum number of messages sent per minute.
+	RateLimitPerMinute int `json:"rateLimitPerMinute,omitempty" norman:"min=0,default=30"`
+}
+
 type NotifierStatus struct {
+	LastSuccessTime string `json:"lastSuccessTime,omitempty"`
+	LastErrorTime   string `json:"lastErrorTime,omitempty"`
+	LastError       string `json:"lastError,omitempty"`
+	QueuedCount     int    `json:"queuedCount,omitempty"`
 }
 
 // HTTPClientConfig configures an HTTP client.
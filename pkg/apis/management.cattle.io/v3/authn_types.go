@@ -32,6 +32,53 @@ type Token struct {
 	Current         bool              `json:"current"`
 	ClusterName     string            `json:"clusterName,omitempty" norman:"noupdate,type=reference[cluster]"`
 	Enabled         *bool             `json:"enabled,omitempty" norman:"default=true"`
+	// Scopes limit the requests the token can be used for, a token without scopes
+	// can make any request its user is allowed to.
+	Scopes []TokenScope `json:"scopes,omitempty" norman:"noupdate"`
+	// LastUsedAt and LastUsedFrom are updated in batches, so they can be a few minutes behind.
+	LastUsedAt   string `json:"lastUsedAt,omitempty" norman:"nocreate,noupdate"`
+	LastUsedFrom string `json:"lastUsedFrom,omitempty" norman:"nocreate,noupdate"`
+}
+
+// TokenScope allows the verbs on the resources it matches. Every non-empty field has
+// to match a request and "*" matches anything.
+type TokenScope struct {
+	// Verbs are the request verbs: get, list, watch, create, update, patch, delete.
+	Verbs []string `json:"verbs" norman:"required"`
+	// APIGroups are API groups, "" is the core group. Requests to /v3 are in the management.cattle.io group.
+	APIGroups []string `json:"apiGroups,omitempty"`
+	// Resources are resource types as they appear in the URL, e.g. clusters or workloads.
+	Resources []string `json:"resources,omitempty"`
+	// ClusterID limits the scope to requests for a cluster, including its projects.
+	ClusterID string `json:"clusterId,omitempty" norman:"type=reference[cluster]"`
+	// ProjectID limits the scope to requests for a project.
+	ProjectID string `json:"projectId,omitempty" norman:"type=reference[project]"`
+	// Namespaces limit the scope to requests for resources in the namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+type TokenUsageInput struct {
+	// IdleDays limits the report to tokens that have not been used for at least that many days.
+	IdleDays int `json:"idleDays,omitempty"`
+}
+
+type TokenUsageOutput struct {
+	Users []UserTokenUsage `json:"users"`
+}
+
+type UserTokenUsage struct {
+	UserID string       `json:"userId" norman:"type=reference[user]"`
+	Tokens []TokenUsage `json:"tokens"`
+}
+
+type TokenUsage struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	Kind         string `json:"kind,omitempty"`
+	IsDerived    bool   `json:"isDerived"`
+	ExpiresAt    string `json:"expiresAt,omitempty"`
+	LastUsedAt   string `json:"lastUsedAt,omitempty"`
+	LastUsedFrom string `json:"lastUsedFrom,omitempty"`
 }
 
 func (t *Token) ObjClusterName() string {
@@ -36,6 +36,7 @@ func init() {
 	ClusterActionRestoreFromEtcdBackup = "restoreFromEtcdBackup"
 	ClusterActionRotateCertificates    = "rotateCertificates"
 	ClusterActionRotateEncryptionKey   = "rotateEncryptionKey"
+	ClusterActionPreviewEtcdRetention  = "previewEtcdRetention"
 	ClusterActionRunSecurityScan       = "runSecurityScan"
 	ClusterActionSaveAsTemplate        = "saveAsTemplate"
 
@@ -116,6 +117,9 @@ type ClusterSpecBase struct {
 	WindowsPreferedCluster               bool                                    `json:"windowsPreferedCluster" norman:"noupdate"`
 	LocalClusterAuthEndpoint             LocalClusterAuthEndpoint                `json:"localClusterAuthEndpoint,omitempty"`
 	ScheduledClusterScan                 *ScheduledClusterScan                   `json:"scheduledClusterScan,omitempty"`
+	EtcdBackupTarget                     *EtcdBackupTarget                       `json:"etcdBackupTarget,omitempty"`
+	EtcdBackupRetention                  []EtcdBackupRetentionTier               `json:"etcdBackupRetention,omitempty"`
+	EtcdRestoreDrill                     *EtcdRestoreDrillConfig                 `json:"etcdRestoreDrill,omitempty"`
 }
 
 type ClusterSpec struct {
@@ -341,6 +345,16 @@ type RotateEncryptionKeyOutput struct {
 	Message string `json:"message,omitempty"`
 }
 
+type PreviewEtcdRetentionInput struct {
+	// Tiers are previewed instead of the retention of the cluster if set
+	Tiers []EtcdBackupRetentionTier `json:"tiers,omitempty"`
+}
+
+type PreviewEtcdRetentionOutput struct {
+	Kept   []string `json:"kept,omitempty"`
+	Pruned []string `json:"pruned,omitempty"`
+}
+
 type LocalClusterAuthEndpoint struct {
 	Enabled bool   `json:"enabled"`
 	FQDN    string `json:"fqdn,omitempty"`
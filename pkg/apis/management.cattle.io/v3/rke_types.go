@@ -1,11 +1,19 @@
 package v3
 
 import (
+	"github.com/rancher/norman/condition"
 	"github.com/rancher/norman/types"
 	rketypes "github.com/rancher/rke/types"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// BackupConditionRestoreDrilled is set by a restore drill of the backup, it is false if the
+	// snapshot failed to restore or the restored data failed the integrity checks.
+	BackupConditionRestoreDrilled      condition.Cond = "RestoreDrilled"
+	BackupConditionRestoreDrillAlerted condition.Cond = Alerted
+)
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
@@ -53,3 +61,77 @@ type RkeAddon struct {
 
 	Template string `yaml:"template" json:"template,omitempty"`
 }
+
+// EtcdBackupTarget is a storage the etcd snapshots of an RKE cluster are copied to after they
+// are taken on the etcd nodes, for storage the RKE backup config can't upload to. Only one of
+// the targets can be set.
+type EtcdBackupTarget struct {
+	AzureBlob  *AzureBlobBackupTarget  `json:"azureBlob,omitempty"`
+	GCS        *GCSBackupTarget        `json:"gcs,omitempty"`
+	SFTP       *SFTPBackupTarget       `json:"sftp,omitempty"`
+	Filesystem *FilesystemBackupTarget `json:"filesystem,omitempty"`
+	// EncryptionKeySecret is the name of a secret with a 32 byte "key" in the namespace of the
+	// cluster, namespace:name is accepted if the namespace is the one of the cluster. The
+	// snapshots copied to the target and the cluster spec stored with each backup are encrypted
+	// with the key. One of the targets must be set, backups fail if the key is set without one
+	// because RKE uploads S3 snapshots and keeps local snapshots unencrypted.
+	EncryptionKeySecret string `json:"encryptionKeySecret,omitempty"`
+}
+
+// EtcdBackupRetentionTier keeps the newest recurring backup of each interval for the last Keep
+// intervals that have a backup. Tiers replace the retention of the backup config and are
+// combined, hourly backups for a day, daily for a week and weekly for three months are the tiers
+// {1, 24}, {24, 7} and {168, 13}. Intervals are aligned to UTC, weeks start on Monday.
+type EtcdBackupRetentionTier struct {
+	IntervalHours int `json:"intervalHours,omitempty" norman:"required,min=1"`
+	Keep          int `json:"keep,omitempty" norman:"required,min=1"`
+}
+
+// EtcdRestoreDrillConfig schedules restore drills for the recurring backups of a cluster. A drill
+// copies the newest backup from the backup target, restores it into a throwaway etcd and checks
+// the restored data, the result is the RestoreDrilled condition of the backup. The throwaway etcd
+// runs with limited resources through the docker daemon of a node without the etcd role, or of
+// an etcd node if the cluster has no other nodes. Drills are only supported for RKE clusters, the
+// drills of RKE2 clusters are set in the etcd config of the provisioning cluster.
+type EtcdRestoreDrillConfig struct {
+	Enabled       bool `json:"enabled,omitempty"`
+	IntervalHours int  `json:"intervalHours,omitempty" norman:"default=24,min=1"`
+	// RequiredNamespaces must exist in the restored data, kube-system and default if empty
+	RequiredNamespaces []string `json:"requiredNamespaces,omitempty"`
+	// MinKeys is the least number of keys the restored data must have
+	MinKeys int `json:"minKeys,omitempty" norman:"min=0"`
+}
+
+type AzureBlobBackupTarget struct {
+	AccountName string `json:"accountName,omitempty" norman:"required"`
+	AccountKey  string `json:"accountKey,omitempty" norman:"required,type=password"`
+	Container   string `json:"container,omitempty" norman:"required"`
+	Folder      string `json:"folder,omitempty"`
+	// EndpointSuffix is the storage endpoint suffix of the Azure cloud, core.windows.net if empty
+	EndpointSuffix string `json:"endpointSuffix,omitempty"`
+}
+
+type GCSBackupTarget struct {
+	Bucket string `json:"bucket,omitempty" norman:"required"`
+	Folder string `json:"folder,omitempty"`
+	// ServiceAccountKey is the JSON key of a service account that can write to the bucket
+	ServiceAccountKey string `json:"serviceAccountKey,omitempty" norman:"required,type=password"`
+}
+
+type SFTPBackupTarget struct {
+	// Address is the host and port of the SFTP server, the port is 22 if it is omitted
+	Address    string `json:"address,omitempty" norman:"required"`
+	Username   string `json:"username,omitempty" norman:"required"`
+	Password   string `json:"password,omitempty" norman:"type=password"`
+	PrivateKey string `json:"privateKey,omitempty" norman:"type=password"`
+	// HostKey is the public key of the server in authorized_keys format
+	HostKey string `json:"hostKey,omitempty" norman:"required"`
+	Path    string `json:"path,omitempty" norman:"required"`
+}
+
+type FilesystemBackupTarget struct {
+	// Path is a directory of the Rancher server under the etcd-backup-filesystem-base-dir
+	// setting, for example an NFS export mounted into the Rancher container. It is relative to
+	// the base directory.
+	Path string `json:"path,omitempty" norman:"required"`
+}
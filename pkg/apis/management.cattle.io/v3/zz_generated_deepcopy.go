@@ -785,6 +785,22 @@ func (in *AzureADProviderList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureBlobBackupTarget) DeepCopyInto(out *AzureBlobBackupTarget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureBlobBackupTarget.
+func (in *AzureBlobBackupTarget) DeepCopy() *AzureBlobBackupTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureBlobBackupTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BasicLogin) DeepCopyInto(out *BasicLogin) {
 	*out = *in
@@ -1634,6 +1650,11 @@ func (in *ClusterAlertRuleSpec) DeepCopyInto(out *ClusterAlertRuleSpec) {
 		*out = new(ClusterScanRule)
 		**out = **in
 	}
+	if in.EtcdRestoreDrillRule != nil {
+		in, out := &in.EtcdRestoreDrillRule, &out.EtcdRestoreDrillRule
+		*out = new(EtcdRestoreDrillRule)
+		**out = **in
+	}
 	return
 }
 
@@ -2445,6 +2466,21 @@ func (in *ClusterSpecBase) DeepCopyInto(out *ClusterSpecBase) {
 		*out = new(ScheduledClusterScan)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.EtcdBackupTarget != nil {
+		in, out := &in.EtcdBackupTarget, &out.EtcdBackupTarget
+		*out = new(EtcdBackupTarget)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EtcdBackupRetention != nil {
+		in, out := &in.EtcdBackupRetention, &out.EtcdBackupRetention
+		*out = make([]EtcdBackupRetentionTier, len(*in))
+		copy(*out, *in)
+	}
+	if in.EtcdRestoreDrill != nil {
+		in, out := &in.EtcdRestoreDrill, &out.EtcdRestoreDrill
+		*out = new(EtcdRestoreDrillConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -3075,6 +3111,22 @@ func (in *CustomTargetConfig) DeepCopy() *CustomTargetConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeliveryConfig) DeepCopyInto(out *DeliveryConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeliveryConfig.
+func (in *DeliveryConfig) DeepCopy() *DeliveryConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DeliveryConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DingtalkConfig) DeepCopyInto(out *DingtalkConfig) {
 	*out = *in
@@ -3386,6 +3438,95 @@ func (in *EtcdBackupList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdBackupRetentionTier) DeepCopyInto(out *EtcdBackupRetentionTier) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdBackupRetentionTier.
+func (in *EtcdBackupRetentionTier) DeepCopy() *EtcdBackupRetentionTier {
+	if in == nil {
+		return nil
+	}
+	out := new(EtcdBackupRetentionTier)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdBackupTarget) DeepCopyInto(out *EtcdBackupTarget) {
+	*out = *in
+	if in.AzureBlob != nil {
+		in, out := &in.AzureBlob, &out.AzureBlob
+		*out = new(AzureBlobBackupTarget)
+		**out = **in
+	}
+	if in.GCS != nil {
+		in, out := &in.GCS, &out.GCS
+		*out = new(GCSBackupTarget)
+		**out = **in
+	}
+	if in.SFTP != nil {
+		in, out := &in.SFTP, &out.SFTP
+		*out = new(SFTPBackupTarget)
+		**out = **in
+	}
+	if in.Filesystem != nil {
+		in, out := &in.Filesystem, &out.Filesystem
+		*out = new(FilesystemBackupTarget)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdBackupTarget.
+func (in *EtcdBackupTarget) DeepCopy() *EtcdBackupTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(EtcdBackupTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdRestoreDrillConfig) DeepCopyInto(out *EtcdRestoreDrillConfig) {
+	*out = *in
+	if in.RequiredNamespaces != nil {
+		in, out := &in.RequiredNamespaces, &out.RequiredNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdRestoreDrillConfig.
+func (in *EtcdRestoreDrillConfig) DeepCopy() *EtcdRestoreDrillConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EtcdRestoreDrillConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdRestoreDrillRule) DeepCopyInto(out *EtcdRestoreDrillRule) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdRestoreDrillRule.
+func (in *EtcdRestoreDrillRule) DeepCopy() *EtcdRestoreDrillRule {
+	if in == nil {
+		return nil
+	}
+	out := new(EtcdRestoreDrillRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EventRule) DeepCopyInto(out *EventRule) {
 	*out = *in
@@ -3559,6 +3700,22 @@ func (in *File) DeepCopy() *File {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FilesystemBackupTarget) DeepCopyInto(out *FilesystemBackupTarget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FilesystemBackupTarget.
+func (in *FilesystemBackupTarget) DeepCopy() *FilesystemBackupTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(FilesystemBackupTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Filter) DeepCopyInto(out *Filter) {
 	*out = *in
@@ -3792,6 +3949,22 @@ func (in *FreeIpaTestAndApplyInput) DeepCopy() *FreeIpaTestAndApplyInput {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCSBackupTarget) DeepCopyInto(out *GCSBackupTarget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCSBackupTarget.
+func (in *GCSBackupTarget) DeepCopy() *GCSBackupTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(GCSBackupTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GKEStatus) DeepCopyInto(out *GKEStatus) {
 	*out = *in
@@ -4378,6 +4551,27 @@ func (in *GlobalRoleList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GoogleChatConfig) DeepCopyInto(out *GoogleChatConfig) {
+	*out = *in
+	if in.HTTPClientConfig != nil {
+		in, out := &in.HTTPClientConfig, &out.HTTPClientConfig
+		*out = new(HTTPClientConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GoogleChatConfig.
+func (in *GoogleChatConfig) DeepCopy() *GoogleChatConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GoogleChatConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GoogleOAuthProvider) DeepCopyInto(out *GoogleOAuthProvider) {
 	*out = *in
@@ -5532,6 +5726,48 @@ func (in MapStringInterface) DeepCopyInto(out *MapStringInterface) {
 	}
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixConfig) DeepCopyInto(out *MatrixConfig) {
+	*out = *in
+	if in.HTTPClientConfig != nil {
+		in, out := &in.HTTPClientConfig, &out.HTTPClientConfig
+		*out = new(HTTPClientConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixConfig.
+func (in *MatrixConfig) DeepCopy() *MatrixConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MattermostConfig) DeepCopyInto(out *MattermostConfig) {
+	*out = *in
+	if in.HTTPClientConfig != nil {
+		in, out := &in.HTTPClientConfig, &out.HTTPClientConfig
+		*out = new(HTTPClientConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MattermostConfig.
+func (in *MattermostConfig) DeepCopy() *MattermostConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MattermostConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Member) DeepCopyInto(out *Member) {
 	*out = *in
@@ -6674,6 +6910,31 @@ func (in *Notification) DeepCopyInto(out *Notification) {
 		*out = new(MSTeamsConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.MattermostConfig != nil {
+		in, out := &in.MattermostConfig, &out.MattermostConfig
+		*out = new(MattermostConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RocketChatConfig != nil {
+		in, out := &in.RocketChatConfig, &out.RocketChatConfig
+		*out = new(RocketChatConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GoogleChatConfig != nil {
+		in, out := &in.GoogleChatConfig, &out.GoogleChatConfig
+		*out = new(GoogleChatConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MatrixConfig != nil {
+		in, out := &in.MatrixConfig, &out.MatrixConfig
+		*out = new(MatrixConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(NotificationTemplate)
+		**out = **in
+	}
 	return
 }
 
@@ -6687,6 +6948,73 @@ func (in *Notification) DeepCopy() *Notification {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationPreviewInput) DeepCopyInto(out *NotificationPreviewInput) {
+	*out = *in
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(NotificationTemplate)
+		**out = **in
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationPreviewInput.
+func (in *NotificationPreviewInput) DeepCopy() *NotificationPreviewInput {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationPreviewInput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationPreviewOutput) DeepCopyInto(out *NotificationPreviewOutput) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationPreviewOutput.
+func (in *NotificationPreviewOutput) DeepCopy() *NotificationPreviewOutput {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationPreviewOutput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationTemplate) DeepCopyInto(out *NotificationTemplate) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationTemplate.
+func (in *NotificationTemplate) DeepCopy() *NotificationTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Notifier) DeepCopyInto(out *Notifier) {
 	*out = *in
@@ -6787,6 +7115,36 @@ func (in *NotifierSpec) DeepCopyInto(out *NotifierSpec) {
 		*out = new(MSTeamsConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.MattermostConfig != nil {
+		in, out := &in.MattermostConfig, &out.MattermostConfig
+		*out = new(MattermostConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RocketChatConfig != nil {
+		in, out := &in.RocketChatConfig, &out.RocketChatConfig
+		*out = new(RocketChatConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GoogleChatConfig != nil {
+		in, out := &in.GoogleChatConfig, &out.GoogleChatConfig
+		*out = new(GoogleChatConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MatrixConfig != nil {
+		in, out := &in.MatrixConfig, &out.MatrixConfig
+		*out = new(MatrixConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeliveryConfig != nil {
+		in, out := &in.DeliveryConfig, &out.DeliveryConfig
+		*out = new(DeliveryConfig)
+		**out = **in
+	}
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(NotificationTemplate)
+		**out = **in
+	}
 	return
 }
 
@@ -7363,6 +7721,53 @@ func (in *PreferenceList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreviewEtcdRetentionInput) DeepCopyInto(out *PreviewEtcdRetentionInput) {
+	*out = *in
+	if in.Tiers != nil {
+		in, out := &in.Tiers, &out.Tiers
+		*out = make([]EtcdBackupRetentionTier, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreviewEtcdRetentionInput.
+func (in *PreviewEtcdRetentionInput) DeepCopy() *PreviewEtcdRetentionInput {
+	if in == nil {
+		return nil
+	}
+	out := new(PreviewEtcdRetentionInput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreviewEtcdRetentionOutput) DeepCopyInto(out *PreviewEtcdRetentionOutput) {
+	*out = *in
+	if in.Kept != nil {
+		in, out := &in.Kept, &out.Kept
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Pruned != nil {
+		in, out := &in.Pruned, &out.Pruned
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreviewEtcdRetentionOutput.
+func (in *PreviewEtcdRetentionOutput) DeepCopy() *PreviewEtcdRetentionOutput {
+	if in == nil {
+		return nil
+	}
+	out := new(PreviewEtcdRetentionOutput)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Principal) DeepCopyInto(out *Principal) {
 	*out = *in
@@ -8796,6 +9201,27 @@ func (in *RkeK8sSystemImageList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RocketChatConfig) DeepCopyInto(out *RocketChatConfig) {
+	*out = *in
+	if in.HTTPClientConfig != nil {
+		in, out := &in.HTTPClientConfig, &out.HTTPClientConfig
+		*out = new(HTTPClientConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RocketChatConfig.
+func (in *RocketChatConfig) DeepCopy() *RocketChatConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RocketChatConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RoleTemplate) DeepCopyInto(out *RoleTemplate) {
 	*out = *in
@@ -8959,6 +9385,22 @@ func (in *Route53ProviderConfig) DeepCopy() *Route53ProviderConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SFTPBackupTarget) DeepCopyInto(out *SFTPBackupTarget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SFTPBackupTarget.
+func (in *SFTPBackupTarget) DeepCopy() *SFTPBackupTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(SFTPBackupTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SMTPConfig) DeepCopyInto(out *SMTPConfig) {
 	*out = *in
@@ -8967,6 +9409,21 @@ func (in *SMTPConfig) DeepCopyInto(out *SMTPConfig) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.CC != nil {
+		in, out := &in.CC, &out.CC
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BCC != nil {
+		in, out := &in.BCC, &out.BCC
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OAuth2 != nil {
+		in, out := &in.OAuth2, &out.OAuth2
+		*out = new(SMTPOAuth2Config)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -8980,6 +9437,27 @@ func (in *SMTPConfig) DeepCopy() *SMTPConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SMTPOAuth2Config) DeepCopyInto(out *SMTPOAuth2Config) {
+	*out = *in
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SMTPOAuth2Config.
+func (in *SMTPOAuth2Config) DeepCopy() *SMTPOAuth2Config {
+	if in == nil {
+		return nil
+	}
+	out := new(SMTPOAuth2Config)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SamlConfig) DeepCopyInto(out *SamlConfig) {
 	*out = *in
@@ -10005,6 +10483,13 @@ func (in *Token) DeepCopyInto(out *Token) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]TokenScope, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -10059,6 +10544,97 @@ func (in *TokenList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenScope) DeepCopyInto(out *TokenScope) {
+	*out = *in
+	if in.Verbs != nil {
+		in, out := &in.Verbs, &out.Verbs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.APIGroups != nil {
+		in, out := &in.APIGroups, &out.APIGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenScope.
+func (in *TokenScope) DeepCopy() *TokenScope {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenScope)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenUsage) DeepCopyInto(out *TokenUsage) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenUsage.
+func (in *TokenUsage) DeepCopy() *TokenUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenUsageInput) DeepCopyInto(out *TokenUsageInput) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenUsageInput.
+func (in *TokenUsageInput) DeepCopy() *TokenUsageInput {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenUsageInput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenUsageOutput) DeepCopyInto(out *TokenUsageOutput) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]UserTokenUsage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenUsageOutput.
+func (in *TokenUsageOutput) DeepCopy() *TokenUsageOutput {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenUsageOutput)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpdateGlobalDNSTargetsInput) DeepCopyInto(out *UpdateGlobalDNSTargetsInput) {
 	*out = *in
@@ -10319,6 +10895,27 @@ func (in *UserStatus) DeepCopy() *UserStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserTokenUsage) DeepCopyInto(out *UserTokenUsage) {
+	*out = *in
+	if in.Tokens != nil {
+		in, out := &in.Tokens, &out.Tokens
+		*out = make([]TokenUsage, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserTokenUsage.
+func (in *UserTokenUsage) DeepCopy() *UserTokenUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(UserTokenUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Values) DeepCopyInto(out *Values) {
 	*out = *in
@@ -30,6 +30,11 @@ type RKEClusterSpecCommon struct {
 	AdditionalManifest       string                   `json:"additionalManifest,omitempty"`
 	Registries               *Registry                `json:"registries,omitempty"`
 	ETCD                     *ETCD                    `json:"etcd,omitempty"`
+	// ReconcileDrift applies the plan again on nodes with files that were changed out-of-band. The
+	// nodes are reconciled in the batches of the rollout strategy if set. Drift is detected from
+	// the hashes of the files a periodic instruction of the plan reports, it requires a
+	// system-agent that runs periodic instructions
+	ReconcileDrift bool `json:"reconcileDrift,omitempty"`
 }
 
 type LocalClusterAuthEndpoint struct {
@@ -57,6 +62,20 @@ type ClusterUpgradeStrategy struct {
 	// How many workers should be upgraded at a time
 	WorkerConcurrency  string       `json:"workerConcurrency,omitempty"`
 	WorkerDrainOptions DrainOptions `json:"workerDrainOptions,omitempty"`
+
+	// Rollout upgrades the nodes of each role in batches of the concurrency that must pass their
+	// probes for a soak time before the next batch starts
+	Rollout *RolloutStrategy `json:"rollout,omitempty"`
+}
+
+type RolloutStrategy struct {
+	// Canary upgrades a single node of each role before the first batch
+	Canary bool `json:"canary,omitempty"`
+	// How long the probes of a batch must pass before the next batch starts
+	SoakSeconds int `json:"soakSeconds,omitempty"`
+	// Paused stops the rollout before the next batch. A rollout that halted because the probes
+	// of a node failed while soaking continues once it is paused and resumed
+	Paused bool `json:"paused,omitempty"`
 }
 
 type DrainOptions struct {
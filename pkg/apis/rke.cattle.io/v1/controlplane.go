@@ -28,6 +28,17 @@ type RKEControlPlaneSpec struct {
 	UnmanagedConfig       bool                `json:"unmanagedConfig,omitempty"`
 }
 
+// RolloutStatus is the state of the rollout of plan changes to the nodes of a role.
+type RolloutStatus struct {
+	Role    string   `json:"role,omitempty"`
+	Batches int      `json:"batches,omitempty"`
+	Batch   []string `json:"batch,omitempty"`
+	// SoakStarted is when the probes of all nodes of the batch passed
+	SoakStarted   *metav1.Time `json:"soakStarted,omitempty"`
+	Halted        bool         `json:"halted,omitempty"`
+	HaltedMessage string       `json:"haltedMessage,omitempty"`
+}
+
 type ETCDSnapshotPhase string
 
 var (
@@ -38,12 +49,18 @@ type RKEControlPlaneSpec struct {
 )
 
 type RKEControlPlaneStatus struct {
-	Conditions               []genericcondition.GenericCondition `json:"conditions,omitempty"`
-	Ready                    bool                                `json:"ready,omitempty"`
-	ObservedGeneration       int64                               `json:"observedGeneration"`
-	ETCDSnapshotRestore      *ETCDSnapshot                       `json:"etcdSnapshotRestore,omitempty"`
-	ETCDSnapshotRestorePhase ETCDSnapshotPhase                   `json:"etcdSnapshotRestorePhase,omitempty"`
-	ETCDSnapshotCreate       *ETCDSnapshotCreate                 `json:"etcdSnapshotCreate,omitempty"`
-	ETCDSnapshotCreatePhase  ETCDSnapshotPhase                   `json:"etcdSnapshotCreatePhase,omitempty"`
-	ConfigGeneration         int64                               `json:"configGeneration,omitempty"`
+	Conditions                    []genericcondition.GenericCondition `json:"conditions,omitempty"`
+	Ready                         bool                                `json:"ready,omitempty"`
+	ObservedGeneration            int64                               `json:"observedGeneration"`
+	ETCDSnapshotRestore           *ETCDSnapshot                       `json:"etcdSnapshotRestore,omitempty"`
+	ETCDSnapshotRestorePhase      ETCDSnapshotPhase                   `json:"etcdSnapshotRestorePhase,omitempty"`
+	ETCDSnapshotCreate            *ETCDSnapshotCreate                 `json:"etcdSnapshotCreate,omitempty"`
+	ETCDSnapshotCreatePhase       ETCDSnapshotPhase                   `json:"etcdSnapshotCreatePhase,omitempty"`
+	ETCDSnapshotRestoreDrill      *ETCDSnapshot                       `json:"etcdSnapshotRestoreDrill,omitempty"`
+	ETCDSnapshotRestoreDrillPhase ETCDSnapshotPhase                   `json:"etcdSnapshotRestoreDrillPhase,omitempty"`
+	ETCDSnapshotsToPrune          []string                            `json:"etcdSnapshotsToPrune,omitempty"`
+	ETCDSnapshotsPruned           []string                            `json:"etcdSnapshotsPruned,omitempty"`
+	ETCDSnapshotsPrunedAt         *metav1.Time                        `json:"etcdSnapshotsPrunedAt,omitempty"`
+	Rollout                       *RolloutStatus                      `json:"rollout,omitempty"`
+	ConfigGeneration              int64                               `json:"configGeneration,omitempty"`
 }
@@ -26,9 +26,39 @@ type ETCDSnapshot struct {
 	S3        *ETCDSnapshotS3 `json:"s3,omitempty"`
 }
 
+// ETCDSnapshotRetentionTier keeps the newest snapshot of each interval for the last Keep
+// intervals that have a snapshot, hourly snapshots for a day, daily for a week and weekly for
+// three months are the tiers {1, 24}, {24, 7} and {168, 13}. Intervals are aligned to UTC.
+type ETCDSnapshotRetentionTier struct {
+	IntervalHours int `json:"intervalHours,omitempty"`
+	Keep          int `json:"keep,omitempty"`
+}
+
+// ETCDRestoreDrill schedules restore drills of the snapshots of a cluster. A drill restores the
+// newest local snapshot into a throwaway etcd on the etcd node that took it and checks the
+// restored data, the result is the RestoreDrilled condition of the control plane. The throwaway
+// etcd runs with limited resources and without a network in a container of the etcd image of
+// the node. Drills are only supported for RKE2, K3s has no etcd image.
+type ETCDRestoreDrill struct {
+	Enabled       bool `json:"enabled,omitempty"`
+	IntervalHours int  `json:"intervalHours,omitempty"`
+	// RequiredNamespaces must exist in the restored data, kube-system and default if empty
+	RequiredNamespaces []string `json:"requiredNamespaces,omitempty"`
+	// MinKeys is the least number of keys the restored data must have
+	MinKeys int `json:"minKeys,omitempty"`
+}
+
 type ETCD struct {
 	DisableSnapshots     bool            `json:"disableSnapshots,omitempty"`
 	SnapshotScheduleCron string          `json:"snapshotScheduleCron,omitempty"`
 	SnapshotRetention    int             `json:"snapshotRetention,omitempty"`
 	S3                   *ETCDSnapshotS3 `json:"s3,omitempty"`
+	// SnapshotRetentionTiers replace SnapshotRetention, the control plane prunes the snapshots
+	// by the tiers once new snapshots are listed in the status of the cluster instead of the
+	// etcd nodes pruning them. Pruning replaces the plan of each etcd node that holds pruned
+	// snapshots with a prune plan until the node applied it, the node then re-applies its own
+	// plan. To limit this churn the snapshots are pruned at most once per the longest interval
+	// of the tiers, snapshots the tiers no longer keep may remain for up to that interval.
+	SnapshotRetentionTiers []ETCDSnapshotRetentionTier `json:"snapshotRetentionTiers,omitempty"`
+	RestoreDrill           *ETCDRestoreDrill           `json:"restoreDrill,omitempty"`
 }
@@ -22,6 +22,9 @@ type RKEMachineStatus struct {
 	FailureReason             string                `json:"failureReason,omitempty"`
 	FailureMessage            string                `json:"failureMessage,omitempty"`
 	Addresses                 []capi.MachineAddress `json:"addresses,omitempty"`
+	// SSHHostKey is the host key of the machine in authorized_keys format, it is captured once
+	// the machine is provisioned and connections with another host key are refused
+	SSHHostKey string `json:"sshHostKey,omitempty"`
 }
 
 // +genclient
@@ -23,6 +23,8 @@ type Node struct {
 	InSync      bool                   `json:"inSync,omitempty"`
 	Healthy     bool                   `json:"healthy,omitempty"`
 	ProbeStatus map[string]ProbeStatus `json:"probeStatus,omitempty"`
+	// PeriodicOutput is the output of the last runs of the periodic instructions of the applied plan
+	PeriodicOutput map[string]PeriodicInstructionOutput `json:"-"`
 }
 
 type Secret struct {
@@ -39,6 +41,25 @@ type Instruction struct {
 	SaveOutput bool     `json:"saveOutput,omitempty"`
 }
 
+// PeriodicInstruction is run by the agent every PeriodSeconds while the plan is applied, the output
+// of the last run is saved to the plan secret.
+type PeriodicInstruction struct {
+	Name          string   `json:"name,omitempty"`
+	Image         string   `json:"image,omitempty"`
+	Env           []string `json:"env,omitempty"`
+	Args          []string `json:"args,omitempty"`
+	Command       string   `json:"command,omitempty"`
+	PeriodSeconds int      `json:"periodSeconds,omitempty"` // default 600
+}
+
+type PeriodicInstructionOutput struct {
+	Name                  string `json:"name,omitempty"`
+	Stdout                []byte `json:"stdout,omitempty"`
+	Stderr                []byte `json:"stderr,omitempty"`
+	ExitCode              int    `json:"exitCode,omitempty"`
+	LastSuccessfulRunTime string `json:"lastSuccessfulRunTime,omitempty"`
+}
+
 type File struct {
 	Content string `json:"content,omitempty"`
 	Path    string `json:"path,omitempty"`
@@ -46,8 +67,9 @@ type File struct {
 }
 
 type NodePlan struct {
-	Files        []File           `json:"files,omitempty"`
-	Instructions []Instruction    `json:"instructions,omitempty"`
-	Error        string           `json:"error,omitempty"`
-	Probes       map[string]Probe `json:"probes,omitempty"`
+	Files                []File                `json:"files,omitempty"`
+	Instructions         []Instruction         `json:"instructions,omitempty"`
+	PeriodicInstructions []PeriodicInstruction `json:"periodicInstructions,omitempty"`
+	Error                string                `json:"error,omitempty"`
+	Probes               map[string]Probe      `json:"probes,omitempty"`
 }
@@ -49,6 +49,11 @@ func (in *ClusterUpgradeStrategy) DeepCopyInto(out *ClusterUpgradeStrategy) {
 	*out = *in
 	in.ControlPlaneDrainOptions.DeepCopyInto(&out.ControlPlaneDrainOptions)
 	in.WorkerDrainOptions.DeepCopyInto(&out.WorkerDrainOptions)
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(RolloutStrategy)
+		**out = **in
+	}
 	return
 }
 
@@ -189,6 +194,16 @@ func (in *ETCD) DeepCopyInto(out *ETCD) {
 		*out = new(ETCDSnapshotS3)
 		**out = **in
 	}
+	if in.SnapshotRetentionTiers != nil {
+		in, out := &in.SnapshotRetentionTiers, &out.SnapshotRetentionTiers
+		*out = make([]ETCDSnapshotRetentionTier, len(*in))
+		copy(*out, *in)
+	}
+	if in.RestoreDrill != nil {
+		in, out := &in.RestoreDrill, &out.RestoreDrill
+		*out = new(ETCDRestoreDrill)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -202,6 +217,27 @@ func (in *ETCD) DeepCopy() *ETCD {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDRestoreDrill) DeepCopyInto(out *ETCDRestoreDrill) {
+	*out = *in
+	if in.RequiredNamespaces != nil {
+		in, out := &in.RequiredNamespaces, &out.RequiredNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDRestoreDrill.
+func (in *ETCDRestoreDrill) DeepCopy() *ETCDRestoreDrill {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDRestoreDrill)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ETCDSnapshot) DeepCopyInto(out *ETCDSnapshot) {
 	*out = *in
@@ -248,6 +284,22 @@ func (in *ETCDSnapshotCreate) DeepCopy() *ETCDSnapshotCreate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotRetentionTier) DeepCopyInto(out *ETCDSnapshotRetentionTier) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotRetentionTier.
+func (in *ETCDSnapshotRetentionTier) DeepCopy() *ETCDSnapshotRetentionTier {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotRetentionTier)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ETCDSnapshotS3) DeepCopyInto(out *ETCDSnapshotS3) {
 	*out = *in
@@ -792,6 +844,30 @@ func (in *RKEControlPlaneStatus) DeepCopyInto(out *RKEControlPlaneStatus) {
 		*out = new(ETCDSnapshotCreate)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ETCDSnapshotRestoreDrill != nil {
+		in, out := &in.ETCDSnapshotRestoreDrill, &out.ETCDSnapshotRestoreDrill
+		*out = new(ETCDSnapshot)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ETCDSnapshotsToPrune != nil {
+		in, out := &in.ETCDSnapshotsToPrune, &out.ETCDSnapshotsToPrune
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ETCDSnapshotsPruned != nil {
+		in, out := &in.ETCDSnapshotsPruned, &out.ETCDSnapshotsPruned
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ETCDSnapshotsPrunedAt != nil {
+		in, out := &in.ETCDSnapshotsPrunedAt, &out.ETCDSnapshotsPrunedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(RolloutStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -898,3 +974,44 @@ func (in *RegistryConfig) DeepCopy() *RegistryConfig {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStatus) DeepCopyInto(out *RolloutStatus) {
+	*out = *in
+	if in.Batch != nil {
+		in, out := &in.Batch, &out.Batch
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SoakStarted != nil {
+		in, out := &in.SoakStarted, &out.SoakStarted
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStatus.
+func (in *RolloutStatus) DeepCopy() *RolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStrategy) DeepCopyInto(out *RolloutStrategy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStrategy.
+func (in *RolloutStrategy) DeepCopy() *RolloutStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -42,6 +42,7 @@
 type auditLog struct {
 	log                *log
 	writer             *LogWriter
+	level              int
 	reqBody            []byte
 	keysToConcealRegex *regexp.Regexp
 }
@@ -98,9 +99,10 @@ func FromContext(ctx context.Context) (*User, bool) {
 	return u, ok
 }
 
-func newAuditLog(writer *LogWriter, req *http.Request, keysToConcealRegex *regexp.Regexp) (*auditLog, error) {
+func newAuditLog(writer *LogWriter, level int, req *http.Request, keysToConcealRegex *regexp.Regexp) (*auditLog, error) {
 	auditLog := &auditLog{
 		writer: writer,
+		level:  level,
 		log: &log{
 			AuditID:          k8stypes.UID(uuid.NewRandom().String()),
 			RequestURI:       req.RequestURI,
@@ -113,7 +115,7 @@ func newAuditLog(writer *LogWriter, req *http.Request, keysToConcealRegex *regex
 
 	contentType := req.Header.Get("Content-Type")
 	loginReq := isLoginRequest(req.RequestURI)
-	if writer.Level >= levelRequest || loginReq {
+	if level >= levelRequest || loginReq {
 		if bodyMethods[req.Method] && strings.HasPrefix(contentType, contentTypeJSON) {
 			reqBody, err := readBodyWithoutLosingContent(req)
 			if err != nil {
@@ -125,7 +127,7 @@ func newAuditLog(writer *LogWriter, req *http.Request, keysToConcealRegex *regex
 					auditLog.log.UserLoginName = loginName
 				}
 			}
-			if writer.Level >= levelRequest {
+			if level >= levelRequest {
 				auditLog.reqBody = reqBody
 			}
 		}
@@ -154,11 +156,11 @@ func (a *auditLog) write(userInfo *User, reqHeaders, resHeaders http.Header, res
 	}
 
 	buffer.Write(bytes.TrimSuffix(alByte, []byte("}")))
-	if a.writer.Level >= levelRequest && len(a.reqBody) > 0 {
+	if a.level >= levelRequest && len(a.reqBody) > 0 {
 		buffer.WriteString(`,"requestBody":`)
 		buffer.Write(bytes.TrimSuffix(a.concealSensitiveData(a.log.RequestURI, a.reqBody), []byte("\n")))
 	}
-	if a.writer.Level >= levelRequestResponse && resHeaders.Get("Content-Type") == contentTypeJSON && len(resBody) > 0 {
+	if a.level >= levelRequestResponse && resHeaders.Get("Content-Type") == contentTypeJSON && len(resBody) > 0 {
 		buffer.WriteString(`,"responseBody":`)
 		buffer.Write(bytes.TrimSuffix(a.concealSensitiveData(a.log.RequestURI, resBody), []byte("\n")))
 	}
@@ -171,7 +173,7 @@ func (a *auditLog) write(userInfo *User, reqHeaders, resHeaders http.Header, res
 	}
 
 	compactBuffer.WriteString("\n")
-	_, err = a.writer.Output.Write(compactBuffer.Bytes())
+	_, err = a.writer.Write(compactBuffer.Bytes())
 	return err
 }
 
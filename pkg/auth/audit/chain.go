@@ -0,0 +1,204 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rancher/rancher/pkg/namespace"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	SigningKeySecretName = "audit-log-signing-key"
+	privateKeyField      = "privateKey"
+	publicKeyField       = "publicKey"
+)
+
+// chainLink is the part of a record that links it to the previous one. The hash
+// of a record is the sha256 of the line as written, without the trailing newline.
+type chainLink struct {
+	Seq      uint64 `json:"seq"`
+	PrevHash string `json:"prevHash"`
+}
+
+// checkpoint is written after every n-th record and signs the hash of that record,
+// so truncating the log and rebuilding the chain from scratch is detectable.
+type checkpoint struct {
+	Seq       uint64 `json:"seq"`
+	Hash      string `json:"hash"`
+	KeyID     string `json:"keyID"`
+	Signature string `json:"signature"`
+}
+
+type checkpointRecord struct {
+	Checkpoint *checkpoint `json:"checkpoint,omitempty"`
+}
+
+// hashChain adds a sequence number and the hash of the previous record to every
+// record. It is not safe for concurrent use, the LogWriter serializes access.
+type hashChain struct {
+	seq        uint64
+	prevHash   string
+	interval   uint64
+	signingKey ed25519.PrivateKey
+}
+
+func newHashChain(interval int, signingKey ed25519.PrivateKey) *hashChain {
+	if interval < 0 {
+		interval = 0
+	}
+	return &hashChain{
+		interval:   uint64(interval),
+		signingKey: signingKey,
+	}
+}
+
+// resume continues the chain from the last chained record in the log file at path.
+func (c *hashChain) resume(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		if len(line) > 0 {
+			var link chainLink
+			if json.Unmarshal(line, &link) == nil && link.Seq > 0 {
+				c.seq = link.Seq
+				c.prevHash = hashRecord(line)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// link returns the record with its chain fields added and a signed checkpoint
+// record when one is due.
+func (c *hashChain) link(record []byte) ([]byte, []byte, error) {
+	c.seq++
+	suffix, err := json.Marshal(chainLink{
+		Seq:      c.seq,
+		PrevHash: c.prevHash,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// both documents are json objects, so merge them by joining their fields
+	line := append([]byte(nil), bytes.TrimSuffix(bytes.TrimSuffix(record, []byte("\n")), []byte("}"))...)
+	if len(line) > 1 {
+		line = append(line, ',')
+	}
+	line = append(line, suffix[1:]...)
+	c.prevHash = hashRecord(line)
+	line = append(line, '\n')
+
+	if c.signingKey == nil || c.interval == 0 || c.seq%c.interval != 0 {
+		return line, nil, nil
+	}
+	cp, err := c.checkpoint()
+	return line, cp, err
+}
+
+// head returns the checkpoint that starts a new log file. It links the file to the
+// last record of the previous file, so removing records from the head or the tail of
+// a rotated file is detectable.
+func (c *hashChain) head() ([]byte, error) {
+	if c.seq == 0 {
+		return nil, nil
+	}
+	return c.checkpoint()
+}
+
+// checkpoint returns the checkpoint record of the last record, it is signed when a
+// signing key is set.
+func (c *hashChain) checkpoint() ([]byte, error) {
+	cp := &checkpoint{
+		Seq:  c.seq,
+		Hash: c.prevHash,
+	}
+	if c.signingKey != nil {
+		cp.KeyID = keyID(c.signingKey.Public().(ed25519.PublicKey))
+		cp.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(c.signingKey, checkpointMessage(c.seq, c.prevHash)))
+	}
+	data, err := json.Marshal(checkpointRecord{
+		Checkpoint: cp,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func hashRecord(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+func checkpointMessage(seq uint64, hash string) []byte {
+	return []byte(fmt.Sprintf("%d:%s", seq, hash))
+}
+
+func keyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// getOrCreateSigningKey loads the checkpoint signing key from its secret in the
+// system namespace and generates it on first use.
+func getOrCreateSigningKey(secrets corecontrollers.SecretClient) (ed25519.PrivateKey, error) {
+	secret, err := secrets.Get(namespace.System, SigningKeySecretName, metav1.GetOptions{})
+	if err == nil {
+		key := secret.Data[privateKeyField]
+		if len(key) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("secret %s/%s does not contain a valid ed25519 private key", namespace.System, SigningKeySecretName)
+		}
+		return ed25519.PrivateKey(key), nil
+	} else if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	_, err = secrets.Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace.System,
+			Name:      SigningKeySecretName,
+		},
+		Data: map[string][]byte{
+			privateKeyField: private,
+			publicKeyField:  public,
+		},
+	})
+	if apierrors.IsAlreadyExists(err) {
+		// another replica created the key first
+		return getOrCreateSigningKey(secrets)
+	} else if err != nil {
+		return nil, err
+	}
+	return private, nil
+}
@@ -0,0 +1,246 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
+)
+
+func writeChain(t *testing.T, chain *hashChain, count int) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < count; i++ {
+		record, cp, err := chain.link([]byte(`{"auditID":"id","method":"GET"}` + "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(record)
+		buf.Write(cp)
+	}
+	return buf.Bytes()
+}
+
+func TestVerifyHashChain(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := writeChain(t, newHashChain(2, private), 5)
+	lines := strings.SplitAfter(string(data), "\n")
+
+	tests := []struct {
+		name          string
+		log           string
+		key           ed25519.PublicKey
+		wantLine      int
+		wantCount     uint64
+		wantUnchained uint64
+	}{
+		{
+			name:      "intact chain",
+			log:       string(data),
+			key:       public,
+			wantCount: 5,
+		},
+		{
+			name:     "modified record",
+			log:      strings.Replace(string(data), `"method":"GET","seq":4`, `"method":"PUT","seq":4`, 1),
+			key:      public,
+			wantLine: 6,
+		},
+		{
+			name:     "removed record",
+			log:      strings.Join(append(append([]string{}, lines[:1]...), lines[2:]...), ""),
+			key:      public,
+			wantLine: 2,
+		},
+		{
+			name:     "wrong signing key",
+			log:      string(data),
+			key:      otherPublic,
+			wantLine: 3,
+		},
+		{
+			name:      "rotated file starts with checkpoint",
+			log:       strings.Join(lines[2:], ""),
+			key:       public,
+			wantCount: 3,
+		},
+		{
+			name:     "head removed",
+			log:      strings.Join(lines[3:], ""),
+			key:      public,
+			wantLine: 1,
+		},
+		{
+			name:          "chain enabled on an existing log",
+			log:           "{\"auditID\":\"a\"}\n{\"auditID\":\"b\"}\n" + string(data),
+			key:           public,
+			wantCount:     5,
+			wantUnchained: 2,
+		},
+		{
+			name:     "unchained record inside the chain",
+			log:      strings.Join(lines[:1], "") + "{\"auditID\":\"a\"}\n" + strings.Join(lines[1:], ""),
+			key:      public,
+			wantLine: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Verify(strings.NewReader(tt.log), tt.key)
+			if tt.wantLine == 0 {
+				if err != nil {
+					t.Fatalf("Verify() unexpected error: %v", err)
+				}
+				if result.Records != tt.wantCount {
+					t.Errorf("Verify() records = %d, want %d", result.Records, tt.wantCount)
+				}
+				if result.Unchained != tt.wantUnchained {
+					t.Errorf("Verify() unchained = %d, want %d", result.Unchained, tt.wantUnchained)
+				}
+				return
+			}
+			broken, ok := err.(*BrokenLinkError)
+			if !ok {
+				t.Fatalf("Verify() error = %v, want BrokenLinkError", err)
+			}
+			if broken.Line != tt.wantLine {
+				t.Errorf("Verify() broken at line %d, want %d: %v", broken.Line, tt.wantLine, broken)
+			}
+		})
+	}
+}
+
+func TestHashChainResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	first := writeChain(t, newHashChain(0, nil), 3)
+	if err := ioutil.WriteFile(path, first, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	chain := newHashChain(0, nil)
+	if err := chain.resume(path); err != nil {
+		t.Fatal(err)
+	}
+	second := writeChain(t, chain, 2)
+
+	result, err := Verify(bytes.NewReader(append(first, second...)), nil)
+	if err != nil {
+		t.Fatalf("Verify() unexpected error after resume: %v", err)
+	}
+	if result.LastSeq != 5 {
+		t.Errorf("Verify() last sequence = %d, want 5", result.LastSeq)
+	}
+}
+
+func TestVerifyRotatedFiles(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	chain := newHashChain(0, private)
+	first := writeChain(t, chain, 3)
+	head, err := chain.head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := append(head, writeChain(t, chain, 2)...)
+
+	firstPath, secondPath := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+	if err := ioutil.WriteFile(secondPath, second, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		first    []byte
+		wantLine int
+	}{
+		{
+			name:  "intact files",
+			first: first,
+		},
+		{
+			name:     "tail of rotated file removed",
+			first:    first[:bytes.LastIndexByte(first[:len(first)-1], '\n')+1],
+			wantLine: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(firstPath, tt.first, 0600); err != nil {
+				t.Fatal(err)
+			}
+			result, err := VerifyFiles([]string{firstPath, secondPath}, public)
+			if tt.wantLine == 0 {
+				if err != nil {
+					t.Fatalf("VerifyFiles() unexpected error: %v", err)
+				}
+				if result.Records != 5 || result.Checkpoints != 1 {
+					t.Errorf("VerifyFiles() = %+v, want 5 records and 1 checkpoint", result)
+				}
+				return
+			}
+			broken, ok := err.(*BrokenLinkError)
+			if !ok {
+				t.Fatalf("VerifyFiles() error = %v, want BrokenLinkError", err)
+			}
+			if broken.File != secondPath || broken.Line != tt.wantLine {
+				t.Errorf("VerifyFiles() broken at %s:%d, want %s:%d", broken.File, broken.Line, secondPath, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestLogWriterRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &LogWriter{
+		Output: &lumberjack.Logger{
+			Filename: path,
+			MaxSize:  1,
+		},
+		chain: newHashChain(2, private),
+	}
+	defer l.Output.Close()
+
+	record := []byte(`{"auditID":"id","requestBody":"` + strings.Repeat("x", 64*1024) + `"}` + "\n")
+	for i := 0; i < 40; i++ {
+		if _, err := l.Write(record); err != nil {
+			t.Fatal(err)
+		}
+		// lumberjack names rotated files by the millisecond
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "audit-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 rotated files, got %d", len(backups))
+	}
+	result, err := VerifyFiles(append(backups, path), private.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatalf("VerifyFiles() unexpected error: %v", err)
+	}
+	if result.Records != 40 {
+		t.Errorf("VerifyFiles() records = %d, want 40", result.Records)
+	}
+}
@@ -2,37 +2,242 @@
 
 import (
 	"context"
+	"crypto/ed25519"
+	"os"
+	"sync"
 
+	"github.com/rancher/rancher/pkg/settings"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
 	lumberjack "gopkg.in/natefinch/lumberjack.v2"
 )
 
+const (
+	megabyte = 1024 * 1024
+	// defaultMaxSize is the size in megabytes lumberjack rotates at if MaxSize is not set
+	defaultMaxSize = 100
+	// maxChainOverhead is more than the chain fields and the checkpoint add to a record
+	maxChainOverhead = 512
+)
+
 type LogWriter struct {
-	Level  int
+	Level int
+	// Output is nil when records only go to the sinks
 	Output *lumberjack.Logger
+
+	sinksLock sync.RWMutex
+	sinks     []Sink
+	// dropped counts the records each failing sink dropped since its outage began
+	droppedLock sync.Mutex
+	dropped     map[Sink]int
+
+	chainLock sync.Mutex
+	chain     *hashChain
+	// size of the log file while the hash chain is enabled
+	size int64
 }
 
-func (l *LogWriter) Start(ctx context.Context) {
+func (l *LogWriter) Start(ctx context.Context, secrets corecontrollers.SecretClient) {
 	if l == nil {
+		if settings.AuditLogPolicy.Get() != "" {
+			logrus.Warnf("audit-log-policy is ignored because the audit log is disabled, set --audit-level to enable it")
+		}
 		return
 	}
+
+	if settings.AuditLogHashChain.Get() == "true" {
+		if l.Output == nil {
+			logrus.Errorf("audit log hash chain requires an audit log path")
+		} else if err := l.startHashChain(secrets); err != nil {
+			logrus.Errorf("failed to start audit log hash chain: %v", err)
+		}
+	}
+
+	sinks, err := newSinksFromSettings()
+	if err != nil {
+		logrus.Errorf("failed to configure audit log sinks: %v", err)
+	}
+	if l.Output == nil && len(sinks) == 0 {
+		logrus.Warnf("audit log is enabled without an audit log path or audit-log-sinks, records are discarded")
+	}
+	l.sinksLock.Lock()
+	l.sinks = sinks
+	l.sinksLock.Unlock()
+
 	go func() {
 		<-ctx.Done()
-		l.Output.Close()
+		if l.Output != nil {
+			l.Output.Close()
+		}
+		l.closeSinks()
 	}()
 }
 
+// startHashChain continues the hash chain of the current log file. Checkpoints are
+// only written when the signing key could be loaded.
+func (l *LogWriter) startHashChain(secrets corecontrollers.SecretClient) error {
+	var (
+		key    ed25519.PrivateKey
+		keyErr error
+	)
+	interval := settings.AuditLogCheckpointInterval.GetInt()
+	if interval > 0 {
+		key, keyErr = getOrCreateSigningKey(secrets)
+	}
+
+	chain := newHashChain(interval, key)
+	if err := chain.resume(l.Output.Filename); err != nil {
+		return err
+	}
+
+	var size int64
+	if info, err := os.Stat(l.Output.Filename); err == nil {
+		size = info.Size()
+	}
+
+	l.chainLock.Lock()
+	l.chain = chain
+	l.size = size
+	l.chainLock.Unlock()
+	return keyErr
+}
+
+// Write sends the record to the log file and fans it out to every configured sink.
+// When hash chaining is enabled records are linked and written one at a time so the
+// order in the file matches the chain. Sinks are written to after the lock is released
+// so a slow sink never holds up other requests, they may receive chained records in a
+// different order than the file and can restore it from the seq field.
+func (l *LogWriter) Write(record []byte) (int, error) {
+	record, cp, n, err := l.writeOutput(record)
+	if err != nil {
+		return n, err
+	}
+	l.writeSinks(record)
+	if cp != nil {
+		l.writeSinks(cp)
+	}
+	return n, nil
+}
+
+// writeOutput links the record if hash chaining is enabled and writes it to the log file.
+// It returns the record as written and the checkpoint that follows it, if any.
+func (l *LogWriter) writeOutput(record []byte) ([]byte, []byte, int, error) {
+	l.chainLock.Lock()
+	defer l.chainLock.Unlock()
+	if l.Output == nil {
+		return record, nil, len(record), nil
+	}
+	if l.chain == nil {
+		n, err := l.Output.Write(record)
+		return record, nil, n, err
+	}
+
+	// the file is rotated before the record is linked, so the new file starts with the
+	// checkpoint of the last record of the previous one
+	if err := l.rotate(len(record) + maxChainOverhead); err != nil {
+		return nil, nil, 0, err
+	}
+
+	record, cp, err := l.chain.link(record)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	// the record and its checkpoint are written at once so they end up in the same file
+	n, err := l.Output.Write(append(record, cp...))
+	l.size += int64(n)
+	return record, cp, n, err
+}
+
+// rotate starts a new log file before lumberjack would rotate it on the next write,
+// the new file starts with a checkpoint that links it to the previous file.
+func (l *LogWriter) rotate(n int) error {
+	maxSize := int64(l.Output.MaxSize) * megabyte
+	if maxSize == 0 {
+		maxSize = defaultMaxSize * megabyte
+	}
+	if l.size == 0 || l.size+int64(n) < maxSize {
+		return nil
+	}
+
+	if err := l.Output.Rotate(); err != nil {
+		return err
+	}
+	l.size = 0
+
+	head, err := l.chain.head()
+	if err != nil || head == nil {
+		return err
+	}
+	written, err := l.Output.Write(head)
+	l.size += int64(written)
+	return err
+}
+
+// writeSinks fans the record out to every configured sink. A failing sink does not prevent
+// the record from reaching the others.
+func (l *LogWriter) writeSinks(record []byte) {
+	l.sinksLock.RLock()
+	defer l.sinksLock.RUnlock()
+	for _, sink := range l.sinks {
+		l.countDropped(sink, sink.Write(record))
+	}
+}
+
+// countDropped counts the records a sink drops, an outage is logged once with the number of
+// records dropped during it.
+func (l *LogWriter) countDropped(sink Sink, err error) {
+	l.droppedLock.Lock()
+	defer l.droppedLock.Unlock()
+	dropped := l.dropped[sink]
+	if err != nil {
+		if dropped == 0 {
+			logrus.Errorf("failed to write audit log records to %T: %v", sink, err)
+		}
+		if l.dropped == nil {
+			l.dropped = map[Sink]int{}
+		}
+		l.dropped[sink] = dropped + 1
+		return
+	}
+	if dropped > 0 {
+		logrus.Infof("writing audit log records to %T again, dropped %d records", sink, dropped)
+		delete(l.dropped, sink)
+	}
+}
+
+func (l *LogWriter) closeSinks() {
+	l.sinksLock.Lock()
+	defer l.sinksLock.Unlock()
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil {
+			logrus.Errorf("failed to close audit log sink %T: %v", sink, err)
+		}
+	}
+	l.sinks = nil
+
+	l.droppedLock.Lock()
+	l.dropped = nil
+	l.droppedLock.Unlock()
+}
+
+// NewLogWriter returns nil if audit logging is disabled. Without a path records are
+// only sent to the sinks configured in the audit-log-sinks setting.
 func NewLogWriter(path string, level, maxAge, maxBackup, maxSize int) *LogWriter {
-	if path == "" || level == levelNull {
+	if level == levelNull {
 		return nil
 	}
 
-	return &LogWriter{
+	l := &LogWriter{
 		Level: level,
-		Output: &lumberjack.Logger{
+	}
+	if path != "" {
+		l.Output = &lumberjack.Logger{
 			Filename:   path,
 			MaxAge:     maxAge,
 			MaxBackups: maxBackup,
 			MaxSize:    maxSize,
-		},
+		}
 	}
+	return l
 }
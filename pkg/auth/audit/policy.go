@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	auditpolicy "github.com/rancher/rancher/pkg/auth/audit/policy"
+	"github.com/rancher/rancher/pkg/auth/util"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
+)
+
+const wildcard = "*"
+
+var levels = map[string]int{
+	"None":            levelNull,
+	"Metadata":        levelMetadata,
+	"Request":         levelRequest,
+	"RequestResponse": levelRequestResponse,
+}
+
+// policy picks the audit level for a request. The first matching rule wins, requests
+// that match no rule are logged at the default level set with --audit-level. The policy
+// is only evaluated if --audit-level is above 0, at 0 the audit log is disabled and rules
+// can not enable it for some requests; use a None rule for everything else instead.
+type policy struct {
+	sync.Mutex
+	getValue func() string
+	raw      string
+	rules    []auditpolicy.Rule
+	// invalid is the last value that failed to parse, it is logged once
+	invalid string
+}
+
+func newPolicy() *policy {
+	return &policy{
+		getValue: settings.AuditLogPolicy.Get,
+	}
+}
+
+// getRules returns the rules of the current audit-log-policy setting, the setting is only
+// parsed again when its value changes. An invalid value keeps the previous rules.
+func (p *policy) getRules() []auditpolicy.Rule {
+	value := p.getValue()
+
+	p.Lock()
+	defer p.Unlock()
+	if value == p.raw || value == p.invalid {
+		return p.rules
+	}
+
+	rules, err := auditpolicy.ParseRules(value)
+	if err != nil {
+		logrus.Errorf("invalid audit log policy, keeping the previous policy: %v", err)
+		p.invalid = value
+		return p.rules
+	}
+	p.raw = value
+	p.rules = rules
+	p.invalid = ""
+	return rules
+}
+
+func (p *policy) levelFor(req *http.Request, user *User, defaultLevel int) int {
+	rules := p.getRules()
+	if len(rules) == 0 {
+		return defaultLevel
+	}
+
+	attrs := util.GetRequestAttributes(req)
+	for _, rule := range rules {
+		if ruleMatches(&rule, attrs, user) {
+			return levels[rule.Level]
+		}
+	}
+	return defaultLevel
+}
+
+func ruleMatches(r *auditpolicy.Rule, attrs *util.RequestAttributes, user *User) bool {
+	if len(r.Verbs) > 0 && !matchAny(r.Verbs, attrs.Verb) {
+		return false
+	}
+	if len(r.Users) > 0 && !matchAny(r.Users, user.Name) {
+		return false
+	}
+	if len(r.UserGroups) > 0 {
+		found := false
+		for _, group := range user.Group {
+			if matchAny(r.UserGroups, group) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.APIGroups) > 0 && (!attrs.IsResource || !matchAny(r.APIGroups, attrs.APIGroup)) {
+		return false
+	}
+	if len(r.Resources) > 0 && (!attrs.IsResource || !matchAny(r.Resources, attrs.Resource)) {
+		return false
+	}
+	if len(r.URIPrefixes) > 0 {
+		found := false
+		for _, prefix := range r.URIPrefixes {
+			if prefix == wildcard || strings.HasPrefix(attrs.Path, prefix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAny(values []string, value string) bool {
+	for _, v := range values {
+		if v == wildcard || v == value {
+			return true
+		}
+	}
+	return false
+}
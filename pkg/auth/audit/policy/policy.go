@@ -0,0 +1,61 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Levels are the audit levels a rule can set.
+var Levels = []string{"None", "Metadata", "Request", "RequestResponse"}
+
+// Rule maps requests to an audit level, modeled after the rules of a Kubernetes audit Policy.
+// Every non-empty field has to match for the rule to apply and "*" matches anything.
+type Rule struct {
+	// Level is one of None, Metadata, Request or RequestResponse.
+	Level string `json:"level"`
+	// Verbs are the request verbs: get, list, watch, create, update, patch, delete.
+	Verbs []string `json:"verbs,omitempty"`
+	// Users are user names, e.g. user-abcde or system:admin.
+	Users []string `json:"users,omitempty"`
+	// UserGroups matches if the user is a member of any of the groups.
+	UserGroups []string `json:"userGroups,omitempty"`
+	// APIGroups are API groups, "" is the core group. Requests to /v3 are in the management.cattle.io group.
+	APIGroups []string `json:"apiGroups,omitempty"`
+	// Resources are resource types as they appear in the URL, e.g. settings or management.cattle.io.settings for /v1.
+	Resources []string `json:"resources,omitempty"`
+	// URIPrefixes match the beginning of the request path.
+	URIPrefixes []string `json:"uriPrefixes,omitempty"`
+}
+
+// ParseRules parses the JSON list of rules stored in the audit-log-policy setting.
+func ParseRules(value string) ([]Rule, error) {
+	var rules []Rule
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, err
+	}
+	for i, rule := range rules {
+		if !validLevel(rule.Level) {
+			return nil, fmt.Errorf("audit policy rule %d has invalid level %q", i, rule.Level)
+		}
+	}
+	return rules, nil
+}
+
+// Validate checks a value of the audit-log-policy setting before it is saved.
+func Validate(value string) error {
+	_, err := ParseRules(value)
+	return err
+}
+
+func validLevel(level string) bool {
+	for _, l := range Levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
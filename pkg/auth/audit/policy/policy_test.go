@@ -0,0 +1,30 @@
+package policy
+
+import (
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(`[{"level": "None", "verbs": ["watch"]}, {"level": "RequestResponse"}]`)
+	if err != nil {
+		t.Fatalf("ParseRules() unexpected error: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Errorf("ParseRules() = %d rules, want 2", len(rules))
+	}
+	if rules, err := ParseRules(" "); err != nil || rules != nil {
+		t.Errorf("ParseRules() = %v, %v for an empty value, want no rules", rules, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(`[{"level": "Everything"}]`); err == nil {
+		t.Error("expected error for invalid level")
+	}
+	if err := Validate(`{"level": "None"}`); err == nil {
+		t.Error("expected error for a rule that is not in a list")
+	}
+	if err := Validate(""); err != nil {
+		t.Errorf("Validate() unexpected error for an empty policy: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testPolicy = `[
+	{"level": "None", "verbs": ["watch"]},
+	{"level": "None", "verbs": ["get", "list"], "uriPrefixes": ["/v3/settings"]},
+	{"level": "RequestResponse", "apiGroups": ["rbac.authorization.k8s.io"]},
+	{"level": "RequestResponse", "resources": ["globalrolebindings", "management.cattle.io.globalrolebindings"]},
+	{"level": "Request", "userGroups": ["system:masters"]}
+]`
+
+func Test_policyLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		uri    string
+		header http.Header
+		groups []string
+		want   int
+	}{
+		{
+			name:   "settings list is skipped",
+			method: http.MethodGet,
+			uri:    "/v3/settings",
+			want:   levelNull,
+		},
+		{
+			name:   "settings update uses the default",
+			method: http.MethodPut,
+			uri:    "/v3/settings/server-url",
+			want:   levelMetadata,
+		},
+		{
+			name:   "websocket watch is skipped",
+			method: http.MethodGet,
+			uri:    "/v1/subscribe",
+			header: http.Header{"Upgrade": []string{"websocket"}},
+			want:   levelNull,
+		},
+		{
+			name:   "kubernetes watch is skipped",
+			method: http.MethodGet,
+			uri:    "/k8s/clusters/c-abcde/api/v1/pods?watch=true",
+			want:   levelNull,
+		},
+		{
+			name:   "proxied rbac change logs bodies",
+			method: http.MethodPost,
+			uri:    "/k8s/clusters/local/apis/rbac.authorization.k8s.io/v1/clusterrolebindings",
+			want:   levelRequestResponse,
+		},
+		{
+			name:   "norman rbac change logs bodies",
+			method: http.MethodPost,
+			uri:    "/v3/globalrolebindings",
+			want:   levelRequestResponse,
+		},
+		{
+			name:   "steve rbac change logs bodies",
+			method: http.MethodDelete,
+			uri:    "/v1/management.cattle.io.globalrolebindings/grb-abcde",
+			want:   levelRequestResponse,
+		},
+		{
+			name:   "user group match",
+			method: http.MethodGet,
+			uri:    "/v3/clusters",
+			groups: []string{"system:authenticated", "system:masters"},
+			want:   levelRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.uri, nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			user := &User{Name: "user-abcde", Group: tt.groups}
+			p := &policy{getValue: func() string { return testPolicy }}
+			if got := p.levelFor(req, user, levelMetadata); got != tt.want {
+				t.Errorf("levelFor() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_policyKeepsRulesOnInvalidValue(t *testing.T) {
+	value := testPolicy
+	p := &policy{getValue: func() string { return value }}
+	if rules := p.getRules(); len(rules) != 5 {
+		t.Fatalf("getRules() = %d rules, want 5", len(rules))
+	}
+
+	value = `[{"level": "Everything"}]`
+	if rules := p.getRules(); len(rules) != 5 {
+		t.Errorf("getRules() = %d rules for an invalid value, want the previous 5", len(rules))
+	}
+	if p.invalid != value {
+		t.Errorf("invalid value %q is not remembered, it would be parsed on every request", value)
+	}
+
+	value = `[{"level": "None"}]`
+	if rules := p.getRules(); len(rules) != 1 {
+		t.Errorf("getRules() = %d rules, want 1", len(rules))
+	}
+	if p.invalid != "" {
+		t.Errorf("invalid value %q is kept after a valid value", p.invalid)
+	}
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/rancher/pkg/settings"
+)
+
+const (
+	sinkStdout  = "stdout"
+	sinkSyslog  = "syslog"
+	sinkWebhook = "webhook"
+)
+
+// Sink receives every audit record in addition to the local log file. Each
+// record is a single compact JSON document terminated by a newline.
+type Sink interface {
+	Write(record []byte) error
+	Close() error
+}
+
+// newSinksFromSettings builds the additional sinks listed in the audit-log-sinks setting.
+func newSinksFromSettings() ([]Sink, error) {
+	var sinks []Sink
+	for _, name := range strings.Split(settings.AuditLogSinks.Get(), ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+			continue
+		case sinkStdout:
+			sinks = append(sinks, newWriterSink(os.Stdout))
+		case sinkSyslog:
+			sink, err := newSyslogSink(settings.AuditLogSyslogAddress.Get())
+			if err != nil {
+				return sinks, err
+			}
+			sinks = append(sinks, sink)
+		case sinkWebhook:
+			sink, err := newWebhookSink(settings.AuditLogWebhookURL.Get(),
+				settings.AuditLogWebhookBatchSize.GetInt(),
+				time.Duration(settings.AuditLogWebhookFlushSeconds.GetInt())*time.Second)
+			if err != nil {
+				return sinks, err
+			}
+			sinks = append(sinks, sink)
+		default:
+			return sinks, fmt.Errorf("unknown audit log sink %q", name)
+		}
+	}
+	return sinks, nil
+}
+
+// writerSink writes JSON lines to an io.Writer such as stdout.
+type writerSink struct {
+	sync.Mutex
+	out io.Writer
+}
+
+func newWriterSink(out io.Writer) *writerSink {
+	return &writerSink{out: out}
+}
+
+func (w *writerSink) Write(record []byte) error {
+	w.Lock()
+	defer w.Unlock()
+	_, err := w.out.Write(record)
+	return err
+}
+
+func (w *writerSink) Close() error {
+	return nil
+}
@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// syslogPriority is facility 13 (log audit) with severity 6 (informational).
+	syslogPriority  = 13*8 + 6
+	syslogAppName   = "rancher"
+	syslogMsgID     = "audit"
+	syslogTimeout   = 10 * time.Second
+	syslogQueueSize = 10000
+)
+
+// syslogSink sends records as RFC 5424 messages over TCP or UDP. TCP messages
+// are framed with octet counting as described in RFC 6587. Records are queued
+// and sent in the background so an unreachable server does not block requests.
+type syslogSink struct {
+	network  string
+	address  string
+	hostname string
+	conn     net.Conn
+	// no new connection is dialed before retryAt after dialing failed
+	retryAt time.Time
+	records chan []byte
+	done    chan struct{}
+}
+
+func newSyslogSink(address string) (*syslogSink, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit log syslog address %q: %v", address, err)
+	}
+	if u.Scheme != "tcp" && u.Scheme != "udp" {
+		return nil, fmt.Errorf("invalid audit log syslog address %q: scheme must be tcp or udp", address)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid audit log syslog address %q: host is required", address)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	s := &syslogSink{
+		network:  u.Scheme,
+		address:  u.Host,
+		hostname: hostname,
+		records:  make(chan []byte, syslogQueueSize),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Write queues the record without blocking the request, records are dropped when the queue is full.
+func (s *syslogSink) Write(record []byte) error {
+	select {
+	case s.records <- s.format(time.Now(), record):
+		return nil
+	default:
+		return fmt.Errorf("audit log syslog queue is full, dropping record")
+	}
+}
+
+// Close sends any queued records and closes the connection.
+func (s *syslogSink) Close() error {
+	close(s.records)
+	<-s.done
+	return nil
+}
+
+func (s *syslogSink) run() {
+	defer close(s.done)
+	defer func() {
+		if s.conn != nil {
+			s.conn.Close()
+		}
+	}()
+
+	// an outage is logged once with the number of records dropped during it
+	dropped := 0
+	for msg := range s.records {
+		if err := s.send(msg); err != nil {
+			if dropped == 0 {
+				logrus.Errorf("failed to send audit log records to syslog %s: %v", s.address, err)
+			}
+			dropped++
+			continue
+		}
+		if dropped > 0 {
+			logrus.Infof("sending audit log records to syslog %s again, dropped %d records", s.address, dropped)
+			dropped = 0
+		}
+	}
+}
+
+func (s *syslogSink) send(msg []byte) error {
+	// retry once with a fresh connection, the remote end may have closed an idle connection
+	var err error
+	for i := 0; i < 2; i++ {
+		if s.conn == nil {
+			if time.Now().Before(s.retryAt) {
+				return fmt.Errorf("not connected")
+			}
+			s.conn, err = net.DialTimeout(s.network, s.address, syslogTimeout)
+			if err != nil {
+				s.retryAt = time.Now().Add(syslogTimeout)
+				return err
+			}
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		if _, err = s.conn.Write(msg); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *syslogSink) format(t time.Time, record []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %d %s - ", syslogPriority, t.UTC().Format(time.RFC3339Nano),
+		s.hostname, syslogAppName, os.Getpid(), syslogMsgID)
+	buf.Write(bytes.TrimSuffix(record, []byte("\n")))
+
+	if s.network == "udp" {
+		return buf.Bytes()
+	}
+	return append([]byte(strconv.Itoa(buf.Len())+" "), buf.Bytes()...)
+}
@@ -0,0 +1,271 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/docker/docker/pkg/reexec"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/urfave/cli"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// VerifyResult summarizes a log file whose chain is intact.
+type VerifyResult struct {
+	Records     uint64
+	Checkpoints uint64
+	FirstSeq    uint64
+	LastSeq     uint64
+	// Unchained is the number of records before the start of the chain, they were written
+	// before the hash chain was enabled and can't be verified
+	Unchained uint64
+}
+
+// BrokenLinkError points at the first line of a log file that breaks the hash chain.
+type BrokenLinkError struct {
+	File   string
+	Line   int
+	Reason string
+}
+
+func (e *BrokenLinkError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s: hash chain broken at line %d: %s", e.File, e.Line, e.Reason)
+	}
+	return fmt.Sprintf("hash chain broken at line %d: %s", e.Line, e.Reason)
+}
+
+// Verify walks a hash chained audit log and returns a BrokenLinkError for the first
+// record that was modified, removed or inserted. A file has to start with the first
+// record of the chain or with the checkpoint that links it to the previous, rotated,
+// file. Records without chain fields before the first record of the chain are skipped
+// and counted, the chain was enabled on an existing log file. Checkpoint signatures are
+// only checked when publicKey is set.
+func Verify(r io.Reader, publicKey ed25519.PublicKey) (*VerifyResult, error) {
+	v := &verifier{
+		publicKey: publicKey,
+	}
+	err := v.verify(r)
+	return &v.result, err
+}
+
+// VerifyFiles verifies rotated log files in order, oldest first. The checkpoint at the
+// head of each file has to match the last record of the file before it.
+func VerifyFiles(paths []string, publicKey ed25519.PublicKey) (*VerifyResult, error) {
+	v := &verifier{
+		publicKey: publicKey,
+	}
+	for _, path := range paths {
+		if err := v.verifyFile(path); err != nil {
+			return &v.result, err
+		}
+	}
+	return &v.result, nil
+}
+
+type verifier struct {
+	result    VerifyResult
+	prevHash  string
+	anchored  bool
+	publicKey ed25519.PublicKey
+}
+
+func (v *verifier) verifyFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = v.verify(f)
+	if broken, ok := err.(*BrokenLinkError); ok {
+		broken.File = path
+	}
+	return err
+}
+
+func (v *verifier) verify(r io.Reader) error {
+	var (
+		lineNo int
+		reader = bufio.NewReader(r)
+	)
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		lineNo++
+
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		if len(line) > 0 {
+			if err := v.verifyLine(line); err != nil {
+				return &BrokenLinkError{Line: lineNo, Reason: err.Error()}
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+// verifyLine checks a single record or checkpoint against the chain so far.
+func (v *verifier) verifyLine(line []byte) error {
+	var cp checkpointRecord
+	if err := json.Unmarshal(line, &cp); err != nil {
+		return fmt.Errorf("invalid json: %v", err)
+	}
+	if cp.Checkpoint != nil {
+		if !v.anchored {
+			// the checkpoint at the head of a rotated file anchors the chain
+			v.result.LastSeq, v.prevHash, v.anchored = cp.Checkpoint.Seq, cp.Checkpoint.Hash, true
+		}
+		if err := verifyCheckpoint(cp.Checkpoint, v.result.LastSeq, v.prevHash, v.publicKey); err != nil {
+			return err
+		}
+		v.result.Checkpoints++
+		return nil
+	}
+
+	var link chainLink
+	if err := json.Unmarshal(line, &link); err != nil || link.Seq == 0 {
+		if !v.anchored && v.result.Checkpoints == 0 {
+			v.result.Unchained++
+			return nil
+		}
+		return fmt.Errorf("record has no sequence number")
+	}
+	if !v.anchored {
+		if link.Seq != 1 || link.PrevHash != "" {
+			return fmt.Errorf("log starts at sequence %d without a checkpoint, its head was removed", link.Seq)
+		}
+		v.anchored = true
+	} else if link.Seq != v.result.LastSeq+1 {
+		return fmt.Errorf("expected sequence %d, got %d", v.result.LastSeq+1, link.Seq)
+	} else if link.PrevHash != v.prevHash {
+		return fmt.Errorf("previous record hash mismatch for sequence %d", link.Seq)
+	}
+	if v.result.Records == 0 {
+		v.result.FirstSeq = link.Seq
+	}
+	v.result.Records++
+	v.result.LastSeq = link.Seq
+	v.prevHash = hashRecord(line)
+	return nil
+}
+
+func verifyCheckpoint(cp *checkpoint, lastSeq uint64, lastHash string, publicKey ed25519.PublicKey) error {
+	if cp.Seq != lastSeq || cp.Hash != lastHash {
+		return fmt.Errorf("checkpoint for sequence %d does not match the preceding record", cp.Seq)
+	}
+	if publicKey == nil {
+		return nil
+	}
+	if cp.KeyID != keyID(publicKey) {
+		return fmt.Errorf("checkpoint for sequence %d was signed with unknown key %s", cp.Seq, cp.KeyID)
+	}
+	sig, err := base64.StdEncoding.DecodeString(cp.Signature)
+	if err != nil || !ed25519.Verify(publicKey, checkpointMessage(cp.Seq, cp.Hash), sig) {
+		return fmt.Errorf("invalid checkpoint signature for sequence %d", cp.Seq)
+	}
+	return nil
+}
+
+func RegisterVerifyCommand() {
+	reexec.Register("/usr/bin/verify-audit-log", verifyAuditLog)
+	reexec.Register("verify-audit-log", verifyAuditLog)
+}
+
+func verifyAuditLog() {
+	app := cli.NewApp()
+	app.Description = "Verify the hash chain and checkpoint signatures of an audit log file"
+	app.ArgsUsage = "FILE..."
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "public-key",
+			Usage: "File with the raw ed25519 public key, read from the audit-log-signing-key secret when not set",
+		},
+		cli.BoolFlag{
+			Name:  "skip-signatures",
+			Usage: "Only verify the hash chain and not the checkpoint signatures",
+		},
+	}
+
+	app.Action = func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			return fmt.Errorf("at least one audit log file is required, rotated files oldest first")
+		}
+
+		var publicKey ed25519.PublicKey
+		if !c.Bool("skip-signatures") {
+			key, err := loadPublicKey(c.String("public-key"))
+			if err != nil {
+				return err
+			}
+			publicKey = key
+		}
+
+		result, err := VerifyFiles(c.Args(), publicKey)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "OK: %d records (sequence %d to %d), %d checkpoints\n",
+			result.Records, result.FirstSeq, result.LastSeq, result.Checkpoints)
+		if result.Unchained > 0 {
+			fmt.Fprintf(os.Stdout, "skipped %d records written before the hash chain was enabled, they are not verified\n",
+				result.Unchained)
+		}
+		return nil
+	}
+
+	err := app.Run(os.Args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func loadPublicKey(path string) (ed25519.PublicKey, error) {
+	var key []byte
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key = data
+	} else {
+		kubeConfigPath := os.ExpandEnv("$HOME/.kube/config")
+		if _, err := os.Stat(kubeConfigPath); err != nil {
+			kubeConfigPath = ""
+		}
+		conf, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get kubeconfig: %v", err)
+		}
+		client, err := kubernetes.NewForConfig(conf)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get kubernetes client: %v", err)
+		}
+		secret, err := client.CoreV1().Secrets(namespace.System).Get(context.Background(), SigningKeySecretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get audit log signing key: %v", err)
+		}
+		key = secret.Data[publicKeyField]
+	}
+
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid ed25519 public key of %d bytes", len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
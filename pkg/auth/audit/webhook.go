@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	webhookQueueSize = 10000
+	webhookTimeout   = 30 * time.Second
+)
+
+var webhookBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    5,
+}
+
+// webhookSink batches records and POSTs them as a JSON array to a URL. Failed
+// batches are retried with exponential backoff before being dropped.
+type webhookSink struct {
+	url       string
+	host      string // logged instead of the url, which may contain credentials
+	batchSize int
+	interval  time.Duration
+	client    *http.Client
+	backoff   wait.Backoff
+	records   chan []byte
+	done      chan struct{}
+}
+
+func newWebhookSink(webhookURL string, batchSize int, interval time.Duration) (*webhookSink, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid audit log webhook url, it must be an http or https url")
+	}
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	w := &webhookSink{
+		url:       webhookURL,
+		host:      u.Scheme + "://" + u.Host,
+		batchSize: batchSize,
+		interval:  interval,
+		client:    &http.Client{Timeout: webhookTimeout},
+		backoff:   webhookBackoff,
+		records:   make(chan []byte, webhookQueueSize),
+		done:      make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Write queues the record without blocking the request, records are dropped when the queue is full.
+func (w *webhookSink) Write(record []byte) error {
+	select {
+	case w.records <- bytes.TrimSuffix(record, []byte("\n")):
+		return nil
+	default:
+		return fmt.Errorf("audit log webhook queue is full, dropping record")
+	}
+}
+
+// Close flushes any queued records and waits for the final batch to be sent.
+func (w *webhookSink) Close() error {
+	close(w.records)
+	<-w.done
+	return nil
+}
+
+func (w *webhookSink) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var batch [][]byte
+	for {
+		select {
+		case record, ok := <-w.records:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = nil
+		}
+	}
+}
+
+func (w *webhookSink) flush(batch [][]byte) {
+	if len(batch) == 0 {
+		return
+	}
+
+	body := append([]byte("["), bytes.Join(batch, []byte(","))...)
+	body = append(body, ']')
+
+	err := wait.ExponentialBackoff(w.backoff, func() (bool, error) {
+		if err := w.post(body); err != nil {
+			logrus.Debugf("failed to send audit log batch to webhook, retrying: %v", err)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		logrus.Errorf("dropping %d audit log records after failing to send them to webhook %s", len(batch), w.host)
+	}
+}
+
+func (w *webhookSink) post(body []byte) error {
+	resp, err := w.client.Post(w.url, contentTypeJSON, bytes.NewReader(body))
+	if urlErr, ok := err.(*url.Error); ok {
+		// the error of the client repeats the url
+		return fmt.Errorf("%s %s: %v", urlErr.Op, w.host, urlErr.Err)
+	} else if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
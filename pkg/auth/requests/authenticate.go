@@ -10,6 +10,7 @@
 	"github.com/rancher/rancher/pkg/auth/providerrefresh"
 	"github.com/rancher/rancher/pkg/auth/providers"
 	"github.com/rancher/rancher/pkg/auth/tokens"
+	"github.com/rancher/rancher/pkg/auth/util"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/rancher/steve/pkg/auth"
@@ -22,6 +23,7 @@
 
 var (
 	ErrMustAuthenticate = httperror.NewAPIError(httperror.Unauthorized, "must authenticate")
+	ErrTokenScope       = httperror.NewAPIError(httperror.PermissionDenied, "token scopes do not allow this request")
 )
 
 type Authenticator interface {
@@ -50,7 +52,20 @@ func ToAuthMiddleware(a Authenticator) auth.Middleware {
 			Extra:  authResp.Extras,
 		}, authResp.IsAuthed, err
 	}
-	return auth.ToMiddleware(auth.AuthenticatorFunc(f))
+	middleware := auth.ToMiddleware(auth.AuthenticatorFunc(f))
+
+	// requests outside the scopes of a token are rejected with 403 instead of 401 so
+	// clients can tell a missing scope from an invalid token
+	return func(next http.Handler) http.Handler {
+		authed := middleware(next)
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if token, err := a.TokenFromRequest(req); err == nil && !tokens.ScopesAllow(token, util.GetRequestAttributes(req)) {
+				util.ReturnHTTPError(rw, req, http.StatusForbidden, ErrTokenScope.Error())
+				return
+			}
+			authed.ServeHTTP(rw, req)
+		})
+	}
 }
 
 type ClusterRouter func(req *http.Request) string
@@ -68,6 +83,7 @@ func NewAuthenticator(ctx context.Context, clusterRouter ClusterRouter, mgmtCtx
 		userLister:          mgmtCtx.Management.Users("").Controller().Lister(),
 		clusterRouter:       clusterRouter,
 		userAuthRefresher:   providerrefresh.NewUserAuthRefresher(ctx, mgmtCtx),
+		usageTracker:        tokens.GetUsageTracker(ctx, mgmtCtx),
 	}
 }
 
@@ -80,6 +96,7 @@ type tokenAuthenticator struct {
 	userLister          v3.UserLister
 	clusterRouter       ClusterRouter
 	userAuthRefresher   providerrefresh.UserAuthRefresher
+	usageTracker        *tokens.UsageTracker
 }
 
 const (
@@ -110,6 +127,9 @@ func (a *tokenAuthenticator) Authenticate(req *http.Request) (*AuthenticatorResp
 	if token.ClusterName != "" && token.ClusterName != a.clusterRouter(req) {
 		return nil, errors.Wrapf(ErrMustAuthenticate, "clusterID does not match")
 	}
+	if !tokens.ScopesAllow(token, util.GetRequestAttributes(req)) {
+		return nil, ErrTokenScope
+	}
 
 	attribs, err := a.userAttributeLister.Get("", token.UserID)
 	if err != nil && !apierrors.IsNotFound(err) {
@@ -154,6 +174,8 @@ func (a *tokenAuthenticator) Authenticate(req *http.Request) (*AuthenticatorResp
 		go a.userAuthRefresher.TriggerUserRefresh(token.UserID, false)
 	}
 
+	a.usageTracker.Record(token, req)
+
 	authResp.IsAuthed = true
 	authResp.User = token.UserID
 	authResp.UserPrincipal = token.UserPrincipal.Name
@@ -8,6 +8,7 @@
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/types"
 	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	managementSchema "github.com/rancher/rancher/pkg/schemas/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/sirupsen/logrus"
@@ -29,13 +30,18 @@
 
 func NewAPIHandler(ctx context.Context, apiContext *config.ScaledContext, opts ...ServerOption) (http.Handler, error) {
 	api := &tokenAPI{
-		mgr: NewManager(ctx, apiContext),
+		mgr:           NewManager(ctx, apiContext),
+		accessControl: apiContext.AccessControl,
 	}
 
 	schemas := types.NewSchemas().AddSchemas(managementSchema.TokenSchemas)
 	schema := schemas.Schema(&managementSchema.Version, client.TokenType)
 	schema.CollectionActions = map[string]types.Action{
 		"logout": {},
+		"usage": {
+			Input:  client.TokenUsageInputType,
+			Output: client.TokenUsageOutputType,
+		},
 	}
 
 	schema.ActionHandler = api.tokenActionHandler
@@ -56,7 +62,8 @@ func NewAPIHandler(ctx context.Context, apiContext *config.ScaledContext, opts .
 }
 
 type tokenAPI struct {
-	mgr *Manager
+	mgr           *Manager
+	accessControl types.AccessControl
 }
 
 func (t *tokenAPI) tokenActionHandler(actionName string, action *types.Action, request *types.APIContext) error {
@@ -64,6 +71,9 @@ func (t *tokenAPI) tokenActionHandler(actionName string, action *types.Action, r
 	if actionName == "logout" {
 		return t.mgr.logout(actionName, action, request)
 	}
+	if actionName == "usage" {
+		return t.mgr.tokenUsage(request, t.canListAllTokens(request))
+	}
 	return httperror.NewAPIError(httperror.ActionNotAvailable, "")
 }
 
@@ -84,3 +94,12 @@ func (t *tokenAPI) tokenDeleteHandler(request *types.APIContext, _ types.Request
 	logrus.Debugf("TokenDeleteHandler called")
 	return t.mgr.removeToken(request)
 }
+
+// canListAllTokens reports whether the user can list the tokens of all users, the usage
+// report of other users only includes their own tokens.
+func (t *tokenAPI) canListAllTokens(request *types.APIContext) bool {
+	if t.accessControl == nil {
+		return false
+	}
+	return t.accessControl.CanDo(v3.TokenGroupVersionKind.Group, v3.TokenResource.Name, "list", request, nil, request.Schema) == nil
+}
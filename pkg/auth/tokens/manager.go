@@ -61,6 +61,7 @@ func NewManager(ctx context.Context, apiContext *config.ScaledContext) *Manager
 		tokensClient:        apiContext.Management.Tokens(""),
 		userIndexer:         informer.GetIndexer(),
 		tokenIndexer:        tokenInformer.GetIndexer(),
+		tokenLister:         apiContext.Management.Tokens("").Controller().Lister(),
 		userAttributes:      apiContext.Management.UserAttributes(""),
 		userAttributeLister: apiContext.Management.UserAttributes("").Controller().Lister(),
 		userLister:          apiContext.Management.Users("").Controller().Lister(),
@@ -76,6 +77,7 @@ type Manager struct {
 	userAttributeLister v3.UserAttributeLister
 	userIndexer         cache.Indexer
 	tokenIndexer        cache.Indexer
+	tokenLister         v3.TokenLister
 	userLister          v3.UserLister
 	secrets             v1.SecretInterface
 	secretLister        v1.SecretLister
@@ -104,6 +106,11 @@ func (m *Manager) createDerivedToken(jsonInput clientv3.Token, tokenAuthValue st
 		return v3.Token{}, "", 500, fmt.Errorf("error validating max-ttl %v", err)
 	}
 
+	scopes, err := derivedScopes(token, toTokenScopes(jsonInput.Scopes))
+	if err != nil {
+		return v3.Token{}, "", http.StatusBadRequest, err
+	}
+
 	var unhashedTokenKey string
 	derivedToken := v3.Token{
 		UserPrincipal: token.UserPrincipal,
@@ -114,6 +121,7 @@ func (m *Manager) createDerivedToken(jsonInput clientv3.Token, tokenAuthValue st
 		ProviderInfo:  token.ProviderInfo,
 		Description:   jsonInput.Description,
 		ClusterName:   jsonInput.ClusterID,
+		Scopes:        scopes,
 	}
 	derivedToken, unhashedTokenKey, err = m.createToken(&derivedToken)
 
@@ -39,20 +39,33 @@ func (p *purger) purge() {
 		logrus.Errorf("Error listing tokens during purge: %v", err)
 	}
 
-	var count int
+	var count, idleCount int
+	idle := idleTimeout()
+	now := time.Now()
+	recordUsageTrackingStart(now)
+	trackingStart := usageTrackingStart(now)
 	for _, token := range allTokens {
-		if IsExpired(*token) {
-			err = p.tokens.Delete(token.ObjectMeta.Name, &metav1.DeleteOptions{})
-			if err != nil && !clientbase.IsNotFound(err) {
-				logrus.Errorf("Error: while deleting expired token %v: %v", err, token.ObjectMeta.Name)
-				continue
-			}
+		expired := IsExpired(*token)
+		if !expired && !isIdle(token, idle, trackingStart, now) {
+			continue
+		}
+		err = p.tokens.Delete(token.ObjectMeta.Name, &metav1.DeleteOptions{})
+		if err != nil && !clientbase.IsNotFound(err) {
+			logrus.Errorf("Error: while deleting expired token %v: %v", err, token.ObjectMeta.Name)
+			continue
+		}
+		if expired {
 			count++
+		} else {
+			idleCount++
 		}
 	}
 	if count > 0 {
 		logrus.Infof("Purged %v expired tokens", count)
 	}
+	if idleCount > 0 {
+		logrus.Infof("Purged %v tokens unused for more than %v", idleCount, idle)
+	}
 
 	// saml tokens store encrypted token for login request from rancher cli
 	samlTokens, err := p.samlTokensLister.List(namespace.GlobalNamespace, labels.Everything())
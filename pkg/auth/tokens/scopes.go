@@ -0,0 +1,119 @@
+package tokens
+
+import (
+	"fmt"
+	"reflect"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/util"
+	clientv3 "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+)
+
+const scopeWildcard = "*"
+
+var scopeVerbs = map[string]bool{
+	scopeWildcard: true,
+	"get":         true,
+	"list":        true,
+	"watch":       true,
+	"create":      true,
+	"update":      true,
+	"patch":       true,
+	"delete":      true,
+}
+
+// ScopesAllow reports whether the scopes of the token allow the request. Tokens without
+// scopes allow every request, RBAC still applies to the requests a scope allows. Scoped
+// tokens can not generate kubeconfigs or open kubectl shells, the tokens of these are not
+// scoped.
+func ScopesAllow(token *v3.Token, attrs *util.RequestAttributes) bool {
+	if len(token.Scopes) == 0 {
+		return true
+	}
+	if attrs.IssuesCredentials {
+		return false
+	}
+	for _, scope := range token.Scopes {
+		if scopeMatches(&scope, attrs) {
+			return true
+		}
+	}
+	return false
+}
+
+func scopeMatches(scope *v32.TokenScope, attrs *util.RequestAttributes) bool {
+	if !attrs.IsResource {
+		return false
+	}
+	if !matchScopeValue(scope.Verbs, attrs.Verb) {
+		return false
+	}
+	if len(scope.APIGroups) > 0 && !matchScopeValue(scope.APIGroups, attrs.APIGroup) {
+		return false
+	}
+	if len(scope.Resources) > 0 && !matchScopeValue(scope.Resources, attrs.Resource) {
+		return false
+	}
+	if scope.ClusterID != "" && scope.ClusterID != attrs.ClusterID {
+		return false
+	}
+	if scope.ProjectID != "" && scope.ProjectID != attrs.ProjectID {
+		return false
+	}
+	if len(scope.Namespaces) > 0 && (attrs.Namespace == "" || !matchScopeValue(scope.Namespaces, attrs.Namespace)) {
+		return false
+	}
+	return true
+}
+
+func matchScopeValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == scopeWildcard || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateScopes checks that every scope allows at least one known verb.
+func ValidateScopes(scopes []v32.TokenScope) error {
+	for i, scope := range scopes {
+		if len(scope.Verbs) == 0 {
+			return fmt.Errorf("scope %d has no verbs", i)
+		}
+		for _, verb := range scope.Verbs {
+			if !scopeVerbs[verb] {
+				return fmt.Errorf("scope %d has invalid verb %q", i, verb)
+			}
+		}
+	}
+	return nil
+}
+
+// derivedScopes returns the scopes of a token created with the parent token. A scoped
+// token can only create tokens with the same scopes, otherwise it could escape them.
+func derivedScopes(parent *v3.Token, requested []v32.TokenScope) ([]v32.TokenScope, error) {
+	if len(parent.Scopes) == 0 {
+		return requested, ValidateScopes(requested)
+	}
+	if len(requested) > 0 && !reflect.DeepEqual(parent.Scopes, requested) {
+		return nil, fmt.Errorf("a scoped token can only create tokens with the same scopes")
+	}
+	return parent.Scopes, nil
+}
+
+func toTokenScopes(scopes []clientv3.TokenScope) []v32.TokenScope {
+	var result []v32.TokenScope
+	for _, scope := range scopes {
+		result = append(result, v32.TokenScope{
+			Verbs:      scope.Verbs,
+			APIGroups:  scope.APIGroups,
+			Resources:  scope.Resources,
+			ClusterID:  scope.ClusterID,
+			ProjectID:  scope.ProjectID,
+			Namespaces: scope.Namespaces,
+		})
+	}
+	return result
+}
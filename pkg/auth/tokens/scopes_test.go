@@ -0,0 +1,94 @@
+package tokens
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/util"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopesAllow(t *testing.T) {
+	readOnlyClusters := []v32.TokenScope{{
+		Verbs:     []string{"get", "list"},
+		Resources: []string{"clusters"},
+	}}
+	projectWorkloads := []v32.TokenScope{{
+		Verbs:     []string{"*"},
+		Resources: []string{"workloads"},
+		ProjectID: "c-abcde:p-fghij",
+	}}
+	allClusters := []v32.TokenScope{{
+		Verbs:     []string{"*"},
+		Resources: []string{"*"},
+	}}
+	clusterPods := []v32.TokenScope{{
+		Verbs:      []string{"get", "list", "watch"},
+		APIGroups:  []string{""},
+		Resources:  []string{"pods"},
+		ClusterID:  "c-abcde",
+		Namespaces: []string{"default"},
+	}}
+
+	tests := []struct {
+		name   string
+		scopes []v32.TokenScope
+		method string
+		path   string
+		allow  bool
+	}{
+		{"unscoped token", nil, http.MethodDelete, "/v3/clusters/c-abcde", true},
+		{"list clusters", readOnlyClusters, http.MethodGet, "/v3/clusters", true},
+		{"get cluster", readOnlyClusters, http.MethodGet, "/v3/clusters/c-abcde", true},
+		{"delete cluster", readOnlyClusters, http.MethodDelete, "/v3/clusters/c-abcde", false},
+		{"list users", readOnlyClusters, http.MethodGet, "/v3/users", false},
+		{"non resource request", readOnlyClusters, http.MethodGet, "/healthz", false},
+		{"create workload", projectWorkloads, http.MethodPost, "/v3/project/c-abcde:p-fghij/workloads", true},
+		{"create workload in other project", projectWorkloads, http.MethodPost, "/v3/project/c-abcde:p-other/workloads", false},
+		{"list pods", clusterPods, http.MethodGet, "/k8s/clusters/c-abcde/api/v1/namespaces/default/pods", true},
+		{"list pods in other namespace", clusterPods, http.MethodGet, "/k8s/clusters/c-abcde/api/v1/namespaces/kube-system/pods", false},
+		{"list pods in all namespaces", clusterPods, http.MethodGet, "/k8s/clusters/c-abcde/api/v1/pods", false},
+		{"list pods in other cluster", clusterPods, http.MethodGet, "/k8s/clusters/c-other/api/v1/namespaces/default/pods", false},
+		{"delete pod", clusterPods, http.MethodDelete, "/k8s/clusters/c-abcde/api/v1/namespaces/default/pods/nginx", false},
+		{"generate kubeconfig", allClusters, http.MethodPost, "/v3/clusters/c-abcde?action=generateKubeconfig", false},
+		{"generate kubeconfig unscoped", nil, http.MethodPost, "/v3/clusters/c-abcde?action=generateKubeconfig", true},
+		{"update cluster", allClusters, http.MethodPut, "/v3/clusters/c-abcde", true},
+		{"shell", allClusters, http.MethodGet, "/v3/clusters/c-abcde?shell=true", false},
+		{"shell with read-only scope", readOnlyClusters, http.MethodGet, "/v3/clusters/c-abcde?shell=true", false},
+		{"steve shell", allClusters, http.MethodGet, "/v1/management.cattle.io.clusters/local?link=shell", false},
+		{"machine ssh shell", allClusters, http.MethodGet, "/v1/cluster.x-k8s.io.machines/fleet-default/pool-1-abcde?link=shell", false},
+		{"machine ssh keys", allClusters, http.MethodGet, "/v1/cluster.x-k8s.io.machines/fleet-default/pool-1-abcde?link=sshkeys", false},
+		{"get machine", allClusters, http.MethodGet, "/v1/cluster.x-k8s.io.machines/fleet-default/pool-1-abcde", true},
+		{"node ssh keys", allClusters, http.MethodGet, "/v3/nodes/c-abcde:m-fghij/nodeconfig", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &v3.Token{Scopes: tt.scopes}
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			assert.Equal(t, tt.allow, ScopesAllow(token, util.GetRequestAttributes(req)))
+		})
+	}
+}
+
+func TestDerivedScopes(t *testing.T) {
+	assert := assert.New(t)
+	scopes := []v32.TokenScope{{Verbs: []string{"get"}, Resources: []string{"clusters"}}}
+
+	result, err := derivedScopes(&v3.Token{}, scopes)
+	assert.Nil(err)
+	assert.Equal(scopes, result)
+
+	_, err = derivedScopes(&v3.Token{}, []v32.TokenScope{{Verbs: []string{"escalate"}}})
+	assert.Error(err)
+
+	result, err = derivedScopes(&v3.Token{Scopes: scopes}, nil)
+	assert.Nil(err)
+	assert.Equal(scopes, result, "tokens created by a scoped token inherit its scopes")
+
+	_, err = derivedScopes(&v3.Token{Scopes: scopes}, []v32.TokenScope{{Verbs: []string{"*"}}})
+	assert.Error(err)
+}
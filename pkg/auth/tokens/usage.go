@@ -0,0 +1,238 @@
+package tokens
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rancher/norman/httperror"
+	"github.com/rancher/norman/types"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/util"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// usageFlushInterval is how often the recorded usage is written to the tokens.
+	usageFlushInterval = time.Minute
+	// usageResolution is how old the last use of a token has to be before a request
+	// records it again, so busy tokens aren't updated on every flush.
+	usageResolution = 5 * time.Minute
+)
+
+var (
+	usageTrackerOnce sync.Once
+	usageTracker     *UsageTracker
+)
+
+type tokenUsage struct {
+	at   time.Time
+	from string
+}
+
+// UsageTracker records when and from where tokens are used and writes it to the tokens
+// in batches instead of updating a token on every request.
+type UsageTracker struct {
+	sync.Mutex
+	tokens      v3.TokenInterface
+	tokenLister v3.TokenLister
+	pending     map[string]tokenUsage
+}
+
+// GetUsageTracker returns the usage tracker shared by all authenticators of the server. It
+// writes the recorded usage until the context of the first caller is done.
+func GetUsageTracker(ctx context.Context, apiContext *config.ScaledContext) *UsageTracker {
+	usageTrackerOnce.Do(func() {
+		usageTracker = &UsageTracker{
+			tokens:      apiContext.Management.Tokens(""),
+			tokenLister: apiContext.Management.Tokens("").Controller().Lister(),
+			pending:     map[string]tokenUsage{},
+		}
+		go wait.Until(usageTracker.flush, usageFlushInterval, ctx.Done())
+	})
+	return usageTracker
+}
+
+// Record records the use of the token by the request.
+func (u *UsageTracker) Record(token *v3.Token, req *http.Request) {
+	u.record(token, util.GetSourceIP(req), time.Now())
+}
+
+func (u *UsageTracker) record(token *v3.Token, from string, now time.Time) {
+	if lastUsed, err := time.Parse(time.RFC3339, token.LastUsedAt); err == nil &&
+		token.LastUsedFrom == from && now.Sub(lastUsed) < usageResolution {
+		return
+	}
+
+	u.Lock()
+	u.pending[token.Name] = tokenUsage{
+		at:   now,
+		from: from,
+	}
+	u.Unlock()
+}
+
+func (u *UsageTracker) flush() {
+	u.Lock()
+	pending := u.pending
+	u.pending = map[string]tokenUsage{}
+	u.Unlock()
+
+	for name, usage := range pending {
+		if err := u.update(name, usage); err != nil && !apierrors.IsNotFound(err) {
+			// a failed update is recorded again by the next request with the token
+			logrus.Debugf("Failed to update last use of token %s: %v", name, err)
+		}
+	}
+}
+
+func (u *UsageTracker) update(name string, usage tokenUsage) error {
+	token, err := u.tokenLister.Get("", name)
+	if err != nil {
+		return err
+	}
+	token = token.DeepCopy()
+	token.LastUsedAt = usage.at.UTC().Format(time.RFC3339)
+	token.LastUsedFrom = usage.from
+	_, err = u.tokens.Update(token)
+	return err
+}
+
+// lastUsed returns when the token was last used, tokens that have not been used since
+// usage tracking began count from the start of the tracking.
+func lastUsed(token *v3.Token, trackingStart time.Time) time.Time {
+	if t, err := time.Parse(time.RFC3339, token.LastUsedAt); err == nil {
+		return t
+	}
+	return trackingStart
+}
+
+// isIdle reports whether the token has not been used for longer than the idle timeout.
+// Only session and API tokens expire when idle, tokens labeled with any other kind are
+// created by rancher for its own components.
+func isIdle(token *v3.Token, timeout time.Duration, trackingStart, now time.Time) bool {
+	if timeout <= 0 {
+		return false
+	}
+	if kind := token.Labels[TokenKindLabel]; kind != "" && kind != "session" {
+		return false
+	}
+	return now.Sub(lastUsed(token, trackingStart)) > timeout
+}
+
+// usageTrackingStart returns when rancher began to track the use of tokens, it is now until
+// the purge daemon recorded it.
+func usageTrackingStart(now time.Time) time.Time {
+	if t, err := time.Parse(time.RFC3339, settings.TokenUsageTrackingStarted.Get()); err == nil {
+		return t
+	}
+	return now
+}
+
+// recordUsageTrackingStart records the start of usage tracking once, tokens created before
+// it that are never used become idle counting from then instead of from their creation.
+func recordUsageTrackingStart(now time.Time) {
+	if settings.TokenUsageTrackingStarted.Get() != "" {
+		return
+	}
+	if err := settings.TokenUsageTrackingStarted.SetIfUnset(now.UTC().Format(time.RFC3339)); err != nil {
+		logrus.Errorf("Failed to record the start of token usage tracking: %v", err)
+	}
+}
+
+func idleTimeout() time.Duration {
+	return time.Duration(settings.TokenIdleTimeoutDays.GetInt()) * 24 * time.Hour
+}
+
+func (m *Manager) tokenUsage(request *types.APIContext, allUsers bool) error {
+	data, err := ioutil.ReadAll(request.Request.Body)
+	if err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, fmt.Sprintf("failed to read request body: %v", err))
+	}
+	input := &v32.TokenUsageInput{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, input); err != nil {
+			return httperror.NewAPIError(httperror.InvalidBodyContent, fmt.Sprintf("failed to parse input: %v", err))
+		}
+	}
+
+	tokenAuthValue := GetTokenAuthFromRequest(request.Request)
+	if tokenAuthValue == "" {
+		return httperror.NewAPIErrorLong(http.StatusUnauthorized, util.GetHTTPErrorCode(http.StatusUnauthorized), "No valid token cookie or auth header")
+	}
+	currentToken, status, err := m.getToken(tokenAuthValue)
+	if err != nil {
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		return httperror.NewAPIErrorLong(status, util.GetHTTPErrorCode(status), fmt.Sprintf("%v", err))
+	}
+
+	selector := labels.Everything()
+	if !allUsers {
+		selector = labels.Set{UserIDLabel: currentToken.UserID}.AsSelector()
+	}
+	tokens, err := m.tokenLister.List("", selector)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	report := usageReport(tokens, time.Duration(input.IdleDays)*24*time.Hour, usageTrackingStart(now), now)
+	request.WriteResponse(http.StatusOK, map[string]interface{}{
+		"type":  "tokenUsageOutput",
+		"users": report.Users,
+	})
+	return nil
+}
+
+// usageReport groups the tokens that have not been used for at least idle by user, the
+// tokens of a user are sorted from the least recently used.
+func usageReport(tokens []*v3.Token, idle time.Duration, trackingStart, now time.Time) v32.TokenUsageOutput {
+	byUser := map[string][]*v3.Token{}
+	for _, token := range tokens {
+		if now.Sub(lastUsed(token, trackingStart)) < idle {
+			continue
+		}
+		byUser[token.UserID] = append(byUser[token.UserID], token)
+	}
+
+	report := v32.TokenUsageOutput{
+		Users: []v32.UserTokenUsage{},
+	}
+	for userID, userTokens := range byUser {
+		sort.Slice(userTokens, func(i, j int) bool {
+			return lastUsed(userTokens[i], trackingStart).Before(lastUsed(userTokens[j], trackingStart))
+		})
+		usage := v32.UserTokenUsage{
+			UserID: userID,
+		}
+		for _, token := range userTokens {
+			usage.Tokens = append(usage.Tokens, v32.TokenUsage{
+				Name:         token.Name,
+				Description:  token.Description,
+				Kind:         token.Labels[TokenKindLabel],
+				IsDerived:    token.IsDerived,
+				ExpiresAt:    token.ExpiresAt,
+				LastUsedAt:   token.LastUsedAt,
+				LastUsedFrom: token.LastUsedFrom,
+			})
+		}
+		report.Users = append(report.Users, usage)
+	}
+	sort.Slice(report.Users, func(i, j int) bool {
+		return report.Users[i].UserID < report.Users[j].UserID
+	})
+	return report
+}
@@ -0,0 +1,109 @@
+package tokens
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func usageToken(name, userID, kind string, created time.Time, lastUsedAt string) *v3.Token {
+	token := &v3.Token{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created),
+			Labels:            map[string]string{},
+		},
+		UserID:     userID,
+		LastUsedAt: lastUsedAt,
+	}
+	if kind != "" {
+		token.Labels[TokenKindLabel] = kind
+	}
+	return token
+}
+
+func TestUsageTrackerRecord(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	u := &UsageTracker{pending: map[string]tokenUsage{}}
+
+	recent := usageToken("recent", "u-1", "", now, now.Add(-time.Minute).Format(time.RFC3339))
+	recent.LastUsedFrom = "10.0.0.1"
+	u.record(recent, "10.0.0.1", now)
+	assert.Empty(u.pending, "recent use from the same address is not recorded again")
+
+	u.record(recent, "10.0.0.2", now)
+	assert.Equal(tokenUsage{at: now, from: "10.0.0.2"}, u.pending["recent"])
+
+	stale := usageToken("stale", "u-1", "", now, now.Add(-time.Hour).Format(time.RFC3339))
+	stale.LastUsedFrom = "10.0.0.1"
+	u.record(stale, "10.0.0.1", now)
+	assert.Contains(u.pending, "stale")
+}
+
+func TestIsIdle(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	timeout := 30 * 24 * time.Hour
+	longAgo := now.Add(-60 * 24 * time.Hour)
+	trackingStart := now.Add(-40 * 24 * time.Hour)
+
+	assert.True(isIdle(usageToken("api", "u-1", "", longAgo, ""), timeout, trackingStart, now))
+	assert.True(isIdle(usageToken("session", "u-1", "session", longAgo, ""), timeout, trackingStart, now))
+	assert.False(isIdle(usageToken("used", "u-1", "", longAgo, now.Add(-time.Hour).Format(time.RFC3339)), timeout, trackingStart, now))
+	assert.False(isIdle(usageToken("agent", "u-1", "agent", longAgo, ""), timeout, trackingStart, now), "system tokens never expire when idle")
+	assert.False(isIdle(usageToken("api", "u-1", "", longAgo, ""), 0, trackingStart, now), "a zero timeout disables idle expiry")
+	assert.False(isIdle(usageToken("api", "u-1", "", longAgo, ""), timeout, now.Add(-10*24*time.Hour), now),
+		"tokens that were not used since tracking began count from the start of the tracking, not their creation")
+}
+
+func TestUsageTrackingStart(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	old := settings.TokenUsageTrackingStarted.Get()
+	defer settings.TokenUsageTrackingStarted.Set(old)
+
+	assert.NoError(settings.TokenUsageTrackingStarted.Set(""))
+	assert.Equal(now, usageTrackingStart(now), "nothing is idle before tracking began")
+
+	recordUsageTrackingStart(now.Add(-time.Hour))
+	recordUsageTrackingStart(now)
+	assert.Equal(now.Add(-time.Hour), usageTrackingStart(now), "the start is recorded once")
+}
+
+func TestUsageReport(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tokens := []*v3.Token{
+		usageToken("b-recent", "u-b", "", now.Add(-10*day), now.Add(-time.Hour).Format(time.RFC3339)),
+		usageToken("b-stale", "u-b", "", now.Add(-100*day), now.Add(-50*day).Format(time.RFC3339)),
+		usageToken("b-never", "u-b", "", now.Add(-90*day), ""),
+		usageToken("a-stale", "u-a", "", now.Add(-100*day), now.Add(-40*day).Format(time.RFC3339)),
+	}
+
+	trackingStart := now.Add(-60 * day)
+	report := usageReport(tokens, 0, trackingStart, now)
+	assert.Len(report.Users, 2)
+	assert.Equal("u-a", report.Users[0].UserID)
+	assert.Equal("u-b", report.Users[1].UserID)
+	var names []string
+	for _, token := range report.Users[1].Tokens {
+		names = append(names, token.Name)
+	}
+	assert.Equal([]string{"b-never", "b-stale", "b-recent"}, names, "least recently used tokens come first")
+
+	report = usageReport(tokens, 45*day, trackingStart, now)
+	assert.Len(report.Users, 1)
+	assert.Len(report.Users[0].Tokens, 2, "b-never counts from the start of the tracking")
+
+	report = usageReport(tokens, 45*day, now, now)
+	assert.Len(report.Users, 1)
+	assert.Len(report.Users[0].Tokens, 1, "never used tokens are not idle before tracking began")
+	assert.Equal("b-stale", report.Users[0].Tokens[0].Name)
+}
@@ -2,8 +2,13 @@
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
 )
 
 var (
@@ -53,6 +58,70 @@ func GetHost(req *http.Request) string {
 	return host
 }
 
+// GetSourceIP returns the address of the client. The X-Forwarded-For header is only used
+// for requests from the proxies of the trusted-proxies setting, the client is the last
+// address in the header that is not one of these proxies.
+func GetSourceIP(req *http.Request) string {
+	remote, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		remote = req.RemoteAddr
+	}
+
+	trusted := trustedProxies()
+	if !isTrustedProxy(remote, trusted) {
+		return remote
+	}
+
+	forwarded := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if addr == "" {
+			continue
+		}
+		if !isTrustedProxy(addr, trusted) || i == 0 {
+			return addr
+		}
+	}
+	return remote
+}
+
+func trustedProxies() []*net.IPNet {
+	var result []*net.IPNet
+	for _, value := range strings.Split(settings.TrustedProxies.Get(), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, cidr, err := net.ParseCIDR(value)
+		if err != nil {
+			logrus.Warnf("Ignoring invalid trusted proxy %s: %v", value, err)
+			continue
+		}
+		result = append(result, cidr)
+	}
+	return result
+}
+
+func isTrustedProxy(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range trusted {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 //AuthError structure contains the error resource definition
 type AuthError struct {
 	Type    string `json:"type"`
@@ -0,0 +1,42 @@
+package util
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSourceIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{"no proxy", "", "203.0.113.7:51234", nil, "203.0.113.7"},
+		{"untrusted forwarded header", "", "203.0.113.7:51234", []string{"10.1.1.1"}, "203.0.113.7"},
+		{"untrusted proxy", "10.0.0.0/8", "203.0.113.7:51234", []string{"10.1.1.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.0/8", "10.0.0.2:443", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"trusted proxy address", "10.0.0.2", "10.0.0.2:443", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofed by client", "10.0.0.0/8", "10.0.0.2:443", []string{"1.2.3.4, 203.0.113.7"}, "203.0.113.7"},
+		{"chain of trusted proxies", "10.0.0.0/8", "10.0.0.2:443", []string{"203.0.113.7, 10.0.0.3"}, "203.0.113.7"},
+		{"multiple headers", "10.0.0.0/8", "10.0.0.2:443", []string{"1.2.3.4", "203.0.113.7"}, "203.0.113.7"},
+		{"only proxies", "10.0.0.0/8", "10.0.0.2:443", []string{"10.0.0.4, 10.0.0.3"}, "10.0.0.4"},
+		{"trusted proxy without header", "10.0.0.0/8", "10.0.0.2:443", nil, "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, settings.TrustedProxies.Set(tt.trusted))
+			req := httptest.NewRequest("GET", "/v3/tokens", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			assert.Equal(t, tt.expected, GetSourceIP(req))
+		})
+	}
+}
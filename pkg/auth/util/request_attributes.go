@@ -0,0 +1,166 @@
+package util
+
+import (
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const managementGroup = "management.cattle.io"
+
+var k8sRequestInfoFactory = &request.RequestInfoFactory{
+	APIPrefixes:          sets.NewString("api", "apis"),
+	GrouplessAPIPrefixes: sets.NewString("api"),
+}
+
+// RequestAttributes describe what a request does independent of the API it was sent to.
+type RequestAttributes struct {
+	// Verb is one of get, list, watch, create, update, patch or delete.
+	Verb string
+	Path string
+	// IsResource is false for requests that don't target an API resource, like /healthz.
+	IsResource bool
+	// APIGroup is the API group of the resource, requests to /v3 are in the management.cattle.io group.
+	APIGroup string
+	// Resource is the resource type as it appears in the URL, e.g. settings or management.cattle.io.settings for /v1.
+	Resource string
+	// ClusterID is set for requests proxied to a downstream cluster and cluster or project scoped /v3 requests.
+	ClusterID string
+	// ProjectID is the <cluster>:<project> id of project scoped /v3 requests.
+	ProjectID string
+	Namespace string
+	// IssuesCredentials is set for requests that hand out new credentials of the user or
+	// access that goes beyond the API, a kubeconfig with a new token, a kubectl shell or an
+	// SSH shell or the SSH keys of a machine.
+	IssuesCredentials bool
+}
+
+// GetRequestAttributes works out the verb, API group and resource of a request to the
+// Kubernetes API (directly or proxied via /k8s/clusters), the steve API (/v1) or the norman API (/v3).
+func GetRequestAttributes(req *http.Request) *RequestAttributes {
+	path := req.URL.Path
+	attrs := &RequestAttributes{
+		Path: path,
+	}
+
+	if strings.HasPrefix(path, "/k8s/clusters/") {
+		parts := strings.SplitN(strings.TrimPrefix(path, "/k8s/clusters/"), "/", 2)
+		attrs.ClusterID = parts[0]
+		path = "/"
+		if len(parts) == 2 {
+			path += parts[1]
+		}
+	}
+
+	if strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/apis/") {
+		k8sReq := req.Clone(req.Context())
+		k8sReq.URL.Path = path
+		info, err := k8sRequestInfoFactory.NewRequestInfo(k8sReq)
+		if err == nil && info.IsResourceRequest {
+			attrs.IsResource = true
+			attrs.Verb = info.Verb
+			if IsWebsocket(req) {
+				attrs.Verb = "watch"
+			}
+			attrs.APIGroup = info.APIGroup
+			attrs.Resource = info.Resource
+			attrs.Namespace = info.Namespace
+			return attrs
+		}
+	}
+
+	var (
+		parts   []string
+		hasName bool
+		// link is the link of a resource, /v3 links are part of the path and /v1 links are
+		// in the link query parameter
+		link = req.URL.Query().Get("link")
+	)
+	if strings.HasPrefix(path, "/v3/") {
+		parts = strings.Split(strings.TrimPrefix(path, "/v3/"), "/")
+		attrs.IsResource = true
+		attrs.APIGroup = managementGroup
+		switch {
+		case (parts[0] == "cluster" || parts[0] == "project") && len(parts) > 2:
+			// cluster and project scoped resources, e.g. /v3/project/c-abcde:p-fghij/workloads
+			if parts[0] == "cluster" {
+				attrs.ClusterID = parts[1]
+			} else {
+				attrs.ProjectID = parts[1]
+				attrs.ClusterID = strings.SplitN(parts[1], ":", 2)[0]
+			}
+			parts = parts[2:]
+		case parts[0] == "clusters" && len(parts) > 1:
+			attrs.ClusterID = parts[1]
+		case parts[0] == "projects" && len(parts) > 1:
+			attrs.ProjectID = parts[1]
+			attrs.ClusterID = strings.SplitN(parts[1], ":", 2)[0]
+		}
+		attrs.Resource = parts[0]
+		link = ""
+		if len(parts) > 2 {
+			link = parts[2]
+		}
+	} else if strings.HasPrefix(path, "/v1/") {
+		parts = strings.Split(strings.TrimPrefix(path, "/v1/"), "/")
+		attrs.IsResource = true
+		attrs.Resource = parts[0]
+		if i := strings.LastIndex(parts[0], "."); i > 0 {
+			attrs.APIGroup = parts[0][:i]
+		}
+		if len(parts) > 2 {
+			attrs.Namespace = parts[1]
+		}
+	}
+	hasName = len(parts) > 1 && parts[1] != ""
+	attrs.Verb = getVerb(req, hasName)
+	attrs.IssuesCredentials = hasName && issuesCredentials(req, attrs.Resource, link)
+
+	return attrs
+}
+
+// issuesCredentials reports whether a request generates a kubeconfig or opens a kubectl shell
+// for a cluster, opens an SSH shell to a machine or downloads its SSH keys.
+func issuesCredentials(req *http.Request, resource, link string) bool {
+	query := req.URL.Query()
+	switch resource {
+	case "clusters", managementGroup + ".clusters":
+		// the norman kubectl shell is /v3/clusters/<id>?shell=true
+		return strings.EqualFold(query.Get("action"), "generateKubeconfig") || strings.EqualFold(link, "shell") ||
+			query.Get("shell") == "true"
+	case "cluster.x-k8s.io.machines":
+		return strings.EqualFold(link, "shell") || strings.EqualFold(link, "sshkeys")
+	case "nodes":
+		// the SSH keys of a node are /v3/nodes/<id>/nodeconfig
+		return strings.EqualFold(link, "nodeConfig")
+	}
+	return false
+}
+
+func getVerb(req *http.Request, hasName bool) string {
+	if IsWebsocket(req) || req.URL.Query().Get("watch") == "true" {
+		return "watch"
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		if hasName {
+			return "get"
+		}
+		return "list"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		return "delete"
+	}
+	return strings.ToLower(req.Method)
+}
+
+func IsWebsocket(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Upgrade"), "websocket")
+}
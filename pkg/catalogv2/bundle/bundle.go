@@ -0,0 +1,278 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/slice"
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ManifestFile lists the files of a bundle with their digests, it is the signed part of
+	// a bundle.
+	ManifestFile = "bundle.json"
+	// SignatureFile is the signature of the manifest.
+	SignatureFile = "bundle.json.sig"
+	// IndexFile is the index of the exported repo, the URLs of its charts and icons are paths
+	// in the bundle.
+	IndexFile = "index.yaml"
+
+	// MaxSize is the maximum size of a bundle archive.
+	MaxSize = 64 << 20
+	// maxExtractedSize limits the size of the files read from an archive.
+	maxExtractedSize = 4 * MaxSize
+)
+
+// Manifest describes the content of a bundle.
+type Manifest struct {
+	// Repo is the name of the exported repo
+	Repo    string    `json:"repo"`
+	Created time.Time `json:"created"`
+	// Files are the digests of the files of the bundle by their path
+	Files map[string]string `json:"files"`
+}
+
+// Writer collects the charts and icons of a bundle.
+type Writer struct {
+	repo  string
+	files map[string][]byte
+}
+
+func NewWriter(repoName string) *Writer {
+	return &Writer{
+		repo:  repoName,
+		files: map[string][]byte{},
+	}
+}
+
+// AddChart adds the archive of a chart version and points the URL of the version at it.
+func (w *Writer) AddChart(chart *repo.ChartVersion, data []byte) {
+	name := fmt.Sprintf("charts/%s-%s.tgz", chart.Name, chart.Version)
+	w.files[name] = data
+	chart.URLs = []string{name}
+}
+
+// AddIcon adds the icon of a chart version and points the icon of the version at it. Icons
+// are stored by their digest so the versions of a chart share them.
+func (w *Writer) AddIcon(chart *repo.ChartVersion, suffix string, data []byte) {
+	name := "icons/" + hex.EncodeToString(digest(data)) + suffix
+	w.files[name] = data
+	chart.Icon = name
+}
+
+// Write writes the bundle with the index as gzipped tar archive signed with the key.
+func (w *Writer) Write(out io.Writer, index *repo.IndexFile, key *ecdsa.PrivateKey) error {
+	indexData, err := yaml.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		IndexFile: indexData,
+	}
+	manifest := Manifest{
+		Repo:    w.repo,
+		Created: time.Now().UTC(),
+		Files: map[string]string{
+			IndexFile: "sha256:" + hex.EncodeToString(digest(indexData)),
+		},
+	}
+	var (
+		names []string
+		size  = len(indexData)
+	)
+	for name, data := range w.files {
+		size += len(data)
+		files[name] = data
+		manifest.Files[name] = "sha256:" + hex.EncodeToString(digest(data))
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if size > MaxSize {
+		return fmt.Errorf("bundle is larger than %d bytes, export fewer charts", MaxSize)
+	}
+
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest(manifestData))
+	if err != nil {
+		return err
+	}
+	files[ManifestFile] = manifestData
+	files[SignatureFile] = signature
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	// the manifest and the index come first so they can be read without reading the charts
+	for _, name := range append([]string{ManifestFile, SignatureFile, IndexFile}, names...) {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(files[name])),
+			ModTime:  manifest.Created,
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Verify checks that the manifest of a bundle archive is signed by a key of the keyring and
+// that the archive has exactly the files of the manifest.
+func Verify(data []byte, keyring *verify.Keyring) (*Manifest, error) {
+	files, err := read(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := keyring.Signature(files[ManifestFile], files[SignatureFile]); err != nil {
+		return nil, fmt.Errorf("bundle is not signed by a trusted key: %w", err)
+	}
+
+	manifest, err := parseManifest(files[ManifestFile])
+	if err != nil {
+		return nil, err
+	}
+	for name, data := range files {
+		if name == ManifestFile || name == SignatureFile {
+			continue
+		}
+		if err := manifest.check(name, data); err != nil {
+			return nil, err
+		}
+	}
+	for name := range manifest.Files {
+		if _, ok := files[name]; !ok {
+			return nil, fmt.Errorf("bundle is missing file %s", name)
+		}
+	}
+	if _, ok := manifest.Files[IndexFile]; !ok {
+		return nil, fmt.Errorf("bundle has no %s", IndexFile)
+	}
+	return manifest, nil
+}
+
+// ReadFile returns a file of a bundle archive after checking it against the manifest.
+func ReadFile(data []byte, name string) ([]byte, error) {
+	files, err := read(data, []string{ManifestFile, name})
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := parseManifest(files[ManifestFile])
+	if err != nil {
+		return nil, err
+	}
+	file, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("failed to find %s in bundle: %w", name, validation.NotFound)
+	}
+	return file, manifest.check(name, file)
+}
+
+// Index returns the index of a bundle archive.
+func Index(data []byte) (*repo.IndexFile, error) {
+	indexData, err := ReadFile(data, IndexFile)
+	if err != nil {
+		return nil, err
+	}
+	index := &repo.IndexFile{}
+	if err := yaml.Unmarshal(indexData, index); err != nil {
+		return nil, fmt.Errorf("failed to parse index of bundle: %w", err)
+	}
+	if index.APIVersion == "" {
+		return nil, repo.ErrNoAPIVersion
+	}
+	return index, nil
+}
+
+// read returns the files of an archive by their name, only the named files are read if any
+// names are given.
+func read(data []byte, names []string) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	defer gz.Close()
+
+	var (
+		files = map[string][]byte{}
+		left  = int64(maxExtractedSize)
+		tr    = tar.NewReader(gz)
+	)
+	for len(names) == 0 || len(files) < len(names) {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid bundle: %w", err)
+		}
+		if !header.FileInfo().Mode().IsRegular() || (len(names) > 0 && !slice.ContainsString(names, header.Name)) {
+			continue
+		}
+		if _, ok := files[header.Name]; ok {
+			return nil, fmt.Errorf("invalid bundle, %s is in the bundle more than once", header.Name)
+		}
+		if header.Size > left {
+			return nil, errors.New("invalid bundle, content is too large")
+		}
+		file, err := ioutil.ReadAll(io.LimitReader(tr, header.Size))
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle: %w", err)
+		}
+		left -= int64(len(file))
+		files[header.Name] = file
+	}
+
+	if _, ok := files[ManifestFile]; !ok {
+		return nil, fmt.Errorf("invalid bundle, %s is missing", ManifestFile)
+	}
+	return files, nil
+}
+
+func parseManifest(data []byte) (*Manifest, error) {
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func (m *Manifest) check(name string, data []byte) error {
+	expected, ok := m.Files[name]
+	if !ok {
+		return fmt.Errorf("%s is not in the bundle manifest", name)
+	}
+	if actual := "sha256:" + hex.EncodeToString(digest(data)); actual != expected {
+		return fmt.Errorf("digest of %s does not match the bundle manifest", name)
+	}
+	return nil
+}
+
+func digest(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
@@ -0,0 +1,125 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newKey(t *testing.T) (*ecdsa.PrivateKey, *verify.Keyring) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	keyring, err := verify.KeyringFromSecret(&corev1.Secret{Data: map[string][]byte{
+		verify.CosignKey: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+	}})
+	require.NoError(t, err)
+	return key, keyring
+}
+
+func newBundle(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	version := &repo.ChartVersion{
+		Metadata: &chart.Metadata{Name: "app", Version: "1.0.0", Icon: "https://example.com/app.png"},
+		URLs:     []string{"https://example.com/app-1.0.0.tgz"},
+	}
+	index := repo.NewIndexFile()
+	index.Entries["app"] = repo.ChartVersions{version}
+
+	w := NewWriter("charts")
+	w.AddChart(version, []byte("chart"))
+	w.AddIcon(version, ".png", []byte("icon"))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, w.Write(buf, index, key))
+	return buf.Bytes()
+}
+
+func TestBundle(t *testing.T) {
+	key, keyring := newKey(t)
+	data := newBundle(t, key)
+
+	manifest, err := Verify(data, keyring)
+	require.NoError(t, err)
+	assert.Equal(t, "charts", manifest.Repo)
+	assert.Len(t, manifest.Files, 3)
+
+	index, err := Index(data)
+	require.NoError(t, err)
+	version, err := index.Get("app", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"charts/app-1.0.0.tgz"}, version.URLs)
+
+	chartData, err := ReadFile(data, version.URLs[0])
+	require.NoError(t, err)
+	assert.Equal(t, []byte("chart"), chartData)
+	iconData, err := ReadFile(data, version.Icon)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("icon"), iconData)
+
+	_, err = ReadFile(data, "charts/missing-1.0.0.tgz")
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	key, keyring := newKey(t)
+	_, otherKeyring := newKey(t)
+	data := newBundle(t, key)
+
+	_, err := Verify(data, otherKeyring)
+	assert.Error(t, err, "signed by an untrusted key")
+
+	tampered := rewrite(t, data, func(name string, content []byte) []byte {
+		if name == "charts/app-1.0.0.tgz" {
+			return []byte("modified")
+		}
+		return content
+	})
+	_, err = Verify(tampered, keyring)
+	assert.Error(t, err, "chart was modified")
+	_, err = ReadFile(tampered, "charts/app-1.0.0.tgz")
+	assert.Error(t, err, "chart was modified")
+}
+
+// rewrite returns a copy of a bundle archive with the content of its files replaced.
+func rewrite(t *testing.T, data []byte, replace func(name string, content []byte) []byte) []byte {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		content = replace(header.Name, content)
+		header.Size = int64(len(content))
+		require.NoError(t, tw.WriteHeader(header))
+		_, err = tw.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+	return buf.Bytes()
+}
@@ -0,0 +1,154 @@
+package bundle
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/rancher/rancher/pkg/catalogv2"
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
+	namespaces "github.com/rancher/rancher/pkg/namespace"
+	corev1controllers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	name2 "github.com/rancher/wrangler/pkg/name"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// SigningKeySecretName is the secret in the cattle-system namespace with the key bundles
+	// are signed with. Its cosign.pub key can be used as keyring to import the bundles in
+	// another installation.
+	SigningKeySecretName = "catalog-bundle-signing-key"
+	signingKeyKey        = "key.pem"
+
+	chunkSize = 512 * 1024
+	// maxCachedBundles bounds the memory of the cache to a few times MaxSize
+	maxCachedBundles = 2
+)
+
+// bundleCache holds the bundles that were read last by the UID and resource version of
+// their first chunk, which changes whenever the bundle is stored again.
+var bundleCache, _ = lru.New(maxCachedBundles)
+
+// SigningKey returns the key bundles are signed with, the key is created the first time it
+// is used.
+func SigningKey(secrets corev1controllers.SecretClient) (*ecdsa.PrivateKey, error) {
+	secret, err := secrets.Get(namespaces.System, SigningKeySecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret, err = newSigningKeySecret()
+		if err != nil {
+			return nil, err
+		}
+		secret, err = secrets.Create(secret)
+		if apierrors.IsAlreadyExists(err) {
+			secret, err = secrets.Get(namespaces.System, SigningKeySecretName, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(secret.Data[signingKeyKey])
+	if block == nil {
+		return nil, fmt.Errorf("secret %s/%s has no %s", secret.Namespace, secret.Name, signingKeyKey)
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+func newSigningKeySecret() (*corev1.Secret, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyData, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	publicData, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SigningKeySecretName,
+			Namespace: namespaces.System,
+		},
+		Data: map[string][]byte{
+			signingKeyKey:    pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyData}),
+			verify.CosignKey: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicData}),
+		},
+	}, nil
+}
+
+// ConfigMapName returns the name of the config map a bundle of the repo is stored in.
+func ConfigMapName(repoName string) string {
+	return chunkName(repoName, 0)
+}
+
+func chunkName(repoName string, i int) string {
+	return name2.SafeConcatName("bundle", repoName, fmt.Sprint(i))
+}
+
+// Store stores a bundle in config maps of the cattle-system namespace that are owned by the
+// repo. Bundles are kept in etcd like the indexes of the repos because it is the only storage
+// all rancher replicas share, MaxSize limits what a bundle adds to the database and the
+// chunks are deleted with the repo.
+func Store(configMaps corev1controllers.ConfigMapClient, owner metav1.OwnerReference, data []byte) error {
+	chunks := catalogv2.Chunks(namespaces.System, func(i int) string {
+		return chunkName(owner.Name, i)
+	}, owner, data, chunkSize)
+
+	for _, chunk := range chunks {
+		_, err := configMaps.Create(chunk)
+		if apierrors.IsAlreadyExists(err) {
+			var existing *corev1.ConfigMap
+			existing, err = configMaps.Get(chunk.Namespace, chunk.Name, metav1.GetOptions{})
+			if err == nil {
+				chunk.ResourceVersion = existing.ResourceVersion
+				_, err = configMaps.Update(chunk)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// delete the chunks of a larger bundle that was stored before
+	for i := len(chunks); ; i++ {
+		err := configMaps.Delete(namespaces.System, chunkName(owner.Name, i), &metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Load returns the bundle stored in a config map, the config map must be owned by the repo.
+// The returned data is shared with other callers and must not be modified.
+func Load(configMaps corev1controllers.ConfigMapCache, name string, owner *metav1.ObjectMeta) ([]byte, error) {
+	cm, err := configMaps.Get(namespaces.System, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(cm.OwnerReferences) == 0 || cm.OwnerReferences[0].UID != owner.UID {
+		return nil, fmt.Errorf("bundle %s is not owned by repo %s: %w", name, owner.Name, validation.Unauthorized)
+	}
+
+	cacheKey := string(cm.UID) + "/" + cm.ResourceVersion
+	if data, ok := bundleCache.Get(cacheKey); ok {
+		return data.([]byte), nil
+	}
+	data, err := catalogv2.ReadChunks(configMaps, cm)
+	if err != nil {
+		return nil, err
+	}
+	bundleCache.Add(cacheKey, data)
+	return data, nil
+}
@@ -0,0 +1,108 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	namespaces "github.com/rancher/rancher/pkg/namespace"
+	corev1controllers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fakeConfigMaps is the config maps of the cattle-system namespace, it is the client that
+// stores bundles.
+type fakeConfigMaps struct {
+	corev1controllers.ConfigMapClient
+	configMaps map[string]*corev1.ConfigMap
+	version    int
+	reads      int
+}
+
+// fakeConfigMapCache is the cache bundles are loaded from.
+type fakeConfigMapCache struct {
+	corev1controllers.ConfigMapCache
+	*fakeConfigMaps
+}
+
+func (f *fakeConfigMaps) Create(cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	if _, ok := f.configMaps[cm.Name]; ok {
+		return nil, apierrors.NewAlreadyExists(schema.GroupResource{Resource: "configmaps"}, cm.Name)
+	}
+	return f.Update(cm)
+}
+
+func (f *fakeConfigMaps) Update(cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	f.version++
+	cm = cm.DeepCopy()
+	cm.UID = types.UID(cm.Name)
+	cm.ResourceVersion = fmt.Sprint(f.version)
+	f.configMaps[cm.Name] = cm
+	return cm, nil
+}
+
+func (f *fakeConfigMaps) Delete(namespace, name string, options *metav1.DeleteOptions) error {
+	if _, ok := f.configMaps[name]; !ok {
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+	}
+	delete(f.configMaps, name)
+	return nil
+}
+
+func (f *fakeConfigMaps) Get(namespace, name string, options metav1.GetOptions) (*corev1.ConfigMap, error) {
+	cm, ok := f.configMaps[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+	}
+	return cm, nil
+}
+
+func (f fakeConfigMapCache) Get(namespace, name string) (*corev1.ConfigMap, error) {
+	f.reads++
+	cm, ok := f.configMaps[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+	}
+	return cm, nil
+}
+
+func TestStoreAndLoad(t *testing.T) {
+	configMaps := &fakeConfigMaps{configMaps: map[string]*corev1.ConfigMap{}}
+	cache := fakeConfigMapCache{fakeConfigMaps: configMaps}
+	owner := metav1.OwnerReference{Name: "charts", UID: "repo-uid"}
+	repo := &metav1.ObjectMeta{Name: "charts", UID: "repo-uid"}
+
+	large := bytes.Repeat([]byte("a"), 3*chunkSize)
+	require.NoError(t, Store(configMaps, owner, large))
+	assert.Len(t, configMaps.configMaps, 3)
+
+	data, err := Load(cache, ConfigMapName("charts"), repo)
+	require.NoError(t, err)
+	assert.Equal(t, large, data)
+	reads := configMaps.reads
+
+	data, err = Load(cache, ConfigMapName("charts"), repo)
+	require.NoError(t, err)
+	assert.Equal(t, large, data)
+	assert.Equal(t, reads+1, configMaps.reads, "only the first chunk is read while the bundle doesn't change")
+
+	// a smaller bundle replaces all chunks of the larger one
+	small := []byte("small bundle")
+	require.NoError(t, Store(configMaps, owner, small))
+	assert.Len(t, configMaps.configMaps, 1)
+	assert.Contains(t, configMaps.configMaps, ConfigMapName("charts"))
+	assert.Equal(t, namespaces.System, configMaps.configMaps[ConfigMapName("charts")].Namespace)
+
+	data, err = Load(cache, ConfigMapName("charts"), repo)
+	require.NoError(t, err)
+	assert.Equal(t, small, data)
+
+	_, err = Load(cache, ConfigMapName("charts"), &metav1.ObjectMeta{Name: "charts", UID: "other"})
+	assert.Error(t, err, "a bundle is only read for the repo that owns it")
+}
@@ -0,0 +1,105 @@
+package catalogv2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	corev1controllers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	nextAnnotation   = "catalog.cattle.io/next"
+	sizeAnnotation   = "catalog.cattle.io/size"
+	chunksAnnotation = "catalog.cattle.io/chunks"
+	digestAnnotation = "catalog.cattle.io/digest"
+)
+
+// Chunks splits data into config maps of at most size bytes. Each chunk links to the next
+// one by name, name returns the name of the i-th chunk.
+func Chunks(namespace string, name func(i int) string, owner metav1.OwnerReference, data []byte, size int) []*corev1.ConfigMap {
+	// readers check the digest to detect a chunk that was read while the data was updated
+	digest := sha256.Sum256(data)
+
+	var (
+		result []*corev1.ConfigMap
+		left   []byte
+		i      = 0
+		total  = len(data)
+		chunks = (total + size - 1) / size
+	)
+
+	for {
+		if len(data) > size {
+			left = data[size:]
+			data = data[:size]
+		}
+
+		next := ""
+		if len(left) > 0 {
+			next = name(i + 1)
+		}
+
+		result = append(result, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name(i),
+				Namespace:       namespace,
+				OwnerReferences: []metav1.OwnerReference{owner},
+				Annotations: map[string]string{
+					nextAnnotation: next,
+					// Size ensure the resource version should update even if this is the head of a multipart chunk
+					sizeAnnotation:   fmt.Sprint(total),
+					chunksAnnotation: fmt.Sprint(chunks),
+					digestAnnotation: "sha256:" + hex.EncodeToString(digest[:]),
+				},
+			},
+			BinaryData: map[string][]byte{
+				"content": data,
+			},
+		})
+		if len(left) == 0 {
+			break
+		}
+
+		i++
+		data = left
+		left = nil
+	}
+
+	return result
+}
+
+// ReadChunks returns the data of the chunks starting at head.
+func ReadChunks(configMaps corev1controllers.ConfigMapCache, head *corev1.ConfigMap) ([]byte, error) {
+	var (
+		cm    = head
+		bytes = cm.BinaryData["content"]
+		err   error
+	)
+
+	for {
+		next := cm.Annotations[nextAnnotation]
+		if next == "" {
+			break
+		}
+		cm, err = configMaps.Get(cm.Namespace, next)
+		if err != nil {
+			return nil, err
+		}
+		bytes = append(bytes, cm.BinaryData["content"]...)
+	}
+
+	// a chunk can be read while the data is updated, the digest of the head only matches if
+	// all chunks are from the same version
+	if expected := head.Annotations[digestAnnotation]; expected != "" {
+		digest := sha256.Sum256(bytes)
+		if actual := "sha256:" + hex.EncodeToString(digest[:]); actual != expected {
+			return nil, fmt.Errorf("%s/%s is being updated: %w", head.Namespace, head.Name, validation.Conflict)
+		}
+	}
+
+	return bytes, nil
+}
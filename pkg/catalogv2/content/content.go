@@ -3,20 +3,27 @@
 import (
 	"bytes"
 	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/url"
-	"sync"
+	"path"
 
 	"github.com/Masterminds/semver/v3"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/rancher/rancher/pkg/api/steve/catalog/types"
 	v1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/catalogv2"
+	"github.com/rancher/rancher/pkg/catalogv2/bundle"
 	"github.com/rancher/rancher/pkg/catalogv2/git"
 	"github.com/rancher/rancher/pkg/catalogv2/helm"
 	helmhttp "github.com/rancher/rancher/pkg/catalogv2/http"
+	"github.com/rancher/rancher/pkg/catalogv2/oci"
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
 	catalogcontrollers "github.com/rancher/rancher/pkg/generated/controllers/catalog.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/settings"
 	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
@@ -33,26 +40,25 @@ type Manager struct {
 	secrets      corecontrollers.SecretCache
 	clusterRepos catalogcontrollers.ClusterRepoCache
 	discovery    discovery.DiscoveryInterface
-	IndexCache   map[string]indexCache
-	lock         sync.RWMutex
+	indexCache   *lru.Cache
 }
 
-type indexCache struct {
-	index    *repo.IndexFile
-	revision string
-}
+// maxCachedIndexes is the number of parsed indexes kept in memory, the least recently used
+// index is dropped first.
+const maxCachedIndexes = 20
 
 func NewManager(
 	discovery discovery.DiscoveryInterface,
 	configMaps corecontrollers.ConfigMapCache,
 	secrets corecontrollers.SecretCache,
 	clusterRepos catalogcontrollers.ClusterRepoCache) *Manager {
+	indexCache, _ := lru.New(maxCachedIndexes)
 	return &Manager{
 		discovery:    discovery,
 		configMaps:   configMaps,
 		secrets:      secrets,
 		clusterRepos: clusterRepos,
-		IndexCache:   map[string]indexCache{},
+		indexCache:   indexCache,
 	}
 }
 
@@ -77,28 +83,11 @@ func (c *Manager) getRepo(namespace, name string) (repoDef, error) {
 		}, nil
 	}
 
-	panic("namespace should never be empty")
+	return repoDef{}, fmt.Errorf("repo %s/%s: namespaced repos are not supported", namespace, name)
 }
 
 func (c *Manager) readBytes(cm *corev1.ConfigMap) ([]byte, error) {
-	var (
-		bytes = cm.BinaryData["content"]
-		err   error
-	)
-
-	for {
-		next := cm.Annotations["catalog.cattle.io/next"]
-		if next == "" {
-			break
-		}
-		cm, err = c.configMaps.Get(cm.Namespace, next)
-		if err != nil {
-			return nil, err
-		}
-		bytes = append(bytes, cm.BinaryData["content"]...)
-	}
-
-	return bytes, nil
+	return catalogv2.ReadChunks(c.configMaps, cm)
 }
 
 func (c *Manager) Index(namespace, name string) (*repo.IndexFile, error) {
@@ -117,14 +106,11 @@ func (c *Manager) Index(namespace, name string) (*repo.IndexFile, error) {
 		return nil, err
 	}
 
-	c.lock.RLock()
-	if cache, ok := c.IndexCache[fmt.Sprintf("%s/%s", r.status.IndexConfigMapNamespace, r.status.IndexConfigMapName)]; ok {
-		if cm.ResourceVersion == cache.revision {
-			c.lock.RUnlock()
-			return c.filterReleases(deepCopyIndex(cache.index), k8sVersion), nil
-		}
+	// the key changes when the repo is recreated or its index is written again
+	cacheKey := fmt.Sprintf("%s/%d/%s", r.metadata.UID, r.metadata.Generation, cm.ResourceVersion)
+	if index, ok := c.indexCache.Get(cacheKey); ok {
+		return c.filterReleases(deepCopyIndex(index.(*repo.IndexFile)), k8sVersion), nil
 	}
-	c.lock.RUnlock()
 
 	if len(cm.OwnerReferences) == 0 || cm.OwnerReferences[0].UID != r.metadata.UID {
 		return nil, validation.Unauthorized
@@ -151,12 +137,7 @@ func (c *Manager) Index(namespace, name string) (*repo.IndexFile, error) {
 		return nil, err
 	}
 
-	c.lock.Lock()
-	c.IndexCache[fmt.Sprintf("%s/%s", r.status.IndexConfigMapNamespace, r.status.IndexConfigMapName)] = indexCache{
-		index:    index,
-		revision: cm.ResourceVersion,
-	}
-	c.lock.Unlock()
+	c.indexCache.Add(cacheKey, index)
 
 	return c.filterReleases(deepCopyIndex(index), k8sVersion), nil
 }
@@ -262,6 +243,19 @@ func (c *Manager) Icon(namespace, name, chartName, version string) (io.ReadClose
 		return git.Icon(namespace, name, repo.status.URL, chart)
 	}
 
+	if !isHTTP(chart.Icon) && repo.spec.Bundle != "" {
+		icon, err := c.bundleFile(repo, chart.Icon)
+		if err != nil {
+			return nil, "", err
+		}
+		return ioutil.NopCloser(bytes.NewReader(icon)), path.Ext(chart.Icon), nil
+	}
+
+	// icons of OCI charts can only be served from a http URL
+	if !isHTTP(chart.Icon) && oci.IsOCI(repo.status.URL) {
+		return nil, "", fmt.Errorf("failed to find icon of chartName %s version %s: %w", chartName, version, validation.NotFound)
+	}
+
 	secret, err := catalogv2.GetSecret(c.secrets, repo.spec, repo.metadata.Namespace)
 	if err != nil {
 		return nil, "", err
@@ -295,14 +289,119 @@ func (c *Manager) Chart(namespace, name, chartName, version string) (io.ReadClos
 		return git.Chart(namespace, name, repo.status.URL, chart)
 	}
 
+	if repo.spec.Bundle != "" {
+		if len(chart.URLs) == 0 {
+			return nil, fmt.Errorf("failed to find chartName %s version %s: %w", chartName, version, validation.NotFound)
+		}
+		data, err := c.bundleFile(repo, chart.URLs[0])
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+
 	secret, err := catalogv2.GetSecret(c.secrets, repo.spec, repo.metadata.Namespace)
 	if err != nil {
 		return nil, err
 	}
 
+	if oci.IsOCI(repo.status.URL) {
+		return oci.Chart(secret, repo.spec.CABundle, repo.spec.InsecureSkipTLSverify, chart)
+	}
+
 	return helmhttp.Chart(secret, repo.status.URL, repo.spec.CABundle, repo.spec.InsecureSkipTLSverify, chart)
 }
 
+// ExportBundle writes a bundle with the index, chart archives and icons of chart versions of a
+// repo, signed with the key.
+func (c *Manager) ExportBundle(namespace, name string, charts []types.BundleChart, key *ecdsa.PrivateKey, w io.Writer) error {
+	index, err := c.Index(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	if len(charts) == 0 {
+		for chartName := range index.Entries {
+			charts = append(charts, types.BundleChart{ChartName: chartName})
+		}
+	}
+
+	var (
+		selected = repo.NewIndexFile()
+		seen     = map[string]bool{}
+	)
+	for _, chart := range charts {
+		versions, ok := index.Entries[chart.ChartName]
+		if !ok {
+			return fmt.Errorf("failed to find chartName %s: %w", chart.ChartName, validation.NotFound)
+		}
+		if chart.Version != "" {
+			version, err := index.Get(chart.ChartName, chart.Version)
+			if err != nil {
+				return fmt.Errorf("failed to find chartName %s version %s: %w", chart.ChartName, chart.Version, validation.NotFound)
+			}
+			versions = repo.ChartVersions{version}
+		}
+		for _, version := range versions {
+			key := chart.ChartName + "/" + version.Version
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			selected.Entries[chart.ChartName] = append(selected.Entries[chart.ChartName], version)
+		}
+	}
+	selected.SortEntries()
+
+	writer := bundle.NewWriter(name)
+	for chartName, versions := range selected.Entries {
+		for _, version := range versions {
+			if err := c.exportChart(writer, namespace, name, chartName, version); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Write(w, selected, key)
+}
+
+func (c *Manager) exportChart(writer *bundle.Writer, namespace, name, chartName string, version *repo.ChartVersion) error {
+	chart, err := c.Chart(namespace, name, chartName, version.Version)
+	if err != nil {
+		return err
+	}
+	defer chart.Close()
+	data, err := ioutil.ReadAll(chart)
+	if err != nil {
+		return err
+	}
+
+	if version.Icon != "" {
+		// icons are only decoration, a chart is still exported without them
+		icon, suffix, err := c.Icon(namespace, name, chartName, version.Version)
+		if err != nil {
+			logrus.Debugf("failed to export icon of chartName %s version %s: %v", chartName, version.Version, err)
+		} else {
+			defer icon.Close()
+			iconData, err := ioutil.ReadAll(icon)
+			if err != nil {
+				return err
+			}
+			writer.AddIcon(version, suffix, iconData)
+		}
+	}
+
+	writer.AddChart(version, data)
+	return nil
+}
+
+func (c *Manager) bundleFile(repo repoDef, name string) ([]byte, error) {
+	data, err := bundle.Load(c.configMaps, repo.spec.Bundle, repo.metadata)
+	if err != nil {
+		return nil, err
+	}
+	return bundle.ReadFile(data, name)
+}
+
 func (c *Manager) Info(namespace, name, chartName, version string) (*types.ChartInfo, error) {
 	chart, err := c.Chart(namespace, name, chartName, version)
 	if err != nil {
@@ -312,3 +411,118 @@ func (c *Manager) Info(namespace, name, chartName, version string) (*types.Chart
 
 	return helm.InfoFromTarball(chart)
 }
+
+// Verify checks the signatures of a chart archive against the keyring of the repo. Charts of
+// repos without a keyring are not verified.
+func (c *Manager) Verify(namespace, name, chartName, version string, chartData []byte) error {
+	repo, err := c.getRepo(namespace, name)
+	if err != nil {
+		return err
+	}
+	if repo.spec.KeyringSecret == nil {
+		return nil
+	}
+
+	keyringSecret, err := catalogv2.GetKeyringSecret(c.secrets, repo.spec, repo.metadata.Namespace)
+	if err != nil {
+		return err
+	}
+	keyring, err := verify.KeyringFromSecret(keyringSecret)
+	if err != nil {
+		return err
+	}
+
+	index, err := c.Index(namespace, name)
+	if err != nil {
+		return err
+	}
+	chart, err := index.Get(chartName, version)
+	if err != nil {
+		return err
+	}
+
+	if repo.status.Commit != "" {
+		return fmt.Errorf("charts of git repos can't be verified")
+	}
+
+	if repo.spec.Bundle != "" {
+		return c.verifyBundle(keyring, repo, chart, chartData)
+	}
+
+	secret, err := catalogv2.GetSecret(c.secrets, repo.spec, repo.metadata.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if oci.IsOCI(repo.status.URL) {
+		return verifyOCI(keyring, secret, repo.spec, chart, chartData)
+	}
+
+	if !keyring.HasProvenanceKeys() {
+		return fmt.Errorf("charts of http repos can only be verified with a %s", verify.KeyringKey)
+	}
+	u, err := helmhttp.ChartURL(repo.status.URL, chart)
+	if err != nil {
+		return err
+	}
+	prov, err := helmhttp.Provenance(secret, repo.status.URL, repo.spec.CABundle, repo.spec.InsecureSkipTLSverify, chart)
+	if err != nil {
+		return fmt.Errorf("failed to download provenance file: %w", err)
+	}
+	return keyring.Provenance(path.Base(u.Path), chartData, prov)
+}
+
+// verifyBundle checks that the bundle of the repo is signed by the keyring and that the chart
+// archive is the one in the bundle.
+func (c *Manager) verifyBundle(keyring *verify.Keyring, repo repoDef, chart *repo.ChartVersion, chartData []byte) error {
+	if len(chart.URLs) == 0 {
+		return fmt.Errorf("failed to find chartName %s version %s: %w", chart.Name, chart.Version, validation.NotFound)
+	}
+	data, err := bundle.Load(c.configMaps, repo.spec.Bundle, repo.metadata)
+	if err != nil {
+		return err
+	}
+	if _, err := bundle.Verify(data, keyring); err != nil {
+		return err
+	}
+	bundled, err := bundle.ReadFile(data, chart.URLs[0])
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(bundled, chartData) {
+		return fmt.Errorf("chart archive does not match the bundle")
+	}
+	return nil
+}
+
+func verifyOCI(keyring *verify.Keyring, secret *corev1.Secret, spec *v1.RepoSpec, chart *repo.ChartVersion, chartData []byte) error {
+	signatures, err := oci.Signatures(secret, spec.CABundle, spec.InsecureSkipTLSverify, chart)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(chartData)
+	if signatures.ChartDigest != "sha256:"+hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("chart archive does not match the signed manifest")
+	}
+
+	if keyring.HasProvenanceKeys() {
+		chartFile := fmt.Sprintf("%s-%s.tgz", chart.Name, chart.Version)
+		if err := keyring.Provenance(chartFile, chartData, signatures.Provenance); err != nil {
+			return err
+		}
+	}
+
+	if keyring.HasCosignKeys() {
+		if len(signatures.Cosign) == 0 {
+			return fmt.Errorf("chart has no cosign signature")
+		}
+		for _, signature := range signatures.Cosign {
+			if err = keyring.Cosign(signatures.ManifestDigest, signature.Payload, signature.Signature); err == nil {
+				break
+			}
+		}
+		return err
+	}
+
+	return nil
+}
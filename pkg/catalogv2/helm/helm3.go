@@ -8,6 +8,7 @@
 	"io/ioutil"
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	v1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
@@ -19,6 +20,14 @@ func isHelm3(labels map[string]string) bool {
 	return labels["owner"] == "helm"
 }
 
+// Helm3Release decodes the helm 3 release stored in a secret.
+func Helm3Release(secret *corev1.Secret) (*release.Release, error) {
+	if !isHelm3(secret.Labels) {
+		return nil, ErrNotHelmRelease
+	}
+	return decodeHelm3(string(secret.Data["release"]))
+}
+
 func fromHelm3Data(data string, isNamespaced IsNamespaced) (*v1.ReleaseSpec, error) {
 	release, err := decodeHelm3(data)
 	if err != nil {
@@ -0,0 +1,372 @@
+package helmop
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/rancher/apiserver/pkg/types"
+	types2 "github.com/rancher/rancher/pkg/api/steve/catalog/types"
+	"github.com/rancher/rancher/pkg/catalogv2/helm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeChanged = "changed"
+)
+
+// DryRun renders the charts of an upgrade and compares them with the deployed releases
+// without changing anything in the cluster. The releases are read with the permissions
+// of the user.
+func (s *Operations) DryRun(apiRequest *types.APIRequest, repoNamespace, repoName string, body io.Reader) (*types2.ChartDryRunOutput, error) {
+	upgradeArgs := &types2.ChartUpgradeAction{}
+	if err := json.NewDecoder(body).Decode(upgradeArgs); err != nil {
+		return nil, err
+	}
+
+	client, err := s.cg.K8sInterface(apiRequest)
+	if err != nil {
+		return nil, err
+	}
+	caps, err := capabilities(client)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &types2.ChartDryRunOutput{}
+	for _, chartUpgrade := range upgradeArgs.Charts {
+		result, err := s.dryRunChart(client, caps, repoNamespace, repoName, namespace(upgradeArgs.Namespace), chartUpgrade)
+		if err != nil {
+			return nil, err
+		}
+		output.Charts = append(output.Charts, *result)
+	}
+	return output, nil
+}
+
+func (s *Operations) dryRunChart(client kubernetes.Interface, caps *chartutil.Capabilities, repoNamespace, repoName, releaseNamespace string, chartUpgrade types2.ChartUpgrade) (*types2.ChartDryRun, error) {
+	cmd, err := s.getChartCommand(repoNamespace, repoName, chartUpgrade.ChartName, chartUpgrade.Version, chartUpgrade.Annotations, chartUpgrade.Values, false)
+	if err != nil {
+		return nil, err
+	}
+	chrt, err := loader.LoadArchive(bytes.NewReader(cmd.Chart))
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := deployedRelease(client, releaseNamespace, chartUpgrade.ReleaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		currentValues  map[string]interface{}
+		values         = map[string]interface{}(chartUpgrade.Values)
+		currentVersion string
+		revision       = 1
+	)
+	if current != nil {
+		currentValues = current.Config
+		revision = current.Version + 1
+		if current.Chart != nil && current.Chart.Metadata != nil {
+			currentVersion = current.Chart.Metadata.Version
+		}
+		// helm keeps the deployed values when an upgrade doesn't set any
+		if len(values) == 0 && !chartUpgrade.ResetValues {
+			values = current.Config
+		}
+	}
+
+	manifests, err := render(chrt, values, chartutil.ReleaseOptions{
+		Name:      chartUpgrade.ReleaseName,
+		Namespace: releaseNamespace,
+		Revision:  revision,
+		IsInstall: current == nil,
+		IsUpgrade: current != nil,
+	}, caps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chart %s version %s: %w", chartUpgrade.ChartName, chartUpgrade.Version, err)
+	}
+
+	var currentManifests []string
+	if current != nil {
+		for _, manifest := range releaseutil.SplitManifests(current.Manifest) {
+			currentManifests = append(currentManifests, manifest)
+		}
+	}
+
+	resources, err := diffResources(currentManifests, manifests)
+	if err != nil {
+		return nil, err
+	}
+	valueDiffs, err := diffValues(currentValues, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types2.ChartDryRun{
+		ChartName:      chartUpgrade.ChartName,
+		Version:        chartUpgrade.Version,
+		ReleaseName:    chartUpgrade.ReleaseName,
+		Namespace:      releaseNamespace,
+		CurrentVersion: currentVersion,
+		Resources:      resources,
+		Values:         valueDiffs,
+	}, nil
+}
+
+func capabilities(client kubernetes.Interface) (*chartutil.Capabilities, error) {
+	serverVersion, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+	apiVersions, err := action.GetVersionSet(client.Discovery())
+	if err != nil {
+		return nil, err
+	}
+	return &chartutil.Capabilities{
+		APIVersions: apiVersions,
+		KubeVersion: chartutil.KubeVersion{
+			Version: serverVersion.GitVersion,
+			Major:   serverVersion.Major,
+			Minor:   serverVersion.Minor,
+		},
+	}, nil
+}
+
+// deployedRelease returns the deployed helm 3 release with the name, or nil if there is none.
+func deployedRelease(client kubernetes.Interface, namespace, name string) (*release.Release, error) {
+	if name == "" {
+		return nil, nil
+	}
+	secrets, err := client.CoreV1().Secrets(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{
+			"owner":  "helm",
+			"name":   name,
+			"status": string(release.StatusDeployed),
+		}).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		latest  *release.Release
+		version = -1
+	)
+	for i := range secrets.Items {
+		v, err := strconv.Atoi(secrets.Items[i].Labels["version"])
+		if err != nil || v <= version {
+			continue
+		}
+		rel, err := helm.Helm3Release(&secrets.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		latest, version = rel, v
+	}
+	return latest, nil
+}
+
+// render returns the manifests helm would apply for the chart, hooks are not included.
+func render(chrt *chart.Chart, values map[string]interface{}, options chartutil.ReleaseOptions, caps *chartutil.Capabilities) ([]string, error) {
+	if err := chartutil.ProcessDependencies(chrt, values); err != nil {
+		return nil, err
+	}
+	renderValues, err := chartutil.ToRenderValues(chrt, values, options, caps)
+	if err != nil {
+		return nil, err
+	}
+	files, err := engine.Render(chrt, renderValues)
+	if err != nil {
+		return nil, err
+	}
+	for name := range files {
+		if strings.HasSuffix(name, "NOTES.txt") {
+			delete(files, name)
+		}
+	}
+
+	_, manifests, err := releaseutil.SortManifests(files, caps.APIVersions, releaseutil.InstallOrder)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, manifest := range manifests {
+		result = append(result, manifest.Content)
+	}
+	return result, nil
+}
+
+type resourceKey struct {
+	apiVersion string
+	kind       string
+	namespace  string
+	name       string
+}
+
+func (k resourceKey) less(other resourceKey) bool {
+	return k.apiVersion+"/"+k.kind+"/"+k.namespace+"/"+k.name < other.apiVersion+"/"+other.kind+"/"+other.namespace+"/"+other.name
+}
+
+// parseManifests returns the objects of the manifests as JSON by their identity.
+func parseManifests(manifests []string) (map[resourceKey][]byte, error) {
+	result := map[resourceKey][]byte{}
+	for _, manifest := range manifests {
+		obj := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(manifest), &obj); err != nil {
+			return nil, err
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		metadata, _ := obj["metadata"].(map[string]interface{})
+		key := resourceKey{
+			apiVersion: fmt.Sprint(obj["apiVersion"]),
+			kind:       fmt.Sprint(obj["kind"]),
+		}
+		if metadata != nil {
+			key.name, _ = metadata["name"].(string)
+			key.namespace, _ = metadata["namespace"].(string)
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = data
+	}
+	return result, nil
+}
+
+// diffResources compares the objects of the deployed and the rendered manifests.
+func diffResources(current, rendered []string) ([]types2.ResourceDiff, error) {
+	currentObjs, err := parseManifests(current)
+	if err != nil {
+		return nil, err
+	}
+	renderedObjs, err := parseManifests(rendered)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []resourceKey
+	for key := range currentObjs {
+		keys = append(keys, key)
+	}
+	for key := range renderedObjs {
+		if _, ok := currentObjs[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j])
+	})
+
+	var result []types2.ResourceDiff
+	for _, key := range keys {
+		diff := types2.ResourceDiff{
+			APIVersion: key.apiVersion,
+			Kind:       key.kind,
+			Namespace:  key.namespace,
+			Name:       key.name,
+		}
+		before, inCurrent := currentObjs[key]
+		after, inRendered := renderedObjs[key]
+		switch {
+		case !inCurrent:
+			diff.Change = changeAdded
+		case !inRendered:
+			diff.Change = changeRemoved
+		default:
+			patch, err := jsonpatch.CreateMergePatch(before, after)
+			if err != nil {
+				return nil, err
+			}
+			if string(patch) == "{}" {
+				continue
+			}
+			diff.Change = changeChanged
+			diff.Patch = string(patch)
+		}
+		result = append(result, diff)
+	}
+	return result, nil
+}
+
+// diffValues compares the values of the deployed release with the new values by their path.
+func diffValues(current, values map[string]interface{}) ([]types2.ValueDiff, error) {
+	// compare both sides in the form they take as JSON
+	var before, after map[string]interface{}
+	for _, v := range []struct {
+		in  map[string]interface{}
+		out *map[string]interface{}
+	}{{current, &before}, {values, &after}} {
+		data, err := json.Marshal(v.in)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, v.out); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []types2.ValueDiff
+	diffValue("", before, after, &result)
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result, nil
+}
+
+func diffValue(path string, before, after interface{}, result *[]types2.ValueDiff) {
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	if (beforeIsMap || before == nil) && (afterIsMap || after == nil) && (beforeIsMap || afterIsMap) {
+		for k, v := range beforeMap {
+			diffValue(joinPath(path, k), v, afterMap[k], result)
+		}
+		for k, v := range afterMap {
+			if _, ok := beforeMap[k]; !ok {
+				diffValue(joinPath(path, k), nil, v, result)
+			}
+		}
+		return
+	}
+
+	switch {
+	case reflect.DeepEqual(before, after):
+		return
+	case before == nil:
+		*result = append(*result, types2.ValueDiff{Path: path, Change: changeAdded, New: after})
+	case after == nil:
+		*result = append(*result, types2.ValueDiff{Path: path, Change: changeRemoved, Old: before})
+	default:
+		*result = append(*result, types2.ValueDiff{Path: path, Change: changeChanged, Old: before, New: after})
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
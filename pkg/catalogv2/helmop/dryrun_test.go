@@ -0,0 +1,61 @@
+package helmop
+
+import (
+	"testing"
+
+	types2 "github.com/rancher/rancher/pkg/api/steve/catalog/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffResources(t *testing.T) {
+	current := []string{
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  a: \"1\"\n",
+		"apiVersion: v1\nkind: Service\nmetadata:\n  name: app\nspec:\n  ports:\n  - port: 80\n",
+		"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: old\n",
+	}
+	rendered := []string{
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  a: \"2\"\n",
+		"apiVersion: v1\nkind: Service\nmetadata:\n  name: app\nspec:\n  ports:\n  - port: 80\n",
+		"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: new\n",
+	}
+
+	diffs, err := diffResources(current, rendered)
+	require.NoError(t, err)
+	assert.Equal(t, []types2.ResourceDiff{
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "new", Change: changeAdded},
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "old", Change: changeRemoved},
+		{APIVersion: "v1", Kind: "ConfigMap", Name: "config", Change: changeChanged, Patch: `{"data":{"a":"2"}}`},
+	}, diffs)
+}
+
+func TestDiffValues(t *testing.T) {
+	current := map[string]interface{}{
+		"image": map[string]interface{}{
+			"tag":        "1.0",
+			"pullPolicy": "Always",
+		},
+		"replicas": 1,
+		"ingress":  false,
+	}
+	values := map[string]interface{}{
+		"image": map[string]interface{}{
+			"tag":        "1.1",
+			"pullPolicy": "Always",
+		},
+		"replicas":  1.0,
+		"resources": map[string]interface{}{"cpu": "100m"},
+	}
+
+	diffs, err := diffValues(current, values)
+	require.NoError(t, err)
+	assert.Equal(t, []types2.ValueDiff{
+		{Path: "image.tag", Change: changeChanged, Old: "1.0", New: "1.1"},
+		{Path: "ingress", Change: changeRemoved, Old: false},
+		{Path: "resources.cpu", Change: changeAdded, New: "100m"},
+	}, diffs)
+
+	diffs, err = diffValues(nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+}
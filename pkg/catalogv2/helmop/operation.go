@@ -18,6 +18,7 @@
 	"time"
 	"unicode/utf8"
 
+	"github.com/rancher/apiserver/pkg/apierror"
 	"github.com/rancher/apiserver/pkg/types"
 	types2 "github.com/rancher/rancher/pkg/api/steve/catalog/types"
 	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
@@ -28,10 +29,12 @@
 	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/steve/pkg/podimpersonation"
 	"github.com/rancher/steve/pkg/stores/proxy"
+	"github.com/rancher/wrangler/pkg/condition"
 	data2 "github.com/rancher/wrangler/pkg/data"
 	"github.com/rancher/wrangler/pkg/data/convert"
 	corev1controllers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -40,6 +43,7 @@
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 	v1internal "k8s.io/kubernetes/pkg/apis/core/v1"
 	"sigs.k8s.io/yaml"
 )
@@ -315,7 +319,7 @@ func (s *Operations) getUpgradeCommand(repoNamespace, repoName string, body io.R
 	}
 
 	for _, chartUpgrade := range upgradeArgs.Charts {
-		cmd, err := s.getChartCommand(repoNamespace, repoName, chartUpgrade.ChartName, chartUpgrade.Version, chartUpgrade.Annotations, chartUpgrade.Values)
+		cmd, err := s.getChartCommand(repoNamespace, repoName, chartUpgrade.ChartName, chartUpgrade.Version, chartUpgrade.Annotations, chartUpgrade.Values, true)
 		if err != nil {
 			return status, nil, err
 		}
@@ -534,7 +538,9 @@ func addAnnotations(data []byte, annotations map[string]string) ([]byte, error)
 	return yaml.Marshal(chartData)
 }
 
-func (s *Operations) getChartCommand(namespace, name, chartName, chartVersion string, annotations map[string]string, values map[string]interface{}) (Command, error) {
+// getChartCommand downloads and verifies a chart, recordVerification is false for operations
+// like dry runs that don't change anything and don't update the status of the repo.
+func (s *Operations) getChartCommand(namespace, name, chartName, chartVersion string, annotations map[string]string, values map[string]interface{}, recordVerification bool) (Command, error) {
 	chart, err := s.contentManager.Chart(namespace, name, chartName, chartVersion)
 	if err != nil {
 		return Command{}, err
@@ -545,6 +551,15 @@ func (s *Operations) getChartCommand(namespace, name, chartName, chartVersion st
 		return Command{}, err
 	}
 
+	verifyErr := s.contentManager.Verify(namespace, name, chartName, chartVersion, chartData)
+	if recordVerification {
+		s.setVerifiedCondition(namespace, name, chartName, chartVersion, verifyErr)
+	}
+	if verifyErr != nil {
+		return Command{}, apierror.WrapFieldAPIError(verifyErr, validation.PermissionDenied, "",
+			fmt.Sprintf("chart %s version %s failed verification: %v", chartName, chartVersion, verifyErr))
+	}
+
 	chartData, err = injectAnnotation(chartData, annotations)
 	if err != nil {
 		return Command{}, err
@@ -566,6 +581,48 @@ func (s *Operations) getChartCommand(namespace, name, chartName, chartVersion st
 	return c, nil
 }
 
+// setVerifiedCondition records the result of the chart verification on a repo that requires
+// signed charts. The status is only updated when the result changes, a verified chart after
+// another one leaves it as it is. Only cluster repos are verified, the content manager rejects
+// the charts of namespaced repos before they are verified.
+func (s *Operations) setVerifiedCondition(namespace, name, chartName, chartVersion string, verifyErr error) {
+	if namespace != "" {
+		logrus.Errorf("can not set verification condition of namespaced repo %s/%s", namespace, name)
+		return
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		repo, err := s.clusterRepos.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if repo.Spec.KeyringSecret == nil {
+			return nil
+		}
+
+		verified := condition.Cond(catalog.RepoVerified)
+		if verifyErr != nil {
+			verifyErr := fmt.Errorf("chart %s version %s failed verification: %w", chartName, chartVersion, verifyErr)
+			if verified.MatchesError(repo, "VerificationFailed", verifyErr) {
+				return nil
+			}
+			repo = repo.DeepCopy()
+			verified.SetError(repo, "VerificationFailed", verifyErr)
+		} else {
+			if verified.IsTrue(repo) && verified.GetReason(repo) == "" {
+				return nil
+			}
+			repo = repo.DeepCopy()
+			verified.SetError(repo, "", nil)
+			verified.Message(repo, fmt.Sprintf("chart %s version %s verified", chartName, chartVersion))
+		}
+		_, err = s.clusterRepos.UpdateStatus(repo)
+		return err
+	})
+	if err != nil {
+		logrus.Errorf("failed to set verification condition of repo %s: %v", name, err)
+	}
+}
+
 func (s *Operations) getInstallCommand(repoNamespace, repoName string, body io.Reader) (catalog.OperationStatus, Commands, error) {
 	installArgs := &types2.ChartInstallAction{}
 	err := json.NewDecoder(body).Decode(installArgs)
@@ -581,7 +638,7 @@ func (s *Operations) getInstallCommand(repoNamespace, repoName string, body io.R
 	)
 
 	for _, chartInstall := range installArgs.Charts {
-		cmd, err := s.getChartCommand(repoNamespace, repoName, chartInstall.ChartName, chartInstall.Version, chartInstall.Annotations, chartInstall.Values)
+		cmd, err := s.getChartCommand(repoNamespace, repoName, chartInstall.ChartName, chartInstall.Version, chartInstall.Annotations, chartInstall.Values, true)
 		if err != nil {
 			return status, nil, err
 		}
@@ -0,0 +1,60 @@
+package helmop
+
+import (
+	"errors"
+	"testing"
+
+	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	catalogcontrollers "github.com/rancher/rancher/pkg/generated/controllers/catalog.cattle.io/v1"
+	"github.com/rancher/wrangler/pkg/condition"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeClusterRepos struct {
+	catalogcontrollers.ClusterRepoClient
+	repo    *catalog.ClusterRepo
+	updates int
+}
+
+func (f *fakeClusterRepos) Get(name string, options metav1.GetOptions) (*catalog.ClusterRepo, error) {
+	return f.repo, nil
+}
+
+func (f *fakeClusterRepos) UpdateStatus(repo *catalog.ClusterRepo) (*catalog.ClusterRepo, error) {
+	f.updates++
+	f.repo = repo
+	return repo, nil
+}
+
+func TestSetVerifiedCondition(t *testing.T) {
+	repos := &fakeClusterRepos{
+		repo: &catalog.ClusterRepo{
+			ObjectMeta: metav1.ObjectMeta{Name: "charts"},
+			Spec: catalog.RepoSpec{
+				KeyringSecret: &catalog.SecretReference{Name: "keyring"},
+			},
+		},
+	}
+	s := &Operations{clusterRepos: repos}
+	verified := condition.Cond(catalog.RepoVerified)
+
+	s.setVerifiedCondition("", "charts", "app", "1.0.0", nil)
+	assert.Equal(t, 1, repos.updates)
+	assert.True(t, verified.IsTrue(repos.repo))
+
+	s.setVerifiedCondition("", "charts", "db", "2.0.0", nil)
+	assert.Equal(t, 1, repos.updates, "another verified chart doesn't change the result")
+
+	verifyErr := errors.New("no valid signature")
+	s.setVerifiedCondition("", "charts", "app", "1.1.0", verifyErr)
+	assert.Equal(t, 2, repos.updates)
+	assert.True(t, verified.IsFalse(repos.repo))
+
+	s.setVerifiedCondition("", "charts", "app", "1.1.0", verifyErr)
+	assert.Equal(t, 2, repos.updates, "the same failure isn't recorded again")
+
+	s.setVerifiedCondition("", "charts", "app", "1.0.0", nil)
+	assert.Equal(t, 3, repos.updates)
+	assert.True(t, verified.IsTrue(repos.repo))
+}
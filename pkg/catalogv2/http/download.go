@@ -76,6 +76,27 @@ func Chart(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipT
 	}
 	defer client.CloseIdleConnections()
 
+	u, err := ChartURL(repoURL, chart)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	return ioutil.NopCloser(bytes.NewBuffer(data)), err
+}
+
+// ChartURL returns the absolute URL of a chart archive.
+func ChartURL(repoURL string, chart *repo.ChartVersion) (*url.URL, error) {
+	if len(chart.URLs) == 0 {
+		return nil, fmt.Errorf("failed to find chartName %s version %s: %w", chart.Name, chart.Version, validation.NotFound)
+	}
+
 	u, err := url.Parse(chart.URLs[0])
 	if err != nil {
 		return nil, err
@@ -93,6 +114,23 @@ func Chart(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipT
 		// contain an access credential.
 		u.RawQuery = base.RawQuery
 	}
+	return u, nil
+}
+
+// Provenance downloads the provenance file that is published next to a chart archive.
+func Provenance(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, chart *repo.ChartVersion) ([]byte, error) {
+	client, err := HelmClient(secret, caBundle, insecureSkipTLSVerify)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseIdleConnections()
+
+	u, err := ChartURL(repoURL, chart)
+	if err != nil {
+		return nil, err
+	}
+	u.Path += ".prov"
+	u.RawPath = ""
 
 	resp, err := client.Get(u.String())
 	if err != nil {
@@ -100,20 +138,40 @@ func Chart(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipT
 	}
 	defer resp.Body.Close()
 
-	data, err := ioutil.ReadAll(resp.Body)
-	return ioutil.NopCloser(bytes.NewBuffer(data)), err
+	if resp.StatusCode != http.StatusOK {
+		defer ioutil.ReadAll(resp.Body)
+		return nil, validation.ErrorCode{
+			Status: resp.StatusCode,
+		}
+	}
+
+	return ioutil.ReadAll(resp.Body)
 }
 
 func DownloadIndex(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool) (*repo.IndexFile, error) {
+	index, _, err := DownloadIndexIfModified(secret, repoURL, caBundle, insecureSkipTLSVerify, IndexValidators{})
+	return index, err
+}
+
+// IndexValidators are the response headers of a downloaded index that are sent back as
+// conditions to only download the index again if it changed.
+type IndexValidators struct {
+	ETag         string
+	LastModified string
+}
+
+// DownloadIndexIfModified downloads the index of a repo unless it didn't change since it
+// was downloaded with the validators, in which case the index is nil.
+func DownloadIndexIfModified(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, validators IndexValidators) (*repo.IndexFile, IndexValidators, error) {
 	client, err := HelmClient(secret, caBundle, insecureSkipTLSVerify)
 	if err != nil {
-		return nil, err
+		return nil, validators, err
 	}
 	defer client.CloseIdleConnections()
 
 	parsedURL, err := url.Parse(repoURL)
 	if err != nil {
-		return nil, err
+		return nil, validators, err
 	}
 
 	parsedURL.RawPath = path.Join(parsedURL.RawPath, "index.yaml")
@@ -124,19 +182,30 @@ func DownloadIndex(secret *corev1.Secret, repoURL string, caBundle []byte, insec
 
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return nil, err
+		return nil, validators, err
 	}
 	req.Header.Set("X-Install-Uuid", settings.InstallUUID.Get())
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, validators, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		logrus.Debugf("Repo index %s has not been modified", url)
+		return nil, validators, nil
+	}
+
 	bytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, validators, err
 	}
 
 	// Marshall to file to ensure it matches the schema and this component doesn't just
@@ -144,12 +213,15 @@ func DownloadIndex(secret *corev1.Secret, repoURL string, caBundle []byte, insec
 	index := &repo.IndexFile{}
 	if err := yaml.Unmarshal(bytes, index); err != nil {
 		logrus.Errorf("failed to unmarshal %s: %v", url, err)
-		return nil, fmt.Errorf("failed to parse response from %s", url)
+		return nil, validators, fmt.Errorf("failed to parse response from %s", url)
 	}
 
 	if index.APIVersion == "" {
-		return nil, repo.ErrNoAPIVersion
+		return nil, validators, repo.ErrNoAPIVersion
 	}
 
-	return index, nil
+	return index, IndexValidators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
 }
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadIndexIfModified(t *testing.T) {
+	const (
+		etag         = `"v1"`
+		lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		if req.URL.Path != "/charts/index.yaml" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if req.Header.Get("If-None-Match") == etag {
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+		rw.Header().Set("ETag", etag)
+		rw.Header().Set("Last-Modified", lastModified)
+		rw.Write([]byte("apiVersion: v1\nentries:\n  app:\n  - name: app\n    version: 1.0.0\n"))
+	}))
+	defer server.Close()
+
+	index, validators, err := DownloadIndexIfModified(nil, server.URL+"/charts", nil, false, IndexValidators{})
+	require.NoError(t, err)
+	require.NotNil(t, index)
+	assert.Len(t, index.Entries["app"], 1)
+	assert.Equal(t, IndexValidators{ETag: etag, LastModified: lastModified}, validators)
+
+	index, next, err := DownloadIndexIfModified(nil, server.URL+"/charts", nil, false, validators)
+	require.NoError(t, err)
+	assert.Nil(t, index, "index didn't change")
+	assert.Equal(t, validators, next)
+
+	index, _, err = DownloadIndexIfModified(nil, server.URL+"/charts", nil, false, IndexValidators{ETag: `"v0"`})
+	require.NoError(t, err)
+	assert.NotNil(t, index, "stale validators download the index again")
+	assert.Equal(t, 3, requests)
+}
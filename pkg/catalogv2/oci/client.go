@@ -0,0 +1,329 @@
+package oci
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	helmhttp "github.com/rancher/rancher/pkg/catalogv2/http"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	dockerHubRegistry = "registry-1.docker.io"
+	dockerHubIndex    = "index.docker.io"
+)
+
+// client talks to a registry using the OCI distribution API. Requests that are
+// answered with a bearer challenge are retried with a token for the requested scope.
+type client struct {
+	client   *http.Client
+	host     string
+	username string
+	password string
+	// insecureSkipTLSVerify allows token services that are served over plain http
+	insecureSkipTLSVerify bool
+
+	lock   sync.Mutex
+	tokens map[string]string
+}
+
+func newClient(secret *corev1.Secret, host string, caBundle []byte, insecureSkipTLSVerify bool) (*client, error) {
+	// only client certificates are handled by the helm client, credentials are sent
+	// to the registry or its token service depending on the challenge
+	var tlsSecret *corev1.Secret
+	if secret != nil && secret.Type == corev1.SecretTypeTLS {
+		tlsSecret = secret
+	}
+	httpClient, err := helmhttp.HelmClient(tlsSecret, caBundle, insecureSkipTLSVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password, err := credentials(secret, host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client{
+		client:                httpClient,
+		host:                  host,
+		username:              username,
+		password:              password,
+		insecureSkipTLSVerify: insecureSkipTLSVerify,
+		tokens:                map[string]string{},
+	}, nil
+}
+
+// credentials returns the username and password for the registry from a basic auth or
+// docker config secret.
+func credentials(secret *corev1.Secret, host string) (string, string, error) {
+	if secret == nil {
+		return "", "", nil
+	}
+
+	switch secret.Type {
+	case corev1.SecretTypeBasicAuth:
+		return string(secret.Data[corev1.BasicAuthUsernameKey]), string(secret.Data[corev1.BasicAuthPasswordKey]), nil
+	case corev1.SecretTypeDockerConfigJson:
+		config := struct {
+			Auths map[string]dockerAuth `json:"auths"`
+		}{}
+		if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config); err != nil {
+			return "", "", fmt.Errorf("failed to parse docker config of secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+		return lookupAuth(config.Auths, host)
+	case corev1.SecretTypeDockercfg:
+		auths := map[string]dockerAuth{}
+		if err := json.Unmarshal(secret.Data[corev1.DockerConfigKey], &auths); err != nil {
+			return "", "", fmt.Errorf("failed to parse docker config of secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+		return lookupAuth(auths, host)
+	}
+	return "", "", nil
+}
+
+type dockerAuth struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+func lookupAuth(auths map[string]dockerAuth, host string) (string, string, error) {
+	for key, auth := range auths {
+		if registryHost(key) != registryHost(host) {
+			continue
+		}
+		if auth.Username != "" || auth.Password != "" {
+			return auth.Username, auth.Password, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid auth for registry %s: %w", key, err)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return "", "", fmt.Errorf("invalid auth for registry %s: expected username:password", key)
+		}
+		return parts[0], parts[1], nil
+	}
+	return "", "", nil
+}
+
+// registryHost normalizes the keys of a docker config, they can be a host or a URL.
+func registryHost(key string) string {
+	if u, err := url.Parse(key); err == nil && u.Host != "" {
+		key = u.Host
+	}
+	key = strings.TrimSuffix(key, "/")
+	if key == dockerHubIndex || key == "docker.io" {
+		return dockerHubRegistry
+	}
+	return key
+}
+
+func (c *client) url(path string) string {
+	return "https://" + c.host + "/v2/" + path
+}
+
+// get sends a GET request to the registry, authenticating with a token for the scope
+// if the registry asks for one.
+func (c *client) get(u, scope string, accept ...string) (*http.Response, error) {
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Install-Uuid", settings.InstallUUID.Get())
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	token := c.tokens[scope]
+	c.lock.Unlock()
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	drain(resp)
+
+	req, err = newRequest()
+	if err != nil {
+		return nil, err
+	}
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "basic":
+		req.SetBasicAuth(c.username, c.password)
+	case "bearer":
+		token, err := c.token(params, scope)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	default:
+		return nil, validation.ErrorCode{Status: http.StatusUnauthorized}
+	}
+	return c.client.Do(req)
+}
+
+// getOK is get for requests that are expected to return 200.
+func (c *client) getOK(u, scope string, accept ...string) (*http.Response, error) {
+	resp, err := c.get(u, scope, accept...)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		drain(resp)
+		return nil, validation.ErrorCode{
+			Status: resp.StatusCode,
+		}
+	}
+	return resp, nil
+}
+
+// token requests a bearer token from the token service named in the challenge.
+func (c *client) token(params map[string]string, scope string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid token realm %q in registry challenge", params["realm"])
+	}
+	// the credentials of the registry are sent to the token service
+	if realm.Scheme != "https" && !(realm.Scheme == "http" && c.insecureSkipTLSVerify) {
+		return "", fmt.Errorf("token realm %q of registry %s is not https", params["realm"], c.host)
+	}
+	if !sameDomain(realm.Hostname(), c.host) {
+		return "", fmt.Errorf("token realm %q is not in the domain of registry %s", params["realm"], c.host)
+	}
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	if scope != "" {
+		query.Set("scope", scope)
+	} else if params["scope"] != "" {
+		query.Set("scope", params["scope"])
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer drain(resp)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get token for %s from %s: %w", c.host, realm.Host, validation.ErrorCode{Status: resp.StatusCode})
+	}
+
+	result := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse token response from %s: %w", realm.Host, err)
+	}
+	token := result.Token
+	if token == "" {
+		token = result.AccessToken
+	}
+	if token == "" {
+		return "", fmt.Errorf("token service %s returned no token", realm.Host)
+	}
+
+	c.lock.Lock()
+	c.tokens[scope] = token
+	c.lock.Unlock()
+	return token, nil
+}
+
+// sameDomain returns whether the token service is served by the registry host or a host of its
+// parent domain, e.g. auth.docker.io for registry-1.docker.io.
+func sameDomain(realmHost, registryHost string) bool {
+	if h, _, err := net.SplitHostPort(registryHost); err == nil {
+		registryHost = h
+	}
+	realmHost, registryHost = strings.ToLower(realmHost), strings.ToLower(registryHost)
+	if realmHost == registryHost {
+		return true
+	}
+	if net.ParseIP(registryHost) != nil {
+		return false
+	}
+	labels := strings.Split(registryHost, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	domain := strings.Join(labels[len(labels)-2:], ".")
+	return realmHost == domain || strings.HasSuffix(realmHost, "."+domain)
+}
+
+// parseChallenge parses a WWW-Authenticate header like
+// Bearer realm="https://auth.example.com/token",service="registry.example.com"
+func parseChallenge(header string) (string, map[string]string) {
+	params := map[string]string{}
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	scheme := strings.ToLower(parts[0])
+	if len(parts) == 1 {
+		return scheme, params
+	}
+
+	rest := parts[1]
+	for rest != "" {
+		i := strings.Index(rest, "=")
+		if i < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:i]))
+		rest = strings.TrimSpace(rest[i+1:])
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if end := strings.Index(rest, ","); end >= 0 {
+			value, rest = rest[:end], rest[end:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return scheme, params
+}
+
+func drain(resp *http.Response) {
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
@@ -0,0 +1,445 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	Scheme = "oci://"
+
+	manifestMediaType        = "application/vnd.oci.image.manifest.v1+json"
+	helmConfigMediaType      = "application/vnd.cncf.helm.config.v1+json"
+	helmChartMediaType       = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	helmChartLegacyMediaType = "application/tar+gzip"
+	helmProvenanceMediaType  = "application/vnd.cncf.helm.chart.provenance.v1.prov"
+
+	annotationTitle       = "org.opencontainers.image.title"
+	annotationVersion     = "org.opencontainers.image.version"
+	annotationDescription = "org.opencontainers.image.description"
+	annotationCreated     = "org.opencontainers.image.created"
+	annotationURL         = "org.opencontainers.image.url"
+	annotationSource      = "org.opencontainers.image.source"
+
+	maxConfigSize = 1 << 20
+	maxChartSize  = 20 << 20
+	pageSize      = 100
+
+	maxCachedVersions = 10000
+)
+
+// versionCache holds the index entries of the tags that were indexed before, by tag and
+// manifest digest, so a refresh only downloads the config of tags that changed.
+var versionCache, _ = lru.New(maxCachedVersions)
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type manifest struct {
+	MediaType   string            `json:"mediaType,omitempty"`
+	Config      descriptor        `json:"config"`
+	Layers      []descriptor      `json:"layers"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// IsOCI returns true if the repo URL points to an OCI registry, e.g. oci://registry.example.com/charts.
+func IsOCI(repoURL string) bool {
+	return strings.HasPrefix(repoURL, Scheme)
+}
+
+// reference is a repository in a registry with an optional tag.
+type reference struct {
+	host       string
+	repository string
+	tag        string
+}
+
+func (r reference) String() string {
+	s := Scheme + r.host + "/" + r.repository
+	if r.tag != "" {
+		s += ":" + r.tag
+	}
+	return s
+}
+
+func (r reference) scope() string {
+	return "repository:" + r.repository + ":pull"
+}
+
+func parseReference(ociURL string) (reference, error) {
+	if !IsOCI(ociURL) {
+		return reference{}, fmt.Errorf("invalid OCI URL %q, expected scheme %s", ociURL, Scheme)
+	}
+	u, err := url.Parse(ociURL)
+	if err != nil {
+		return reference{}, err
+	}
+	if u.Host == "" {
+		return reference{}, fmt.Errorf("invalid OCI URL %q, missing registry", ociURL)
+	}
+
+	ref := reference{
+		host:       u.Host,
+		repository: strings.Trim(u.Path, "/"),
+	}
+	if i := strings.LastIndex(ref.repository, ":"); i > strings.LastIndex(ref.repository, "/") {
+		ref.repository, ref.tag = ref.repository[:i], ref.repository[i+1:]
+	}
+	return ref, nil
+}
+
+// DownloadIndex builds an index of the charts in an OCI registry. The repo URL is either
+// a single chart repository or a path in the registry, in which case all chart repositories
+// under the path are indexed. Every tag that is a semantic version is a chart version, tags
+// that can't be indexed are left out of the index.
+func DownloadIndex(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool) (*repo.IndexFile, error) {
+	ref, err := parseReference(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClient(secret, ref.host, caBundle, insecureSkipTLSVerify)
+	if err != nil {
+		return nil, err
+	}
+	defer c.client.CloseIdleConnections()
+
+	logrus.Infof("Downloading repo index from %s", repoURL)
+
+	repositories, err := c.repositories(ref.repository)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		index   = repo.NewIndexFile()
+		indexed int
+		lastErr error
+	)
+	for _, repository := range repositories {
+		tags, err := c.tags(repository)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			chartRef := reference{host: ref.host, repository: repository, tag: tag}
+			version, err := c.chartVersion(chartRef)
+			if err != nil {
+				logrus.Warnf("Skipping chart %s: %v", chartRef, err)
+				lastErr = fmt.Errorf("failed to index %s: %w", chartRef, err)
+				continue
+			}
+			if version == nil {
+				continue
+			}
+			indexed++
+			index.Entries[version.Name] = append(index.Entries[version.Name], version)
+		}
+	}
+	// no chart could be indexed, the registry is likely unavailable rather than a chart broken
+	if indexed == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	return index, nil
+}
+
+// repositories returns the repository of the URL if it has tags, otherwise the repositories
+// under it from the registry catalog.
+func (c *client) repositories(repository string) ([]string, error) {
+	if repository != "" {
+		resp, err := c.get(c.url(repository+"/tags/list?n=1"), reference{repository: repository}.scope())
+		if err != nil {
+			return nil, err
+		}
+		drain(resp)
+		if resp.StatusCode == http.StatusOK {
+			return []string{repository}, nil
+		}
+	}
+
+	var (
+		result []string
+		prefix = repository + "/"
+	)
+	if repository == "" {
+		prefix = ""
+	}
+	err := c.list(c.url(fmt.Sprintf("_catalog?n=%d", pageSize)), "registry:catalog:*", func(data []byte) error {
+		page := struct {
+			Repositories []string `json:"repositories"`
+		}{}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		for _, r := range page.Repositories {
+			if strings.HasPrefix(r, prefix) {
+				result = append(result, r)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repositories under %s: %w", repository, err)
+	}
+	return result, nil
+}
+
+func (c *client) tags(repository string) ([]string, error) {
+	var result []string
+	err := c.list(c.url(fmt.Sprintf("%s/tags/list?n=%d", repository, pageSize)), reference{repository: repository}.scope(), func(data []byte) error {
+		page := struct {
+			Tags []string `json:"tags"`
+		}{}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		result = append(result, page.Tags...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", repository, err)
+	}
+	return result, nil
+}
+
+// list follows the Link headers of a paginated list.
+func (c *client) list(u, scope string, page func([]byte) error) error {
+	for u != "" {
+		resp, err := c.getOK(u, scope)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxConfigSize))
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if err := page(data); err != nil {
+			return err
+		}
+
+		next, err := nextLink(u, resp.Header.Get("Link"))
+		if err != nil {
+			return err
+		}
+		u = next
+	}
+	return nil
+}
+
+// nextLink parses a Link header like </v2/_catalog?last=b&n=100>; rel="next"
+func nextLink(current, header string) (string, error) {
+	if header == "" {
+		return "", nil
+	}
+	start, end := strings.Index(header, "<"), strings.Index(header, ">")
+	if start < 0 || end < start || !strings.Contains(header[end:], `rel="next"`) {
+		return "", nil
+	}
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	next, err := url.Parse(header[start+1 : end])
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(next).String(), nil
+}
+
+// manifest returns the manifest of a tag and its digest.
+func (c *client) manifest(ref reference) (*manifest, string, error) {
+	resp, err := c.getOK(c.url(ref.repository+"/manifests/"+ref.tag), ref.scope(), manifestMediaType)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxConfigSize))
+	if err != nil {
+		return nil, "", err
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, "", fmt.Errorf("failed to parse manifest of %s: %w", ref, err)
+	}
+	sum := sha256.Sum256(data)
+	return m, "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// blob downloads a blob and verifies its digest.
+func (c *client) blob(ref reference, desc descriptor, maxSize int64) ([]byte, error) {
+	if desc.Size > maxSize {
+		return nil, fmt.Errorf("blob %s of %s is %d bytes, the limit is %d", desc.Digest, ref, desc.Size, maxSize)
+	}
+	resp, err := c.getOK(c.url(ref.repository+"/blobs/"+desc.Digest), ref.scope())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("blob %s of %s is larger than %d bytes", desc.Digest, ref, maxSize)
+	}
+	if err := verifyDigest(desc.Digest, data); err != nil {
+		return nil, fmt.Errorf("blob %s of %s: %w", desc.Digest, ref, err)
+	}
+	return data, nil
+}
+
+func verifyDigest(digest string, data []byte) error {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || parts[0] != "sha256" {
+		return fmt.Errorf("unsupported digest %q", digest)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != parts[1] {
+		return errors.New("digest mismatch")
+	}
+	return nil
+}
+
+// chartVersion returns the index entry of a tag, or nil if the tag isn't a chart version.
+func (c *client) chartVersion(ref reference) (*repo.ChartVersion, error) {
+	// helm replaces the + of build metadata with _ because + isn't allowed in tags
+	if _, err := semver.NewVersion(strings.ReplaceAll(ref.tag, "_", "+")); err != nil {
+		return nil, nil
+	}
+
+	m, manifestDigest, err := c.manifest(ref)
+	if err != nil {
+		return nil, err
+	}
+	if m.Config.MediaType != helmConfigMediaType {
+		return nil, nil
+	}
+	layer, ok := chartLayer(m)
+	if !ok {
+		return nil, nil
+	}
+
+	cacheKey := ref.String() + "@" + manifestDigest
+	if cached, ok := versionCache.Get(cacheKey); ok {
+		version := *cached.(*repo.ChartVersion)
+		return &version, nil
+	}
+
+	data, err := c.blob(ref, m.Config, maxConfigSize)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &chart.Metadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse chart metadata: %w", err)
+	}
+	created := applyAnnotations(metadata, m.Annotations)
+	if err := metadata.Validate(); err != nil {
+		logrus.Warnf("Skipping invalid chart %s: %v", ref, err)
+		return nil, nil
+	}
+
+	version := &repo.ChartVersion{
+		Metadata: metadata,
+		URLs:     []string{ref.String()},
+		Created:  created,
+		Digest:   layer.Digest,
+	}
+	cached := *version
+	versionCache.Add(cacheKey, &cached)
+	return version, nil
+}
+
+// applyAnnotations fills in the chart metadata that is missing from the config with the
+// manifest annotations and returns the creation time.
+func applyAnnotations(metadata *chart.Metadata, annotations map[string]string) time.Time {
+	if metadata.Name == "" {
+		metadata.Name = annotations[annotationTitle]
+	}
+	if metadata.Version == "" {
+		metadata.Version = annotations[annotationVersion]
+	}
+	if metadata.Description == "" {
+		metadata.Description = annotations[annotationDescription]
+	}
+	if metadata.Home == "" {
+		metadata.Home = annotations[annotationURL]
+	}
+	if source := annotations[annotationSource]; source != "" && len(metadata.Sources) == 0 {
+		metadata.Sources = []string{source}
+	}
+	created, _ := time.Parse(time.RFC3339, annotations[annotationCreated])
+	return created
+}
+
+func chartLayer(m *manifest) (descriptor, bool) {
+	for _, layer := range m.Layers {
+		if layer.MediaType == helmChartMediaType || layer.MediaType == helmChartLegacyMediaType {
+			return layer, true
+		}
+	}
+	return descriptor{}, false
+}
+
+// Chart pulls the chart layer of an index entry.
+func Chart(secret *corev1.Secret, caBundle []byte, insecureSkipTLSVerify bool, chart *repo.ChartVersion) (io.ReadCloser, error) {
+	if len(chart.URLs) == 0 {
+		return nil, fmt.Errorf("failed to find chartName %s version %s: %w", chart.Name, chart.Version, validation.NotFound)
+	}
+	ref, err := parseReference(chart.URLs[0])
+	if err != nil {
+		return nil, err
+	}
+	if ref.tag == "" {
+		return nil, fmt.Errorf("failed to find chartName %s version %s: %w", chart.Name, chart.Version, validation.NotFound)
+	}
+
+	c, err := newClient(secret, ref.host, caBundle, insecureSkipTLSVerify)
+	if err != nil {
+		return nil, err
+	}
+	defer c.client.CloseIdleConnections()
+
+	m, _, err := c.manifest(ref)
+	if err != nil {
+		return nil, err
+	}
+	layer, ok := chartLayer(m)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a helm chart: %w", ref, validation.NotFound)
+	}
+	if chart.Digest != "" && layer.Digest != chart.Digest {
+		return nil, fmt.Errorf("chart %s was changed after the repo was indexed, expected digest %s got %s", ref, chart.Digest, layer.Digest)
+	}
+
+	data, err := c.blob(ref, layer, maxChartSize)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewBuffer(data)), nil
+}
@@ -0,0 +1,385 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type testRegistry struct {
+	// tags by repository, manifests by repository:tag and blobs by digest
+	tags      map[string][]string
+	manifests map[string][]byte
+	blobs     map[string][]byte
+	token     string
+	// blobRequests counts the downloaded blobs
+	blobRequests int
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (r *testRegistry) addChart(repository, tag, metadata string, chart []byte) {
+	config := []byte(metadata)
+	r.blobs[digest(config)] = config
+	r.blobs[digest(chart)] = chart
+	m, _ := json.Marshal(manifest{
+		Config: descriptor{MediaType: helmConfigMediaType, Digest: digest(config), Size: int64(len(config))},
+		Layers: []descriptor{{MediaType: helmChartMediaType, Digest: digest(chart), Size: int64(len(chart))}},
+		Annotations: map[string]string{
+			annotationCreated:     "2021-06-01T10:00:00Z",
+			annotationDescription: "from annotations",
+		},
+	})
+	r.manifests[repository+":"+tag] = m
+	r.tags[repository] = append(r.tags[repository], tag)
+}
+
+// addSignature stores a cosign signature for a tag and returns the digest of the tag's manifest.
+func (r *testRegistry) addSignature(repository, tag string, payload, signature []byte) string {
+	manifestDigest := digest(r.manifests[repository+":"+tag])
+	r.blobs[digest(payload)] = payload
+	m, _ := json.Marshal(manifest{
+		Layers: []descriptor{{
+			MediaType:   cosignSignatureMediaType,
+			Digest:      digest(payload),
+			Size:        int64(len(payload)),
+			Annotations: map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(signature)},
+		}},
+	})
+	r.manifests[repository+":"+strings.Replace(manifestDigest, ":", "-", 1)+".sig"] = m
+	return manifestDigest
+}
+
+func (r *testRegistry) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		user, pass, _ := req.BasicAuth()
+		if user != "user" || pass != "pass" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(rw).Encode(map[string]string{"token": r.token})
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer "+r.token {
+		rw.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="https://%s/token",service="test"`, req.Host))
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case path == "_catalog":
+		var repos []string
+		for repo := range r.tags {
+			repos = append(repos, repo)
+		}
+		json.NewEncoder(rw).Encode(map[string][]string{"repositories": repos})
+	case strings.HasSuffix(path, "/tags/list"):
+		repo := strings.TrimSuffix(path, "/tags/list")
+		tags, ok := r.tags[repo]
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// one tag per page to exercise pagination
+		i := 0
+		if last := req.URL.Query().Get("last"); last != "" {
+			for tags[i] != last {
+				i++
+			}
+			i++
+		}
+		if i+1 < len(tags) {
+			rw.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?last=%s&n=1>; rel="next"`, repo, tags[i]))
+		}
+		json.NewEncoder(rw).Encode(map[string]interface{}{"name": repo, "tags": tags[i : i+1]})
+	case strings.Contains(path, "/manifests/"):
+		parts := strings.SplitN(path, "/manifests/", 2)
+		m, ok := r.manifests[parts[0]+":"+parts[1]]
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Header().Set("Content-Type", manifestMediaType)
+		rw.Write(m)
+	case strings.Contains(path, "/blobs/"):
+		r.blobRequests++
+		blob, ok := r.blobs[path[strings.Index(path, "/blobs/")+len("/blobs/"):]]
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Write(blob)
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestRegistry(t *testing.T) (*testRegistry, string, *corev1.Secret) {
+	r := &testRegistry{
+		tags:      map[string][]string{},
+		manifests: map[string][]byte{},
+		blobs:     map[string][]byte{},
+		token:     "token",
+	}
+	server := httptest.NewTLSServer(r)
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	config, _ := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			"https://" + host: map[string]string{
+				"auth": base64.StdEncoding.EncodeToString([]byte("user:pass")),
+			},
+		},
+	})
+	secret := &corev1.Secret{
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{corev1.DockerConfigJsonKey: config},
+	}
+	return r, host, secret
+}
+
+func TestDownloadIndex(t *testing.T) {
+	r, host, secret := newTestRegistry(t)
+	r.addChart("charts/app", "1.0.0", `{"apiVersion":"v2","name":"app","version":"1.0.0"}`, []byte("app-1.0.0"))
+	r.addChart("charts/app", "1.1.0_build.1", `{"apiVersion":"v2","name":"app","version":"1.1.0+build.1","description":"app chart"}`, []byte("app-1.1.0"))
+	r.addChart("charts/app", "latest", `{"apiVersion":"v2","name":"app","version":"1.1.0"}`, []byte("app-latest"))
+	r.addChart("charts/db", "2.0.0", `{"apiVersion":"v2","name":"db","version":"2.0.0"}`, []byte("db-2.0.0"))
+	r.addChart("other/web", "1.0.0", `{"apiVersion":"v2","name":"web","version":"1.0.0"}`, []byte("web-1.0.0"))
+
+	// a path in the registry indexes every chart repository under it
+	index, err := DownloadIndex(secret, "oci://"+host+"/charts", nil, true)
+	require.NoError(t, err)
+	index.SortEntries()
+	assert.Len(t, index.Entries, 2)
+	require.Len(t, index.Entries["app"], 2, "tags that aren't versions are skipped")
+	assert.Len(t, index.Entries["db"], 1)
+
+	latest := index.Entries["app"][0]
+	assert.Equal(t, "1.1.0+build.1", latest.Version)
+	assert.Equal(t, "app chart", latest.Description)
+	assert.Equal(t, []string{"oci://" + host + "/charts/app:1.1.0_build.1"}, latest.URLs)
+	assert.Equal(t, digest([]byte("app-1.1.0")), latest.Digest)
+	assert.Equal(t, "from annotations", index.Entries["app"][1].Description)
+	assert.Equal(t, 2021, index.Entries["app"][1].Created.Year())
+
+	// a chart repository is indexed on its own
+	index, err = DownloadIndex(secret, "oci://"+host+"/other/web", nil, true)
+	require.NoError(t, err)
+	assert.Len(t, index.Entries, 1)
+	assert.Len(t, index.Entries["web"], 1)
+
+	chart, err := Chart(secret, nil, true, latest)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(chart)
+	require.NoError(t, err)
+	assert.Equal(t, "app-1.1.0", string(data))
+
+	// the chart changed since the repo was indexed
+	latest.Digest = digest([]byte("other"))
+	_, err = Chart(secret, nil, true, latest)
+	assert.Error(t, err)
+
+	_, err = DownloadIndex(nil, "oci://"+host+"/charts", nil, true)
+	assert.Error(t, err, "the token service requires credentials")
+}
+
+func TestDownloadIndexSkipsBrokenTags(t *testing.T) {
+	r, host, secret := newTestRegistry(t)
+	r.addChart("charts/app", "1.0.0", `{"apiVersion":"v2","name":"app","version":"1.0.0"}`, []byte("app-1.0.0"))
+	r.addChart("charts/app", "1.1.0", `{"apiVersion":"v2","name":"app","version":"1.1.0"}`, []byte("app-1.1.0"))
+	r.tags["charts/app"] = append(r.tags["charts/app"], "1.2.0")
+
+	index, err := DownloadIndex(secret, "oci://"+host+"/charts/app", nil, true)
+	require.NoError(t, err)
+	assert.Len(t, index.Entries["app"], 2, "the tag without a manifest is skipped")
+
+	r.tags["charts/app"] = []string{"1.2.0"}
+	_, err = DownloadIndex(secret, "oci://"+host+"/charts/app", nil, true)
+	assert.Error(t, err, "a repo without a single indexable chart fails")
+}
+
+func TestDownloadIndexReusesUnchangedTags(t *testing.T) {
+	r, host, secret := newTestRegistry(t)
+	r.addChart("charts/app", "1.0.0", `{"apiVersion":"v2","name":"app","version":"1.0.0"}`, []byte("app-1.0.0"))
+	r.addChart("charts/app", "1.1.0", `{"apiVersion":"v2","name":"app","version":"1.1.0"}`, []byte("app-1.1.0"))
+
+	_, err := DownloadIndex(secret, "oci://"+host+"/charts/app", nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, r.blobRequests)
+
+	index, err := DownloadIndex(secret, "oci://"+host+"/charts/app", nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, r.blobRequests, "the config of unchanged tags isn't downloaded again")
+	assert.Len(t, index.Entries["app"], 2)
+
+	// pushing the tag again changes its manifest
+	r.tags["charts/app"] = r.tags["charts/app"][:1]
+	r.addChart("charts/app", "1.1.0", `{"apiVersion":"v2","name":"app","version":"1.1.0","description":"new"}`, []byte("app-1.1.0-new"))
+	index, err = DownloadIndex(secret, "oci://"+host+"/charts/app", nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, 3, r.blobRequests)
+	chart, err := index.Get("app", "1.1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "new", chart.Description)
+	assert.Equal(t, digest([]byte("app-1.1.0-new")), chart.Digest)
+}
+
+func TestSignatures(t *testing.T) {
+	r, host, secret := newTestRegistry(t)
+	r.addChart("charts/app", "1.0.0", `{"apiVersion":"v2","name":"app","version":"1.0.0"}`, []byte("app-1.0.0"))
+	r.addChart("charts/app", "1.1.0", `{"apiVersion":"v2","name":"app","version":"1.1.0"}`, []byte("app-1.1.0"))
+	manifestDigest := r.addSignature("charts/app", "1.0.0", []byte("payload"), []byte("signature"))
+
+	index, err := DownloadIndex(secret, "oci://"+host+"/charts/app", nil, true)
+	require.NoError(t, err)
+	assert.Len(t, index.Entries["app"], 2, "signature tags aren't chart versions")
+
+	chart, err := index.Get("app", "1.0.0")
+	require.NoError(t, err)
+	signatures, err := Signatures(secret, nil, true, chart)
+	require.NoError(t, err)
+	assert.Equal(t, manifestDigest, signatures.ManifestDigest)
+	assert.Equal(t, digest([]byte("app-1.0.0")), signatures.ChartDigest)
+	assert.Equal(t, []CosignSignature{{Payload: []byte("payload"), Signature: []byte("signature")}}, signatures.Cosign)
+
+	chart, err = index.Get("app", "1.1.0")
+	require.NoError(t, err)
+	signatures, err = Signatures(secret, nil, true, chart)
+	require.NoError(t, err)
+	assert.Empty(t, signatures.Cosign)
+}
+
+func TestCredentials(t *testing.T) {
+	config := []byte(`{"auths":{"https://index.docker.io/v1/":{"username":"hub","password":"hubpass"},"registry.example.com":{"auth":"` +
+		base64.StdEncoding.EncodeToString([]byte("user:pass")) + `"}}}`)
+	secret := &corev1.Secret{
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{corev1.DockerConfigJsonKey: config},
+	}
+
+	user, pass, err := credentials(secret, "registry-1.docker.io")
+	require.NoError(t, err)
+	assert.Equal(t, "hub", user)
+	assert.Equal(t, "hubpass", pass)
+
+	user, pass, err = credentials(secret, "registry.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "user", user)
+	assert.Equal(t, "pass", pass)
+
+	user, _, err = credentials(secret, "other.example.com")
+	require.NoError(t, err)
+	assert.Empty(t, user)
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:charts/app:pull"`)
+	assert.Equal(t, "bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:charts/app:pull",
+	}, params)
+
+	scheme, params = parseChallenge(`Basic realm=registry`)
+	assert.Equal(t, "basic", scheme)
+	assert.Equal(t, "registry", params["realm"])
+}
+
+func TestTokenRealm(t *testing.T) {
+	tests := []struct {
+		name     string
+		realm    string
+		insecure bool
+		wantErr  string
+	}{
+		{
+			name:    "plain http",
+			realm:   "http://registry.example.com/token",
+			wantErr: "is not https",
+		},
+		{
+			name:    "other domain",
+			realm:   "https://example.org/token",
+			wantErr: "is not in the domain of registry",
+		},
+		{
+			name:    "other domain with the registry domain as suffix",
+			realm:   "https://notexample.com/token",
+			wantErr: "is not in the domain of registry",
+		},
+		{
+			name:     "plain http with insecureSkipTLSVerify",
+			realm:    "http://registry.example.com/token",
+			insecure: true,
+		},
+		{
+			name:  "host of the parent domain",
+			realm: "https://auth.example.com/token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested bool
+			c := &client{
+				client: &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+					requested = true
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(strings.NewReader(`{"token":"abc"}`)),
+					}, nil
+				})},
+				host:                  "registry.example.com:5000",
+				username:              "user",
+				password:              "pass",
+				insecureSkipTLSVerify: tt.insecure,
+				tokens:                map[string]string{},
+			}
+			token, err := c.token(map[string]string{"realm": tt.realm}, "repository:charts/app:pull")
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "abc", token)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+			assert.False(t, requested, "the credentials are not sent to the realm")
+		})
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestParseReference(t *testing.T) {
+	ref, err := parseReference("oci://registry.example.com:5000/charts/app:1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, reference{host: "registry.example.com:5000", repository: "charts/app", tag: "1.0.0"}, ref)
+
+	ref, err = parseReference("oci://registry.example.com/charts/")
+	require.NoError(t, err)
+	assert.Equal(t, reference{host: "registry.example.com", repository: "charts"}, ref)
+
+	_, err = parseReference("https://registry.example.com/charts")
+	assert.Error(t, err)
+}
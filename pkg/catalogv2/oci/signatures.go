@@ -0,0 +1,104 @@
+package oci
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	cosignSignatureMediaType  = "application/vnd.dev.cosign.simplesigning.v1+json"
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+)
+
+// ChartSignatures are the signatures of a chart stored in the registry.
+type ChartSignatures struct {
+	// ManifestDigest is the digest of the manifest the cosign signatures are for
+	ManifestDigest string
+	// ChartDigest is the digest of the chart layer of the manifest
+	ChartDigest string
+	// Provenance is the provenance file helm pushes as a layer of the chart, if any
+	Provenance []byte
+	Cosign     []CosignSignature
+}
+
+// CosignSignature is a signature of the simple signing payload cosign stores for a manifest.
+type CosignSignature struct {
+	Payload   []byte
+	Signature []byte
+}
+
+// Signatures returns the provenance file and the cosign signatures of a chart. Cosign stores
+// the signatures of a manifest in the tag sha256-<digest>.sig of the same repository.
+func Signatures(secret *corev1.Secret, caBundle []byte, insecureSkipTLSVerify bool, chart *repo.ChartVersion) (*ChartSignatures, error) {
+	if len(chart.URLs) == 0 {
+		return nil, fmt.Errorf("failed to find chartName %s version %s: %w", chart.Name, chart.Version, validation.NotFound)
+	}
+	ref, err := parseReference(chart.URLs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := newClient(secret, ref.host, caBundle, insecureSkipTLSVerify)
+	if err != nil {
+		return nil, err
+	}
+	defer c.client.CloseIdleConnections()
+
+	m, digest, err := c.manifest(ref)
+	if err != nil {
+		return nil, err
+	}
+	layer, ok := chartLayer(m)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a helm chart: %w", ref, validation.NotFound)
+	}
+
+	result := &ChartSignatures{
+		ManifestDigest: digest,
+		ChartDigest:    layer.Digest,
+	}
+	for _, layer := range m.Layers {
+		if layer.MediaType == helmProvenanceMediaType {
+			result.Provenance, err = c.blob(ref, layer, maxConfigSize)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sigRef := reference{
+		host:       ref.host,
+		repository: ref.repository,
+		tag:        strings.Replace(digest, ":", "-", 1) + ".sig",
+	}
+	sigManifest, _, err := c.manifest(sigRef)
+	if code, ok := err.(validation.ErrorCode); ok && code.Status == http.StatusNotFound {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, layer := range sigManifest.Layers {
+		if layer.MediaType != cosignSignatureMediaType {
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(layer.Annotations[cosignSignatureAnnotation])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cosign signature in %s: %w", sigRef, err)
+		}
+		payload, err := c.blob(sigRef, layer, maxConfigSize)
+		if err != nil {
+			return nil, err
+		}
+		result.Cosign = append(result.Cosign, CosignSignature{
+			Payload:   payload,
+			Signature: signature,
+		})
+	}
+	return result, nil
+}
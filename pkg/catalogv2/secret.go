@@ -7,13 +7,21 @@
 )
 
 func GetSecret(secrets corev1controllers.SecretCache, repoSpec *v1.RepoSpec, repoNamespace string) (*corev1.Secret, error) {
-	if repoSpec.ClientSecret == nil {
+	return getSecret(secrets, repoSpec.ClientSecret, repoNamespace)
+}
+
+func GetKeyringSecret(secrets corev1controllers.SecretCache, repoSpec *v1.RepoSpec, repoNamespace string) (*corev1.Secret, error) {
+	return getSecret(secrets, repoSpec.KeyringSecret, repoNamespace)
+}
+
+func getSecret(secrets corev1controllers.SecretCache, ref *v1.SecretReference, repoNamespace string) (*corev1.Secret, error) {
+	if ref == nil {
 		return nil, nil
 	}
-	ns := repoSpec.ClientSecret.Namespace
+	ns := ref.Namespace
 	if repoNamespace != "" {
 		ns = repoNamespace
 	}
 
-	return secrets.Get(ns, repoSpec.ClientSecret.Name)
+	return secrets.Get(ns, ref.Name)
 }
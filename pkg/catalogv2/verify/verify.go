@@ -0,0 +1,167 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"helm.sh/helm/v3/pkg/provenance"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// KeyringKey is the key of the GPG keyring in a keyring secret.
+	KeyringKey = "keyring"
+	// CosignKey is the key of the PEM encoded cosign public keys in a keyring secret.
+	CosignKey = "cosign.pub"
+)
+
+// Keyring holds the public keys the charts of a repo must be signed with.
+type Keyring struct {
+	pgp    openpgp.EntityList
+	cosign []crypto.PublicKey
+}
+
+// KeyringFromSecret reads the GPG keyring and cosign public keys of a keyring secret. The
+// GPG keyring can be armored or binary like the pubring.gpg used by helm.
+func KeyringFromSecret(secret *corev1.Secret) (*Keyring, error) {
+	k := &Keyring{}
+	if data := secret.Data[KeyringKey]; len(data) > 0 {
+		ring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+		if err != nil {
+			ring, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keyring of secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+		k.pgp = ring
+	}
+
+	rest := secret.Data[CosignKey]
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cosign public key of secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+		k.cosign = append(k.cosign, key)
+	}
+
+	if len(k.pgp) == 0 && len(k.cosign) == 0 {
+		return nil, fmt.Errorf("keyring secret %s/%s has no %s or %s keys", secret.Namespace, secret.Name, KeyringKey, CosignKey)
+	}
+	return k, nil
+}
+
+// HasProvenanceKeys returns true if charts must have a provenance file signed by the keyring.
+func (k *Keyring) HasProvenanceKeys() bool {
+	return len(k.pgp) > 0
+}
+
+// HasCosignKeys returns true if OCI charts must have a cosign signature.
+func (k *Keyring) HasCosignKeys() bool {
+	return len(k.cosign) > 0
+}
+
+// Provenance verifies that the provenance file of a chart is signed by a key of the keyring
+// and that it has the digest of the chart archive, chartFile is the name of the archive.
+func (k *Keyring) Provenance(chartFile string, chart, prov []byte) error {
+	if len(prov) == 0 {
+		return errors.New("chart has no provenance file")
+	}
+	block, _ := clearsign.Decode(prov)
+	if block == nil {
+		return errors.New("signature block not found in provenance file")
+	}
+	if _, err := openpgp.CheckDetachedSignature(k.pgp, bytes.NewBuffer(block.Bytes), block.ArmoredSignature.Body); err != nil {
+		return fmt.Errorf("provenance file is not signed by a trusted key: %w", err)
+	}
+
+	// the signed message is the chart metadata and the digests of the files, separated by a
+	// yaml document end marker
+	parts := bytes.Split(block.Plaintext, []byte("\n...\n"))
+	if len(parts) < 2 {
+		return errors.New("invalid provenance file, message block must have at least two parts")
+	}
+	sums := &provenance.SumCollection{}
+	if err := yaml.Unmarshal(parts[1], sums); err != nil {
+		return fmt.Errorf("invalid provenance file: %w", err)
+	}
+	digest, err := provenance.Digest(bytes.NewReader(chart))
+	if err != nil {
+		return err
+	}
+	sum, ok := sums.Files[chartFile]
+	if !ok {
+		return fmt.Errorf("provenance file does not contain a digest for %s", chartFile)
+	}
+	if sum != "sha256:"+digest {
+		return fmt.Errorf("digest of %s does not match the provenance file", chartFile)
+	}
+	return nil
+}
+
+// cosignPayload is the simple signing payload cosign signs for an image manifest.
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// Cosign verifies that a cosign signature is made by a key of the keyring and that its
+// payload is for the manifest digest.
+func (k *Keyring) Cosign(manifestDigest string, payload, signature []byte) error {
+	p := &cosignPayload{}
+	if err := json.Unmarshal(payload, p); err != nil {
+		return fmt.Errorf("invalid cosign payload: %w", err)
+	}
+	if p.Critical.Image.DockerManifestDigest != manifestDigest {
+		return fmt.Errorf("cosign signature is for manifest %s, not %s", p.Critical.Image.DockerManifestDigest, manifestDigest)
+	}
+
+	if err := k.Signature(payload, signature); err != nil {
+		return errors.New("cosign signature is not made by a trusted key")
+	}
+	return nil
+}
+
+// Signature verifies that the signature of the payload is made by one of the cosign public
+// keys of the keyring.
+func (k *Keyring) Signature(payload, signature []byte) error {
+	sum := sha256.Sum256(payload)
+	for _, key := range k.cosign {
+		switch key := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(key, sum[:], signature) {
+				return nil
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature) == nil {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, payload, signature) {
+				return nil
+			}
+		}
+	}
+	return errors.New("signature is not made by a trusted key")
+}
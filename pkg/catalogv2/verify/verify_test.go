@@ -0,0 +1,102 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"helm.sh/helm/v3/pkg/provenance"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newEntity(t *testing.T, name string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	require.NoError(t, err)
+	return entity
+}
+
+func armoredKeyring(t *testing.T, entity *openpgp.Entity) []byte {
+	buf := &bytes.Buffer{}
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func sign(t *testing.T, entity *openpgp.Entity, chartFile string, chart []byte) []byte {
+	digest, err := provenance.Digest(bytes.NewReader(chart))
+	require.NoError(t, err)
+	message := fmt.Sprintf("apiVersion: v2\nname: app\nversion: 1.0.0\n\n...\nfiles:\n  %s: sha256:%s\n", chartFile, digest)
+
+	buf := &bytes.Buffer{}
+	w, err := clearsign.Encode(buf, entity.PrivateKey, nil)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(message))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestProvenance(t *testing.T) {
+	trusted := newEntity(t, "trusted")
+	untrusted := newEntity(t, "untrusted")
+	chart := []byte("app-1.0.0")
+
+	keyring, err := KeyringFromSecret(&corev1.Secret{Data: map[string][]byte{KeyringKey: armoredKeyring(t, trusted)}})
+	require.NoError(t, err)
+	assert.True(t, keyring.HasProvenanceKeys())
+	assert.False(t, keyring.HasCosignKeys())
+
+	assert.NoError(t, keyring.Provenance("app-1.0.0.tgz", chart, sign(t, trusted, "app-1.0.0.tgz", chart)))
+	assert.Error(t, keyring.Provenance("app-1.0.0.tgz", chart, sign(t, untrusted, "app-1.0.0.tgz", chart)), "signed by an untrusted key")
+	assert.Error(t, keyring.Provenance("app-1.0.0.tgz", []byte("modified"), sign(t, trusted, "app-1.0.0.tgz", chart)), "chart was modified")
+	assert.Error(t, keyring.Provenance("app-1.0.1.tgz", chart, sign(t, trusted, "app-1.0.0.tgz", chart)), "no digest for the chart")
+	assert.Error(t, keyring.Provenance("app-1.0.0.tgz", chart, nil), "no provenance file")
+}
+
+func TestCosign(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	keyring, err := KeyringFromSecret(&corev1.Secret{Data: map[string][]byte{
+		CosignKey: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+	}})
+	require.NoError(t, err)
+	assert.True(t, keyring.HasCosignKeys())
+	assert.False(t, keyring.HasProvenanceKeys())
+
+	payload := []byte(`{"critical":{"identity":{"docker-reference":"registry.example.com/charts/app"},"image":{"docker-manifest-digest":"sha256:abc"},"type":"cosign container image signature"},"optional":null}`)
+	sum := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	require.NoError(t, err)
+	otherSignature, err := ecdsa.SignASN1(rand.Reader, other, sum[:])
+	require.NoError(t, err)
+
+	assert.NoError(t, keyring.Cosign("sha256:abc", payload, signature))
+	assert.Error(t, keyring.Cosign("sha256:def", payload, signature), "signature is for another manifest")
+	assert.Error(t, keyring.Cosign("sha256:abc", payload, otherSignature), "signed by an untrusted key")
+}
+
+func TestKeyringFromSecret(t *testing.T) {
+	_, err := KeyringFromSecret(&corev1.Secret{Data: map[string][]byte{}})
+	assert.Error(t, err, "a keyring without keys would accept nothing")
+
+	_, err = KeyringFromSecret(&corev1.Secret{Data: map[string][]byte{KeyringKey: []byte("not a keyring")}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,18 @@
+package client
+
+const (
+	AzureBlobBackupTargetType                = "azureBlobBackupTarget"
+	AzureBlobBackupTargetFieldAccountKey     = "accountKey"
+	AzureBlobBackupTargetFieldAccountName    = "accountName"
+	AzureBlobBackupTargetFieldContainer      = "container"
+	AzureBlobBackupTargetFieldEndpointSuffix = "endpointSuffix"
+	AzureBlobBackupTargetFieldFolder         = "folder"
+)
+
+type AzureBlobBackupTarget struct {
+	AccountKey     string `json:"accountKey,omitempty" yaml:"accountKey,omitempty"`
+	AccountName    string `json:"accountName,omitempty" yaml:"accountName,omitempty"`
+	Container      string `json:"container,omitempty" yaml:"container,omitempty"`
+	EndpointSuffix string `json:"endpointSuffix,omitempty" yaml:"endpointSuffix,omitempty"`
+	Folder         string `json:"folder,omitempty" yaml:"folder,omitempty"`
+}
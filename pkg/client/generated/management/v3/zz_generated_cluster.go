@@ -45,6 +45,9 @@
 	ClusterFieldEnableClusterAlerting                = "enableClusterAlerting"
 	ClusterFieldEnableClusterMonitoring              = "enableClusterMonitoring"
 	ClusterFieldEnableNetworkPolicy                  = "enableNetworkPolicy"
+	ClusterFieldEtcdBackupRetention                  = "etcdBackupRetention"
+	ClusterFieldEtcdBackupTarget                     = "etcdBackupTarget"
+	ClusterFieldEtcdRestoreDrill                     = "etcdRestoreDrill"
 	ClusterFieldFailedSpec                           = "failedSpec"
 	ClusterFieldFleetWorkspaceName                   = "fleetWorkspaceName"
 	ClusterFieldGKEConfig                            = "gkeConfig"
@@ -117,6 +120,9 @@ type Cluster struct {
 	EnableClusterAlerting                bool                           `json:"enableClusterAlerting,omitempty" yaml:"enableClusterAlerting,omitempty"`
 	EnableClusterMonitoring              bool                           `json:"enableClusterMonitoring,omitempty" yaml:"enableClusterMonitoring,omitempty"`
 	EnableNetworkPolicy                  *bool                          `json:"enableNetworkPolicy,omitempty" yaml:"enableNetworkPolicy,omitempty"`
+	EtcdBackupRetention                  []EtcdBackupRetentionTier      `json:"etcdBackupRetention,omitempty" yaml:"etcdBackupRetention,omitempty"`
+	EtcdBackupTarget                     *EtcdBackupTarget              `json:"etcdBackupTarget,omitempty" yaml:"etcdBackupTarget,omitempty"`
+	EtcdRestoreDrill                     *EtcdRestoreDrillConfig        `json:"etcdRestoreDrill,omitempty" yaml:"etcdRestoreDrill,omitempty"`
 	FailedSpec                           *ClusterSpec                   `json:"failedSpec,omitempty" yaml:"failedSpec,omitempty"`
 	FleetWorkspaceName                   string                         `json:"fleetWorkspaceName,omitempty" yaml:"fleetWorkspaceName,omitempty"`
 	GKEConfig                            *GKEClusterConfigSpec          `json:"gkeConfig,omitempty" yaml:"gkeConfig,omitempty"`
@@ -181,6 +187,8 @@ type ClusterOperations interface {
 
 	ActionImportYaml(resource *Cluster, input *ImportClusterYamlInput) (*ImportYamlOutput, error)
 
+	ActionPreviewEtcdRetention(resource *Cluster, input *PreviewEtcdRetentionInput) (*PreviewEtcdRetentionOutput, error)
+
 	ActionRestoreFromEtcdBackup(resource *Cluster, input *RestoreFromEtcdBackupInput) error
 
 	ActionRotateCertificates(resource *Cluster, input *RotateCertificateInput) (*RotateCertificateOutput, error)
@@ -301,6 +309,12 @@ func (c *ClusterClient) ActionImportYaml(resource *Cluster, input *ImportCluster
 	return resp, err
 }
 
+func (c *ClusterClient) ActionPreviewEtcdRetention(resource *Cluster, input *PreviewEtcdRetentionInput) (*PreviewEtcdRetentionOutput, error) {
+	resp := &PreviewEtcdRetentionOutput{}
+	err := c.apiClient.Ops.DoAction(ClusterType, "previewEtcdRetention", &resource.Resource, input, resp)
+	return resp, err
+}
+
 func (c *ClusterClient) ActionRestoreFromEtcdBackup(resource *Cluster, input *RestoreFromEtcdBackupInput) error {
 	err := c.apiClient.Ops.DoAction(ClusterType, "restoreFromEtcdBackup", &resource.Resource, input, nil)
 	return err
@@ -12,6 +12,7 @@
 	ClusterAlertRuleFieldClusterScanRule       = "clusterScanRule"
 	ClusterAlertRuleFieldCreated               = "created"
 	ClusterAlertRuleFieldCreatorID             = "creatorId"
+	ClusterAlertRuleFieldEtcdRestoreDrillRule  = "etcdRestoreDrillRule"
 	ClusterAlertRuleFieldEventRule             = "eventRule"
 	ClusterAlertRuleFieldGroupID               = "groupId"
 	ClusterAlertRuleFieldGroupIntervalSeconds  = "groupIntervalSeconds"
@@ -35,31 +36,32 @@
 
 type ClusterAlertRule struct {
 	types.Resource
-	AlertState            string             `json:"alertState,omitempty" yaml:"alertState,omitempty"`
-	Annotations           map[string]string  `json:"annotations,omitempty" yaml:"annotations,omitempty"`
-	ClusterID             string             `json:"clusterId,omitempty" yaml:"clusterId,omitempty"`
-	ClusterScanRule       *ClusterScanRule   `json:"clusterScanRule,omitempty" yaml:"clusterScanRule,omitempty"`
-	Created               string             `json:"created,omitempty" yaml:"created,omitempty"`
-	CreatorID             string             `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
-	EventRule             *EventRule         `json:"eventRule,omitempty" yaml:"eventRule,omitempty"`
-	GroupID               string             `json:"groupId,omitempty" yaml:"groupId,omitempty"`
-	GroupIntervalSeconds  int64              `json:"groupIntervalSeconds,omitempty" yaml:"groupIntervalSeconds,omitempty"`
-	GroupWaitSeconds      int64              `json:"groupWaitSeconds,omitempty" yaml:"groupWaitSeconds,omitempty"`
-	Inherited             *bool              `json:"inherited,omitempty" yaml:"inherited,omitempty"`
-	Labels                map[string]string  `json:"labels,omitempty" yaml:"labels,omitempty"`
-	MetricRule            *MetricRule        `json:"metricRule,omitempty" yaml:"metricRule,omitempty"`
-	Name                  string             `json:"name,omitempty" yaml:"name,omitempty"`
-	NamespaceId           string             `json:"namespaceId,omitempty" yaml:"namespaceId,omitempty"`
-	NodeRule              *NodeRule          `json:"nodeRule,omitempty" yaml:"nodeRule,omitempty"`
-	OwnerReferences       []OwnerReference   `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
-	Removed               string             `json:"removed,omitempty" yaml:"removed,omitempty"`
-	RepeatIntervalSeconds int64              `json:"repeatIntervalSeconds,omitempty" yaml:"repeatIntervalSeconds,omitempty"`
-	Severity              string             `json:"severity,omitempty" yaml:"severity,omitempty"`
-	State                 string             `json:"state,omitempty" yaml:"state,omitempty"`
-	SystemServiceRule     *SystemServiceRule `json:"systemServiceRule,omitempty" yaml:"systemServiceRule,omitempty"`
-	Transitioning         string             `json:"transitioning,omitempty" yaml:"transitioning,omitempty"`
-	TransitioningMessage  string             `json:"transitioningMessage,omitempty" yaml:"transitioningMessage,omitempty"`
-	UUID                  string             `json:"uuid,omitempty" yaml:"uuid,omitempty"`
+	AlertState            string                `json:"alertState,omitempty" yaml:"alertState,omitempty"`
+	Annotations           map[string]string     `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	ClusterID             string                `json:"clusterId,omitempty" yaml:"clusterId,omitempty"`
+	ClusterScanRule       *ClusterScanRule      `json:"clusterScanRule,omitempty" yaml:"clusterScanRule,omitempty"`
+	Created               string                `json:"created,omitempty" yaml:"created,omitempty"`
+	CreatorID             string                `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
+	EtcdRestoreDrillRule  *EtcdRestoreDrillRule `json:"etcdRestoreDrillRule,omitempty" yaml:"etcdRestoreDrillRule,omitempty"`
+	EventRule             *EventRule            `json:"eventRule,omitempty" yaml:"eventRule,omitempty"`
+	GroupID               string                `json:"groupId,omitempty" yaml:"groupId,omitempty"`
+	GroupIntervalSeconds  int64                 `json:"groupIntervalSeconds,omitempty" yaml:"groupIntervalSeconds,omitempty"`
+	GroupWaitSeconds      int64                 `json:"groupWaitSeconds,omitempty" yaml:"groupWaitSeconds,omitempty"`
+	Inherited             *bool                 `json:"inherited,omitempty" yaml:"inherited,omitempty"`
+	Labels                map[string]string     `json:"labels,omitempty" yaml:"labels,omitempty"`
+	MetricRule            *MetricRule           `json:"metricRule,omitempty" yaml:"metricRule,omitempty"`
+	Name                  string                `json:"name,omitempty" yaml:"name,omitempty"`
+	NamespaceId           string                `json:"namespaceId,omitempty" yaml:"namespaceId,omitempty"`
+	NodeRule              *NodeRule             `json:"nodeRule,omitempty" yaml:"nodeRule,omitempty"`
+	OwnerReferences       []OwnerReference      `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
+	Removed               string                `json:"removed,omitempty" yaml:"removed,omitempty"`
+	RepeatIntervalSeconds int64                 `json:"repeatIntervalSeconds,omitempty" yaml:"repeatIntervalSeconds,omitempty"`
+	Severity              string                `json:"severity,omitempty" yaml:"severity,omitempty"`
+	State                 string                `json:"state,omitempty" yaml:"state,omitempty"`
+	SystemServiceRule     *SystemServiceRule    `json:"systemServiceRule,omitempty" yaml:"systemServiceRule,omitempty"`
+	Transitioning         string                `json:"transitioning,omitempty" yaml:"transitioning,omitempty"`
+	TransitioningMessage  string                `json:"transitioningMessage,omitempty" yaml:"transitioningMessage,omitempty"`
+	UUID                  string                `json:"uuid,omitempty" yaml:"uuid,omitempty"`
 }
 
 type ClusterAlertRuleCollection struct {
@@ -22,6 +22,9 @@
 	ClusterSpecFieldEnableClusterAlerting               = "enableClusterAlerting"
 	ClusterSpecFieldEnableClusterMonitoring             = "enableClusterMonitoring"
 	ClusterSpecFieldEnableNetworkPolicy                 = "enableNetworkPolicy"
+	ClusterSpecFieldEtcdBackupRetention                 = "etcdBackupRetention"
+	ClusterSpecFieldEtcdBackupTarget                    = "etcdBackupTarget"
+	ClusterSpecFieldEtcdRestoreDrill                    = "etcdRestoreDrill"
 	ClusterSpecFieldFleetWorkspaceName                  = "fleetWorkspaceName"
 	ClusterSpecFieldGKEConfig                           = "gkeConfig"
 	ClusterSpecFieldGenericEngineConfig                 = "genericEngineConfig"
@@ -57,6 +60,9 @@ type ClusterSpec struct {
 	EnableClusterAlerting               bool                           `json:"enableClusterAlerting,omitempty" yaml:"enableClusterAlerting,omitempty"`
 	EnableClusterMonitoring             bool                           `json:"enableClusterMonitoring,omitempty" yaml:"enableClusterMonitoring,omitempty"`
 	EnableNetworkPolicy                 *bool                          `json:"enableNetworkPolicy,omitempty" yaml:"enableNetworkPolicy,omitempty"`
+	EtcdBackupRetention                 []EtcdBackupRetentionTier      `json:"etcdBackupRetention,omitempty" yaml:"etcdBackupRetention,omitempty"`
+	EtcdBackupTarget                    *EtcdBackupTarget              `json:"etcdBackupTarget,omitempty" yaml:"etcdBackupTarget,omitempty"`
+	EtcdRestoreDrill                    *EtcdRestoreDrillConfig        `json:"etcdRestoreDrill,omitempty" yaml:"etcdRestoreDrill,omitempty"`
 	FleetWorkspaceName                  string                         `json:"fleetWorkspaceName,omitempty" yaml:"fleetWorkspaceName,omitempty"`
 	GKEConfig                           *GKEClusterConfigSpec          `json:"gkeConfig,omitempty" yaml:"gkeConfig,omitempty"`
 	GenericEngineConfig                 map[string]interface{}         `json:"genericEngineConfig,omitempty" yaml:"genericEngineConfig,omitempty"`
@@ -0,0 +1,14 @@
+package client
+
+const (
+	DeliveryConfigType                    = "deliveryConfig"
+	DeliveryConfigFieldDedupWindowSeconds = "dedupWindowSeconds"
+	DeliveryConfigFieldMaxRetries         = "maxRetries"
+	DeliveryConfigFieldRateLimitPerMinute = "rateLimitPerMinute"
+)
+
+type DeliveryConfig struct {
+	DedupWindowSeconds int64 `json:"dedupWindowSeconds,omitempty" yaml:"dedupWindowSeconds,omitempty"`
+	MaxRetries         int64 `json:"maxRetries,omitempty" yaml:"maxRetries,omitempty"`
+	RateLimitPerMinute int64 `json:"rateLimitPerMinute,omitempty" yaml:"rateLimitPerMinute,omitempty"`
+}
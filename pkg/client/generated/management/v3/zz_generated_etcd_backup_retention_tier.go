@@ -0,0 +1,12 @@
+package client
+
+const (
+	EtcdBackupRetentionTierType               = "etcdBackupRetentionTier"
+	EtcdBackupRetentionTierFieldIntervalHours = "intervalHours"
+	EtcdBackupRetentionTierFieldKeep          = "keep"
+)
+
+type EtcdBackupRetentionTier struct {
+	IntervalHours int64 `json:"intervalHours,omitempty" yaml:"intervalHours,omitempty"`
+	Keep          int64 `json:"keep,omitempty" yaml:"keep,omitempty"`
+}
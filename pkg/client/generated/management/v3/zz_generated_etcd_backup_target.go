@@ -0,0 +1,18 @@
+package client
+
+const (
+	EtcdBackupTargetType                     = "etcdBackupTarget"
+	EtcdBackupTargetFieldAzureBlob           = "azureBlob"
+	EtcdBackupTargetFieldEncryptionKeySecret = "encryptionKeySecret"
+	EtcdBackupTargetFieldFilesystem          = "filesystem"
+	EtcdBackupTargetFieldGCS                 = "gcs"
+	EtcdBackupTargetFieldSFTP                = "sftp"
+)
+
+type EtcdBackupTarget struct {
+	AzureBlob           *AzureBlobBackupTarget  `json:"azureBlob,omitempty" yaml:"azureBlob,omitempty"`
+	EncryptionKeySecret string                  `json:"encryptionKeySecret,omitempty" yaml:"encryptionKeySecret,omitempty"`
+	Filesystem          *FilesystemBackupTarget `json:"filesystem,omitempty" yaml:"filesystem,omitempty"`
+	GCS                 *GCSBackupTarget        `json:"gcs,omitempty" yaml:"gcs,omitempty"`
+	SFTP                *SFTPBackupTarget       `json:"sftp,omitempty" yaml:"sftp,omitempty"`
+}
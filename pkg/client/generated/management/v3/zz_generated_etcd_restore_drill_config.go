@@ -0,0 +1,16 @@
+package client
+
+const (
+	EtcdRestoreDrillConfigType                    = "etcdRestoreDrillConfig"
+	EtcdRestoreDrillConfigFieldEnabled            = "enabled"
+	EtcdRestoreDrillConfigFieldIntervalHours      = "intervalHours"
+	EtcdRestoreDrillConfigFieldMinKeys            = "minKeys"
+	EtcdRestoreDrillConfigFieldRequiredNamespaces = "requiredNamespaces"
+)
+
+type EtcdRestoreDrillConfig struct {
+	Enabled            bool     `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	IntervalHours      int64    `json:"intervalHours,omitempty" yaml:"intervalHours,omitempty"`
+	MinKeys            int64    `json:"minKeys,omitempty" yaml:"minKeys,omitempty"`
+	RequiredNamespaces []string `json:"requiredNamespaces,omitempty" yaml:"requiredNamespaces,omitempty"`
+}
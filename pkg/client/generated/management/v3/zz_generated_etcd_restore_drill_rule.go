@@ -0,0 +1,10 @@
+package client
+
+const (
+	EtcdRestoreDrillRuleType              = "etcdRestoreDrillRule"
+	EtcdRestoreDrillRuleFieldFailuresOnly = "failuresOnly"
+)
+
+type EtcdRestoreDrillRule struct {
+	FailuresOnly bool `json:"failuresOnly,omitempty" yaml:"failuresOnly,omitempty"`
+}
@@ -0,0 +1,10 @@
+package client
+
+const (
+	FilesystemBackupTargetType      = "filesystemBackupTarget"
+	FilesystemBackupTargetFieldPath = "path"
+)
+
+type FilesystemBackupTarget struct {
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+}
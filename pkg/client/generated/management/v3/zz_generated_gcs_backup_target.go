@@ -0,0 +1,14 @@
+package client
+
+const (
+	GCSBackupTargetType                   = "gcsBackupTarget"
+	GCSBackupTargetFieldBucket            = "bucket"
+	GCSBackupTargetFieldFolder            = "folder"
+	GCSBackupTargetFieldServiceAccountKey = "serviceAccountKey"
+)
+
+type GCSBackupTarget struct {
+	Bucket            string `json:"bucket,omitempty" yaml:"bucket,omitempty"`
+	Folder            string `json:"folder,omitempty" yaml:"folder,omitempty"`
+	ServiceAccountKey string `json:"serviceAccountKey,omitempty" yaml:"serviceAccountKey,omitempty"`
+}
@@ -0,0 +1,16 @@
+package client
+
+const (
+	MatrixConfigType                  = "matrixConfig"
+	MatrixConfigFieldAccessToken      = "accessToken"
+	MatrixConfigFieldDefaultRecipient = "defaultRecipient"
+	MatrixConfigFieldHomeserverURL    = "homeserverUrl"
+	MatrixConfigFieldProxyURL         = "proxyUrl"
+)
+
+type MatrixConfig struct {
+	AccessToken      string `json:"accessToken,omitempty" yaml:"accessToken,omitempty"`
+	DefaultRecipient string `json:"defaultRecipient,omitempty" yaml:"defaultRecipient,omitempty"`
+	HomeserverURL    string `json:"homeserverUrl,omitempty" yaml:"homeserverUrl,omitempty"`
+	ProxyURL         string `json:"proxyUrl,omitempty" yaml:"proxyUrl,omitempty"`
+}
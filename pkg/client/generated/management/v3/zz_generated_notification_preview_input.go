@@ -0,0 +1,18 @@
+package client
+
+const (
+	NotificationPreviewInputType             = "notificationPreviewInput"
+	NotificationPreviewInputFieldAnnotations = "annotations"
+	NotificationPreviewInputFieldContent     = "content"
+	NotificationPreviewInputFieldLabels      = "labels"
+	NotificationPreviewInputFieldTemplate    = "template"
+	NotificationPreviewInputFieldTitle       = "title"
+)
+
+type NotificationPreviewInput struct {
+	Annotations map[string]string     `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	Content     string                `json:"content,omitempty" yaml:"content,omitempty"`
+	Labels      map[string]string     `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Template    *NotificationTemplate `json:"template,omitempty" yaml:"template,omitempty"`
+	Title       string                `json:"title,omitempty" yaml:"title,omitempty"`
+}
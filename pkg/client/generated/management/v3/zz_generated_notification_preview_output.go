@@ -0,0 +1,12 @@
+package client
+
+const (
+	NotificationPreviewOutputType       = "notificationPreviewOutput"
+	NotificationPreviewOutputFieldBody  = "body"
+	NotificationPreviewOutputFieldTitle = "title"
+)
+
+type NotificationPreviewOutput struct {
+	Body  string `json:"body,omitempty" yaml:"body,omitempty"`
+	Title string `json:"title,omitempty" yaml:"title,omitempty"`
+}
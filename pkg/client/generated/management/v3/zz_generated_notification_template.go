@@ -0,0 +1,12 @@
+package client
+
+const (
+	NotificationTemplateType       = "notificationTemplate"
+	NotificationTemplateFieldBody  = "body"
+	NotificationTemplateFieldTitle = "title"
+)
+
+type NotificationTemplate struct {
+	Body  string `json:"body,omitempty" yaml:"body,omitempty"`
+	Title string `json:"title,omitempty" yaml:"title,omitempty"`
+}
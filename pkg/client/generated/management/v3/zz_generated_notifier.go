@@ -10,20 +10,26 @@
 	NotifierFieldClusterID            = "clusterId"
 	NotifierFieldCreated              = "created"
 	NotifierFieldCreatorID            = "creatorId"
+	NotifierFieldDeliveryConfig       = "deliveryConfig"
 	NotifierFieldDescription          = "description"
 	NotifierFieldDingtalkConfig       = "dingtalkConfig"
+	NotifierFieldGoogleChatConfig     = "googleChatConfig"
 	NotifierFieldLabels               = "labels"
 	NotifierFieldMSTeamsConfig        = "msteamsConfig"
+	NotifierFieldMatrixConfig         = "matrixConfig"
+	NotifierFieldMattermostConfig     = "mattermostConfig"
 	NotifierFieldName                 = "name"
 	NotifierFieldNamespaceId          = "namespaceId"
 	NotifierFieldOwnerReferences      = "ownerReferences"
 	NotifierFieldPagerdutyConfig      = "pagerdutyConfig"
 	NotifierFieldRemoved              = "removed"
+	NotifierFieldRocketChatConfig     = "rocketChatConfig"
 	NotifierFieldSMTPConfig           = "smtpConfig"
 	NotifierFieldSendResolved         = "sendResolved"
 	NotifierFieldSlackConfig          = "slackConfig"
 	NotifierFieldState                = "state"
 	NotifierFieldStatus               = "status"
+	NotifierFieldTemplate             = "template"
 	NotifierFieldTransitioning        = "transitioning"
 	NotifierFieldTransitioningMessage = "transitioningMessage"
 	NotifierFieldUUID                 = "uuid"
@@ -33,29 +39,35 @@
 
 type Notifier struct {
 	types.Resource
-	Annotations          map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
-	ClusterID            string            `json:"clusterId,omitempty" yaml:"clusterId,omitempty"`
-	Created              string            `json:"created,omitempty" yaml:"created,omitempty"`
-	CreatorID            string            `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
-	Description          string            `json:"description,omitempty" yaml:"description,omitempty"`
-	DingtalkConfig       *DingtalkConfig   `json:"dingtalkConfig,omitempty" yaml:"dingtalkConfig,omitempty"`
-	Labels               map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
-	MSTeamsConfig        *MSTeamsConfig    `json:"msteamsConfig,omitempty" yaml:"msteamsConfig,omitempty"`
-	Name                 string            `json:"name,omitempty" yaml:"name,omitempty"`
-	NamespaceId          string            `json:"namespaceId,omitempty" yaml:"namespaceId,omitempty"`
-	OwnerReferences      []OwnerReference  `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
-	PagerdutyConfig      *PagerdutyConfig  `json:"pagerdutyConfig,omitempty" yaml:"pagerdutyConfig,omitempty"`
-	Removed              string            `json:"removed,omitempty" yaml:"removed,omitempty"`
-	SMTPConfig           *SMTPConfig       `json:"smtpConfig,omitempty" yaml:"smtpConfig,omitempty"`
-	SendResolved         bool              `json:"sendResolved,omitempty" yaml:"sendResolved,omitempty"`
-	SlackConfig          *SlackConfig      `json:"slackConfig,omitempty" yaml:"slackConfig,omitempty"`
-	State                string            `json:"state,omitempty" yaml:"state,omitempty"`
-	Status               *NotifierStatus   `json:"status,omitempty" yaml:"status,omitempty"`
-	Transitioning        string            `json:"transitioning,omitempty" yaml:"transitioning,omitempty"`
-	TransitioningMessage string            `json:"transitioningMessage,omitempty" yaml:"transitioningMessage,omitempty"`
-	UUID                 string            `json:"uuid,omitempty" yaml:"uuid,omitempty"`
-	WebhookConfig        *WebhookConfig    `json:"webhookConfig,omitempty" yaml:"webhookConfig,omitempty"`
-	WechatConfig         *WechatConfig     `json:"wechatConfig,omitempty" yaml:"wechatConfig,omitempty"`
+	Annotations          map[string]string     `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	ClusterID            string                `json:"clusterId,omitempty" yaml:"clusterId,omitempty"`
+	Created              string                `json:"created,omitempty" yaml:"created,omitempty"`
+	CreatorID            string                `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
+	DeliveryConfig       *DeliveryConfig       `json:"deliveryConfig,omitempty" yaml:"deliveryConfig,omitempty"`
+	Description          string                `json:"description,omitempty" yaml:"description,omitempty"`
+	DingtalkConfig       *DingtalkConfig       `json:"dingtalkConfig,omitempty" yaml:"dingtalkConfig,omitempty"`
+	GoogleChatConfig     *GoogleChatConfig     `json:"googleChatConfig,omitempty" yaml:"googleChatConfig,omitempty"`
+	Labels               map[string]string     `json:"labels,omitempty" yaml:"labels,omitempty"`
+	MSTeamsConfig        *MSTeamsConfig        `json:"msteamsConfig,omitempty" yaml:"msteamsConfig,omitempty"`
+	MatrixConfig         *MatrixConfig         `json:"matrixConfig,omitempty" yaml:"matrixConfig,omitempty"`
+	MattermostConfig     *MattermostConfig     `json:"mattermostConfig,omitempty" yaml:"mattermostConfig,omitempty"`
+	Name                 string                `json:"name,omitempty" yaml:"name,omitempty"`
+	NamespaceId          string                `json:"namespaceId,omitempty" yaml:"namespaceId,omitempty"`
+	OwnerReferences      []OwnerReference      `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
+	PagerdutyConfig      *PagerdutyConfig      `json:"pagerdutyConfig,omitempty" yaml:"pagerdutyConfig,omitempty"`
+	Removed              string                `json:"removed,omitempty" yaml:"removed,omitempty"`
+	RocketChatConfig     *RocketChatConfig     `json:"rocketChatConfig,omitempty" yaml:"rocketChatConfig,omitempty"`
+	SMTPConfig           *SMTPConfig           `json:"smtpConfig,omitempty" yaml:"smtpConfig,omitempty"`
+	SendResolved         bool                  `json:"sendResolved,omitempty" yaml:"sendResolved,omitempty"`
+	SlackConfig          *SlackConfig          `json:"slackConfig,omitempty" yaml:"slackConfig,omitempty"`
+	State                string                `json:"state,omitempty" yaml:"state,omitempty"`
+	Status               *NotifierStatus       `json:"status,omitempty" yaml:"status,omitempty"`
+	Template             *NotificationTemplate `json:"template,omitempty" yaml:"template,omitempty"`
+	Transitioning        string                `json:"transitioning,omitempty" yaml:"transitioning,omitempty"`
+	TransitioningMessage string                `json:"transitioningMessage,omitempty" yaml:"transitioningMessage,omitempty"`
+	UUID                 string                `json:"uuid,omitempty" yaml:"uuid,omitempty"`
+	WebhookConfig        *WebhookConfig        `json:"webhookConfig,omitempty" yaml:"webhookConfig,omitempty"`
+	WechatConfig         *WechatConfig         `json:"wechatConfig,omitempty" yaml:"wechatConfig,omitempty"`
 }
 
 type NotifierCollection struct {
@@ -77,8 +89,12 @@ type NotifierOperations interface {
 	ByID(id string) (*Notifier, error)
 	Delete(container *Notifier) error
 
+	ActionPreview(resource *Notifier, input *NotificationPreviewInput) (*NotificationPreviewOutput, error)
+
 	ActionSend(resource *Notifier, input *Notification) error
 
+	CollectionActionPreview(resource *NotifierCollection, input *NotificationPreviewInput) (*NotificationPreviewOutput, error)
+
 	CollectionActionSend(resource *NotifierCollection, input *Notification) error
 }
 
@@ -151,11 +167,23 @@ func (c *NotifierClient) Delete(container *Notifier) error {
 	return c.apiClient.Ops.DoResourceDelete(NotifierType, &container.Resource)
 }
 
+func (c *NotifierClient) ActionPreview(resource *Notifier, input *NotificationPreviewInput) (*NotificationPreviewOutput, error) {
+	resp := &NotificationPreviewOutput{}
+	err := c.apiClient.Ops.DoAction(NotifierType, "preview", &resource.Resource, input, resp)
+	return resp, err
+}
+
 func (c *NotifierClient) ActionSend(resource *Notifier, input *Notification) error {
 	err := c.apiClient.Ops.DoAction(NotifierType, "send", &resource.Resource, input, nil)
 	return err
 }
 
+func (c *NotifierClient) CollectionActionPreview(resource *NotifierCollection, input *NotificationPreviewInput) (*NotificationPreviewOutput, error) {
+	resp := &NotificationPreviewOutput{}
+	err := c.apiClient.Ops.DoCollectionAction(NotifierType, "preview", &resource.Collection, input, resp)
+	return resp, err
+}
+
 func (c *NotifierClient) CollectionActionSend(resource *NotifierCollection, input *Notification) error {
 	err := c.apiClient.Ops.DoCollectionAction(NotifierType, "send", &resource.Collection, input, nil)
 	return err
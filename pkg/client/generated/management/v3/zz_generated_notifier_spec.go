@@ -1,30 +1,42 @@
 package client
 
 const (
-	NotifierSpecType                 = "notifierSpec"
-	NotifierSpecFieldClusterID       = "clusterId"
-	NotifierSpecFieldDescription     = "description"
-	NotifierSpecFieldDingtalkConfig  = "dingtalkConfig"
-	NotifierSpecFieldDisplayName     = "displayName"
-	NotifierSpecFieldMSTeamsConfig   = "msteamsConfig"
-	NotifierSpecFieldPagerdutyConfig = "pagerdutyConfig"
-	NotifierSpecFieldSMTPConfig      = "smtpConfig"
-	NotifierSpecFieldSendResolved    = "sendResolved"
-	NotifierSpecFieldSlackConfig     = "slackConfig"
-	NotifierSpecFieldWebhookConfig   = "webhookConfig"
-	NotifierSpecFieldWechatConfig    = "wechatConfig"
+	NotifierSpecType                  = "notifierSpec"
+	NotifierSpecFieldClusterID        = "clusterId"
+	NotifierSpecFieldDeliveryConfig   = "deliveryConfig"
+	NotifierSpecFieldDescription      = "description"
+	NotifierSpecFieldDingtalkConfig   = "dingtalkConfig"
+	NotifierSpecFieldDisplayName      = "displayName"
+	NotifierSpecFieldGoogleChatConfig = "googleChatConfig"
+	NotifierSpecFieldMSTeamsConfig    = "msteamsConfig"
+	NotifierSpecFieldMatrixConfig     = "matrixConfig"
+	NotifierSpecFieldMattermostConfig = "mattermostConfig"
+	NotifierSpecFieldPagerdutyConfig  = "pagerdutyConfig"
+	NotifierSpecFieldRocketChatConfig = "rocketChatConfig"
+	NotifierSpecFieldSMTPConfig       = "smtpConfig"
+	NotifierSpecFieldSendResolved     = "sendResolved"
+	NotifierSpecFieldSlackConfig      = "slackConfig"
+	NotifierSpecFieldTemplate         = "template"
+	NotifierSpecFieldWebhookConfig    = "webhookConfig"
+	NotifierSpecFieldWechatConfig     = "wechatConfig"
 )
 
 type NotifierSpec struct {
-	ClusterID       string           `json:"clusterId,omitempty" yaml:"clusterId,omitempty"`
-	Description     string           `json:"description,omitempty" yaml:"description,omitempty"`
-	DingtalkConfig  *DingtalkConfig  `json:"dingtalkConfig,omitempty" yaml:"dingtalkConfig,omitempty"`
-	DisplayName     string           `json:"displayName,omitempty" yaml:"displayName,omitempty"`
-	MSTeamsConfig   *MSTeamsConfig   `json:"msteamsConfig,omitempty" yaml:"msteamsConfig,omitempty"`
-	PagerdutyConfig *PagerdutyConfig `json:"pagerdutyConfig,omitempty" yaml:"pagerdutyConfig,omitempty"`
-	SMTPConfig      *SMTPConfig      `json:"smtpConfig,omitempty" yaml:"smtpConfig,omitempty"`
-	SendResolved    bool             `json:"sendResolved,omitempty" yaml:"sendResolved,omitempty"`
-	SlackConfig     *SlackConfig     `json:"slackConfig,omitempty" yaml:"slackConfig,omitempty"`
-	WebhookConfig   *WebhookConfig   `json:"webhookConfig,omitempty" yaml:"webhookConfig,omitempty"`
-	WechatConfig    *WechatConfig    `json:"wechatConfig,omitempty" yaml:"wechatConfig,omitempty"`
+	ClusterID        string                `json:"clusterId,omitempty" yaml:"clusterId,omitempty"`
+	DeliveryConfig   *DeliveryConfig       `json:"deliveryConfig,omitempty" yaml:"deliveryConfig,omitempty"`
+	Description      string                `json:"description,omitempty" yaml:"description,omitempty"`
+	DingtalkConfig   *DingtalkConfig       `json:"dingtalkConfig,omitempty" yaml:"dingtalkConfig,omitempty"`
+	DisplayName      string                `json:"displayName,omitempty" yaml:"displayName,omitempty"`
+	GoogleChatConfig *GoogleChatConfig     `json:"googleChatConfig,omitempty" yaml:"googleChatConfig,omitempty"`
+	MSTeamsConfig    *MSTeamsConfig        `json:"msteamsConfig,omitempty" yaml:"msteamsConfig,omitempty"`
+	MatrixConfig     *MatrixConfig         `json:"matrixConfig,omitempty" yaml:"matrixConfig,omitempty"`
+	MattermostConfig *MattermostConfig     `json:"mattermostConfig,omitempty" yaml:"mattermostConfig,omitempty"`
+	PagerdutyConfig  *PagerdutyConfig      `json:"pagerdutyConfig,omitempty" yaml:"pagerdutyConfig,omitempty"`
+	RocketChatConfig *RocketChatConfig     `json:"rocketChatConfig,omitempty" yaml:"rocketChatConfig,omitempty"`
+	SMTPConfig       *SMTPConfig           `json:"smtpConfig,omitempty" yaml:"smtpConfig,omitempty"`
+	SendResolved     bool                  `json:"sendResolved,omitempty" yaml:"sendResolved,omitempty"`
+	SlackConfig      *SlackConfig          `json:"slackConfig,omitempty" yaml:"slackConfig,omitempty"`
+	Template         *NotificationTemplate `json:"template,omitempty" yaml:"template,omitempty"`
+	WebhookConfig    *WebhookConfig        `json:"webhookConfig,omitempty" yaml:"webhookConfig,omitempty"`
+	WechatConfig     *WechatConfig         `json:"wechatConfig,omitempty" yaml:"wechatConfig,omitempty"`
 }
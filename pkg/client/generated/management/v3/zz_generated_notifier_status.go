@@ -1,8 +1,16 @@
 package client
 
 const (
-	NotifierStatusType = "notifierStatus"
+	NotifierStatusType                 = "notifierStatus"
+	NotifierStatusFieldLastError       = "lastError"
+	NotifierStatusFieldLastErrorTime   = "lastErrorTime"
+	NotifierStatusFieldLastSuccessTime = "lastSuccessTime"
+	NotifierStatusFieldQueuedCount     = "queuedCount"
 )
 
 type NotifierStatus struct {
+	LastError       string `json:"lastError,omitempty" yaml:"lastError,omitempty"`
+	LastErrorTime   string `json:"lastErrorTime,omitempty" yaml:"lastErrorTime,omitempty"`
+	LastSuccessTime string `json:"lastSuccessTime,omitempty" yaml:"lastSuccessTime,omitempty"`
+	QueuedCount     int64  `json:"queuedCount,omitempty" yaml:"queuedCount,omitempty"`
 }
@@ -0,0 +1,10 @@
+package client
+
+const (
+	PreviewEtcdRetentionInputType       = "previewEtcdRetentionInput"
+	PreviewEtcdRetentionInputFieldTiers = "tiers"
+)
+
+type PreviewEtcdRetentionInput struct {
+	Tiers []EtcdBackupRetentionTier `json:"tiers,omitempty" yaml:"tiers,omitempty"`
+}
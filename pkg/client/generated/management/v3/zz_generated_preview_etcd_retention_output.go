@@ -0,0 +1,12 @@
+package client
+
+const (
+	PreviewEtcdRetentionOutputType        = "previewEtcdRetentionOutput"
+	PreviewEtcdRetentionOutputFieldKept   = "kept"
+	PreviewEtcdRetentionOutputFieldPruned = "pruned"
+)
+
+type PreviewEtcdRetentionOutput struct {
+	Kept   []string `json:"kept,omitempty" yaml:"kept,omitempty"`
+	Pruned []string `json:"pruned,omitempty" yaml:"pruned,omitempty"`
+}
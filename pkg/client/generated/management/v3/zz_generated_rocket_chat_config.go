@@ -0,0 +1,14 @@
+package client
+
+const (
+	RocketChatConfigType                  = "rocketChatConfig"
+	RocketChatConfigFieldDefaultRecipient = "defaultRecipient"
+	RocketChatConfigFieldProxyURL         = "proxyUrl"
+	RocketChatConfigFieldURL              = "url"
+)
+
+type RocketChatConfig struct {
+	DefaultRecipient string `json:"defaultRecipient,omitempty" yaml:"defaultRecipient,omitempty"`
+	ProxyURL         string `json:"proxyUrl,omitempty" yaml:"proxyUrl,omitempty"`
+	URL              string `json:"url,omitempty" yaml:"url,omitempty"`
+}
@@ -0,0 +1,20 @@
+package client
+
+const (
+	SFTPBackupTargetType            = "sftpBackupTarget"
+	SFTPBackupTargetFieldAddress    = "address"
+	SFTPBackupTargetFieldHostKey    = "hostKey"
+	SFTPBackupTargetFieldPassword   = "password"
+	SFTPBackupTargetFieldPath       = "path"
+	SFTPBackupTargetFieldPrivateKey = "privateKey"
+	SFTPBackupTargetFieldUsername   = "username"
+)
+
+type SFTPBackupTarget struct {
+	Address    string `json:"address,omitempty" yaml:"address,omitempty"`
+	HostKey    string `json:"hostKey,omitempty" yaml:"hostKey,omitempty"`
+	Password   string `json:"password,omitempty" yaml:"password,omitempty"`
+	Path       string `json:"path,omitempty" yaml:"path,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty" yaml:"privateKey,omitempty"`
+	Username   string `json:"username,omitempty" yaml:"username,omitempty"`
+}
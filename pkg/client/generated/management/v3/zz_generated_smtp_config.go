@@ -2,21 +2,31 @@
 
 const (
 	SMTPConfigType                  = "smtpConfig"
+	SMTPConfigFieldBCC              = "bcc"
+	SMTPConfigFieldCACerts          = "caCerts"
+	SMTPConfigFieldCC               = "cc"
 	SMTPConfigFieldDefaultRecipient = "defaultRecipient"
 	SMTPConfigFieldHost             = "host"
+	SMTPConfigFieldOAuth2           = "oauth2"
 	SMTPConfigFieldPassword         = "password"
 	SMTPConfigFieldPort             = "port"
 	SMTPConfigFieldSender           = "sender"
 	SMTPConfigFieldTLS              = "tls"
+	SMTPConfigFieldTLSMode          = "tlsMode"
 	SMTPConfigFieldUsername         = "username"
 )
 
 type SMTPConfig struct {
-	DefaultRecipient string `json:"defaultRecipient,omitempty" yaml:"defaultRecipient,omitempty"`
-	Host             string `json:"host,omitempty" yaml:"host,omitempty"`
-	Password         string `json:"password,omitempty" yaml:"password,omitempty"`
-	Port             int64  `json:"port,omitempty" yaml:"port,omitempty"`
-	Sender           string `json:"sender,omitempty" yaml:"sender,omitempty"`
-	TLS              *bool  `json:"tls,omitempty" yaml:"tls,omitempty"`
-	Username         string `json:"username,omitempty" yaml:"username,omitempty"`
+	BCC              []string          `json:"bcc,omitempty" yaml:"bcc,omitempty"`
+	CACerts          string            `json:"caCerts,omitempty" yaml:"caCerts,omitempty"`
+	CC               []string          `json:"cc,omitempty" yaml:"cc,omitempty"`
+	DefaultRecipient string            `json:"defaultRecipient,omitempty" yaml:"defaultRecipient,omitempty"`
+	Host             string            `json:"host,omitempty" yaml:"host,omitempty"`
+	OAuth2           *SMTPOAuth2Config `json:"oauth2,omitempty" yaml:"oauth2,omitempty"`
+	Password         string            `json:"password,omitempty" yaml:"password,omitempty"`
+	Port             int64             `json:"port,omitempty" yaml:"port,omitempty"`
+	Sender           string            `json:"sender,omitempty" yaml:"sender,omitempty"`
+	TLS              *bool             `json:"tls,omitempty" yaml:"tls,omitempty"`
+	TLSMode          string            `json:"tlsMode,omitempty" yaml:"tlsMode,omitempty"`
+	Username         string            `json:"username,omitempty" yaml:"username,omitempty"`
 }
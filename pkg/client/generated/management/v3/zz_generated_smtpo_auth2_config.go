@@ -0,0 +1,14 @@
+package client
+
+const (
+	SMTPOAuth2ConfigType            = "smtpoAuth2Config"
+	SMTPOAuth2ConfigFieldScopes     = "scopes"
+	SMTPOAuth2ConfigFieldSecretName = "secretName"
+	SMTPOAuth2ConfigFieldTokenURL   = "tokenUrl"
+)
+
+type SMTPOAuth2Config struct {
+	Scopes     []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	SecretName string   `json:"secretName,omitempty" yaml:"secretName,omitempty"`
+	TokenURL   string   `json:"tokenUrl,omitempty" yaml:"tokenUrl,omitempty"`
+}
@@ -20,10 +20,13 @@
 	TokenFieldIsDerived       = "isDerived"
 	TokenFieldLabels          = "labels"
 	TokenFieldLastUpdateTime  = "lastUpdateTime"
+	TokenFieldLastUsedAt      = "lastUsedAt"
+	TokenFieldLastUsedFrom    = "lastUsedFrom"
 	TokenFieldName            = "name"
 	TokenFieldOwnerReferences = "ownerReferences"
 	TokenFieldProviderInfo    = "providerInfo"
 	TokenFieldRemoved         = "removed"
+	TokenFieldScopes          = "scopes"
 	TokenFieldTTLMillis       = "ttl"
 	TokenFieldToken           = "token"
 	TokenFieldUUID            = "uuid"
@@ -47,10 +50,13 @@ type Token struct {
 	IsDerived       bool              `json:"isDerived,omitempty" yaml:"isDerived,omitempty"`
 	Labels          map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 	LastUpdateTime  string            `json:"lastUpdateTime,omitempty" yaml:"lastUpdateTime,omitempty"`
+	LastUsedAt      string            `json:"lastUsedAt,omitempty" yaml:"lastUsedAt,omitempty"`
+	LastUsedFrom    string            `json:"lastUsedFrom,omitempty" yaml:"lastUsedFrom,omitempty"`
 	Name            string            `json:"name,omitempty" yaml:"name,omitempty"`
 	OwnerReferences []OwnerReference  `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
 	ProviderInfo    map[string]string `json:"providerInfo,omitempty" yaml:"providerInfo,omitempty"`
 	Removed         string            `json:"removed,omitempty" yaml:"removed,omitempty"`
+	Scopes          []TokenScope      `json:"scopes,omitempty" yaml:"scopes,omitempty"`
 	TTLMillis       int64             `json:"ttl,omitempty" yaml:"ttl,omitempty"`
 	Token           string            `json:"token,omitempty" yaml:"token,omitempty"`
 	UUID            string            `json:"uuid,omitempty" yaml:"uuid,omitempty"`
@@ -78,6 +84,8 @@ type TokenOperations interface {
 	Delete(container *Token) error
 
 	CollectionActionLogout(resource *TokenCollection) error
+
+	CollectionActionUsage(resource *TokenCollection, input *TokenUsageInput) (*TokenUsageOutput, error)
 }
 
 func newTokenClient(apiClient *Client) *TokenClient {
@@ -153,3 +161,9 @@ func (c *TokenClient) CollectionActionLogout(resource *TokenCollection) error {
 	err := c.apiClient.Ops.DoCollectionAction(TokenType, "logout", &resource.Collection, nil, nil)
 	return err
 }
+
+func (c *TokenClient) CollectionActionUsage(resource *TokenCollection, input *TokenUsageInput) (*TokenUsageOutput, error) {
+	resp := &TokenUsageOutput{}
+	err := c.apiClient.Ops.DoCollectionAction(TokenType, "usage", &resource.Collection, input, resp)
+	return resp, err
+}
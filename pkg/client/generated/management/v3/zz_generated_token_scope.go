@@ -0,0 +1,20 @@
+package client
+
+const (
+	TokenScopeType            = "tokenScope"
+	TokenScopeFieldAPIGroups  = "apiGroups"
+	TokenScopeFieldClusterID  = "clusterId"
+	TokenScopeFieldNamespaces = "namespaces"
+	TokenScopeFieldProjectID  = "projectId"
+	TokenScopeFieldResources  = "resources"
+	TokenScopeFieldVerbs      = "verbs"
+)
+
+type TokenScope struct {
+	APIGroups  []string `json:"apiGroups,omitempty" yaml:"apiGroups,omitempty"`
+	ClusterID  string   `json:"clusterId,omitempty" yaml:"clusterId,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+	ProjectID  string   `json:"projectId,omitempty" yaml:"projectId,omitempty"`
+	Resources  []string `json:"resources,omitempty" yaml:"resources,omitempty"`
+	Verbs      []string `json:"verbs,omitempty" yaml:"verbs,omitempty"`
+}
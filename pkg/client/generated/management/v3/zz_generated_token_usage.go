@@ -0,0 +1,22 @@
+package client
+
+const (
+	TokenUsageType              = "tokenUsage"
+	TokenUsageFieldDescription  = "description"
+	TokenUsageFieldExpiresAt    = "expiresAt"
+	TokenUsageFieldIsDerived    = "isDerived"
+	TokenUsageFieldKind         = "kind"
+	TokenUsageFieldLastUsedAt   = "lastUsedAt"
+	TokenUsageFieldLastUsedFrom = "lastUsedFrom"
+	TokenUsageFieldName         = "name"
+)
+
+type TokenUsage struct {
+	Description  string `json:"description,omitempty" yaml:"description,omitempty"`
+	ExpiresAt    string `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+	IsDerived    bool   `json:"isDerived,omitempty" yaml:"isDerived,omitempty"`
+	Kind         string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	LastUsedAt   string `json:"lastUsedAt,omitempty" yaml:"lastUsedAt,omitempty"`
+	LastUsedFrom string `json:"lastUsedFrom,omitempty" yaml:"lastUsedFrom,omitempty"`
+	Name         string `json:"name,omitempty" yaml:"name,omitempty"`
+}
@@ -0,0 +1,10 @@
+package client
+
+const (
+	TokenUsageInputType          = "tokenUsageInput"
+	TokenUsageInputFieldIdleDays = "idleDays"
+)
+
+type TokenUsageInput struct {
+	IdleDays int64 `json:"idleDays,omitempty" yaml:"idleDays,omitempty"`
+}
@@ -0,0 +1,10 @@
+package client
+
+const (
+	TokenUsageOutputType       = "tokenUsageOutput"
+	TokenUsageOutputFieldUsers = "users"
+)
+
+type TokenUsageOutput struct {
+	Users []UserTokenUsage `json:"users,omitempty" yaml:"users,omitempty"`
+}
@@ -0,0 +1,12 @@
+package client
+
+const (
+	UserTokenUsageType        = "userTokenUsage"
+	UserTokenUsageFieldTokens = "tokens"
+	UserTokenUsageFieldUserID = "userId"
+)
+
+type UserTokenUsage struct {
+	Tokens []TokenUsage `json:"tokens,omitempty" yaml:"tokens,omitempty"`
+	UserID string       `json:"userId,omitempty" yaml:"userId,omitempty"`
+}
@@ -10,8 +10,10 @@
 
 	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/catalogv2"
+	"github.com/rancher/rancher/pkg/catalogv2/bundle"
 	"github.com/rancher/rancher/pkg/catalogv2/git"
 	helmhttp "github.com/rancher/rancher/pkg/catalogv2/http"
+	"github.com/rancher/rancher/pkg/catalogv2/oci"
 	catalogcontrollers "github.com/rancher/rancher/pkg/generated/controllers/catalog.cattle.io/v1"
 	namespaces "github.com/rancher/rancher/pkg/namespace"
 	"github.com/rancher/wrangler/pkg/apply"
@@ -33,10 +35,11 @@
 )
 
 type repoHandler struct {
-	secrets      corev1controllers.SecretCache
-	clusterRepos catalogcontrollers.ClusterRepoController
-	configMaps   corev1controllers.ConfigMapClient
-	apply        apply.Apply
+	secrets        corev1controllers.SecretCache
+	clusterRepos   catalogcontrollers.ClusterRepoController
+	configMaps     corev1controllers.ConfigMapClient
+	configMapCache corev1controllers.ConfigMapCache
+	apply          apply.Apply
 }
 
 func RegisterRepos(ctx context.Context,
@@ -45,10 +48,11 @@ func RegisterRepos(ctx context.Context,
 	clusterRepos catalogcontrollers.ClusterRepoController,
 	configMap corev1controllers.ConfigMapController) {
 	h := &repoHandler{
-		secrets:      secrets,
-		clusterRepos: clusterRepos,
-		configMaps:   configMap,
-		apply:        apply.WithCacheTypes(configMap).WithStrictCaching().WithSetOwnerReference(false, false),
+		secrets:        secrets,
+		clusterRepos:   clusterRepos,
+		configMaps:     configMap,
+		configMapCache: configMap.Cache(),
+		apply:          apply.WithCacheTypes(configMap).WithStrictCaching().WithSetOwnerReference(false, false),
 	}
 
 	catalogcontrollers.RegisterClusterRepoStatusHandler(ctx, clusterRepos,
@@ -119,52 +123,16 @@ func (r *repoHandler) createOrUpdateMap(namespace, name string, index *repo.Inde
 		namespace = namespaces.System
 	}
 
-	var (
-		objs  []runtime.Object
-		bytes = buf.Bytes()
-		left  []byte
-		i     = 0
-		size  = len(bytes)
-	)
-
-	for {
-		if len(bytes) > maxSize {
-			left = bytes[maxSize:]
-			bytes = bytes[:maxSize]
-		}
-
-		next := ""
-		if len(left) > 0 {
-			next = name2.SafeConcatName(owner.Name, fmt.Sprint(i+1), string(owner.UID))
-		}
-
-		cm := &corev1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:            name2.SafeConcatName(owner.Name, fmt.Sprint(i), string(owner.UID)),
-				Namespace:       namespace,
-				OwnerReferences: []metav1.OwnerReference{owner},
-				Annotations: map[string]string{
-					"catalog.cattle.io/next": next,
-					// Size ensure the resource version should update even if this is the head of a multipart chunk
-					"catalog.cattle.io/size": fmt.Sprint(size),
-				},
-			},
-			BinaryData: map[string][]byte{
-				"content": bytes,
-			},
-		}
+	chunks := catalogv2.Chunks(namespace, func(i int) string {
+		return name2.SafeConcatName(owner.Name, fmt.Sprint(i), string(owner.UID))
+	}, owner, buf.Bytes(), maxSize)
 
-		objs = append(objs, cm)
-		if len(left) == 0 {
-			break
-		}
-
-		i++
-		bytes = left
-		left = nil
+	var objs []runtime.Object
+	for _, chunk := range chunks {
+		objs = append(objs, chunk)
 	}
 
-	return objs[0].(*corev1.ConfigMap), r.apply.WithOwner(ownerObject).ApplyObjects(objs...)
+	return chunks[0], r.apply.WithOwner(ownerObject).ApplyObjects(objs...)
 }
 
 func (r *repoHandler) ensure(repoSpec *catalog.RepoSpec, status catalog.RepoStatus, metadata *metav1.ObjectMeta) (catalog.RepoStatus, error) {
@@ -183,9 +151,11 @@ func (r *repoHandler) ensure(repoSpec *catalog.RepoSpec, status catalog.RepoStat
 
 func (r *repoHandler) download(repoSpec *catalog.RepoSpec, status catalog.RepoStatus, metadata *metav1.ObjectMeta, owner metav1.OwnerReference) (catalog.RepoStatus, error) {
 	var (
-		index  *repo.IndexFile
-		commit string
-		err    error
+		index             *repo.IndexFile
+		commit            string
+		indexETag         string
+		indexLastModified string
+		err               error
 	)
 
 	status.ObservedGeneration = metadata.Generation
@@ -216,10 +186,34 @@ func (r *repoHandler) download(repoSpec *catalog.RepoSpec, status catalog.RepoSt
 			return status, nil
 		}
 		index, err = git.BuildOrGetIndex(metadata.Namespace, metadata.Name, repoSpec.GitRepo)
+	} else if repoSpec.Bundle != "" {
+		status.URL = ""
+		status.Branch = ""
+		var data []byte
+		data, err = bundle.Load(r.configMapCache, repoSpec.Bundle, metadata)
+		if err != nil {
+			return status, err
+		}
+		index, err = bundle.Index(data)
+	} else if oci.IsOCI(repoSpec.URL) {
+		status.URL = repoSpec.URL
+		status.Branch = ""
+		index, err = oci.DownloadIndex(secret, repoSpec.URL, repoSpec.CABundle, repoSpec.InsecureSkipTLSverify)
 	} else if repoSpec.URL != "" {
+		var validators helmhttp.IndexValidators
+		if r.canRevalidate(repoSpec, &status) {
+			validators.ETag = status.IndexETag
+			validators.LastModified = status.IndexLastModified
+		}
 		status.URL = repoSpec.URL
 		status.Branch = ""
-		index, err = helmhttp.DownloadIndex(secret, repoSpec.URL, repoSpec.CABundle, repoSpec.InsecureSkipTLSverify)
+		index, validators, err = helmhttp.DownloadIndexIfModified(secret, repoSpec.URL, repoSpec.CABundle, repoSpec.InsecureSkipTLSverify, validators)
+		if err == nil && index == nil {
+			// the index didn't change since it was stored
+			status.DownloadTime = downloadTime
+			return status, nil
+		}
+		indexETag, indexLastModified = validators.ETag, validators.LastModified
 	} else {
 		return status, nil
 	}
@@ -244,9 +238,28 @@ func (r *repoHandler) download(repoSpec *catalog.RepoSpec, status catalog.RepoSt
 	status.IndexConfigMapResourceVersion = cm.ResourceVersion
 	status.DownloadTime = downloadTime
 	status.Commit = commit
+	status.IndexETag = indexETag
+	status.IndexLastModified = indexLastModified
 	return status, nil
 }
 
+// canRevalidate returns true if the stored index of a http repo can be kept when the server
+// reports that it didn't change. A forced update always downloads the full index, as does a
+// missing index config map.
+func (r *repoHandler) canRevalidate(spec *catalog.RepoSpec, status *catalog.RepoStatus) bool {
+	if status.IndexConfigMapName == "" || spec.URL != status.URL || status.Commit != "" {
+		return false
+	}
+	if spec.ForceUpdate != nil && spec.ForceUpdate.After(status.DownloadTime.Time) {
+		return false
+	}
+	if status.IndexETag == "" && status.IndexLastModified == "" {
+		return false
+	}
+	_, err := r.configMapCache.Get(status.IndexConfigMapNamespace, status.IndexConfigMapName)
+	return err == nil
+}
+
 func shouldRefresh(spec *catalog.RepoSpec, status *catalog.RepoStatus) bool {
 	if spec.GitRepo != "" && status.Branch != spec.GitBranch {
 		return true
@@ -5,7 +5,10 @@
 	"time"
 
 	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	corev1controllers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -209,3 +212,44 @@ func TestShouldRefresh(t *testing.T) {
 		})
 	}
 }
+
+// configMapCache has the config maps of a map by namespace/name
+type configMapCache struct {
+	corev1controllers.ConfigMapCache
+	configMaps map[string]*corev1.ConfigMap
+}
+
+func (c *configMapCache) Get(namespace, name string) (*corev1.ConfigMap, error) {
+	if cm, ok := c.configMaps[namespace+"/"+name]; ok {
+		return cm, nil
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+}
+
+func TestCanRevalidate(t *testing.T) {
+	h := &repoHandler{
+		configMapCache: &configMapCache{configMaps: map[string]*corev1.ConfigMap{
+			"cattle-system/index": {},
+		}},
+	}
+	spec := &catalog.RepoSpec{URL: "https://example.com"}
+	status := func(configMapName, etag string) *catalog.RepoStatus {
+		return &catalog.RepoStatus{
+			URL:                     "https://example.com",
+			IndexConfigMapName:      configMapName,
+			IndexConfigMapNamespace: "cattle-system",
+			IndexETag:               etag,
+			DownloadTime:            metav1.Time{Time: time.Now().Add(-time.Hour)},
+		}
+	}
+
+	assert.True(t, h.canRevalidate(spec, status("index", `"abc"`)))
+	assert.False(t, h.canRevalidate(spec, status("index", "")), "no validators")
+	assert.False(t, h.canRevalidate(spec, status("", `"abc"`)), "index not stored")
+	assert.False(t, h.canRevalidate(spec, status("deleted", `"abc"`)), "index config map deleted")
+	assert.False(t, h.canRevalidate(&catalog.RepoSpec{URL: "https://example.com/other"}, status("index", `"abc"`)), "url changed")
+	assert.False(t, h.canRevalidate(&catalog.RepoSpec{
+		URL:         "https://example.com",
+		ForceUpdate: &metav1.Time{Time: time.Now()},
+	}, status("index", `"abc"`)), "forced update")
+}
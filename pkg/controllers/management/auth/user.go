@@ -1,9 +1,11 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
+	"github.com/rancher/rancher/pkg/api/steve/clusters"
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 
 	"github.com/rancher/rancher/pkg/clustermanager"
@@ -16,6 +18,7 @@
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -39,6 +42,7 @@ type userLifecycle struct {
 	userManager     user.Manager
 	clusterLister   v3.ClusterLister
 	clusterManager  *clustermanager.Manager
+	k8sClient       kubernetes.Interface
 }
 
 const (
@@ -63,6 +67,7 @@ func newUserLifecycle(management *config.ManagementContext, clusterManager *clus
 		userManager:     management.UserManager,
 		clusterLister:   management.Management.Clusters("").Controller().Lister(),
 		clusterManager:  clusterManager,
+		k8sClient:       management.K8sClient,
 	}
 
 	prtbInformer := management.Management.ProjectRoleTemplateBindings("").Controller().Informer()
@@ -212,6 +217,11 @@ func (l *userLifecycle) Remove(user *v3.User) (runtime.Object, error) {
 		return nil, err
 	}
 
+	err = clusters.DeleteHomeVolumes(context.Background(), l.k8sClient, user.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting shell home volumes: %v", err)
+	}
+
 	user, err = l.removeLegacyFinalizers(user)
 	if err != nil {
 		return nil, err
@@ -43,6 +43,10 @@
 	RKEDriverKey          = "rancherKubernetesEngineConfig"
 	KontainerEngineUpdate = "provisioner.cattle.io/ke-driver-update"
 	RkeRestoreAnnotation  = "rke.cattle.io/restore"
+
+	// RestoreVerifiedAnnotation is the snapshot of the restore config that the etcd backup
+	// controller verified, a cluster is restored once it matches the snapshot to restore.
+	RestoreVerifiedAnnotation = "rke.cattle.io/restore-verified"
 )
 
 type Provisioner struct {
@@ -526,6 +530,10 @@ func (p *Provisioner) reconcileCluster(cluster *v3.Cluster, create bool) (*v3.Cl
 			apiEndpoint, serviceAccountToken, caCert, updateTriggered, err = p.driverUpdate(cluster, *spec)
 		}
 	} else if spec.RancherKubernetesEngineConfig != nil && spec.RancherKubernetesEngineConfig.Restore.Restore {
+		if cluster.Annotations[RestoreVerifiedAnnotation] != spec.RancherKubernetesEngineConfig.Restore.SnapshotName {
+			logrus.Infof("Waiting for the snapshot of backup [%s] to be verified before restoring cluster [%s]", spec.RancherKubernetesEngineConfig.Restore.SnapshotName, cluster.Name)
+			return cluster, nil
+		}
 		logrus.Infof("Restoring cluster [%s] from backup", cluster.Name)
 		// cluster may need to be restored if key rotation fails
 		// ensure restore does not get short-circuited by key rotation since RKE checks for key rotation before restore
@@ -665,6 +673,7 @@ func resetRkeConfigFlags(cluster *v3.Cluster, updateTriggered bool) {
 			cluster.Annotations[RkeRestoreAnnotation] = "true"
 			cluster.Status.NodeVersion++
 		}
+		delete(cluster.Annotations, RestoreVerifiedAnnotation)
 		cluster.Spec.RancherKubernetesEngineConfig.Restore = rketypes.RestoreConfig{}
 		if cluster.Status.AppliedSpec.RancherKubernetesEngineConfig != nil {
 			cluster.Status.AppliedSpec.RancherKubernetesEngineConfig.RotateCertificates = nil
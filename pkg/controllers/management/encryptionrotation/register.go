@@ -0,0 +1,29 @@
+package encryptionrotation
+
+import (
+	"context"
+
+	"github.com/rancher/rancher/pkg/encryptedstore"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Register starts data key rotations of the encrypted stores when they are requested on
+// the rotation status config map. Nothing is registered unless a KMS plugin is configured.
+func Register(ctx context.Context, management *config.ManagementContext) {
+	// the stores of the cluster states of the kontainer engine and of the node configs
+	rotator, err := encryptedstore.NewRotator(ctx, namespace.System, []string{"c-", "mc-"}, management.Core, management.Core)
+	if err != nil {
+		logrus.Errorf("Failed to set up encryption key rotation: %v", err)
+		return
+	}
+	if rotator == nil {
+		return
+	}
+
+	if err := rotator.EnsureStatus(); err != nil {
+		logrus.Errorf("Failed to create encryption key rotation status: %v", err)
+	}
+	management.Core.ConfigMaps(namespace.System).AddHandler(ctx, "encryption-key-rotation", rotator.Sync)
+}
@@ -0,0 +1,364 @@
+package etcdbackup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/controllers/management/clusterprovisioner"
+	"github.com/rancher/rancher/pkg/etcddrill"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rke/docker"
+	"github.com/rancher/rke/hosts"
+	"github.com/rancher/rke/services"
+	rketypes "github.com/rancher/rke/types"
+	"github.com/rancher/wrangler/pkg/ticker"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	restoreDrillCheckInterval   = 15 * time.Minute
+	restoreDrillTimeout         = 10 * time.Minute
+	defaultRestoreDrillInterval = 24 * time.Hour
+	restoreDrillContainer       = "etcd-restore-drill"
+	// maxRestoreDrillSnapshotSize limits the compressed snapshot a drill buffers on the Rancher
+	// server, it is the largest backend quota etcd supports
+	maxRestoreDrillSnapshotSize = 8 * 1024 * 1024 * 1024
+	// restoreDrillNanoCPUs and restoreDrillMemoryOverhead limit the resources of the drill
+	// container, its memory is limited to the size of the restored data plus the overhead
+	restoreDrillNanoCPUs       = 1000000000
+	restoreDrillMemoryOverhead = 512 * 1024 * 1024
+)
+
+func (c *Controller) restoreDrillSync(ctx context.Context, interval time.Duration) error {
+	for range ticker.Context(ctx, interval) {
+		clusters, err := c.clusterLister.List("", labels.NewSelector())
+		if err != nil {
+			logrus.Error(fmt.Errorf("[etcd-backup] error while listing clusters: %v", err))
+			return err
+		}
+		for _, cluster := range clusters {
+			if err := c.doRestoreDrill(ctx, cluster); err != nil && !apierrors.IsConflict(err) {
+				logrus.Error(fmt.Errorf("[etcd-backup] error while running restore drill for cluster [%s]: %v", cluster.Name, err))
+			}
+		}
+	}
+	return nil
+}
+
+// doRestoreDrill restores the newest backup of the cluster when a drill is due and records the
+// result on the backup.
+func (c *Controller) doRestoreDrill(ctx context.Context, cluster *v3.Cluster) error {
+	drill := cluster.Spec.EtcdRestoreDrill
+	if drill == nil || !drill.Enabled || cluster.Status.AppliedSpec.RancherKubernetesEngineConfig == nil {
+		return nil
+	}
+
+	backups, err := c.backupLister.List(cluster.Name, labels.NewSelector())
+	if err != nil {
+		return err
+	}
+	backup := nextRestoreDrill(backups, restoreDrillInterval(drill), time.Now())
+	if backup == nil {
+		return nil
+	}
+
+	logrus.Infof("[etcd-backup] running restore drill of backup [%s] for cluster [%s]", backup.Name, cluster.Name)
+	drillCtx, cancel := context.WithTimeout(ctx, restoreDrillTimeout)
+	defer cancel()
+	result, drillErr := c.runRestoreDrill(drillCtx, cluster, backup)
+	if drillErr == nil {
+		drillErr = result.Check(drill.MinKeys, drill.RequiredNamespaces)
+	}
+
+	backup = backup.DeepCopy()
+	if drillErr != nil {
+		logrus.Warnf("[etcd-backup] restore drill of backup [%s] failed: %v", backup.Name, drillErr)
+		v32.BackupConditionRestoreDrilled.False(backup)
+		v32.BackupConditionRestoreDrilled.Message(backup, drillErr.Error())
+	} else {
+		v32.BackupConditionRestoreDrilled.True(backup)
+		v32.BackupConditionRestoreDrilled.Message(backup, fmt.Sprintf("restored %d keys at revision %d", result.Keys, result.Revision))
+	}
+	v32.BackupConditionRestoreDrilled.LastUpdated(backup, time.Now().UTC().Format(time.RFC3339))
+	// the alert watcher of the cluster alerts on the result
+	v32.BackupConditionRestoreDrillAlerted.Unknown(backup)
+	_, err = c.backupClient.Update(backup)
+	return err
+}
+
+// nextRestoreDrill returns the newest completed backup if it wasn't drilled yet and the last
+// drill of the cluster is older than the interval.
+func nextRestoreDrill(backups []*v3.EtcdBackup, interval time.Duration, now time.Time) *v3.EtcdBackup {
+	var (
+		newest    *v3.EtcdBackup
+		lastDrill time.Time
+	)
+	for _, backup := range backups {
+		if drilled, err := time.Parse(time.RFC3339, v32.BackupConditionRestoreDrilled.GetLastUpdated(backup)); err == nil && drilled.After(lastDrill) {
+			lastDrill = drilled
+		}
+		if !rketypes.BackupConditionCompleted.IsTrue(backup) {
+			continue
+		}
+		if newest == nil || GetBackupTime(backup).After(GetBackupTime(newest)) {
+			newest = backup
+		}
+	}
+	if newest == nil || v32.BackupConditionRestoreDrilled.GetStatus(newest) != "" || now.Sub(lastDrill) < interval {
+		return nil
+	}
+	return newest
+}
+
+func restoreDrillInterval(drill *v32.EtcdRestoreDrillConfig) time.Duration {
+	if drill.IntervalHours < 1 {
+		return defaultRestoreDrillInterval
+	}
+	return time.Duration(drill.IntervalHours) * time.Hour
+}
+
+// runRestoreDrill copies the snapshot of a backup from the backup target and restores it into a
+// container running the etcd image of the cluster, the etcd of the cluster is not touched. The
+// container runs on a node without the etcd role if the cluster has one, and its resources are
+// limited in any case.
+func (c *Controller) runRestoreDrill(ctx context.Context, cluster *v3.Cluster, backup *v3.EtcdBackup) (*etcddrill.Result, error) {
+	rkeConfig := cluster.Status.AppliedSpec.RancherKubernetesEngineConfig
+	if rkeConfig == nil {
+		return nil, fmt.Errorf("cluster [%s] is not provisioned by RKE", cluster.Name)
+	}
+
+	// the zip archive is read from the end, it is buffered in a temporary file
+	zipped, err := ioutil.TempFile("", "etcd-restore-drill-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(zipped.Name())
+	defer zipped.Close()
+	snapshot := &drillSnapshot{file: zipped}
+	if err := c.copyDrillSnapshot(ctx, cluster, backup, snapshot); err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, host := range drillHosts(rkeConfig.Nodes) {
+		if err := host.TunnelUp(ctx, c.dockerDialer, rkeConfig.PrefixPath, rkeConfig.Version); err != nil {
+			logrus.Debugf("[etcd-backup] failed to connect to host [%s] for restore drill: %v", host.Address, err)
+			lastErr = err
+			continue
+		}
+		return restoreOnHost(ctx, rkeConfig, host, snapshot)
+	}
+	if lastErr == nil {
+		return nil, fmt.Errorf("cluster [%s] has no nodes", cluster.Name)
+	}
+	return nil, fmt.Errorf("failed to connect to the nodes of cluster [%s]: %v", cluster.Name, lastErr)
+}
+
+// copyDrillSnapshot copies the snapshot of a backup from the backup target it was saved to, the
+// copy of targets with manifests is checked against the manifest. Snapshots that are only kept
+// on the etcd nodes, or were saved to a previous target of the cluster, are copied from the
+// nodes.
+func (c *Controller) copyDrillSnapshot(ctx context.Context, cluster *v3.Cluster, backup *v3.EtcdBackup, snapshot *drillSnapshot) error {
+	key, err := getEncryptionKey(cluster, c.secretLister)
+	if err != nil {
+		return err
+	}
+	manifest, err := backupManifest(ctx, cluster, backup, key)
+	if err != nil {
+		return err
+	}
+	target, err := newTarget(cluster, key, snapshot)
+	if err != nil {
+		return err
+	}
+
+	snapshotName := clusterprovisioner.GetBackupFilename(backup)
+	if target, ok := target.(*objectTarget); ok && manifest != nil {
+		return target.Restore(ctx, snapshotName, manifest)
+	}
+	if target, ok := target.(*s3Target); ok && backupProviderFlag(backup) == getProviderFlag(cluster) {
+		return target.copyTo(ctx, snapshotName, snapshot)
+	}
+	data, size, err := openSnapshot(ctx, c.dockerDialer, cluster, snapshotName)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+	return snapshot.write(ctx, snapshotName, data, size)
+}
+
+// drillHosts returns the nodes a restore drill can run on, nodes without the etcd role come
+// first so the drill doesn't compete with the etcd of the cluster.
+func drillHosts(nodes []rketypes.RKEConfigNode) []*hosts.Host {
+	var etcdHosts, result []*hosts.Host
+	for _, host := range hosts.NodesToHosts(nodes, "") {
+		if hasRole(host, services.ETCDRole) {
+			etcdHosts = append(etcdHosts, host)
+		} else {
+			result = append(result, host)
+		}
+	}
+	return append(result, etcdHosts...)
+}
+
+func hasRole(host *hosts.Host, role string) bool {
+	for _, r := range host.Role {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// drillSnapshot is the temporary file a restore drill buffers the compressed snapshot in, it
+// takes the snapshot like the etcd nodes of a restore.
+type drillSnapshot struct {
+	file *os.File
+	size int64
+}
+
+func (d *drillSnapshot) open(ctx context.Context, snapshotName string) (io.ReadCloser, int64, error) {
+	return nil, 0, fmt.Errorf("snapshot of a restore drill can't be read back")
+}
+
+func (d *drillSnapshot) write(ctx context.Context, snapshotName string, data io.Reader, size int64) error {
+	if size > maxRestoreDrillSnapshotSize {
+		return fmt.Errorf("snapshot [%s] is larger than %d bytes", snapshotName, int64(maxRestoreDrillSnapshotSize))
+	}
+	if err := d.remove(ctx, snapshotName); err != nil {
+		return err
+	}
+	n, err := io.Copy(d.file, io.LimitReader(data, size+1))
+	d.size = n
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot [%s]: %v", snapshotName, err)
+	}
+	if n != size {
+		return fmt.Errorf("snapshot [%s] has %d bytes, expected %d", snapshotName, n, size)
+	}
+	return nil
+}
+
+func (d *drillSnapshot) remove(ctx context.Context, snapshotName string) error {
+	d.size = 0
+	if err := d.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := d.file.Seek(0, io.SeekStart)
+	return err
+}
+
+func restoreOnHost(ctx context.Context, rkeConfig *rketypes.RancherKubernetesEngineConfig, host *hosts.Host, snapshot *drillSnapshot) (*etcddrill.Result, error) {
+	snapshotTar, dbSize, err := snapshotDBTar(snapshot.file, snapshot.size)
+	if err != nil {
+		return nil, err
+	}
+	defer snapshotTar.Close()
+
+	image, registries := systemImage(rkeConfig, rkeConfig.SystemImages.Etcd)
+	if image == "" {
+		return nil, fmt.Errorf("cluster has no etcd system image")
+	}
+	if err := docker.UseLocalOrPull(ctx, host.DClient, host.Address, image, services.ETCDRole, registries); err != nil {
+		return nil, err
+	}
+
+	name, err := containerName(restoreDrillContainer)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := docker.CreateContainer(ctx, host.DClient, host.Address, name, &container.Config{
+		Image:      image,
+		Entrypoint: []string{"/bin/sh", "-c"},
+		Cmd:        []string{etcddrill.Script},
+		Env:        []string{"ETCDCTL_API=3"},
+	}, &container.HostConfig{
+		NetworkMode: "none",
+		Resources: container.Resources{
+			NanoCPUs: restoreDrillNanoCPUs,
+			Memory:   dbSize + restoreDrillMemoryOverhead,
+		},
+	}); err != nil {
+		return nil, err
+	}
+	defer docker.DoRemoveContainer(context.Background(), host.DClient, name, host.Address)
+
+	if err := host.DClient.CopyToContainer(ctx, name, "/", snapshotTar, types.CopyToContainerOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to copy snapshot to host [%s]: %v", host.Address, err)
+	}
+	if err := docker.StartContainer(ctx, host.DClient, host.Address, name); err != nil {
+		return nil, err
+	}
+	exitCode, err := docker.WaitForContainer(ctx, host.DClient, host.Address, name)
+	if err != nil {
+		return nil, err
+	}
+	stderr, stdout, err := docker.GetContainerLogsStdoutStderr(ctx, host.DClient, name, "all", false)
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("failed to restore snapshot on host [%s]: %s", host.Address, lastLine(stderr))
+	}
+	return etcddrill.ParseOutput(stdout)
+}
+
+// snapshotDBTar streams the snapshot of a zip archive taken by RKE as a tar archive with the
+// single file snapshot.db, the format docker copies into a container. It returns the size of
+// snapshot.db.
+func snapshotDBTar(zipped *os.File, size int64) (io.ReadCloser, int64, error) {
+	zr, err := zip.NewReader(zipped, size)
+	if err != nil {
+		return nil, 0, fmt.Errorf("snapshot is not a zip archive: %v", err)
+	}
+	var db *zip.File
+	for _, file := range zr.File {
+		if file.Mode().IsRegular() {
+			db = file
+			break
+		}
+	}
+	if db == nil {
+		return nil, 0, fmt.Errorf("snapshot archive is empty")
+	}
+	data, err := db.Open()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer data.Close()
+		tw := tar.NewWriter(pw)
+		err := tw.WriteHeader(&tar.Header{
+			Name: "snapshot.db",
+			Mode: 0600,
+			Size: int64(db.UncompressedSize64),
+		})
+		if err == nil {
+			_, err = io.Copy(tw, data)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, int64(db.UncompressedSize64), nil
+}
+
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return lines[len(lines)-1]
+}
@@ -0,0 +1,130 @@
+package etcdbackup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rke/services"
+	rketypes "github.com/rancher/rke/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextRestoreDrill(t *testing.T) {
+	now := time.Date(2021, 3, 17, 12, 30, 0, 0, time.UTC)
+	backups := hourlyBackups(now, 48)
+	taking := newCompletedBackup("taking", time.Time{}, false)
+	backups = append(backups, taking)
+
+	assert.Equal(t, "recurring-0", nextRestoreDrill(backups, 24*time.Hour, now).Name, "newest completed backup")
+
+	v32.BackupConditionRestoreDrilled.True(backups[30])
+	v32.BackupConditionRestoreDrilled.LastUpdated(backups[30], now.Add(-12*time.Hour).Format(time.RFC3339))
+	assert.Nil(t, nextRestoreDrill(backups, 24*time.Hour, now), "last drill is not older than the interval")
+	assert.Equal(t, "recurring-0", nextRestoreDrill(backups, 6*time.Hour, now).Name)
+
+	v32.BackupConditionRestoreDrilled.False(backups[0])
+	v32.BackupConditionRestoreDrilled.LastUpdated(backups[0], now.Add(-12*time.Hour).Format(time.RFC3339))
+	assert.Nil(t, nextRestoreDrill(backups, 6*time.Hour, now), "newest backup was drilled")
+	assert.Nil(t, nextRestoreDrill(nil, 6*time.Hour, now))
+}
+
+func TestSnapshotDBTar(t *testing.T) {
+	zipped, err := ioutil.TempFile("", "snapshot-")
+	require.NoError(t, err)
+	defer os.Remove(zipped.Name())
+	defer zipped.Close()
+
+	zw := zip.NewWriter(zipped)
+	_, err = zw.Create("backup/")
+	require.NoError(t, err)
+	w, err := zw.Create("backup/c-abcde-rl-fghij_2021-03-17T12:30:00Z")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("etcd snapshot"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	info, err := zipped.Stat()
+	require.NoError(t, err)
+
+	rc, size, err := snapshotDBTar(zipped, info.Size())
+	require.NoError(t, err)
+	defer rc.Close()
+	assert.Equal(t, int64(len("etcd snapshot")), size)
+	tr := tar.NewReader(rc)
+	header, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "snapshot.db", header.Name)
+	data, err := ioutil.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, "etcd snapshot", string(data))
+}
+
+func TestDrillHosts(t *testing.T) {
+	nodes := []rketypes.RKEConfigNode{
+		{Address: "etcd-1", Role: []string{services.ETCDRole}},
+		{Address: "all", Role: []string{services.ETCDRole, services.ControlRole, services.WorkerRole}},
+		{Address: "controlplane", Role: []string{services.ControlRole}},
+		{Address: "worker", Role: []string{services.WorkerRole}},
+	}
+	var addresses []string
+	for _, host := range drillHosts(nodes) {
+		addresses = append(addresses, host.Address)
+	}
+	assert.Equal(t, []string{"controlplane", "worker", "etcd-1", "all"}, addresses, "nodes without the etcd role come first")
+}
+
+func TestDrillSnapshot(t *testing.T) {
+	file, err := ioutil.TempFile("", "etcd-restore-drill-")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+	snapshot := &drillSnapshot{file: file}
+
+	require.NoError(t, snapshot.write(context.Background(), "c-abcde-rn-12345", strings.NewReader("longer snapshot"), 15))
+	require.NoError(t, snapshot.write(context.Background(), "c-abcde-rn-12345", strings.NewReader("snapshot"), 8), "retried copy")
+	data, err := ioutil.ReadFile(file.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "snapshot", string(data), "a retried copy replaces the previous one")
+	assert.Equal(t, int64(8), snapshot.size)
+
+	assert.Error(t, snapshot.write(context.Background(), "c-abcde-rn-12345", strings.NewReader("snapshot"), 4), "snapshot is longer than its size")
+	assert.Error(t, snapshot.write(context.Background(), "c-abcde-rn-12345", strings.NewReader("snapshot"), 10), "snapshot is shorter than its size")
+	assert.Error(t, snapshot.write(context.Background(), "c-abcde-rn-12345", strings.NewReader(""), maxRestoreDrillSnapshotSize+1), "snapshot is too large")
+
+	require.NoError(t, snapshot.remove(context.Background(), "c-abcde-rn-12345"))
+	info, err := file.Stat()
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+}
+
+func TestCopyDrillSnapshotFromTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcd-backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	setFilesystemBaseDir(t, filepath.Dir(dir))
+
+	store, err := newFilesystemStore(&v32.FilesystemBackupTarget{Path: dir})
+	require.NoError(t, err)
+	key := newTestKey(t)
+	require.NoError(t, (&objectTarget{store: store, nodes: snapshotContent("snapshot"), key: key}).Save(context.Background(), "c-abcde-rn-12345"))
+	manifest, err := (&objectTarget{store: store, key: key}).Manifest(context.Background(), "c-abcde-rn-12345")
+	require.NoError(t, err)
+
+	file, err := ioutil.TempFile("", "etcd-restore-drill-")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+	snapshot := &drillSnapshot{file: file}
+	require.NoError(t, (&objectTarget{store: store, nodes: snapshot, key: key}).Restore(context.Background(), "c-abcde-rn-12345", manifest))
+	data, err := ioutil.ReadFile(file.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "snapshot", string(data), "the drill reads the decrypted copy of the target")
+}
@@ -0,0 +1,264 @@
+package etcdbackup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/ref"
+)
+
+const (
+	// EncryptionKeyIDAnnotation is the id of the key the cluster object and the snapshot of a
+	// backup are encrypted with.
+	EncryptionKeyIDAnnotation = "etcdbackup.cattle.io/encryption-key-id"
+
+	encryptionKeySecretKey = "key"
+	encryptedClusterPrefix = "encrypted:"
+
+	// an encrypted snapshot is the magic and a nonce prefix followed by chunks sealed with
+	// AES-GCM, the last chunk is marked so a truncated snapshot can't be decrypted
+	snapshotMagic       = "RKESNAP1"
+	snapshotNonceSize   = 8
+	encryptionChunkSize = 64 * 1024
+)
+
+// encryptionKey holds keys derived from the key of the secret for each use.
+type encryptionKey struct {
+	id       string
+	snapshot cipher.AEAD
+	cluster  cipher.AEAD
+	manifest []byte
+}
+
+// getEncryptionKey returns the key the backups of the cluster are encrypted with, the key is nil
+// if encryption is not configured. The secret must be in the namespace of the cluster so the
+// owner of a cluster can't use the secrets of other namespaces.
+func getEncryptionKey(cluster *v3.Cluster, secrets v1.SecretLister) (*encryptionKey, error) {
+	if cluster.Spec.EtcdBackupTarget == nil || cluster.Spec.EtcdBackupTarget.EncryptionKeySecret == "" {
+		return nil, nil
+	}
+	namespace, name := ref.Parse(cluster.Spec.EtcdBackupTarget.EncryptionKeySecret)
+	if namespace == "" {
+		namespace = cluster.Name
+	}
+	if namespace != cluster.Name {
+		return nil, fmt.Errorf("etcd backup encryption key secret [%s] is not in the namespace of cluster [%s]", cluster.Spec.EtcdBackupTarget.EncryptionKeySecret, cluster.Name)
+	}
+	secret, err := secrets.Get(namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get etcd backup encryption key: %v", err)
+	}
+	return newEncryptionKey(secret.Data[encryptionKeySecretKey])
+}
+
+// checkEncryptionSupported returns an error if the cluster has an encryption key but no etcd
+// backup target storage. The snapshots of the S3 backup config are uploaded by RKE and local
+// snapshots stay on the etcd nodes, neither can be encrypted or get a manifest.
+func checkEncryptionSupported(cluster *v3.Cluster) error {
+	spec := cluster.Spec.EtcdBackupTarget
+	if spec == nil || spec.EncryptionKeySecret == "" {
+		return nil
+	}
+	if spec.AzureBlob == nil && spec.GCS == nil && spec.SFTP == nil && spec.Filesystem == nil {
+		return fmt.Errorf("etcd backup encryption requires an etcd backup target, snapshots uploaded to S3 or kept on the etcd nodes can not be encrypted")
+	}
+	return nil
+}
+
+func newEncryptionKey(key []byte) (*encryptionKey, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("etcd backup encryption key must be 32 bytes, got %d", len(key))
+	}
+
+	snapshot, err := newGCM(deriveKey(key, "snapshot"))
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := newGCM(deriveKey(key, "cluster"))
+	if err != nil {
+		return nil, err
+	}
+	id := sha256.Sum256(key)
+	return &encryptionKey{
+		id:       hex.EncodeToString(id[:8]),
+		snapshot: snapshot,
+		cluster:  cluster,
+		manifest: deriveKey(key, "manifest"),
+	}, nil
+}
+
+func deriveKey(key []byte, use string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("rancher etcd backup " + use))
+	return mac.Sum(nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// checkKeyID returns an error if the backup was encrypted with another key than the key.
+func checkKeyID(backup *v3.EtcdBackup, key *encryptionKey) error {
+	id := backup.Annotations[EncryptionKeyIDAnnotation]
+	switch {
+	case id == "":
+		return nil
+	case key == nil:
+		return fmt.Errorf("backup [%s] is encrypted but the cluster has no encryption key", backup.Name)
+	case id != key.id:
+		return fmt.Errorf("backup [%s] is encrypted with key [%s], the encryption key of the cluster is [%s]", backup.Name, id, key.id)
+	}
+	return nil
+}
+
+// encryptClusterObject encrypts a compressed cluster object, the name of the cluster is
+// authenticated so the object can't be used for another cluster.
+func encryptClusterObject(compressed, clusterName string, key *encryptionKey) (string, error) {
+	nonce := make([]byte, key.cluster.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := key.cluster.Seal(nonce, nonce, []byte(compressed), []byte(clusterName))
+	return encryptedClusterPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptClusterObject(encrypted, clusterName string, key *encryptionKey) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, encryptedClusterPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted cluster object: %v", err)
+	}
+	if len(sealed) < key.cluster.NonceSize() {
+		return "", errors.New("invalid encrypted cluster object")
+	}
+	nonceSize := key.cluster.NonceSize()
+	compressed, err := key.cluster.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(clusterName))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt cluster object, it was modified or encrypted with another key: %v", err)
+	}
+	return string(compressed), nil
+}
+
+// encryptedSize returns the size of an encrypted snapshot, every snapshot ends with a final
+// chunk that may be empty.
+func encryptedSize(size int64, key *encryptionKey) int64 {
+	chunks := size/encryptionChunkSize + 1
+	return int64(len(snapshotMagic)+snapshotNonceSize) + chunks*int64(key.snapshot.Overhead()) + size
+}
+
+// encryptingReader encrypts the snapshot read from src.
+type encryptingReader struct {
+	src     io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	plain   []byte
+	out     []byte
+	done    bool
+}
+
+func newEncryptingReader(src io.Reader, key *encryptionKey) (io.Reader, error) {
+	prefix := make([]byte, snapshotNonceSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	return &encryptingReader{
+		src:    src,
+		aead:   key.snapshot,
+		prefix: prefix,
+		plain:  make([]byte, encryptionChunkSize),
+		out:    append([]byte(snapshotMagic), prefix...),
+	}, nil
+}
+
+func (e *encryptingReader) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(e.src, e.plain)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			e.done = true
+		} else if err != nil {
+			return 0, err
+		}
+		e.out = e.aead.Seal(nil, chunkNonce(e.prefix, e.counter), e.plain[:n], chunkData(e.done))
+		e.counter++
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+// DecryptSnapshot writes the decrypted content of a snapshot that was encrypted before it was
+// stored in a backup target, it is meant for snapshots copied out of a target by hand. The
+// controller decrypts snapshots the same way when it copies them back to the etcd nodes.
+func DecryptSnapshot(w io.Writer, r io.Reader, key []byte) error {
+	encryptionKey, err := newEncryptionKey(key)
+	if err != nil {
+		return err
+	}
+	return decryptSnapshot(w, r, encryptionKey)
+}
+
+func decryptSnapshot(w io.Writer, r io.Reader, key *encryptionKey) error {
+	aead := key.snapshot
+
+	header := make([]byte, len(snapshotMagic)+snapshotNonceSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("invalid encrypted snapshot: %v", err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return errors.New("snapshot is not encrypted")
+	}
+	prefix := header[len(snapshotMagic):]
+
+	chunk := make([]byte, encryptionChunkSize+aead.Overhead())
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(r, chunk)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("encrypted snapshot is truncated: %v", err)
+		}
+		// only the final chunk is shorter than the others
+		final := n < len(chunk)
+		plain, err := aead.Open(chunk[:0], chunkNonce(prefix, counter), chunk[:n], chunkData(final))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt snapshot, it was modified or encrypted with another key: %v", err)
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+func chunkNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, snapshotNonceSize+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[snapshotNonceSize:], counter)
+	return nonce
+}
+
+func chunkData(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
@@ -0,0 +1,192 @@
+package etcdbackup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/generated/norman/core/v1/fakes"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+func newTestKey(t *testing.T) *encryptionKey {
+	key, err := newEncryptionKey(testKey)
+	require.NoError(t, err)
+	return key
+}
+
+func TestSnapshotEncryption(t *testing.T) {
+	key := newTestKey(t)
+	for _, size := range []int{0, 1, encryptionChunkSize - 1, encryptionChunkSize, 2*encryptionChunkSize + 5} {
+		snapshot := make([]byte, size)
+		for i := range snapshot {
+			snapshot[i] = byte(i)
+		}
+
+		r, err := newEncryptingReader(bytes.NewReader(snapshot), key)
+		require.NoError(t, err)
+		encrypted, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, encryptedSize(int64(size), key), int64(len(encrypted)), "size %d", size)
+
+		decrypted := &bytes.Buffer{}
+		require.NoError(t, DecryptSnapshot(decrypted, bytes.NewReader(encrypted), testKey), "size %d", size)
+		assert.True(t, bytes.Equal(snapshot, decrypted.Bytes()), "size %d", size)
+
+		// a snapshot truncated after any chunk can't be decrypted
+		truncated := encrypted[:len(encrypted)-key.snapshot.Overhead()]
+		assert.Error(t, DecryptSnapshot(ioutil.Discard, bytes.NewReader(truncated), testKey), "size %d", size)
+	}
+
+	r, err := newEncryptingReader(bytes.NewReader([]byte("snapshot")), key)
+	require.NoError(t, err)
+	encrypted, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	encrypted[len(encrypted)-1] ^= 1
+	assert.Error(t, DecryptSnapshot(ioutil.Discard, bytes.NewReader(encrypted), testKey), "modified snapshot")
+	assert.Error(t, DecryptSnapshot(ioutil.Discard, bytes.NewReader([]byte("PK\x03\x04 plain zip")), testKey), "snapshot is not encrypted")
+}
+
+func TestClusterObjectEncryption(t *testing.T) {
+	key := newTestKey(t)
+	cluster := &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-abcde"}}
+	compressed, err := CompressCluster(cluster)
+	require.NoError(t, err)
+
+	encrypted, err := encryptClusterObject(compressed, cluster.Name, key)
+	require.NoError(t, err)
+	backup := &v3.EtcdBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "c-abcde-rl-12345",
+			Annotations: map[string]string{EncryptionKeyIDAnnotation: key.id},
+		},
+	}
+	backup.Spec.ClusterID = cluster.Name
+	backup.Status.ClusterObject = encrypted
+
+	decrypted, err := getClusterObject(backup, key)
+	require.NoError(t, err)
+	assert.Equal(t, cluster.Name, decrypted.Name)
+
+	_, err = getClusterObject(backup, nil)
+	assert.Error(t, err, "no key")
+	otherKey, err := newEncryptionKey(bytes.Repeat([]byte{8}, 32))
+	require.NoError(t, err)
+	_, err = getClusterObject(backup, otherKey)
+	assert.Error(t, err, "other key")
+
+	backup.Spec.ClusterID = "c-fghij"
+	_, err = getClusterObject(backup, key)
+	assert.Error(t, err, "object of another cluster")
+}
+
+func TestEncryptedTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcd-backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	setFilesystemBaseDir(t, filepath.Dir(dir))
+
+	key := newTestKey(t)
+	store, err := newFilesystemStore(&v32.FilesystemBackupTarget{Path: dir})
+	require.NoError(t, err)
+	nodes := snapshotContent("snapshot")
+	target := &objectTarget{store: store, nodes: nodes, key: key}
+
+	require.NoError(t, target.Save(context.Background(), "c-abcde-rn-12345"))
+	assert.Equal(t, []string{"c-abcde-rn-12345"}, nodes.removed, "the plaintext snapshot is removed from the nodes")
+	nodes.content = "retried"
+	require.NoError(t, target.Save(context.Background(), "c-abcde-rn-12345"), "retried save")
+	assert.Equal(t, []string{"c-abcde-rn-12345", "c-abcde-rn-12345"}, nodes.removed)
+	encrypted, err := ioutil.ReadFile(filepath.Join(dir, "c-abcde-rn-12345.zip"))
+	require.NoError(t, err)
+	decrypted := &bytes.Buffer{}
+	require.NoError(t, DecryptSnapshot(decrypted, bytes.NewReader(encrypted), testKey))
+	assert.Equal(t, "snapshot", decrypted.String())
+
+	manifest, err := target.Manifest(context.Background(), "c-abcde-rn-12345")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("snapshot")), manifest.Size)
+	assert.Equal(t, int64(len(encrypted)), manifest.StoredSize)
+	assert.NoError(t, manifest.verify("c-abcde-rn-12345", key.id, key))
+	assert.Error(t, manifest.verify("c-abcde-rn-67890", key.id, key), "manifest of another snapshot")
+	assert.Error(t, manifest.verify("c-abcde-rn-12345", "", key), "backup is not encrypted")
+
+	// a manifest that was modified is rejected
+	manifest.SHA256 = "0000"
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c-abcde-rn-12345.manifest.json"), data, 0600))
+	manifest, err = target.Manifest(context.Background(), "c-abcde-rn-12345")
+	require.NoError(t, err)
+	assert.Error(t, manifest.verify("c-abcde-rn-12345", key.id, key))
+
+	snapshots, err := target.List(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 1, "manifests are not listed")
+
+	require.NoError(t, target.Remove(context.Background(), "c-abcde-rn-12345"))
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files, "snapshot and manifest are removed")
+}
+
+func TestGetEncryptionKey(t *testing.T) {
+	secrets := &fakes.SecretListerMock{
+		GetFunc: func(namespace, name string) (*corev1.Secret, error) {
+			return &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+				Data:       map[string][]byte{encryptionKeySecretKey: testKey},
+			}, nil
+		},
+	}
+	cluster := func(secret string) *v3.Cluster {
+		return &v3.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "c-abcde"},
+			Spec: v32.ClusterSpec{
+				ClusterSpecBase: v32.ClusterSpecBase{
+					EtcdBackupTarget: &v32.EtcdBackupTarget{EncryptionKeySecret: secret},
+				},
+			},
+		}
+	}
+
+	key, err := getEncryptionKey(cluster("backup-key"), secrets)
+	require.NoError(t, err)
+	assert.Equal(t, newTestKey(t).id, key.id)
+	_, err = getEncryptionKey(cluster("c-abcde:backup-key"), secrets)
+	assert.NoError(t, err)
+	_, err = getEncryptionKey(cluster("cattle-system:backup-key"), secrets)
+	assert.Error(t, err, "secret of another namespace")
+	for _, call := range secrets.GetCalls() {
+		assert.Equal(t, "c-abcde", call.Namespace)
+	}
+}
+
+func TestCheckEncryptionSupported(t *testing.T) {
+	cluster := &v3.Cluster{
+		Spec: v32.ClusterSpec{
+			ClusterSpecBase: v32.ClusterSpecBase{
+				EtcdBackupTarget: &v32.EtcdBackupTarget{EncryptionKeySecret: "backup-key"},
+			},
+		},
+	}
+	assert.Error(t, checkEncryptionSupported(cluster), "no target")
+
+	cluster.Spec.EtcdBackupTarget.Filesystem = &v32.FilesystemBackupTarget{Path: "snapshots"}
+	assert.NoError(t, checkEncryptionSupported(cluster))
+
+	cluster.Spec.EtcdBackupTarget = nil
+	assert.NoError(t, checkEncryptionSupported(cluster), "no encryption")
+}
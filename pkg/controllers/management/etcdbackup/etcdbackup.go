@@ -21,12 +21,14 @@
 	minio "github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/rancher/rancher/pkg/controllers/management/clusterprovisioner"
+	corev1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/kontainer-engine/drivers/rke"
 	"github.com/rancher/rancher/pkg/kontainer-engine/service"
 	"github.com/rancher/rancher/pkg/rkedialerfactory"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/rancher/rancher/pkg/types/config/dialer"
+	"github.com/rancher/rke/hosts"
 	"github.com/rancher/wrangler/pkg/ticker"
 	"github.com/sirupsen/logrus"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -50,6 +52,8 @@ type Controller struct {
 	backupLister          v3.EtcdBackupLister
 	backupDriver          *service.EngineService
 	KontainerDriverLister v3.KontainerDriverLister
+	dockerDialer          hosts.DialerFactory
+	secretLister          corev1.SecretLister
 }
 
 func Register(ctx context.Context, management *config.ManagementContext) {
@@ -61,6 +65,7 @@ func Register(ctx context.Context, management *config.ManagementContext) {
 		backupLister:          management.Management.EtcdBackups("").Controller().Lister(),
 		backupDriver:          service.NewEngineService(clusterprovisioner.NewPersistentStore(management.Core.Namespaces(""), management.Core)),
 		KontainerDriverLister: management.Management.KontainerDrivers("").Controller().Lister(),
+		secretLister:          management.Core.Secrets("").Controller().Lister(),
 	}
 
 	local := &rkedialerfactory.RKEDialerFactory{
@@ -77,9 +82,12 @@ func Register(ctx context.Context, management *config.ManagementContext) {
 	rkeDriver.DockerDialer = docker.Build
 	rkeDriver.LocalDialer = local.Build
 	rkeDriver.WrapTransportFactory = docker.WrapTransport
+	c.dockerDialer = docker.Build
 
 	c.backupClient.AddLifecycle(ctx, "etcdbackup-controller", c)
+	c.clusterClient.AddHandler(ctx, "etcdbackup-restore-verifier", c.verifyRestore)
 	go c.clusterBackupSync(ctx, clusterBackupCheckInterval)
+	go c.restoreDrillSync(ctx, restoreDrillCheckInterval)
 }
 
 func (c *Controller) Create(b *v3.EtcdBackup) (runtime.Object, error) {
@@ -96,9 +104,23 @@ func (c *Controller) Create(b *v3.EtcdBackup) (runtime.Object, error) {
 		return b, fmt.Errorf("[etcd-backup] cluster doesn't have a backup config")
 	}
 
+	// RKE would take the snapshot unencrypted, the backup fails before it is taken
+	if err := checkEncryptionSupported(cluster); err != nil {
+		rketypes.BackupConditionCompleted.False(b)
+		rketypes.BackupConditionCompleted.ReasonAndMessageFromError(b, err)
+		updated, updateErr := c.backupClient.Update(b)
+		if updateErr != nil {
+			return b, updateErr
+		}
+		return updated, fmt.Errorf("[etcd-backup] failed to perform etcd backup: %v", err)
+	}
+
 	if !rketypes.BackupConditionCreated.IsTrue(b) {
 		b.Spec.Filename = generateBackupFilename(b.Name, cluster.Spec.RancherKubernetesEngineConfig.Services.Etcd.BackupConfig)
 		b.Spec.BackupConfig = *cluster.Spec.RancherKubernetesEngineConfig.Services.Etcd.BackupConfig
+		if err := c.encryptBackup(cluster, b); err != nil {
+			return b, err
+		}
 		rketypes.BackupConditionCreated.True(b)
 		// we set ConditionCompleted to Unknown to avoid incorrect "active" state
 		rketypes.BackupConditionCompleted.Unknown(b)
@@ -119,6 +141,28 @@ func (c *Controller) Create(b *v3.EtcdBackup) (runtime.Object, error) {
 	return b, nil
 }
 
+// encryptBackup stores the encrypted cluster object with a backup if the cluster has an
+// encryption key, the snapshot is encrypted by the backup target.
+func (c *Controller) encryptBackup(cluster *v3.Cluster, b *v3.EtcdBackup) error {
+	key, err := getEncryptionKey(cluster, c.secretLister)
+	if err != nil || key == nil {
+		return err
+	}
+
+	compressedCluster, err := CompressCluster(cluster)
+	if err != nil {
+		return err
+	}
+	if b.Status.ClusterObject, err = encryptClusterObject(compressedCluster, cluster.Name, key); err != nil {
+		return err
+	}
+	if b.Annotations == nil {
+		b.Annotations = map[string]string{}
+	}
+	b.Annotations[EncryptionKeyIDAnnotation] = key.id
+	return nil
+}
+
 func (c *Controller) Remove(b *v3.EtcdBackup) (runtime.Object, error) {
 	logrus.Debugf("[etcd-backup] deleting backup %s ", b.Name)
 	if err := c.etcdRemoveSnapshotWithBackoff(b); err != nil {
@@ -231,6 +275,24 @@ func (c *Controller) etcdSaveWithBackoff(b *v3.EtcdBackup) (runtime.Object, erro
 		if err != nil {
 			return b, err
 		}
+		if inErr != nil {
+			return b, inErr
+		}
+
+		target, err := c.getTarget(cluster)
+		if err != nil {
+			return b, err
+		}
+		err = wait.ExponentialBackoff(getBackoff(), func() (bool, error) {
+			if inErr = target.Save(c.ctx, snapshotName); inErr != nil {
+				logrus.Warnf("[etcd-backup] failed to save snapshot [%s] to backup target: %v", snapshotName, inErr)
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil {
+			return b, err
+		}
 		return b, inErr
 	})
 	if err != nil {
@@ -252,47 +314,80 @@ func (c *Controller) etcdRemoveSnapshotWithBackoff(b *v3.EtcdBackup) error {
 	if err != nil {
 		return err
 	}
+	target, err := c.getTarget(cluster)
+	if err != nil {
+		return err
+	}
 	snapshotName := clusterprovisioner.GetBackupFilename(b)
-	return wait.ExponentialBackoff(backoff, func() (bool, error) {
+	err = wait.ExponentialBackoff(backoff, func() (bool, error) {
 		if inErr := c.backupDriver.ETCDRemoveSnapshot(c.ctx, cluster.Name, kontainerDriver, cluster.Spec, snapshotName); inErr != nil {
 			logrus.Warnf("%v", inErr)
 			return false, nil
 		}
 		return true, nil
 	})
+	if err != nil {
+		return err
+	}
+	return wait.ExponentialBackoff(getBackoff(), func() (bool, error) {
+		if inErr := target.Remove(c.ctx, snapshotName); inErr != nil {
+			logrus.Warnf("[etcd-backup] failed to remove snapshot [%s] from backup target: %v", snapshotName, inErr)
+			return false, nil
+		}
+		return true, nil
+	})
 }
 
 func (c *Controller) rotateExpiredBackups(cluster *v3.Cluster, clusterBackups []*v3.EtcdBackup) error {
-	retention := cluster.Spec.RancherKubernetesEngineConfig.Services.Etcd.BackupConfig.Retention
-	intervalHours := cluster.Spec.RancherKubernetesEngineConfig.Services.Etcd.BackupConfig.IntervalHours
-	expiredBackups := getExpiredBackups(retention, intervalHours, clusterBackups)
+	expiredBackups := GetExpiredBackups(cluster, cluster.Spec.EtcdBackupRetention, clusterBackups)
 	for _, backup := range expiredBackups {
-		if backup.Spec.Manual {
-			continue
-		}
 		if err := c.backupClient.DeleteNamespaced(backup.Namespace, backup.Name, &metav1.DeleteOptions{}); err != nil {
 			return err
 		}
 	}
+	return c.removeOrphanedSnapshots(cluster, clusterBackups, getRetentionDuration(cluster))
+}
+
+// removeOrphanedSnapshots removes the recurring snapshots of the cluster from the backup target
+// that are older than the retention and have no backup object, for example because the backup
+// was deleted while the target was unreachable.
+func (c *Controller) removeOrphanedSnapshots(cluster *v3.Cluster, clusterBackups []*v3.EtcdBackup, toKeepDuration time.Duration) error {
+	target, err := c.getTarget(cluster)
+	if err != nil {
+		return err
+	}
+	snapshots, err := target.List(c.ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, snapshot := range getOrphanedSnapshots(cluster.Name, toKeepDuration, snapshots, clusterBackups) {
+		logrus.Infof("[etcd-backup] removing orphaned snapshot [%s] of cluster [%s]", snapshot.Name, cluster.Name)
+		if err := target.Remove(c.ctx, snapshot.Name); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func NewBackupObject(cluster *v3.Cluster, manual bool) (*v3.EtcdBackup, error) {
 	controller := true
-	typeFlag := "r"     // recurring is the default
-	providerFlag := "l" // local is the default
+	typeFlag := "r" // recurring is the default
+	providerFlag := getProviderFlag(cluster)
 
 	if manual {
 		typeFlag = "m" // manual backup
 	}
-	if cluster.Spec.RancherKubernetesEngineConfig.Services.Etcd.BackupConfig.S3BackupConfig != nil {
-		providerFlag = "s" // s3 backup
-	}
 	prefix := fmt.Sprintf("%s-%s%s-", cluster.Name, typeFlag, providerFlag)
 
-	compressedCluster, err := CompressCluster(cluster)
-	if err != nil {
-		return nil, err
+	// the cluster object of a cluster with an encryption key is stored encrypted by the
+	// controller, it is never stored in plain text
+	var compressedCluster string
+	if cluster.Spec.EtcdBackupTarget == nil || cluster.Spec.EtcdBackupTarget.EncryptionKeySecret == "" {
+		var err error
+		if compressedCluster, err = CompressCluster(cluster); err != nil {
+			return nil, err
+		}
 	}
 
 	return &v3.EtcdBackup{
@@ -478,6 +573,26 @@ func getExpiredBackups(retention, intervalHours int, backups []*v3.EtcdBackup) [
 	return expiredList
 }
 
+func getOrphanedSnapshots(clusterName string, toKeepDuration time.Duration, snapshots []Snapshot, backups []*v3.EtcdBackup) []Snapshot {
+	known := map[string]bool{}
+	for _, backup := range backups {
+		known[clusterprovisioner.GetBackupFilename(backup)] = true
+	}
+
+	// recurring backups are named <cluster>-r<provider>-, manual snapshots are never removed
+	prefix := clusterName + "-r"
+	orphanedList := []Snapshot{}
+	for _, snapshot := range snapshots {
+		if known[snapshot.Name] || !strings.HasPrefix(snapshot.Name, prefix) {
+			continue
+		}
+		if time.Since(snapshot.Modified) > toKeepDuration {
+			orphanedList = append(orphanedList, snapshot)
+		}
+	}
+	return orphanedList
+}
+
 func shouldBackup(cluster *v3.Cluster) bool {
 	// not an rke cluster, we do nothing
 	if cluster.Spec.RancherKubernetesEngineConfig == nil {
@@ -0,0 +1,203 @@
+package etcdbackup
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/pkg/controllers/management/clusterprovisioner"
+	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rke/hosts"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	manifestExtension = "manifest.json"
+	// maxManifestSize limits the size of a manifest read from a target
+	maxManifestSize = 64 * 1024
+)
+
+// Manifest is stored next to a snapshot in a backup target to verify the snapshot before it is
+// restored.
+type Manifest struct {
+	Snapshot string    `json:"snapshot"`
+	Created  time.Time `json:"created"`
+	// Size and SHA256 are of the snapshot taken on the etcd nodes
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// StoredSize and StoredSHA256 are of the object in the target, they differ from the
+	// snapshot if it is encrypted
+	StoredSize   int64  `json:"storedSize"`
+	StoredSHA256 string `json:"storedSha256"`
+	// KeyID is the id of the key the snapshot is encrypted with
+	KeyID string `json:"keyId,omitempty"`
+	// MAC authenticates the manifest with the encryption key, manifests of snapshots that
+	// aren't encrypted only detect corruption
+	MAC string `json:"mac,omitempty"`
+}
+
+func manifestFile(snapshotName string) string {
+	return snapshotName + "." + manifestExtension
+}
+
+func (m *Manifest) sign(key *encryptionKey) error {
+	m.KeyID = key.id
+	mac, err := m.mac(key)
+	if err != nil {
+		return err
+	}
+	m.MAC = hex.EncodeToString(mac)
+	return nil
+}
+
+func (m *Manifest) mac(key *encryptionKey) ([]byte, error) {
+	unsigned := *m
+	unsigned.MAC = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key.manifest)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// verify checks that the manifest belongs to the snapshot and was signed with the key if the
+// snapshot is encrypted, keyID is the id of the key the backup was encrypted with.
+func (m *Manifest) verify(snapshotName, keyID string, key *encryptionKey) error {
+	if m.Snapshot != snapshotName {
+		return fmt.Errorf("manifest is of snapshot [%s], not [%s]", m.Snapshot, snapshotName)
+	}
+	if m.KeyID != keyID {
+		return fmt.Errorf("manifest of snapshot [%s] is not signed with the key the backup was encrypted with", snapshotName)
+	}
+	if m.KeyID == "" {
+		return nil
+	}
+	if key == nil || key.id != m.KeyID {
+		return fmt.Errorf("snapshot [%s] is encrypted with key [%s] that is not the encryption key of the cluster", snapshotName, m.KeyID)
+	}
+	expected, err := m.mac(key)
+	if err != nil {
+		return err
+	}
+	actual, err := hex.DecodeString(m.MAC)
+	if err != nil || !hmac.Equal(expected, actual) {
+		return fmt.Errorf("manifest of snapshot [%s] was modified", snapshotName)
+	}
+	return nil
+}
+
+// GetClusterObject returns the cluster object stored with a backup, an encrypted object is
+// decrypted with the encryption key of the cluster.
+func GetClusterObject(cluster *v3.Cluster, backup *v3.EtcdBackup, secrets v1.SecretLister) (*v3.Cluster, error) {
+	key, err := getEncryptionKey(cluster, secrets)
+	if err != nil {
+		return nil, err
+	}
+	return getClusterObject(backup, key)
+}
+
+func getClusterObject(backup *v3.EtcdBackup, key *encryptionKey) (*v3.Cluster, error) {
+	compressed := backup.Status.ClusterObject
+	if strings.HasPrefix(compressed, encryptedClusterPrefix) {
+		if err := checkKeyID(backup, key); err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return nil, fmt.Errorf("backup [%s] is encrypted but the cluster has no encryption key", backup.Name)
+		}
+		var err error
+		if compressed, err = decryptClusterObject(compressed, backup.Spec.ClusterID, key); err != nil {
+			return nil, err
+		}
+	}
+	return DecompressCluster(compressed)
+}
+
+// VerifyBackup checks a backup before the cluster is flagged for restore. The cluster object
+// must decrypt with the encryption key of the cluster and the manifest stored in the backup
+// target must belong to the backup. The controller copies the snapshot from the target to the
+// etcd nodes and checks it against the manifest before RKE restores it, see verifyRestore.
+func VerifyBackup(ctx context.Context, cluster *v3.Cluster, backup *v3.EtcdBackup, secrets v1.SecretLister) error {
+	key, err := getEncryptionKey(cluster, secrets)
+	if err != nil {
+		return err
+	}
+	_, err = backupManifest(ctx, cluster, backup, key)
+	return err
+}
+
+// backupManifest checks the cluster object of a backup and returns the verified manifest of its
+// snapshot, the manifest is nil if the target doesn't store manifests.
+func backupManifest(ctx context.Context, cluster *v3.Cluster, backup *v3.EtcdBackup, key *encryptionKey) (*Manifest, error) {
+	if err := checkKeyID(backup, key); err != nil {
+		return nil, err
+	}
+	if backup.Status.ClusterObject != "" {
+		if _, err := getClusterObject(backup, key); err != nil {
+			return nil, fmt.Errorf("cluster object of backup [%s] is invalid: %v", backup.Name, err)
+		}
+	}
+
+	// the manifest is in the target the backup was saved to, backups saved before the target of
+	// the cluster changed can't be verified
+	if provider := backupProviderFlag(backup); provider != getProviderFlag(cluster) {
+		logrus.Warnf("[etcd-backup] backup [%s] was saved to another backup target, skipping snapshot verification", backup.Name)
+		return nil, nil
+	}
+
+	target, err := newTarget(cluster, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	snapshotName := clusterprovisioner.GetBackupFilename(backup)
+	manifest, err := target.Manifest(ctx, snapshotName)
+	if err != nil || manifest == nil {
+		return nil, err
+	}
+	if err := manifest.verify(snapshotName, backup.Annotations[EncryptionKeyIDAnnotation], key); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// restoreSnapshot copies the snapshot of a backup from the backup target to the etcd nodes and
+// checks it against its manifest, RKE restores the snapshot from the etcd nodes. Snapshots of
+// targets without manifests are restored by RKE from the etcd nodes or S3 as they are. It
+// returns whether a decrypted copy was staged on the etcd nodes, the copy is removed once the
+// cluster is restored.
+func restoreSnapshot(ctx context.Context, cluster *v3.Cluster, backup *v3.EtcdBackup, secrets v1.SecretLister, dockerDialer hosts.DialerFactory) (bool, error) {
+	key, err := getEncryptionKey(cluster, secrets)
+	if err != nil {
+		return false, err
+	}
+	manifest, err := backupManifest(ctx, cluster, backup, key)
+	if err != nil || manifest == nil {
+		return false, err
+	}
+
+	target, err := newTarget(cluster, key, &etcdNodes{dockerDialer: dockerDialer, cluster: cluster})
+	if err != nil {
+		return false, err
+	}
+	if err := target.Restore(ctx, clusterprovisioner.GetBackupFilename(backup), manifest); err != nil {
+		return false, err
+	}
+	return manifest.KeyID != "", nil
+}
+
+// backupProviderFlag returns the provider flag of a backup named <cluster>-<type><provider>-.
+func backupProviderFlag(backup *v3.EtcdBackup) string {
+	flags := strings.TrimPrefix(backup.Name, backup.Spec.ClusterID+"-")
+	if len(flags) < 2 {
+		return ""
+	}
+	return flags[1:2]
+}
@@ -0,0 +1,103 @@
+package etcdbackup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/controllers/management/clusterprovisioner"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/ref"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// restoreCopyTimeout limits copying the snapshot from the backup target to the etcd nodes
+	restoreCopyTimeout = 30 * time.Minute
+	// restoreStagedAnnotation is the decrypted snapshot copied to the etcd nodes for a restore
+	restoreStagedAnnotation = "etcdbackup.cattle.io/restore-staged"
+)
+
+// verifyRestore copies the snapshot of the backup a cluster is flagged to restore from the
+// backup target to the etcd nodes, checks it against its manifest and marks the restore as
+// verified, the provisioner restores the cluster once the RestoreVerifiedAnnotation matches
+// the snapshot it restores. A failed verification is the message of the Updated condition of
+// the cluster and is retried with a backoff. Decrypted snapshots are removed from the etcd nodes
+// once the provisioner cleared the restore config.
+func (c *Controller) verifyRestore(key string, cluster *v3.Cluster) (runtime.Object, error) {
+	if cluster == nil || cluster.DeletionTimestamp != nil || cluster.Spec.RancherKubernetesEngineConfig == nil {
+		return cluster, nil
+	}
+	restore := cluster.Spec.RancherKubernetesEngineConfig.Restore
+	if staged := cluster.Annotations[restoreStagedAnnotation]; staged != "" && !restore.Restore {
+		return c.removeStagedSnapshot(cluster, staged)
+	}
+	if !restore.Restore || cluster.Annotations[clusterprovisioner.RestoreVerifiedAnnotation] == restore.SnapshotName {
+		return cluster, nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, restoreCopyTimeout)
+	defer cancel()
+	staged, err := c.verifyRestoreSnapshot(ctx, cluster, restore.SnapshotName)
+	if err != nil {
+		err = fmt.Errorf("backup %s failed verification: %v", restore.SnapshotName, err)
+		if v32.ClusterConditionUpdated.IsFalse(cluster) && v32.ClusterConditionUpdated.GetMessage(cluster) == err.Error() {
+			return cluster, err
+		}
+		cluster = cluster.DeepCopy()
+		v32.ClusterConditionUpdated.False(cluster)
+		v32.ClusterConditionUpdated.Message(cluster, err.Error())
+		if _, updateErr := c.clusterClient.Update(cluster); updateErr != nil {
+			return cluster, updateErr
+		}
+		return cluster, err
+	}
+
+	cluster = cluster.DeepCopy()
+	if cluster.Annotations == nil {
+		cluster.Annotations = map[string]string{}
+	}
+	cluster.Annotations[clusterprovisioner.RestoreVerifiedAnnotation] = restore.SnapshotName
+	if staged != "" {
+		cluster.Annotations[restoreStagedAnnotation] = staged
+	}
+	return c.clusterClient.Update(cluster)
+}
+
+// verifyRestoreSnapshot copies the snapshot of a backup to the etcd nodes and returns the name
+// of the decrypted snapshot staged on the nodes, if any.
+func (c *Controller) verifyRestoreSnapshot(ctx context.Context, cluster *v3.Cluster, snapshotName string) (string, error) {
+	_, name := ref.Parse(snapshotName)
+	backup, err := c.backupLister.Get(cluster.Name, name)
+	if err != nil {
+		return "", err
+	}
+	if backup.Spec.ClusterID != cluster.Name {
+		return "", fmt.Errorf("snapshot [%s] is not a backup of cluster [%s]", backup.Name, cluster.Name)
+	}
+	// a decrypted snapshot staged for a restore that was changed is replaced
+	if staged := cluster.Annotations[restoreStagedAnnotation]; staged != "" {
+		if err := removeSnapshot(ctx, c.dockerDialer, cluster, staged); err != nil {
+			return "", err
+		}
+	}
+	staged, err := restoreSnapshot(ctx, cluster, backup, c.secretLister, c.dockerDialer)
+	if err != nil || !staged {
+		return "", err
+	}
+	return clusterprovisioner.GetBackupFilename(backup), nil
+}
+
+// removeStagedSnapshot removes the decrypted snapshot a cluster was restored from from the etcd
+// nodes.
+func (c *Controller) removeStagedSnapshot(cluster *v3.Cluster, snapshotName string) (runtime.Object, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, restoreCopyTimeout)
+	defer cancel()
+	if err := removeSnapshot(ctx, c.dockerDialer, cluster, snapshotName); err != nil {
+		return cluster, fmt.Errorf("failed to remove decrypted snapshot [%s] from the etcd nodes: %v", snapshotName, err)
+	}
+	cluster = cluster.DeepCopy()
+	delete(cluster.Annotations, restoreStagedAnnotation)
+	return c.clusterClient.Update(cluster)
+}
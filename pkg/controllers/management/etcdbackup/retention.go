@@ -0,0 +1,81 @@
+package etcdbackup
+
+import (
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/etcdretention"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	rketypes "github.com/rancher/rke/types"
+)
+
+// GetExpiredBackups returns the recurring backups that are pruned by the retention tiers, manual
+// backups are never pruned. The retention of the backup config of the cluster is used if there
+// are no tiers.
+func GetExpiredBackups(cluster *v3.Cluster, tiers []v32.EtcdBackupRetentionTier, backups []*v3.EtcdBackup) []*v3.EtcdBackup {
+	var recurring []*v3.EtcdBackup
+	for _, backup := range backups {
+		if !backup.Spec.Manual {
+			recurring = append(recurring, backup)
+		}
+	}
+
+	if len(tiers) == 0 {
+		backupConfig := getBackupConfig(cluster)
+		if backupConfig == nil {
+			return nil
+		}
+		return getExpiredBackups(backupConfig.Retention, backupConfig.IntervalHours, recurring)
+	}
+
+	snapshots := make([]etcdretention.Snapshot, len(recurring))
+	byName := map[string]*v3.EtcdBackup{}
+	for i, backup := range recurring {
+		snapshots[i] = etcdretention.Snapshot{Name: backup.Name, Created: GetBackupTime(backup)}
+		byName[backup.Name] = backup
+	}
+	var expiredList []*v3.EtcdBackup
+	for _, snapshot := range etcdretention.Prune(retentionTiers(tiers), snapshots) {
+		expiredList = append(expiredList, byName[snapshot.Name])
+	}
+	return expiredList
+}
+
+// GetBackupTime returns when a backup completed, or when it was created if it is not completed,
+// so a backup that is still being taken is the newest backup.
+func GetBackupTime(backup *v3.EtcdBackup) time.Time {
+	if completed := getBackupCompletedTime(backup); !completed.IsZero() {
+		return completed
+	}
+	return backup.CreationTimestamp.Time
+}
+
+// getRetentionDuration returns how long the recurring snapshots of the cluster are kept at most.
+func getRetentionDuration(cluster *v3.Cluster) time.Duration {
+	if len(cluster.Spec.EtcdBackupRetention) > 0 {
+		return etcdretention.MaxAge(retentionTiers(cluster.Spec.EtcdBackupRetention))
+	}
+	backupConfig := getBackupConfig(cluster)
+	if backupConfig == nil {
+		return 0
+	}
+	return time.Duration(backupConfig.Retention*backupConfig.IntervalHours) * time.Hour
+}
+
+func retentionTiers(tiers []v32.EtcdBackupRetentionTier) []etcdretention.Tier {
+	result := make([]etcdretention.Tier, 0, len(tiers))
+	for _, tier := range tiers {
+		result = append(result, etcdretention.Tier{
+			Interval: time.Duration(tier.IntervalHours) * time.Hour,
+			Keep:     tier.Keep,
+		})
+	}
+	return result
+}
+
+func getBackupConfig(cluster *v3.Cluster) *rketypes.BackupConfig {
+	if cluster.Spec.RancherKubernetesEngineConfig == nil {
+		return nil
+	}
+	return cluster.Spec.RancherKubernetesEngineConfig.Services.Etcd.BackupConfig
+}
@@ -0,0 +1,67 @@
+package etcdbackup
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	rketypes "github.com/rancher/rke/types"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newCompletedBackup(name string, completed time.Time, manual bool) *v3.EtcdBackup {
+	backup := &v3.EtcdBackup{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	backup.Spec.Manual = manual
+	if !completed.IsZero() {
+		rketypes.BackupConditionCompleted.True(backup)
+		rketypes.BackupConditionCompleted.LastUpdated(backup, completed.UTC().Format(time.RFC3339))
+	}
+	return backup
+}
+
+func hourlyBackups(now time.Time, hours int) []*v3.EtcdBackup {
+	var backups []*v3.EtcdBackup
+	for i := 0; i < hours; i++ {
+		backups = append(backups, newCompletedBackup(fmt.Sprintf("recurring-%d", i), now.Add(-time.Duration(i)*time.Hour), false))
+	}
+	return backups
+}
+
+func TestGetExpiredBackups(t *testing.T) {
+	cluster := &v3.Cluster{}
+	cluster.Spec.RancherKubernetesEngineConfig = &rketypes.RancherKubernetesEngineConfig{}
+	cluster.Spec.RancherKubernetesEngineConfig.Services.Etcd.BackupConfig = &rketypes.BackupConfig{
+		IntervalHours: 1,
+		Retention:     3,
+	}
+
+	// the retention of the backup config keeps the backups of the last hours
+	backups := hourlyBackups(time.Now().Add(-30*time.Minute), 72)
+	assert.Len(t, GetExpiredBackups(cluster, nil, backups), 72-3)
+
+	now := time.Date(2021, 3, 17, 12, 30, 0, 0, time.UTC)
+	backups = append(hourlyBackups(now, 72), newCompletedBackup("manual", now.Add(-100*time.Hour), true))
+	taking := newCompletedBackup("taking", time.Time{}, false)
+	taking.CreationTimestamp = metav1.NewTime(now.Add(time.Minute))
+	backups = append(backups, taking)
+
+	tiers := []v32.EtcdBackupRetentionTier{
+		{IntervalHours: 1, Keep: 2},
+		{IntervalHours: 24, Keep: 3},
+	}
+	expired := map[string]bool{}
+	for _, backup := range GetExpiredBackups(cluster, tiers, backups) {
+		expired[backup.Name] = true
+	}
+	assert.False(t, expired["taking"], "backup that is being taken is the newest")
+	assert.True(t, expired["recurring-0"], "taken in the same hour as the newest backup")
+	assert.False(t, expired["recurring-1"])
+	assert.False(t, expired["recurring-13"], "last backup of yesterday")
+	assert.False(t, expired["recurring-37"], "last backup of the day before yesterday")
+	assert.False(t, expired["manual"], "manual backups are never pruned")
+	assert.Len(t, expired, 72-3)
+	assert.Equal(t, 72*time.Hour, getRetentionDuration(&v3.Cluster{Spec: v32.ClusterSpec{ClusterSpecBase: v32.ClusterSpecBase{EtcdBackupRetention: tiers}}}))
+}
@@ -0,0 +1,286 @@
+package etcdbackup
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rke/docker"
+	"github.com/rancher/rke/hosts"
+	"github.com/rancher/rke/services"
+	rketypes "github.com/rancher/rke/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	snapshotCopyContainer   = "etcd-snapshot-copy"
+	snapshotRemoveContainer = "etcd-snapshot-remove"
+)
+
+// containerName returns a unique name for the container of an operation, the backup, the
+// restore verification and the restore drill may read the same snapshot on a node at once.
+func containerName(prefix string) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return prefix + "-" + hex.EncodeToString(suffix), nil
+}
+
+// openSnapshot opens a snapshot taken by RKE on the first etcd node of the cluster that has it.
+// The snapshot directory of the node is read through a container that is created but never
+// started.
+func openSnapshot(ctx context.Context, dockerDialer hosts.DialerFactory, cluster *v3.Cluster, snapshotName string) (io.ReadCloser, int64, error) {
+	rkeConfig := cluster.Status.AppliedSpec.RancherKubernetesEngineConfig
+	if rkeConfig == nil {
+		return nil, 0, fmt.Errorf("cluster [%s] is not provisioned by RKE", cluster.Name)
+	}
+
+	var lastErr error
+	for _, host := range hosts.NodesToHosts(rkeConfig.Nodes, services.ETCDRole) {
+		data, size, err := openSnapshotOnHost(ctx, dockerDialer, rkeConfig, host, snapshotName)
+		if err == nil {
+			return data, size, nil
+		}
+		logrus.Debugf("[etcd-backup] failed to read snapshot [%s] from host [%s]: %v", snapshotName, host.Address, err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		return nil, 0, fmt.Errorf("cluster [%s] has no etcd nodes", cluster.Name)
+	}
+	return nil, 0, fmt.Errorf("failed to read snapshot [%s] from the etcd nodes of cluster [%s]: %v", snapshotName, cluster.Name, lastErr)
+}
+
+func openSnapshotOnHost(ctx context.Context, dockerDialer hosts.DialerFactory, rkeConfig *rketypes.RancherKubernetesEngineConfig, host *hosts.Host, snapshotName string) (io.ReadCloser, int64, error) {
+	name, removeContainer, err := createSnapshotContainer(ctx, dockerDialer, rkeConfig, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	// the container is removed when the copy is closed, or here if the copy isn't returned
+	var copied *snapshotCopy
+	defer func() {
+		if copied == nil {
+			_ = removeContainer()
+		}
+	}()
+
+	rc, _, err := host.DClient.CopyFromContainer(ctx, name, path.Join(services.EtcdSnapshotPath, snapshotFile(snapshotName)))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the snapshot is the only file of the archive docker returns
+	tr := tar.NewReader(rc)
+	header, err := tr.Next()
+	if err == nil && !header.FileInfo().Mode().IsRegular() {
+		err = fmt.Errorf("snapshot [%s] is not a file", snapshotName)
+	}
+	if err != nil {
+		rc.Close()
+		return nil, 0, err
+	}
+
+	copied = &snapshotCopy{
+		Reader: tr,
+		close: func() error {
+			err := rc.Close()
+			if removeErr := removeContainer(); err == nil {
+				err = removeErr
+			}
+			return err
+		},
+	}
+	return copied, header.Size, nil
+}
+
+// createSnapshotContainer creates a container on an etcd node that binds the snapshot directory
+// of the node, the container is never started, the snapshots are copied from and to it. The
+// returned func removes the container.
+func createSnapshotContainer(ctx context.Context, dockerDialer hosts.DialerFactory, rkeConfig *rketypes.RancherKubernetesEngineConfig, host *hosts.Host) (string, func() error, error) {
+	if err := host.TunnelUp(ctx, dockerDialer, rkeConfig.PrefixPath, rkeConfig.Version); err != nil {
+		return "", nil, err
+	}
+
+	image, registries := snapshotCopyImage(rkeConfig)
+	if image == "" {
+		return "", nil, fmt.Errorf("cluster has no alpine system image")
+	}
+	if err := docker.UseLocalOrPull(ctx, host.DClient, host.Address, image, services.ETCDRole, registries); err != nil {
+		return "", nil, err
+	}
+
+	name, err := containerName(snapshotCopyContainer)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := docker.CreateContainer(ctx, host.DClient, host.Address, name, &container.Config{
+		Image: image,
+	}, &container.HostConfig{
+		Binds: []string{fmt.Sprintf("%s:%s", services.EtcdSnapshotPath, services.EtcdSnapshotPath)},
+	}); err != nil {
+		return "", nil, err
+	}
+	return name, func() error {
+		return docker.DoRemoveContainer(context.Background(), host.DClient, name, host.Address)
+	}, nil
+}
+
+// writeSnapshot copies a snapshot to the snapshot directory of every etcd node of the cluster,
+// the data is streamed to all nodes at once.
+func writeSnapshot(ctx context.Context, dockerDialer hosts.DialerFactory, cluster *v3.Cluster, snapshotName string, data io.Reader, size int64) error {
+	rkeConfig := cluster.Status.AppliedSpec.RancherKubernetesEngineConfig
+	if rkeConfig == nil {
+		return fmt.Errorf("cluster [%s] is not provisioned by RKE", cluster.Name)
+	}
+	etcdHosts := hosts.NodesToHosts(rkeConfig.Nodes, services.ETCDRole)
+	if len(etcdHosts) == 0 {
+		return fmt.Errorf("cluster [%s] has no etcd nodes", cluster.Name)
+	}
+
+	var (
+		writers []io.Writer
+		pipes   []*io.PipeWriter
+		errs    = make(chan error, len(etcdHosts))
+	)
+	for _, host := range etcdHosts {
+		archive, pipe := io.Pipe()
+		writers = append(writers, pipe)
+		pipes = append(pipes, pipe)
+		go func(host *hosts.Host) {
+			err := writeSnapshotOnHost(ctx, dockerDialer, rkeConfig, host, archive)
+			if err != nil {
+				err = fmt.Errorf("failed to copy snapshot to host [%s]: %v", host.Address, err)
+				// stops the copy to the other nodes too
+				archive.CloseWithError(err)
+			}
+			errs <- err
+		}(host)
+	}
+
+	// docker extracts the snapshot from a tar archive
+	tw := tar.NewWriter(io.MultiWriter(writers...))
+	err := tw.WriteHeader(&tar.Header{
+		Name:    snapshotFile(snapshotName),
+		Mode:    0600,
+		Size:    size,
+		ModTime: time.Now(),
+	})
+	if err == nil {
+		_, err = io.Copy(tw, data)
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	for _, pipe := range pipes {
+		pipe.CloseWithError(err)
+	}
+	for range etcdHosts {
+		if hostErr := <-errs; err == nil {
+			err = hostErr
+		}
+	}
+	return err
+}
+
+func writeSnapshotOnHost(ctx context.Context, dockerDialer hosts.DialerFactory, rkeConfig *rketypes.RancherKubernetesEngineConfig, host *hosts.Host, archive io.Reader) error {
+	name, removeContainer, err := createSnapshotContainer(ctx, dockerDialer, rkeConfig, host)
+	if err != nil {
+		return err
+	}
+	defer removeContainer()
+	return host.DClient.CopyToContainer(ctx, name, services.EtcdSnapshotPath, archive, types.CopyToContainerOptions{})
+}
+
+// removeSnapshot removes a snapshot from the snapshot directory of every etcd node of the cluster.
+func removeSnapshot(ctx context.Context, dockerDialer hosts.DialerFactory, cluster *v3.Cluster, snapshotName string) error {
+	rkeConfig := cluster.Status.AppliedSpec.RancherKubernetesEngineConfig
+	if rkeConfig == nil {
+		return fmt.Errorf("cluster [%s] is not provisioned by RKE", cluster.Name)
+	}
+	image, registries := snapshotCopyImage(rkeConfig)
+	if image == "" {
+		return fmt.Errorf("cluster has no alpine system image")
+	}
+
+	var lastErr error
+	for _, host := range hosts.NodesToHosts(rkeConfig.Nodes, services.ETCDRole) {
+		if err := removeSnapshotOnHost(ctx, dockerDialer, rkeConfig, host, image, registries, snapshotName); err != nil {
+			logrus.Debugf("[etcd-backup] failed to remove snapshot [%s] from host [%s]: %v", snapshotName, host.Address, err)
+			lastErr = fmt.Errorf("failed to remove snapshot [%s] from host [%s]: %v", snapshotName, host.Address, err)
+		}
+	}
+	return lastErr
+}
+
+func removeSnapshotOnHost(ctx context.Context, dockerDialer hosts.DialerFactory, rkeConfig *rketypes.RancherKubernetesEngineConfig, host *hosts.Host, image string, registries map[string]rketypes.PrivateRegistry, snapshotName string) error {
+	if err := host.TunnelUp(ctx, dockerDialer, rkeConfig.PrefixPath, rkeConfig.Version); err != nil {
+		return err
+	}
+	name, err := containerName(snapshotRemoveContainer)
+	if err != nil {
+		return err
+	}
+	defer docker.DoRemoveContainer(context.Background(), host.DClient, name, host.Address)
+	return docker.DoRunOnetimeContainer(ctx, host.DClient, &container.Config{
+		Image: image,
+		// RKE leaves the uncompressed snapshot if compressing it failed
+		Cmd: []string{"rm", "-f", path.Join(services.EtcdSnapshotPath, snapshotFile(snapshotName)), path.Join(services.EtcdSnapshotPath, snapshotName)},
+	}, &container.HostConfig{
+		Binds: []string{fmt.Sprintf("%s:%s", services.EtcdSnapshotPath, services.EtcdSnapshotPath)},
+	}, name, host.Address, services.ETCDRole, registries)
+}
+
+// etcdNodes reads, writes and removes the snapshots on the etcd nodes of an RKE cluster.
+type etcdNodes struct {
+	dockerDialer hosts.DialerFactory
+	cluster      *v3.Cluster
+}
+
+func (e *etcdNodes) open(ctx context.Context, snapshotName string) (io.ReadCloser, int64, error) {
+	return openSnapshot(ctx, e.dockerDialer, e.cluster, snapshotName)
+}
+
+func (e *etcdNodes) write(ctx context.Context, snapshotName string, data io.Reader, size int64) error {
+	return writeSnapshot(ctx, e.dockerDialer, e.cluster, snapshotName, data, size)
+}
+
+func (e *etcdNodes) remove(ctx context.Context, snapshotName string) error {
+	return removeSnapshot(ctx, e.dockerDialer, e.cluster, snapshotName)
+}
+
+// snapshotCopyImage returns the image the snapshot is read with and the private registries the
+// image can be pulled from.
+func snapshotCopyImage(rkeConfig *rketypes.RancherKubernetesEngineConfig) (string, map[string]rketypes.PrivateRegistry) {
+	return systemImage(rkeConfig, rkeConfig.SystemImages.Alpine)
+}
+
+// systemImage returns a system image of the cluster prefixed with the default private registry,
+// and the private registries the image can be pulled from.
+func systemImage(rkeConfig *rketypes.RancherKubernetesEngineConfig, image string) (string, map[string]rketypes.PrivateRegistry) {
+	registries := map[string]rketypes.PrivateRegistry{}
+	for _, registry := range rkeConfig.PrivateRegistries {
+		registries[registry.URL] = registry
+		if registry.IsDefault && image != "" && !strings.HasPrefix(image, registry.URL+"/") {
+			image = path.Join(registry.URL, image)
+		}
+	}
+	return image, registries
+}
+
+type snapshotCopy struct {
+	io.Reader
+	close func() error
+}
+
+func (s *snapshotCopy) Close() error {
+	return s.close()
+}
@@ -0,0 +1,370 @@
+package etcdbackup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// Snapshot is an etcd snapshot stored in a backup target.
+type Snapshot struct {
+	// Name is the name of the snapshot without the compressed extension
+	Name     string
+	Modified time.Time
+}
+
+// Target is a storage the etcd snapshots of a cluster are kept in.
+type Target interface {
+	// Save stores a snapshot after RKE took it on the etcd nodes.
+	Save(ctx context.Context, snapshotName string) error
+	// List returns the snapshots in the target, targets that can't be listed return nil.
+	List(ctx context.Context) ([]Snapshot, error)
+	// Remove removes a snapshot from the target, removing a missing snapshot is not an error.
+	Remove(ctx context.Context, snapshotName string) error
+	// Manifest returns the manifest stored next to a snapshot, targets that don't store
+	// manifests return nil.
+	Manifest(ctx context.Context, snapshotName string) (*Manifest, error)
+	// Restore copies a snapshot from the target to the etcd nodes before RKE restores it,
+	// targets RKE restores from itself do nothing.
+	Restore(ctx context.Context, snapshotName string, manifest *Manifest) error
+}
+
+// objectStore is a storage a target copies the snapshots to, names are relative to the folder
+// of the target.
+type objectStore interface {
+	put(ctx context.Context, name string, data io.Reader, size int64) error
+	get(ctx context.Context, name string, limit int64) ([]byte, error)
+	// read copies an object to w without holding it in memory
+	read(ctx context.Context, name string, w io.Writer) error
+	list(ctx context.Context) ([]Snapshot, error)
+	remove(ctx context.Context, name string) error
+}
+
+// snapshotNodes are the etcd nodes of a cluster, RKE takes the snapshots on them and restores
+// a snapshot from them.
+type snapshotNodes interface {
+	// open opens the compressed snapshot on an etcd node and returns its size.
+	open(ctx context.Context, snapshotName string) (io.ReadCloser, int64, error)
+	// write copies a compressed snapshot to every etcd node.
+	write(ctx context.Context, snapshotName string, data io.Reader, size int64) error
+	// remove removes a snapshot from every etcd node, removing a missing snapshot is not an error.
+	remove(ctx context.Context, snapshotName string) error
+}
+
+// objectTarget copies the snapshots from the etcd nodes to an object store, the snapshots are
+// encrypted if the target has a key.
+type objectTarget struct {
+	store objectStore
+	nodes snapshotNodes
+	key   *encryptionKey
+}
+
+// Save copies a snapshot from the etcd nodes to the store and writes its manifest. The
+// plaintext snapshot is removed from the nodes once an encrypted copy is stored, a retried save
+// of a snapshot that already has a manifest only removes it.
+func (o *objectTarget) Save(ctx context.Context, snapshotName string) error {
+	if o.key != nil {
+		if _, err := o.store.get(ctx, manifestFile(snapshotName), maxManifestSize); err == nil {
+			return o.removeFromNodes(ctx, snapshotName)
+		}
+	}
+
+	if err := o.copyToStore(ctx, snapshotName); err != nil {
+		return err
+	}
+	if o.key != nil {
+		return o.removeFromNodes(ctx, snapshotName)
+	}
+	return nil
+}
+
+func (o *objectTarget) copyToStore(ctx context.Context, snapshotName string) error {
+	data, size, err := o.nodes.open(ctx, snapshotName)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	var (
+		digest       = sha256.New()
+		storedDigest = sha256.New()
+		stored       = io.TeeReader(data, digest)
+		storedSize   = size
+	)
+	if o.key != nil {
+		if stored, err = newEncryptingReader(stored, o.key); err != nil {
+			return err
+		}
+		storedSize = encryptedSize(size, o.key)
+	}
+	if err := o.store.put(ctx, snapshotFile(snapshotName), io.TeeReader(stored, storedDigest), storedSize); err != nil {
+		return err
+	}
+
+	manifest := &Manifest{
+		Snapshot:     snapshotName,
+		Created:      time.Now().UTC(),
+		Size:         size,
+		SHA256:       hex.EncodeToString(digest.Sum(nil)),
+		StoredSize:   storedSize,
+		StoredSHA256: hex.EncodeToString(storedDigest.Sum(nil)),
+	}
+	if o.key != nil {
+		if err := manifest.sign(o.key); err != nil {
+			return err
+		}
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return o.store.put(ctx, manifestFile(snapshotName), bytes.NewReader(manifestData), int64(len(manifestData)))
+}
+
+func (o *objectTarget) removeFromNodes(ctx context.Context, snapshotName string) error {
+	if err := o.nodes.remove(ctx, snapshotName); err != nil {
+		return fmt.Errorf("failed to remove plaintext snapshot [%s] from the etcd nodes: %v", snapshotName, err)
+	}
+	return nil
+}
+
+func (o *objectTarget) Manifest(ctx context.Context, snapshotName string) (*Manifest, error) {
+	data, err := o.store.get(ctx, manifestFile(snapshotName), maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest of snapshot [%s]: %v", snapshotName, err)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest of snapshot [%s]: %v", snapshotName, err)
+	}
+	return manifest, nil
+}
+
+// Restore copies a snapshot from the target to the etcd nodes, the stored object is checked
+// against the manifest while it is read and decrypted on the way. The copy is removed from the
+// nodes if the stored object or the snapshot doesn't match, so RKE never restores a snapshot
+// that wasn't stored in the target.
+func (o *objectTarget) Restore(ctx context.Context, snapshotName string, manifest *Manifest) error {
+	if manifest == nil {
+		return fmt.Errorf("snapshot [%s] has no manifest", snapshotName)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	storedPipe, storedWriter := io.Pipe()
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		storedWriter.CloseWithError(o.store.read(ctx, snapshotFile(snapshotName), storedWriter))
+	}()
+
+	var (
+		storedDigest = sha256.New()
+		storedSize   = &countingWriter{}
+		stored       = io.TeeReader(storedPipe, io.MultiWriter(storedDigest, storedSize))
+		plain        = stored
+		plainReader  *io.PipeReader
+		decryptDone  = make(chan struct{})
+	)
+	if o.key != nil {
+		var plainWriter *io.PipeWriter
+		plainReader, plainWriter = io.Pipe()
+		plain = plainReader
+		go func() {
+			defer close(decryptDone)
+			err := decryptSnapshot(plainWriter, stored, o.key)
+			if err == nil {
+				// read the rest of the object so it is checked against the manifest
+				_, err = io.Copy(ioutil.Discard, stored)
+			}
+			plainWriter.CloseWithError(err)
+		}()
+	} else {
+		close(decryptDone)
+	}
+
+	digest := sha256.New()
+	err := o.nodes.write(ctx, snapshotName, io.TeeReader(plain, digest), manifest.Size)
+	if err == nil && o.key == nil {
+		_, err = io.Copy(ioutil.Discard, stored)
+	}
+	// stop the copy from the target if writing to the nodes failed
+	storedPipe.CloseWithError(io.ErrClosedPipe)
+	if plainReader != nil {
+		plainReader.CloseWithError(io.ErrClosedPipe)
+	}
+	<-decryptDone
+	<-readDone
+
+	if err == nil && (storedSize.n != manifest.StoredSize || hex.EncodeToString(storedDigest.Sum(nil)) != manifest.StoredSHA256) {
+		err = fmt.Errorf("stored snapshot [%s] does not match its manifest", snapshotName)
+	}
+	if err == nil && hex.EncodeToString(digest.Sum(nil)) != manifest.SHA256 {
+		err = fmt.Errorf("snapshot [%s] in the backup target does not match its manifest", snapshotName)
+	}
+	if err != nil {
+		if removeErr := o.nodes.remove(context.Background(), snapshotName); removeErr != nil {
+			logrus.Errorf("[etcd-backup] failed to remove snapshot [%s] from the etcd nodes: %v", snapshotName, removeErr)
+		}
+		return fmt.Errorf("failed to copy snapshot [%s] to the etcd nodes: %v", snapshotName, err)
+	}
+	return nil
+}
+
+func (o *objectTarget) List(ctx context.Context) ([]Snapshot, error) {
+	objects, err := o.store.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result []Snapshot
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Name, "."+compressedExtension) {
+			continue
+		}
+		object.Name = strings.TrimSuffix(object.Name, "."+compressedExtension)
+		result = append(result, object)
+	}
+	return result, nil
+}
+
+func (o *objectTarget) Remove(ctx context.Context, snapshotName string) error {
+	if err := o.store.remove(ctx, snapshotFile(snapshotName)); err != nil {
+		return err
+	}
+	return o.store.remove(ctx, manifestFile(snapshotName))
+}
+
+// localTarget keeps the snapshots on the etcd nodes only, RKE saves and removes them.
+type localTarget struct{}
+
+func (localTarget) Save(ctx context.Context, snapshotName string) error {
+	return nil
+}
+
+func (localTarget) List(ctx context.Context) ([]Snapshot, error) {
+	return nil, nil
+}
+
+func (localTarget) Remove(ctx context.Context, snapshotName string) error {
+	return nil
+}
+
+func (localTarget) Manifest(ctx context.Context, snapshotName string) (*Manifest, error) {
+	return nil, nil
+}
+
+func (localTarget) Restore(ctx context.Context, snapshotName string, manifest *Manifest) error {
+	return nil
+}
+
+// getTarget returns the backup target configured for the cluster.
+func (c *Controller) getTarget(cluster *v3.Cluster) (Target, error) {
+	key, err := getEncryptionKey(cluster, c.secretLister)
+	if err != nil {
+		return nil, err
+	}
+	return newTarget(cluster, key, &etcdNodes{dockerDialer: c.dockerDialer, cluster: cluster})
+}
+
+// newTarget returns the backup target configured for the cluster. An etcd backup target takes
+// precedence over the S3 backup config, snapshots are kept on the etcd nodes only if neither is
+// set.
+func newTarget(cluster *v3.Cluster, key *encryptionKey, nodes snapshotNodes) (Target, error) {
+	store, err := newObjectStore(cluster.Spec.EtcdBackupTarget)
+	if err != nil {
+		return nil, err
+	}
+	if store != nil {
+		return &objectTarget{store: store, nodes: nodes, key: key}, nil
+	}
+	if isBackupSet(cluster.Spec.RancherKubernetesEngineConfig) &&
+		cluster.Spec.RancherKubernetesEngineConfig.Services.Etcd.BackupConfig.S3BackupConfig != nil {
+		return newS3Target(cluster.Spec.RancherKubernetesEngineConfig.Services.Etcd.BackupConfig.S3BackupConfig)
+	}
+	return localTarget{}, nil
+}
+
+// newObjectStore returns the storage of an etcd backup target, the store is nil if no storage
+// is configured.
+func newObjectStore(spec *v32.EtcdBackupTarget) (objectStore, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	switch {
+	case spec.AzureBlob != nil:
+		return newAzureBlobStore(spec.AzureBlob)
+	case spec.GCS != nil:
+		return newGCSStore(spec.GCS)
+	case spec.SFTP != nil:
+		return newSFTPStore(spec.SFTP)
+	case spec.Filesystem != nil:
+		return newFilesystemStore(spec.Filesystem)
+	}
+	return nil, nil
+}
+
+// getProviderFlag returns the flag of the backup names that identifies where the backup is
+// stored.
+func getProviderFlag(cluster *v3.Cluster) string {
+	if spec := cluster.Spec.EtcdBackupTarget; spec != nil {
+		switch {
+		case spec.AzureBlob != nil:
+			return "a"
+		case spec.GCS != nil:
+			return "g"
+		case spec.SFTP != nil:
+			return "f"
+		case spec.Filesystem != nil:
+			return "n"
+		}
+	}
+	if cluster.Spec.RancherKubernetesEngineConfig.Services.Etcd.BackupConfig.S3BackupConfig != nil {
+		return "s"
+	}
+	return "l"
+}
+
+// readLimited reads at most limit bytes, reading more is an error.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("object is larger than %d bytes", limit)
+	}
+	return data, nil
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+func snapshotFile(snapshotName string) string {
+	return snapshotName + "." + compressedExtension
+}
+
+func joinFolder(folder, name string) string {
+	if folder == "" {
+		return name
+	}
+	return path.Join(folder, name)
+}
@@ -0,0 +1,119 @@
+package etcdbackup
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/storage"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+)
+
+// azureBlockSize is the size of the blocks a snapshot is uploaded in, a single put is limited
+// to a few hundred megabytes.
+const azureBlockSize = 32 << 20
+
+// azureBlobStore stores the snapshots as block blobs of an Azure storage container.
+type azureBlobStore struct {
+	container *storage.Container
+	folder    string
+}
+
+func newAzureBlobStore(spec *v32.AzureBlobBackupTarget) (objectStore, error) {
+	suffix := spec.EndpointSuffix
+	if suffix == "" {
+		suffix = storage.DefaultBaseURL
+	}
+	client, err := storage.NewClient(spec.AccountName, spec.AccountKey, suffix, storage.DefaultAPIVersion, true)
+	if err != nil {
+		return nil, err
+	}
+	blobService := client.GetBlobService()
+	return &azureBlobStore{
+		container: blobService.GetContainerReference(spec.Container),
+		folder:    strings.Trim(spec.Folder, "/"),
+	}, nil
+}
+
+func (a *azureBlobStore) put(ctx context.Context, name string, data io.Reader, size int64) error {
+	blob := a.container.GetBlobReference(joinFolder(a.folder, name))
+
+	var blocks []storage.Block
+	for offset := int64(0); offset < size; offset += azureBlockSize {
+		length := size - offset
+		if length > azureBlockSize {
+			length = azureBlockSize
+		}
+		// block ids of a blob must have the same length
+		id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%010d", len(blocks))))
+		if err := blob.PutBlockWithLength(id, uint64(length), io.LimitReader(data, length), nil); err != nil {
+			return fmt.Errorf("failed to upload snapshot %s to azure container %s: %v", name, a.container.Name, err)
+		}
+		blocks = append(blocks, storage.Block{ID: id, Status: storage.BlockStatusUncommitted})
+	}
+
+	if err := blob.PutBlockList(blocks, nil); err != nil {
+		return fmt.Errorf("failed to upload snapshot %s to azure container %s: %v", name, a.container.Name, err)
+	}
+	return nil
+}
+
+func (a *azureBlobStore) get(ctx context.Context, name string, limit int64) ([]byte, error) {
+	data, err := a.container.GetBlobReference(joinFolder(a.folder, name)).Get(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from azure container %s: %v", name, a.container.Name, err)
+	}
+	defer data.Close()
+	return readLimited(data, limit)
+}
+
+func (a *azureBlobStore) read(ctx context.Context, name string, w io.Writer) error {
+	data, err := a.container.GetBlobReference(joinFolder(a.folder, name)).Get(nil)
+	if err != nil {
+		return fmt.Errorf("failed to read %s from azure container %s: %v", name, a.container.Name, err)
+	}
+	defer data.Close()
+	_, err = io.Copy(w, data)
+	return err
+}
+
+func (a *azureBlobStore) list(ctx context.Context) ([]Snapshot, error) {
+	prefix := ""
+	if a.folder != "" {
+		prefix = a.folder + "/"
+	}
+
+	var (
+		result []Snapshot
+		params = storage.ListBlobsParameters{
+			Prefix:    prefix,
+			Delimiter: "/",
+		}
+	)
+	for {
+		resp, err := a.container.ListBlobs(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list azure container %s: %v", a.container.Name, err)
+		}
+		for _, blob := range resp.Blobs {
+			result = append(result, Snapshot{
+				Name:     strings.TrimPrefix(blob.Name, prefix),
+				Modified: time.Time(blob.Properties.LastModified),
+			})
+		}
+		if resp.NextMarker == "" {
+			return result, nil
+		}
+		params.Marker = resp.NextMarker
+	}
+}
+
+func (a *azureBlobStore) remove(ctx context.Context, name string) error {
+	if _, err := a.container.GetBlobReference(joinFolder(a.folder, name)).DeleteIfExists(nil); err != nil {
+		return fmt.Errorf("failed to remove snapshot %s from azure container %s: %v", name, a.container.Name, err)
+	}
+	return nil
+}
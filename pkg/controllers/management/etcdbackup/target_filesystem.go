@@ -0,0 +1,130 @@
+package etcdbackup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+)
+
+// filesystemStore stores the snapshots in a directory of the Rancher server.
+type filesystemStore struct {
+	dir string
+}
+
+// newFilesystemStore returns the store of a directory under the base directory set by the
+// admin in the etcd-backup-filesystem-base-dir setting. The path of the target is relative to
+// the base directory, an absolute path is accepted if it is under the base directory.
+func newFilesystemStore(spec *v32.FilesystemBackupTarget) (objectStore, error) {
+	dir, err := filesystemTargetDir(settings.EtcdBackupFilesystemBaseDir.Get(), spec.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &filesystemStore{dir: dir}, nil
+}
+
+func filesystemTargetDir(baseDir, targetPath string) (string, error) {
+	if baseDir == "" {
+		return "", fmt.Errorf("filesystem backup targets are disabled, the %s setting is not set", settings.EtcdBackupFilesystemBaseDir.Name)
+	}
+	if !filepath.IsAbs(baseDir) {
+		return "", fmt.Errorf("the %s setting [%s] is not absolute", settings.EtcdBackupFilesystemBaseDir.Name, baseDir)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(targetPath), "/") {
+		if part == ".." {
+			return "", fmt.Errorf("filesystem backup target path [%s] must not contain ..", targetPath)
+		}
+	}
+
+	baseDir = filepath.Clean(baseDir)
+	dir := filepath.Clean(targetPath)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(baseDir, dir)
+	}
+	if rel, err := filepath.Rel(baseDir, dir); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("filesystem backup target path [%s] is not a directory under %s", targetPath, baseDir)
+	}
+	return dir, nil
+}
+
+func (f *filesystemStore) put(ctx context.Context, name string, data io.Reader, size int64) error {
+	if err := os.MkdirAll(f.dir, 0700); err != nil {
+		return err
+	}
+
+	// the snapshot is written to a temporary file first so an incomplete copy is never listed
+	tmp, err := ioutil.TempFile(f.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, data)
+	if err == nil && n != size {
+		err = fmt.Errorf("copied %d bytes of snapshot %s, expected %d", n, name, size)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(f.dir, filepath.Base(name)))
+}
+
+func (f *filesystemStore) get(ctx context.Context, name string, limit int64) ([]byte, error) {
+	file, err := os.Open(filepath.Join(f.dir, filepath.Base(name)))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readLimited(file, limit)
+}
+
+func (f *filesystemStore) read(ctx context.Context, name string, w io.Writer) error {
+	file, err := os.Open(filepath.Join(f.dir, filepath.Base(name)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func (f *filesystemStore) list(ctx context.Context) ([]Snapshot, error) {
+	files, err := ioutil.ReadDir(f.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var result []Snapshot
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		result = append(result, Snapshot{
+			Name:     file.Name(),
+			Modified: file.ModTime(),
+		})
+	}
+	return result, nil
+}
+
+func (f *filesystemStore) remove(ctx context.Context, name string) error {
+	err := os.Remove(filepath.Join(f.dir, filepath.Base(name)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,205 @@
+package etcdbackup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"golang.org/x/oauth2/jwt"
+)
+
+const (
+	gcsURL      = "https://storage.googleapis.com"
+	gcsTokenURL = "https://oauth2.googleapis.com/token"
+	gcsScope    = "https://www.googleapis.com/auth/devstorage.read_write"
+)
+
+// gcsStore stores the snapshots as objects of a Google Cloud Storage bucket through the JSON
+// API.
+type gcsStore struct {
+	baseURL string
+	bucket  string
+	folder  string
+	config  *jwt.Config
+}
+
+// serviceAccountKey is the part of the JSON key of a service account used to authenticate.
+type serviceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+type gcsObjectList struct {
+	Items []struct {
+		Name    string    `json:"name"`
+		Updated time.Time `json:"updated"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func newGCSStore(spec *v32.GCSBackupTarget) (objectStore, error) {
+	key := serviceAccountKey{}
+	if err := json.Unmarshal([]byte(spec.ServiceAccountKey), &key); err != nil {
+		return nil, fmt.Errorf("invalid gcs service account key: %v", err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, fmt.Errorf("gcs service account key has no client_email or private_key")
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = gcsTokenURL
+	}
+	return &gcsStore{
+		baseURL: gcsURL,
+		bucket:  spec.Bucket,
+		folder:  strings.Trim(spec.Folder, "/"),
+		config: &jwt.Config{
+			Email:        key.ClientEmail,
+			PrivateKey:   []byte(key.PrivateKey),
+			PrivateKeyID: key.PrivateKeyID,
+			Scopes:       []string{gcsScope},
+			TokenURL:     key.TokenURI,
+		},
+	}, nil
+}
+
+func (g *gcsStore) put(ctx context.Context, name string, data io.Reader, size int64) error {
+	query := url.Values{
+		"uploadType": []string{"media"},
+		"name":       []string{joinFolder(g.folder, name)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", g.baseURL, url.PathEscape(g.bucket), query.Encode()), data)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/zip")
+	return g.do(ctx, req, nil)
+}
+
+func (g *gcsStore) get(ctx context.Context, name string, limit int64) ([]byte, error) {
+	resp, err := g.media(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	return readLimited(resp, limit)
+}
+
+func (g *gcsStore) read(ctx context.Context, name string, w io.Writer) error {
+	resp, err := g.media(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+	_, err = io.Copy(w, resp)
+	return err
+}
+
+// media returns the content of an object.
+func (g *gcsStore) media(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", g.baseURL, url.PathEscape(g.bucket), url.PathEscape(joinFolder(g.folder, name))), nil)
+	if err != nil {
+		return nil, err
+	}
+	return g.open(ctx, req)
+}
+
+func (g *gcsStore) list(ctx context.Context) ([]Snapshot, error) {
+	prefix := ""
+	if g.folder != "" {
+		prefix = g.folder + "/"
+	}
+
+	var (
+		result []Snapshot
+		query  = url.Values{
+			"prefix":    []string{prefix},
+			"delimiter": []string{"/"},
+			"fields":    []string{"items(name,updated),nextPageToken"},
+		}
+	)
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/storage/v1/b/%s/o?%s", g.baseURL, url.PathEscape(g.bucket), query.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+		objects := gcsObjectList{}
+		if err := g.do(ctx, req, &objects); err != nil {
+			return nil, err
+		}
+		for _, item := range objects.Items {
+			result = append(result, Snapshot{
+				Name:     strings.TrimPrefix(item.Name, prefix),
+				Modified: item.Updated,
+			})
+		}
+		if objects.NextPageToken == "" {
+			return result, nil
+		}
+		query.Set("pageToken", objects.NextPageToken)
+	}
+}
+
+func (g *gcsStore) remove(ctx context.Context, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, fmt.Sprintf("%s/storage/v1/b/%s/o/%s", g.baseURL, url.PathEscape(g.bucket), url.PathEscape(joinFolder(g.folder, name))), nil)
+	if err != nil {
+		return err
+	}
+	err = g.do(ctx, req, nil)
+	if isGCSNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+type gcsError struct {
+	statusCode int
+	message    string
+}
+
+func (e *gcsError) Error() string {
+	return fmt.Sprintf("gcs request failed with status %d: %s", e.statusCode, e.message)
+}
+
+func isGCSNotFound(err error) bool {
+	gcsErr, ok := err.(*gcsError)
+	return ok && gcsErr.statusCode == http.StatusNotFound
+}
+
+// do sends a request authenticated as the service account and decodes the JSON response into
+// out if it is set.
+func (g *gcsStore) do(ctx context.Context, req *http.Request, out interface{}) error {
+	body, err := g.open(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(body).Decode(out)
+}
+
+// open sends a request authenticated as the service account and returns the body of the
+// response.
+func (g *gcsStore) open(ctx context.Context, req *http.Request) (io.ReadCloser, error) {
+	resp, err := g.config.Client(ctx).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &gcsError{statusCode: resp.StatusCode, message: strings.TrimSpace(string(body))}
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,85 @@
+package etcdbackup
+
+import (
+	"context"
+	"strings"
+
+	minio "github.com/minio/minio-go/v7"
+	rketypes "github.com/rancher/rke/types"
+)
+
+// s3Target lists and removes the snapshots RKE uploads to S3.
+type s3Target struct {
+	client *minio.Client
+	bucket string
+	folder string
+}
+
+func newS3Target(sbc *rketypes.S3BackupConfig) (Target, error) {
+	client, err := GetS3Client(sbc, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Target{
+		client: client,
+		bucket: sbc.BucketName,
+		folder: strings.Trim(sbc.Folder, "/"),
+	}, nil
+}
+
+// Save does nothing, RKE uploads the snapshot itself.
+func (s *s3Target) Save(ctx context.Context, snapshotName string) error {
+	return nil
+}
+
+func (s *s3Target) List(ctx context.Context) ([]Snapshot, error) {
+	prefix := ""
+	if s.folder != "" {
+		prefix = s.folder + "/"
+	}
+
+	var result []Snapshot
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		name := strings.TrimPrefix(object.Key, prefix)
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, "."+compressedExtension) {
+			continue
+		}
+		result = append(result, Snapshot{
+			Name:     strings.TrimSuffix(name, "."+compressedExtension),
+			Modified: object.LastModified,
+		})
+	}
+	return result, nil
+}
+
+func (s *s3Target) Remove(ctx context.Context, snapshotName string) error {
+	return s.client.RemoveObject(ctx, s.bucket, joinFolder(s.folder, snapshotFile(snapshotName)), minio.RemoveObjectOptions{})
+}
+
+// Manifest returns nil, RKE uploads the snapshot without a manifest.
+func (s *s3Target) Manifest(ctx context.Context, snapshotName string) (*Manifest, error) {
+	return nil, nil
+}
+
+// Restore does nothing, RKE downloads the snapshot from S3 itself.
+func (s *s3Target) Restore(ctx context.Context, snapshotName string, manifest *Manifest) error {
+	return nil
+}
+
+// copyTo copies a snapshot from S3 to nodes, RKE restores it from S3 itself but the restore
+// drill reads the copy in S3.
+func (s *s3Target) copyTo(ctx context.Context, snapshotName string, nodes snapshotNodes) error {
+	object, err := s.client.GetObject(ctx, s.bucket, joinFolder(s.folder, snapshotFile(snapshotName)), minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+	info, err := object.Stat()
+	if err != nil {
+		return err
+	}
+	return nodes.write(ctx, snapshotName, object, info.Size)
+}
@@ -0,0 +1,221 @@
+package etcdbackup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	sftpDialTimeout = 30 * time.Second
+	sftpTempPrefix  = ".tmp-"
+)
+
+// sftpStore stores the snapshots in a directory of an SFTP server.
+type sftpStore struct {
+	address string
+	dir     string
+	config  *ssh.ClientConfig
+}
+
+func newSFTPStore(spec *v32.SFTPBackupTarget) (objectStore, error) {
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(spec.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid sftp host key: %v", err)
+	}
+
+	var auth []ssh.AuthMethod
+	if spec.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(spec.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid sftp private key: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if spec.Password != "" {
+		auth = append(auth, ssh.Password(spec.Password))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("sftp backup target has neither a password nor a private key")
+	}
+
+	address := spec.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "22")
+	}
+	return &sftpStore{
+		address: address,
+		dir:     path.Clean(spec.Path),
+		config: &ssh.ClientConfig{
+			User:            spec.Username,
+			Auth:            auth,
+			HostKeyCallback: ssh.FixedHostKey(hostKey),
+			Timeout:         sftpDialTimeout,
+		},
+	}, nil
+}
+
+func (s *sftpStore) put(ctx context.Context, name string, data io.Reader, size int64) error {
+	return s.session(ctx, func(c *sftp.Client) error {
+		return sftpPut(c, s.dir, name, data, size)
+	})
+}
+
+func (s *sftpStore) get(ctx context.Context, name string, limit int64) ([]byte, error) {
+	var result []byte
+	err := s.session(ctx, func(c *sftp.Client) error {
+		var err error
+		result, err = sftpGet(c, s.dir, name, limit)
+		return err
+	})
+	return result, err
+}
+
+func (s *sftpStore) read(ctx context.Context, name string, w io.Writer) error {
+	return s.session(ctx, func(c *sftp.Client) error {
+		return sftpRead(c, s.dir, name, w)
+	})
+}
+
+func (s *sftpStore) list(ctx context.Context) ([]Snapshot, error) {
+	var result []Snapshot
+	err := s.session(ctx, func(c *sftp.Client) error {
+		var err error
+		result, err = sftpList(c, s.dir)
+		return err
+	})
+	return result, err
+}
+
+func (s *sftpStore) remove(ctx context.Context, name string) error {
+	return s.session(ctx, func(c *sftp.Client) error {
+		return sftpRemove(c, s.dir, name)
+	})
+}
+
+// session connects to the server and runs f with an SFTP client, the connection is closed when
+// f returns or the context is done.
+func (s *sftpStore) session(ctx context.Context, f func(c *sftp.Client) error) error {
+	dialer := &net.Dialer{Timeout: sftpDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to sftp server %s: %v", s.address, err)
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, s.address, s.config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to sftp server %s: %v", s.address, err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	c, err := sftp.NewClient(client)
+	if err != nil {
+		return fmt.Errorf("sftp server %s does not support the sftp subsystem: %v", s.address, err)
+	}
+	defer c.Close()
+	return f(c)
+}
+
+func sftpPut(c *sftp.Client, dir, name string, data io.Reader, size int64) error {
+	if err := c.MkdirAll(dir); err != nil {
+		return err
+	}
+
+	// the snapshot is written to a temporary file first so an incomplete copy is never listed
+	target := path.Join(dir, path.Base(name))
+	tmp := path.Join(dir, sftpTempPrefix+path.Base(name))
+	if err := sftpWrite(c, tmp, data, size); err != nil {
+		_ = c.Remove(tmp)
+		return err
+	}
+	// version 3 servers don't overwrite files on rename
+	if err := c.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return c.Rename(tmp, target)
+}
+
+func sftpWrite(c *sftp.Client, file string, data io.Reader, size int64) error {
+	f, err := c.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, data)
+	if err == nil && n != size {
+		err = fmt.Errorf("copied %d bytes of %s, expected %d", n, path.Base(file), size)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func sftpGet(c *sftp.Client, dir, name string, limit int64) ([]byte, error) {
+	f, err := c.Open(path.Join(dir, path.Base(name)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readLimited(f, limit)
+}
+
+func sftpRead(c *sftp.Client, dir, name string, w io.Writer) error {
+	f, err := c.Open(path.Join(dir, path.Base(name)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func sftpList(c *sftp.Client, dir string) ([]Snapshot, error) {
+	files, err := c.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var result []Snapshot
+	for _, file := range files {
+		if !file.Mode().IsRegular() || strings.HasPrefix(file.Name(), sftpTempPrefix) {
+			continue
+		}
+		result = append(result, Snapshot{
+			Name:     file.Name(),
+			Modified: file.ModTime(),
+		})
+	}
+	return result, nil
+}
+
+func sftpRemove(c *sftp.Client, dir, name string) error {
+	err := c.Remove(path.Join(dir, path.Base(name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
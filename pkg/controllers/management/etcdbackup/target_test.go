@@ -0,0 +1,346 @@
+package etcdbackup
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	rketypes "github.com/rancher/rke/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeNodes are etcd nodes that have the same content for every snapshot, the snapshots
+// written to them are kept by name.
+type fakeNodes struct {
+	content string
+	// size is the size of the snapshot if it isn't the size of the content
+	size    int64
+	written map[string][]byte
+	removed []string
+}
+
+func snapshotContent(content string) *fakeNodes {
+	return &fakeNodes{content: content, size: int64(len(content)), written: map[string][]byte{}}
+}
+
+func (f *fakeNodes) open(ctx context.Context, snapshotName string) (io.ReadCloser, int64, error) {
+	return ioutil.NopCloser(strings.NewReader(f.content)), f.size, nil
+}
+
+func (f *fakeNodes) write(ctx context.Context, snapshotName string, data io.Reader, size int64) error {
+	written, err := ioutil.ReadAll(data)
+	f.written[snapshotName] = written
+	if err == nil && int64(len(written)) != size {
+		err = fmt.Errorf("wrote %d bytes, expected %d", len(written), size)
+	}
+	return err
+}
+
+func (f *fakeNodes) remove(ctx context.Context, snapshotName string) error {
+	delete(f.written, snapshotName)
+	f.removed = append(f.removed, snapshotName)
+	return nil
+}
+
+func setFilesystemBaseDir(t *testing.T, dir string) {
+	old := settings.EtcdBackupFilesystemBaseDir.Get()
+	require.NoError(t, settings.EtcdBackupFilesystemBaseDir.Set(dir))
+	t.Cleanup(func() {
+		_ = settings.EtcdBackupFilesystemBaseDir.Set(old)
+	})
+}
+
+func TestFilesystemTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcd-backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	setFilesystemBaseDir(t, dir)
+
+	store, err := newFilesystemStore(&v32.FilesystemBackupTarget{Path: "snapshots"})
+	require.NoError(t, err)
+	target := &objectTarget{store: store, nodes: snapshotContent("snapshot")}
+
+	snapshots, err := target.List(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, snapshots, "directory doesn't exist yet")
+
+	require.NoError(t, target.Save(context.Background(), "c-abcde-rn-12345_2021-01-01T00:00:00Z"))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "snapshots", "c-abcde-rn-12345_2021-01-01T00:00:00Z.zip"))
+	require.NoError(t, err)
+	assert.Equal(t, "snapshot", string(data))
+	assert.Empty(t, target.nodes.(*fakeNodes).removed, "snapshots that aren't encrypted are kept on the nodes")
+
+	// files that aren't snapshots are ignored
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "snapshots", "README"), []byte("readme"), 0600))
+
+	snapshots, err = target.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, "c-abcde-rn-12345_2021-01-01T00:00:00Z", snapshots[0].Name)
+
+	require.NoError(t, target.Remove(context.Background(), snapshots[0].Name))
+	require.NoError(t, target.Remove(context.Background(), snapshots[0].Name), "snapshot is already removed")
+	snapshots, err = target.List(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, snapshots)
+}
+
+func TestRestoreFromTarget(t *testing.T) {
+	tests := []struct {
+		name string
+		key  *encryptionKey
+	}{
+		{name: "plain"},
+		{name: "encrypted", key: newTestKey(t)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "etcd-backup")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			setFilesystemBaseDir(t, filepath.Dir(dir))
+
+			store, err := newFilesystemStore(&v32.FilesystemBackupTarget{Path: dir})
+			require.NoError(t, err)
+			nodes := snapshotContent("snapshot")
+			target := &objectTarget{store: store, nodes: nodes, key: tt.key}
+			require.NoError(t, target.Save(context.Background(), "c-abcde-rn-12345"))
+			manifest, err := target.Manifest(context.Background(), "c-abcde-rn-12345")
+			require.NoError(t, err)
+
+			require.NoError(t, target.Restore(context.Background(), "c-abcde-rn-12345", manifest))
+			assert.Equal(t, "snapshot", string(nodes.written["c-abcde-rn-12345"]), "the nodes get the snapshot that was taken")
+
+			// the stored object is checked even if the snapshot matches
+			modified := *manifest
+			modified.StoredSHA256 = "0000"
+			assert.Error(t, target.Restore(context.Background(), "c-abcde-rn-12345", &modified))
+			assert.NotContains(t, nodes.written, "c-abcde-rn-12345", "a snapshot that doesn't match is removed from the nodes")
+			modified = *manifest
+			modified.StoredSize++
+			assert.Error(t, target.Restore(context.Background(), "c-abcde-rn-12345", &modified))
+
+			// the stored snapshot was replaced
+			file := filepath.Join(dir, "c-abcde-rn-12345.zip")
+			stored, err := ioutil.ReadFile(file)
+			require.NoError(t, err)
+			stored[len(stored)-1] ^= 1
+			require.NoError(t, ioutil.WriteFile(file, stored, 0600))
+			assert.Error(t, target.Restore(context.Background(), "c-abcde-rn-12345", manifest))
+			assert.NotContains(t, nodes.written, "c-abcde-rn-12345", "a snapshot that doesn't match is removed from the nodes")
+
+			assert.Error(t, target.Restore(context.Background(), "c-abcde-rn-12345", nil), "snapshot without a manifest")
+		})
+	}
+}
+
+func TestFilesystemTargetShortRead(t *testing.T) {
+	base, err := ioutil.TempDir("", "etcd-backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(base)
+	setFilesystemBaseDir(t, base)
+	dir := filepath.Join(base, "snapshots")
+	require.NoError(t, os.Mkdir(dir, 0700))
+
+	store, err := newFilesystemStore(&v32.FilesystemBackupTarget{Path: dir})
+	require.NoError(t, err)
+	nodes := snapshotContent("short")
+	nodes.size = 10
+	target := &objectTarget{store: store, nodes: nodes}
+
+	assert.Error(t, target.Save(context.Background(), "snapshot"))
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files, "incomplete copy is removed")
+}
+
+func TestFilesystemTargetDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseDir string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "relative", baseDir: "/backups", path: "cluster-a", want: "/backups/cluster-a"},
+		{name: "nested", baseDir: "/backups/", path: "team/cluster-a/", want: "/backups/team/cluster-a"},
+		{name: "absolute under the base dir", baseDir: "/backups", path: "/backups/cluster-a", want: "/backups/cluster-a"},
+		{name: "dots in a name", baseDir: "/backups", path: "..cluster-a", want: "/backups/..cluster-a"},
+		{name: "base dir not set", baseDir: "", path: "/backups/cluster-a", wantErr: true},
+		{name: "base dir not absolute", baseDir: "backups", path: "cluster-a", wantErr: true},
+		{name: "absolute outside the base dir", baseDir: "/backups", path: "/etc", wantErr: true},
+		{name: "prefix of another directory", baseDir: "/backups", path: "/backups-other/cluster-a", wantErr: true},
+		{name: "base dir itself", baseDir: "/backups", path: "/backups", wantErr: true},
+		{name: "empty path", baseDir: "/backups", path: "", wantErr: true},
+		{name: "parent", baseDir: "/backups", path: "../etc", wantErr: true},
+		{name: "parent that stays under the base dir", baseDir: "/backups", path: "team/../cluster-a", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := filesystemTargetDir(tt.baseDir, tt.path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, dir)
+		})
+	}
+}
+
+func TestGCSTarget(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	objects := map[string][]byte{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`))
+	})
+	mux.HandleFunc("/upload/storage/v1/b/backups/o", func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		assert.Equal(t, http.MethodPost, req.Method)
+		data, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		objects[req.URL.Query().Get("name")] = data
+		_, _ = rw.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/storage/v1/b/backups/o", func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "etcd/", req.URL.Query().Get("prefix"))
+		var list gcsObjectList
+		for name := range objects {
+			list.Items = append(list.Items, struct {
+				Name    string    `json:"name"`
+				Updated time.Time `json:"updated"`
+			}{Name: name, Updated: time.Now()})
+		}
+		_ = json.NewEncoder(rw).Encode(list)
+	})
+	mux.HandleFunc("/storage/v1/b/backups/o/", func(rw http.ResponseWriter, req *http.Request) {
+		name := strings.TrimPrefix(req.URL.Path, "/storage/v1/b/backups/o/")
+		data, ok := objects[name]
+		if !ok {
+			http.NotFound(rw, req)
+			return
+		}
+		switch req.Method {
+		case http.MethodGet:
+			assert.Equal(t, "media", req.URL.Query().Get("alt"))
+			_, _ = rw.Write(data)
+		case http.MethodDelete:
+			delete(objects, name)
+			rw.WriteHeader(http.StatusNoContent)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	serviceAccountKey, err := json.Marshal(serviceAccountKey{
+		ClientEmail: "backup@project.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		TokenURI:    server.URL + "/token",
+	})
+	require.NoError(t, err)
+	store, err := newGCSStore(&v32.GCSBackupTarget{
+		Bucket:            "backups",
+		Folder:            "/etcd/",
+		ServiceAccountKey: string(serviceAccountKey),
+	})
+	require.NoError(t, err)
+	store.(*gcsStore).baseURL = server.URL
+	target := &objectTarget{store: store, nodes: snapshotContent("snapshot")}
+
+	require.NoError(t, target.Save(context.Background(), "c-abcde-rg-12345"))
+	assert.Equal(t, []byte("snapshot"), objects["etcd/c-abcde-rg-12345.zip"])
+	manifest, err := target.Manifest(context.Background(), "c-abcde-rg-12345")
+	require.NoError(t, err)
+	assert.Equal(t, "c-abcde-rg-12345", manifest.Snapshot)
+	assert.Equal(t, "16a0eeb0791b6c92451fd284dd9f599e0a7dbe7f6ebea6e2d2d06c7f74aec112", manifest.SHA256)
+
+	snapshots, err := target.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, "c-abcde-rg-12345", snapshots[0].Name)
+
+	require.NoError(t, target.Remove(context.Background(), "c-abcde-rg-12345"))
+	require.NoError(t, target.Remove(context.Background(), "c-abcde-rg-12345"), "snapshot is already removed")
+	assert.Empty(t, objects)
+}
+
+func TestGetOrphanedSnapshots(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	snapshots := []Snapshot{
+		{Name: "c-abcde-rn-known_2021-01-01T00:00:00Z", Modified: old},
+		{Name: "c-abcde-rn-orphan_2021-01-01T00:00:00Z", Modified: old},
+		{Name: "c-abcde-rn-recent_2021-01-01T00:00:00Z", Modified: time.Now()},
+		{Name: "c-abcde-mn-manual_2021-01-01T00:00:00Z", Modified: old},
+		{Name: "c-fghij-rn-other_2021-01-01T00:00:00Z", Modified: old},
+	}
+	backups := []*v3.EtcdBackup{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "c-abcde-rn-known"},
+			Spec:       rketypes.EtcdBackupSpec{Filename: "c-abcde-rn-known_2021-01-01T00:00:00Z.zip"},
+		},
+	}
+
+	orphaned := getOrphanedSnapshots("c-abcde", 24*time.Hour, snapshots, backups)
+	require.Len(t, orphaned, 1)
+	assert.Equal(t, "c-abcde-rn-orphan_2021-01-01T00:00:00Z", orphaned[0].Name)
+}
+
+func TestSFTPStore(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	server := sftp.NewRequestServer(serverConn, sftp.InMemHandler())
+	go server.Serve()
+	defer server.Close()
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	require.NoError(t, err)
+	defer client.Close()
+
+	snapshots, err := sftpList(client, "/backups/etcd")
+	require.NoError(t, err)
+	assert.Empty(t, snapshots, "directory doesn't exist yet")
+
+	require.NoError(t, sftpPut(client, "/backups/etcd", "snapshot.zip", strings.NewReader("old"), 3))
+	require.NoError(t, sftpPut(client, "/backups/etcd", "snapshot.zip", strings.NewReader("snapshot"), 8), "existing snapshot is replaced")
+	assert.Error(t, sftpPut(client, "/backups/etcd", "short.zip", strings.NewReader("short"), 10))
+	require.NoError(t, client.Mkdir("/backups/etcd/folder"))
+
+	data, err := sftpGet(client, "/backups/etcd", "snapshot.zip", 100)
+	require.NoError(t, err)
+	assert.Equal(t, "snapshot", string(data))
+	copied := &strings.Builder{}
+	require.NoError(t, sftpRead(client, "/backups/etcd", "snapshot.zip", copied))
+	assert.Equal(t, "snapshot", copied.String())
+
+	snapshots, err = sftpList(client, "/backups/etcd")
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1, "folders and incomplete copies are not listed")
+	assert.Equal(t, "snapshot.zip", snapshots[0].Name)
+
+	require.NoError(t, sftpRemove(client, "/backups/etcd", "snapshot.zip"))
+	require.NoError(t, sftpRemove(client, "/backups/etcd", "snapshot.zip"), "snapshot is already removed")
+	_, err = sftpGet(client, "/backups/etcd", "snapshot.zip", 100)
+	assert.Error(t, err)
+}
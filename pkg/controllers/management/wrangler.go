@@ -13,6 +13,7 @@
 	"github.com/rancher/rancher/pkg/controllers/management/gke"
 	"github.com/rancher/rancher/pkg/controllers/management/k3sbasedupgrade"
 	"github.com/rancher/rancher/pkg/features"
+	"github.com/rancher/rancher/pkg/sessionrecording"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/rancher/rancher/pkg/wrangler"
 )
@@ -26,6 +27,7 @@ func RegisterWrangler(ctx context.Context, wranglerContext *wrangler.Context, ma
 
 	feature.Register(ctx, wranglerContext)
 	clusterconnected.Register(ctx, wranglerContext)
+	sessionrecording.Register(ctx, wranglerContext.Core.Secret())
 
 	if features.ProvisioningV2.Enabled() {
 		if err := authprovisioningv2.Register(ctx, wranglerContext); err != nil {
@@ -7,6 +7,7 @@
 	"net/url"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
@@ -486,6 +487,10 @@ func (d *ConfigSyncer) addRecipients(notifiers []*v3.Notifier, receiver *alertco
 				logrus.Debugf("Can not find the notifier %s", r.NotifierName)
 				continue
 			}
+			if notifierutil.AlertsSentByRancher(&notifier.Spec) {
+				// the state syncer sends the alerts of the notifier
+				continue
+			}
 			commonNotifierConfig := alertconfig.NotifierConfig{
 				VSendResolved: notifier.Spec.SendResolved,
 			}
@@ -618,6 +623,16 @@ func (d *ConfigSyncer) addRecipients(notifiers []*v3.Notifier, receiver *alertco
 				receiver.SlackConfigs = append(receiver.SlackConfigs, slack)
 				receiverExist = true
 
+			} else if notifier.Spec.MattermostConfig != nil || notifier.Spec.RocketChatConfig != nil {
+				// Mattermost and Rocket.Chat incoming webhooks accept slack payloads
+				slack, err := slackCompatibleConfig(commonNotifierConfig, notifier, r.Recipient)
+				if err != nil {
+					logrus.Errorf("Failed to configure notifier %s, %v", notifier.Name, err)
+					continue
+				}
+				receiver.SlackConfigs = append(receiver.SlackConfigs, slack)
+				receiverExist = true
+
 			} else if notifier.Spec.SMTPConfig != nil {
 				header := map[string]string{}
 				header["Subject"] = `{{ template "rancher.title" . }}`
@@ -635,6 +650,20 @@ func (d *ConfigSyncer) addRecipients(notifiers []*v3.Notifier, receiver *alertco
 				if r.Recipient != "" {
 					email.To = r.Recipient
 				}
+				// alert manager connects with TLS on port 465, the modes it can not honor are sent
+				// by rancher, see notifierutil.AlertsSentByRancher
+				if mode := notifier.Spec.SMTPConfig.TLSMode; mode != "" {
+					requireTLS := mode == v32.SMTPTLSModeStartTLS
+					email.RequireTLS = &requireTLS
+				}
+				// alertmanager sends to every address in to, the headers control what recipients see
+				if cc, bcc := notifier.Spec.SMTPConfig.CC, notifier.Spec.SMTPConfig.BCC; len(cc) > 0 || len(bcc) > 0 {
+					header["To"] = email.To
+					if len(cc) > 0 {
+						header["Cc"] = strings.Join(cc, ", ")
+					}
+					email.To = strings.Join(append(append([]string{email.To}, cc...), bcc...), ", ")
+				}
 				receiver.EmailConfigs = append(receiver.EmailConfigs, email)
 				receiverExist = true
 			}
@@ -646,6 +675,44 @@ func (d *ConfigSyncer) addRecipients(notifiers []*v3.Notifier, receiver *alertco
 
 }
 
+func slackCompatibleConfig(commonNotifierConfig alertconfig.NotifierConfig, notifier *v3.Notifier, recipient string) (*alertconfig.SlackConfig, error) {
+	var (
+		webhookURL string
+		channel    string
+		username   string
+		httpConfig *v32.HTTPClientConfig
+	)
+	if c := notifier.Spec.MattermostConfig; c != nil {
+		webhookURL, channel, username, httpConfig = c.URL, c.DefaultRecipient, c.Username, c.HTTPClientConfig
+	} else if c := notifier.Spec.RocketChatConfig; c != nil {
+		webhookURL, channel, httpConfig = c.URL, c.DefaultRecipient, c.HTTPClientConfig
+	}
+
+	slack := &alertconfig.SlackConfig{
+		NotifierConfig: commonNotifierConfig,
+		APIURL:         alertconfig.Secret(webhookURL),
+		Channel:        channel,
+		Username:       username,
+		Text:           `{{ template "slack.text" . }}`,
+		Title:          `{{ template "rancher.title" . }}`,
+		Color:          `{{ if eq (index .Alerts 0).Labels.severity "critical" }}danger{{ else if eq (index .Alerts 0).Labels.severity "warning" }}warning{{ else }}good{{ end }}`,
+	}
+	if recipient != "" {
+		slack.Channel = recipient
+	}
+
+	if notifierutil.IsHTTPClientConfigSet(httpConfig) {
+		url, err := toAlertManagerURL(httpConfig.ProxyURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse proxy url %s", httpConfig.ProxyURL)
+		}
+		slack.HTTPConfig = &alertconfig.HTTPClientConfig{
+			ProxyURL: *url,
+		}
+	}
+	return slack, nil
+}
+
 func (d *ConfigSyncer) isAppDeploy(appNamespace string) (bool, bool, error) {
 	appName, _ := monitorutil.ClusterAlertManagerInfo()
 	app, err := d.appLister.Get(appNamespace, appName)
@@ -739,6 +806,10 @@ func (d *ConfigSyncer) syncWebhookConfig(notifiers []*v3.Notifier, cAlertGroupsM
 				logrus.Debugf("Can not find the notifier %s", r.NotifierName)
 				continue
 			}
+			if notifierutil.AlertsSentByRancher(&notifier.Spec) {
+				// the state syncer sends the alerts of the notifier
+				continue
+			}
 			if notifier.Spec.DingtalkConfig != nil {
 				provider := &Provider{
 					Type:       DingTalk,
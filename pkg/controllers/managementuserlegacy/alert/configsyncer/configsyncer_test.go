@@ -8,6 +8,7 @@
 
 	"github.com/prometheus/common/model"
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	alertconfig "github.com/rancher/rancher/pkg/controllers/managementuserlegacy/alert/config"
 	"github.com/rancher/rancher/pkg/controllers/managementuserlegacy/alert/manager"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -184,6 +185,51 @@ func TestAddProjectAlert2Config(t *testing.T) {
 	}
 )
 
+func TestAddRecipientsSentByRancher(t *testing.T) {
+	sentByRancher := []*v3.Notifier{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "googlechat"},
+			Spec: v32.NotifierSpec{
+				GoogleChatConfig: &v32.GoogleChatConfig{URL: "https://chat.googleapis.com/v1/spaces/x/messages"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "matrix"},
+			Spec: v32.NotifierSpec{
+				MatrixConfig: &v32.MatrixConfig{HomeserverURL: "https://matrix.org", DefaultRecipient: "!room:matrix.org"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "templated"},
+			Spec: v32.NotifierSpec{
+				SlackConfig: &v32.SlackConfig{URL: "www.slack.com"},
+				Template:    &v32.NotificationTemplate{Title: "{{.Title}}"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "xoauth2"},
+			Spec: v32.NotifierSpec{
+				SMTPConfig: &v32.SMTPConfig{
+					Host:   "smtp.gmail.com",
+					Port:   587,
+					OAuth2: &v32.SMTPOAuth2Config{TokenURL: "https://oauth2.googleapis.com/token", SecretName: "smtp"},
+				},
+			},
+		},
+	}
+
+	configSyncer := ConfigSyncer{
+		clusterName: clusterName,
+	}
+	for _, notifier := range sentByRancher {
+		receiver := &alertconfig.Receiver{}
+		recipients := []v32.Recipient{{NotifierName: clusterName + ":" + notifier.Name}}
+		if configSyncer.addRecipients(sentByRancher, receiver, recipients) {
+			t.Errorf("notifier %s is sent by rancher, expect no alert manager receiver, actual %+v", notifier.Name, receiver)
+		}
+	}
+}
+
 var (
 	defaultChannel = "testChannel"
 	slack          = "slack"
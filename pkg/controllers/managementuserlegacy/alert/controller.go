@@ -107,6 +107,7 @@ func Register(ctx context.Context, cluster *config.UserContext) {
 	watcher.StartWorkloadWatcher(ctx, cluster, alertmanager)
 	watcher.StartNodeWatcher(ctx, cluster, alertmanager)
 	watcher.StartClusterScanWatcher(ctx, cluster, alertmanager)
+	watcher.StartEtcdRestoreDrillWatcher(ctx, cluster, alertmanager)
 
 }
 
@@ -0,0 +1,349 @@
+package statesyncer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/rancher/norman/controller"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/controllers/managementuserlegacy/alert/common"
+	"github.com/rancher/rancher/pkg/controllers/managementuserlegacy/alert/manager"
+	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/notifiers"
+	"github.com/rancher/rancher/pkg/ref"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// relayStateName is the ConfigMap in the cluster namespace that holds the relayed alerts
+	relayStateName     = "alert-relay-state"
+	relayStateKey      = "sent"
+	relayRetryDelay    = 10 * time.Second
+	relayMaxRetryDelay = 10 * time.Minute
+)
+
+// hiddenLabels are the labels of an alert that are not listed in the default message.
+var hiddenLabels = map[string]bool{
+	"group_id": true,
+	"rule_id":  true,
+}
+
+// relay sends the alerts of the notifiers that alert manager can not send, see
+// notifiers.AlertsSentByRancher. The config syncer leaves these notifiers out of the
+// receivers of alert manager, the relay sends a message for every recipient of an alert
+// group when an alert starts firing and, if the notifier sends resolved alerts, when it is
+// resolved. Unlike alert manager it does not repeat the message of an alert that keeps
+// firing. Messages go through the delivery queue of the notifiers, which retries, dedups
+// and rate limits them. The relayed alerts are stored in a ConfigMap so they are not sent
+// again after a restart or a change of the leader.
+type relay struct {
+	clusterName        string
+	clusterAlertGroups v3.ClusterAlertGroupLister
+	projectAlertGroups v3.ProjectAlertGroupLister
+	notifiers          v3.NotifierLister
+	configMaps         v1.ConfigMapInterface
+	// sent are the firing alerts that were sent, by recipient. It is nil until the
+	// stored state is loaded.
+	sent map[relayKey]*relayedAlert
+	// failed are the alerts whose message could not be queued, they are retried with backoff
+	failed map[relayKey]*relayFailure
+
+	enqueue func(notifier *v3.Notifier, recipient string, msg *notifiers.Message) error
+	now     func() time.Time
+}
+
+type relayKey struct {
+	Fingerprint string `json:"fingerprint"`
+	Notifier    string `json:"notifier"`
+	Recipient   string `json:"recipient,omitempty"`
+}
+
+type relayedAlert struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// relayState is an entry of the stored relayed alerts.
+type relayState struct {
+	relayKey
+	relayedAlert
+}
+
+type relayFailure struct {
+	attempts int
+	retryAt  time.Time
+}
+
+type relayRecipient struct {
+	notifier  *v3.Notifier
+	recipient string
+}
+
+// sync sends the alerts that started firing or were resolved since the last sync.
+func (r *relay) sync(apiAlerts []*manager.APIAlert) error {
+	if r.sent == nil {
+		sent, err := r.load()
+		if err != nil {
+			return fmt.Errorf("failed to load relayed alerts: %v", err)
+		}
+		r.sent = sent
+	}
+
+	recipients, err := r.recipients()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	firing := map[relayKey]bool{}
+	for _, alert := range apiAlerts {
+		if alert.Alert == nil || alert.Status.State == "suppressed" {
+			continue
+		}
+		alertLabels, annotations := stringMap(alert.Labels), stringMap(alert.Annotations)
+		for _, recipient := range recipients[alertLabels["group_id"]] {
+			key := relayKey{
+				Fingerprint: alert.Fingerprint,
+				Notifier:    recipient.notifier.Name,
+				Recipient:   recipient.recipient,
+			}
+			firing[key] = true
+			if _, ok := r.sent[key]; ok || !r.ready(key) {
+				continue
+			}
+			if err := r.deliver(key, recipient, alertMessage(alertLabels, annotations, false)); err != nil {
+				logrus.Errorf("Failed to send alert %s to notifier %s: %v", alert.Fingerprint, recipient.notifier.Name, err)
+				continue
+			}
+			r.sent[key] = &relayedAlert{
+				Labels:      alertLabels,
+				Annotations: annotations,
+			}
+			changed = true
+		}
+	}
+
+	for key, alert := range r.sent {
+		if firing[key] || !r.ready(key) {
+			continue
+		}
+		notifier, err := r.notifiers.Get(r.clusterName, key.Notifier)
+		if err == nil && notifier.Spec.SendResolved && notifiers.AlertsSentByRancher(&notifier.Spec) {
+			recipient := relayRecipient{notifier: notifier, recipient: key.Recipient}
+			if err := r.deliver(key, recipient, alertMessage(alert.Labels, alert.Annotations, true)); err != nil {
+				logrus.Errorf("Failed to send resolved alert %s to notifier %s: %v", key.Fingerprint, key.Notifier, err)
+				continue
+			}
+		}
+		delete(r.sent, key)
+		changed = true
+	}
+
+	// failures of alerts that are neither firing nor waiting to be sent as resolved are forgotten
+	for key := range r.failed {
+		if _, ok := r.sent[key]; !ok && !firing[key] {
+			delete(r.failed, key)
+		}
+	}
+
+	if changed {
+		return r.save()
+	}
+	return nil
+}
+
+// ready reports whether the message of the alert can be sent, it is false while a failed
+// attempt is backing off.
+func (r *relay) ready(key relayKey) bool {
+	failure, ok := r.failed[key]
+	return !ok || !r.now().Before(failure.retryAt)
+}
+
+func (r *relay) deliver(key relayKey, recipient relayRecipient, msg *notifiers.Message) error {
+	if err := r.enqueue(recipient.notifier, recipient.recipient, msg); err != nil {
+		failure := r.failed[key]
+		if failure == nil {
+			failure = &relayFailure{}
+			r.failed[key] = failure
+		}
+		failure.attempts++
+		failure.retryAt = r.now().Add(retryDelay(failure.attempts))
+		return err
+	}
+	delete(r.failed, key)
+	return nil
+}
+
+func retryDelay(attempts int) time.Duration {
+	delay := relayRetryDelay
+	for i := 1; i < attempts && delay < relayMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > relayMaxRetryDelay {
+		delay = relayMaxRetryDelay
+	}
+	return delay
+}
+
+// load returns the relayed alerts stored in the ConfigMap of the cluster.
+func (r *relay) load() (map[relayKey]*relayedAlert, error) {
+	sent := map[relayKey]*relayedAlert{}
+	cm, err := r.configMaps.Get(relayStateName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return sent, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var states []relayState
+	if err := json.Unmarshal([]byte(cm.Data[relayStateKey]), &states); err != nil {
+		logrus.Errorf("Discarding invalid relayed alerts %s/%s: %v", cm.Namespace, cm.Name, err)
+		return sent, nil
+	}
+	for i := range states {
+		sent[states[i].relayKey] = &states[i].relayedAlert
+	}
+	return sent, nil
+}
+
+// save stores the relayed alerts in the ConfigMap of the cluster.
+func (r *relay) save() error {
+	states := make([]relayState, 0, len(r.sent))
+	for key, alert := range r.sent {
+		states = append(states, relayState{relayKey: key, relayedAlert: *alert})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		a, b := states[i].relayKey, states[j].relayKey
+		if a.Fingerprint != b.Fingerprint {
+			return a.Fingerprint < b.Fingerprint
+		}
+		if a.Notifier != b.Notifier {
+			return a.Notifier < b.Notifier
+		}
+		return a.Recipient < b.Recipient
+	})
+	data, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+
+	cm, err := r.configMaps.Get(relayStateName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = r.configMaps.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      relayStateName,
+				Namespace: r.clusterName,
+			},
+			Data: map[string]string{
+				relayStateKey: string(data),
+			},
+		})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	cm = cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[relayStateKey] = string(data)
+	_, err = r.configMaps.Update(cm)
+	return err
+}
+
+// recipients returns the recipients of the alert groups whose notifier is sent by rancher,
+// by group id.
+func (r *relay) recipients() (map[string][]relayRecipient, error) {
+	result := map[string][]relayRecipient{}
+
+	clusterGroups, err := r.clusterAlertGroups.List(r.clusterName, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range clusterGroups {
+		groupID := common.GetGroupID(group.Namespace, group.Name)
+		result[groupID] = r.groupRecipients(group.Spec.Recipients)
+	}
+
+	projectGroups, err := r.projectAlertGroups.List("", labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range projectGroups {
+		if !controller.ObjectInCluster(r.clusterName, group) {
+			continue
+		}
+		groupID := common.GetGroupID(group.Namespace, group.Name)
+		result[groupID] = r.groupRecipients(group.Spec.Recipients)
+	}
+
+	return result, nil
+}
+
+func (r *relay) groupRecipients(recipients []v32.Recipient) []relayRecipient {
+	var result []relayRecipient
+	for _, recipient := range recipients {
+		clusterName, name := ref.Parse(recipient.NotifierName)
+		if clusterName != r.clusterName {
+			continue
+		}
+		notifier, err := r.notifiers.Get(r.clusterName, name)
+		if err != nil || !notifiers.AlertsSentByRancher(&notifier.Spec) {
+			continue
+		}
+		result = append(result, relayRecipient{
+			notifier:  notifier,
+			recipient: recipient.Recipient,
+		})
+	}
+	return result
+}
+
+// alertMessage is the message of an alert before the template of the notifier renders it,
+// the title and content are like the ones of the alert manager template.
+func alertMessage(alertLabels, annotations map[string]string, resolved bool) *notifiers.Message {
+	title := alertLabels["alert_name"]
+	if title == "" {
+		title = alertLabels["alertname"]
+	}
+	if resolved {
+		title = "[Resolved] " + title
+	}
+
+	var keys []string
+	for k := range alertLabels {
+		if !hiddenLabels[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var lines []string
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s: %s", k, alertLabels[k]))
+	}
+
+	return &notifiers.Message{
+		Title:       title,
+		Content:     strings.Join(lines, "\n"),
+		Labels:      alertLabels,
+		Annotations: annotations,
+	}
+}
+
+func stringMap(labelSet model.LabelSet) map[string]string {
+	result := make(map[string]string, len(labelSet))
+	for k, v := range labelSet {
+		result[string(k)] = string(v)
+	}
+	return result
+}
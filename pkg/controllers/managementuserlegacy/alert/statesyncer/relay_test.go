@@ -0,0 +1,218 @@
+package statesyncer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/controllers/managementuserlegacy/alert/manager"
+	corefakes "github.com/rancher/rancher/pkg/generated/norman/core/v1/fakes"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	"github.com/rancher/rancher/pkg/notifiers"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type clusterAlertGroupLister []*v3.ClusterAlertGroup
+
+func (l clusterAlertGroupLister) List(namespace string, selector labels.Selector) ([]*v3.ClusterAlertGroup, error) {
+	return l, nil
+}
+
+func (l clusterAlertGroupLister) Get(namespace, name string) (*v3.ClusterAlertGroup, error) {
+	return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+}
+
+type projectAlertGroupLister []*v3.ProjectAlertGroup
+
+func (l projectAlertGroupLister) List(namespace string, selector labels.Selector) ([]*v3.ProjectAlertGroup, error) {
+	return l, nil
+}
+
+func (l projectAlertGroupLister) Get(namespace, name string) (*v3.ProjectAlertGroup, error) {
+	return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+}
+
+type sentMessage struct {
+	notifier  string
+	recipient string
+	msg       notifiers.Message
+}
+
+func newTestRelay(configMaps map[string]*corev1.ConfigMap, notifierList ...*v3.Notifier) (*relay, *[]sentMessage) {
+	var sent []sentMessage
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := &relay{
+		clusterName: "c-1",
+		clusterAlertGroups: clusterAlertGroupLister{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "c-1", Name: "group"},
+			Spec: v32.ClusterGroupSpec{
+				ClusterName: "c-1",
+				Recipients: []v32.Recipient{
+					{NotifierName: "c-1:templated", Recipient: "ops"},
+					{NotifierName: "c-1:plain"},
+				},
+			},
+		}},
+		projectAlertGroups: projectAlertGroupLister{},
+		notifiers: &fakes.NotifierListerMock{
+			GetFunc: func(namespace, name string) (*v3.Notifier, error) {
+				for _, n := range notifierList {
+					if n.Name == name {
+						return n, nil
+					}
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+			},
+		},
+		configMaps: &corefakes.ConfigMapInterfaceMock{
+			GetFunc: func(name string, opts metav1.GetOptions) (*corev1.ConfigMap, error) {
+				if cm, ok := configMaps[name]; ok {
+					return cm, nil
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+			},
+			CreateFunc: func(in *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+				configMaps[in.Name] = in
+				return in, nil
+			},
+			UpdateFunc: func(in *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+				configMaps[in.Name] = in
+				return in, nil
+			},
+		},
+		failed: map[relayKey]*relayFailure{},
+		now:    func() time.Time { return now },
+	}
+	r.enqueue = func(notifier *v3.Notifier, recipient string, msg *notifiers.Message) error {
+		sent = append(sent, sentMessage{notifier: notifier.Name, recipient: recipient, msg: *msg})
+		return nil
+	}
+	return r, &sent
+}
+
+func testAlert(fingerprint string) *manager.APIAlert {
+	return &manager.APIAlert{
+		Alert: &model.Alert{
+			Labels: model.LabelSet{
+				"alert_name": "High CPU",
+				"group_id":   "c-1:group",
+				"severity":   "critical",
+			},
+			Annotations: model.LabelSet{
+				"current_value": "97",
+			},
+		},
+		Fingerprint: fingerprint,
+	}
+}
+
+func TestRelaySendsTemplatedNotifiers(t *testing.T) {
+	assert := assert.New(t)
+	r, sent := newTestRelay(map[string]*corev1.ConfigMap{},
+		&v3.Notifier{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "c-1", Name: "templated"},
+			Spec: v32.NotifierSpec{
+				SendResolved: true,
+				Template:     &v32.NotificationTemplate{Title: "{{.Title}} is at {{.Annotations.current_value}}"},
+			},
+		},
+		&v3.Notifier{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "c-1", Name: "plain"},
+		},
+	)
+
+	assert.NoError(r.sync([]*manager.APIAlert{testAlert("a")}))
+	assert.Len(*sent, 1, "only the notifier with a template is sent by rancher")
+	assert.Equal("templated", (*sent)[0].notifier)
+	assert.Equal("ops", (*sent)[0].recipient)
+	assert.Equal("High CPU", (*sent)[0].msg.Title)
+	assert.Equal("97", (*sent)[0].msg.Annotations["current_value"])
+	assert.Contains((*sent)[0].msg.Content, "severity: critical")
+	assert.NotContains((*sent)[0].msg.Content, "group_id")
+
+	assert.NoError(r.sync([]*manager.APIAlert{testAlert("a")}))
+	assert.Len(*sent, 1, "a firing alert is sent once")
+
+	assert.NoError(r.sync(nil))
+	assert.Len(*sent, 2)
+	assert.Equal("[Resolved] High CPU", (*sent)[1].msg.Title)
+
+	assert.NoError(r.sync(nil))
+	assert.Len(*sent, 2, "a resolved alert is sent once")
+}
+
+func TestRelaySkipsMutedAlerts(t *testing.T) {
+	r, sent := newTestRelay(map[string]*corev1.ConfigMap{}, &v3.Notifier{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c-1", Name: "templated"},
+		Spec: v32.NotifierSpec{
+			Template: &v32.NotificationTemplate{Body: "{{.Content}}"},
+		},
+	})
+
+	alert := testAlert("a")
+	alert.Status.State = "suppressed"
+	assert.NoError(t, r.sync([]*manager.APIAlert{alert}))
+	assert.Empty(t, *sent)
+}
+
+func TestRelayKeepsSentAlertsAcrossRestarts(t *testing.T) {
+	assert := assert.New(t)
+	templated := &v3.Notifier{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c-1", Name: "templated"},
+		Spec: v32.NotifierSpec{
+			SendResolved: true,
+			Template:     &v32.NotificationTemplate{Body: "{{.Content}}"},
+		},
+	}
+	configMaps := map[string]*corev1.ConfigMap{}
+
+	r, sent := newTestRelay(configMaps, templated)
+	assert.NoError(r.sync([]*manager.APIAlert{testAlert("a")}))
+	assert.Len(*sent, 1)
+	assert.Contains(configMaps, relayStateName)
+
+	restarted, resent := newTestRelay(configMaps, templated)
+	assert.NoError(restarted.sync([]*manager.APIAlert{testAlert("a")}))
+	assert.Empty(*resent, "a firing alert is not sent again after a restart")
+
+	assert.NoError(restarted.sync(nil))
+	assert.Len(*resent, 1)
+	assert.Equal("[Resolved] High CPU", (*resent)[0].msg.Title)
+	assert.Equal("High CPU", (*resent)[0].msg.Labels["alert_name"])
+}
+
+func TestRelayBacksOffFailedAlerts(t *testing.T) {
+	assert := assert.New(t)
+	r, _ := newTestRelay(map[string]*corev1.ConfigMap{}, &v3.Notifier{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c-1", Name: "templated"},
+		Spec: v32.NotifierSpec{
+			Template: &v32.NotificationTemplate{Body: "{{.Content}}"},
+		},
+	})
+	attempts := 0
+	r.enqueue = func(notifier *v3.Notifier, recipient string, msg *notifiers.Message) error {
+		attempts++
+		return errors.New("configmaps is forbidden")
+	}
+
+	assert.NoError(r.sync([]*manager.APIAlert{testAlert("a")}))
+	assert.NoError(r.sync([]*manager.APIAlert{testAlert("a")}))
+	assert.Equal(1, attempts, "a failed alert is not retried before its backoff")
+
+	now := r.now().Add(relayRetryDelay)
+	r.now = func() time.Time { return now }
+	assert.NoError(r.sync([]*manager.APIAlert{testAlert("a")}))
+	assert.Equal(2, attempts)
+	assert.Equal(now.Add(2*relayRetryDelay), r.failed[relayKey{Fingerprint: "a", Notifier: "templated", Recipient: "ops"}].retryAt)
+
+	assert.NoError(r.sync(nil))
+	assert.Empty(r.failed, "failures of alerts that stopped firing are forgotten")
+}
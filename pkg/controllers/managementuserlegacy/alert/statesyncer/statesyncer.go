@@ -8,6 +8,7 @@
 	"github.com/rancher/rancher/pkg/controllers/managementuserlegacy/alert/common"
 	"github.com/rancher/rancher/pkg/controllers/managementuserlegacy/alert/manager"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/notifiers"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/rancher/wrangler/pkg/ticker"
 	"github.com/sirupsen/logrus"
@@ -16,11 +17,23 @@
 )
 
 func StartStateSyncer(ctx context.Context, cluster *config.UserContext, manager *manager.AlertManager) {
+	queue := notifiers.GetQueue(ctx, cluster.ClusterName, cluster.Management.Management.Notifiers(cluster.ClusterName),
+		cluster.Management.Core.ConfigMaps(cluster.ClusterName), cluster.Management.Core.Secrets("").Controller().Lister(), cluster.Management.Dialer)
 	s := &StateSyncer{
 		clusterAlertRules: cluster.Management.Management.ClusterAlertRules(cluster.ClusterName),
 		projectAlertRules: cluster.Management.Management.ProjectAlertRules(""),
 		alertManager:      manager,
 		clusterName:       cluster.ClusterName,
+		relay: &relay{
+			clusterName:        cluster.ClusterName,
+			clusterAlertGroups: cluster.Management.Management.ClusterAlertGroups(cluster.ClusterName).Controller().Lister(),
+			projectAlertGroups: cluster.Management.Management.ProjectAlertGroups("").Controller().Lister(),
+			notifiers:          cluster.Management.Management.Notifiers(cluster.ClusterName).Controller().Lister(),
+			configMaps:         cluster.Management.Core.ConfigMaps(cluster.ClusterName),
+			failed:             map[relayKey]*relayFailure{},
+			enqueue:            queue.Enqueue,
+			now:                time.Now,
+		},
 	}
 	go s.watch(ctx, 10*time.Second)
 }
@@ -36,6 +49,7 @@ type StateSyncer struct {
 	projectAlertRules v3.ProjectAlertRuleInterface
 	alertManager      *manager.AlertManager
 	clusterName       string
+	relay             *relay
 }
 
 //synchronize the state between alert CRD and alertmanager.
@@ -47,6 +61,10 @@ func (s *StateSyncer) syncState() error {
 
 	apiAlerts, err := s.alertManager.GetAlertList()
 	if err == nil {
+		if err := s.relay.sync(apiAlerts); err != nil {
+			logrus.Errorf("Error occurred while relaying alerts: %v", err)
+		}
+
 		clusterAlerts, err := s.clusterAlertRules.Controller().Lister().List("", labels.NewSelector())
 		if err != nil {
 			return err
@@ -0,0 +1,153 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rancher/rancher/pkg/controllers/managementuserlegacy/alert/common"
+	"github.com/rancher/rancher/pkg/controllers/managementuserlegacy/alert/manager"
+	rkecontrollers "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/provisioningv2/rke2/planner"
+	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type EtcdRestoreDrillWatcher struct {
+	clusterName            string
+	clusterLister          v3.ClusterLister
+	clusterAlertRuleLister v3.ClusterAlertRuleLister
+	rkeControlPlanes       rkecontrollers.RKEControlPlaneClient
+	alertManager           *manager.AlertManager
+}
+
+func StartEtcdRestoreDrillWatcher(ctx context.Context, cluster *config.UserContext, alertManager *manager.AlertManager) {
+	etcdBackups := cluster.Management.Management.EtcdBackups(cluster.ClusterName)
+
+	etcdRestoreDrillWatcher := &EtcdRestoreDrillWatcher{
+		clusterName:            cluster.ClusterName,
+		clusterLister:          cluster.Management.Management.Clusters("").Controller().Lister(),
+		clusterAlertRuleLister: cluster.Management.Management.ClusterAlertRules(cluster.ClusterName).Controller().Lister(),
+		rkeControlPlanes:       cluster.Management.Wrangler.RKE.RKEControlPlane(),
+		alertManager:           alertManager,
+	}
+	etcdBackups.AddClusterScopedHandler(ctx, "etcd-restore-drill-watcher", cluster.ClusterName, etcdRestoreDrillWatcher.Sync)
+	// the drills of RKE2 clusters are recorded on the control plane of the cluster
+	cluster.Management.Wrangler.RKE.RKEControlPlane().OnChange(ctx, "etcd-restore-drill-watcher-"+cluster.ClusterName, etcdRestoreDrillWatcher.SyncControlPlane)
+}
+
+func (w *EtcdRestoreDrillWatcher) Sync(_ string, backup *v3.EtcdBackup) (runtime.Object, error) {
+	if backup == nil || backup.DeletionTimestamp != nil {
+		return backup, nil
+	}
+	// The etcd backup controller sets Alerted to Unknown when a drill of the backup has finished,
+	// True if there is/are a matching alert rule(s), else False
+	if !v32.BackupConditionRestoreDrillAlerted.IsUnknown(backup) ||
+		v32.BackupConditionRestoreDrilled.GetStatus(backup) == "" {
+		return backup, nil
+	}
+	alerted, message, err := w.alert(backup.Name, v32.BackupConditionRestoreDrilled.IsFalse(backup), getRestoreDrillMessage(backup))
+	if err != nil {
+		return backup, err
+	}
+	if alerted {
+		v32.BackupConditionRestoreDrillAlerted.True(backup)
+	} else {
+		v32.BackupConditionRestoreDrillAlerted.False(backup)
+	}
+	v32.BackupConditionRestoreDrillAlerted.Message(backup, message)
+	return backup, nil
+}
+
+// SyncControlPlane alerts on the restore drills of the control plane of an RKE2 cluster, the
+// planner sets Alerted to Unknown when a drill has finished.
+func (w *EtcdRestoreDrillWatcher) SyncControlPlane(_ string, controlPlane *rkev1.RKEControlPlane) (*rkev1.RKEControlPlane, error) {
+	if controlPlane == nil || controlPlane.DeletionTimestamp != nil || controlPlane.Spec.ManagementClusterName != w.clusterName {
+		return controlPlane, nil
+	}
+	if !planner.RestoreDrillAlerted.IsUnknown(controlPlane) || planner.RestoreDrilled.GetStatus(controlPlane) == "" {
+		return controlPlane, nil
+	}
+	snapshotName := ""
+	if controlPlane.Status.ETCDSnapshotRestoreDrill != nil {
+		snapshotName = controlPlane.Status.ETCDSnapshotRestoreDrill.Name
+	}
+	alerted, message, err := w.alert(snapshotName, planner.RestoreDrilled.IsFalse(controlPlane), planner.RestoreDrilled.GetMessage(controlPlane))
+	if err != nil {
+		return controlPlane, err
+	}
+	controlPlane = controlPlane.DeepCopy()
+	planner.RestoreDrillAlerted.SetStatusBool(controlPlane, alerted)
+	planner.RestoreDrillAlerted.Message(controlPlane, message)
+	return w.rkeControlPlanes.UpdateStatus(controlPlane)
+}
+
+// alert sends the result of a drill to the matching alert rules, it returns whether any rule
+// matched and otherwise the reason.
+func (w *EtcdRestoreDrillWatcher) alert(componentName string, failed bool, logs string) (bool, string, error) {
+	if w.alertManager.IsDeploy == false {
+		logrus.Debugf("EtcdRestoreDrillWatcher: Sync: alert manager not deployed")
+		return false, MsgAlertManagerNotDeployed, nil
+	}
+	clusterAlertRules, err := w.clusterAlertRuleLister.List("", labels.NewSelector())
+	if err != nil {
+		return false, "", fmt.Errorf("EtcdRestoreDrillWatcher: Sync: error listing cluster alert rules: %v", err)
+	}
+
+	var matchingAlertRules []*v3.ClusterAlertRule
+	for _, alertRule := range clusterAlertRules {
+		if alertRule.Status.AlertState == "inactive" || alertRule.Spec.EtcdRestoreDrillRule == nil {
+			continue
+		}
+		if alertRule.Spec.EtcdRestoreDrillRule.FailuresOnly && !failed {
+			continue
+		}
+		matchingAlertRules = append(matchingAlertRules, alertRule)
+	}
+	if len(matchingAlertRules) == 0 {
+		return false, MsgNoMatchingAlertRule, nil
+	}
+
+	alertSuccessful := true
+	for _, alertRule := range matchingAlertRules {
+		if e := w.sendAlert(componentName, logs, alertRule); e != nil {
+			alertSuccessful = false
+			logrus.Errorf("EtcdRestoreDrillWatcher: Sync: error sending alert: %v", e)
+			err = multierror.Append(err, e)
+		}
+	}
+	if !alertSuccessful {
+		return false, "", err
+	}
+	return true, "", nil
+}
+
+func (w *EtcdRestoreDrillWatcher) sendAlert(componentName, logs string, alertRule *v3.ClusterAlertRule) error {
+	ruleID := common.GetRuleID(alertRule.Spec.GroupName, alertRule.Name)
+	clusterDisplayName := common.GetClusterDisplayName(w.clusterName, w.clusterLister)
+
+	data := map[string]string{}
+	data["rule_id"] = ruleID
+	data["group_id"] = alertRule.Spec.GroupName
+	data["alert_type"] = "etcdRestoreDrill"
+	data["alert_name"] = alertRule.Spec.DisplayName
+	data["severity"] = alertRule.Spec.Severity
+	data["cluster_name"] = clusterDisplayName
+	data["component_name"] = componentName
+	data["logs"] = logs
+
+	return w.alertManager.SendAlert(data)
+}
+
+func getRestoreDrillMessage(backup *v3.EtcdBackup) string {
+	if v32.BackupConditionRestoreDrilled.IsFalse(backup) {
+		return fmt.Sprintf("Restore drill of etcd backup %s failed: %s", backup.Name, v32.BackupConditionRestoreDrilled.GetMessage(backup))
+	}
+	return fmt.Sprintf("Restore drill of etcd backup %s passed: %s", backup.Name, v32.BackupConditionRestoreDrilled.GetMessage(backup))
+}
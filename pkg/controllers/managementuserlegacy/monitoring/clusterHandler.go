@@ -48,6 +48,7 @@ type clusterHandler struct {
 	cattleClustersClient mgmtv3.ClusterInterface
 	cattleCatalogManager manager.CatalogManager
 	agentEndpointsLister corev1.EndpointsLister
+	clusterStore         kcluster.PersistentStore
 	app                  *appHandler
 }
 
@@ -183,21 +184,15 @@ func (ch *clusterHandler) ensureAppProjectName(clusterID, appTargetNamespace str
 func (ch *clusterHandler) deployEtcdCert(clusterName, appTargetNamespace string) ([]*etcdTLSConfig, error) {
 	var etcdTLSConfigs []*etcdTLSConfig
 
-	rkeCertSecretName := "c-" + clusterName
-	systemNamespace := "cattle-system"
-	sec, err := ch.app.cattleSecretClient.GetNamespaced(systemNamespace, rkeCertSecretName, metav1.GetOptions{})
+	// the cluster state is read through the store because it can be encrypted
+	data, err := ch.clusterStore.Get(clusterName)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get %s:%s in deploy etcd cert to prometheus", systemNamespace, rkeCertSecretName)
-	}
-
-	var data kcluster.Cluster
-	if err = json.Unmarshal(sec.Data["cluster"], &data); err != nil {
-		return nil, errors.Wrapf(err, "failed to decode secret %s:%s to get etcd cert", systemNamespace, rkeCertSecretName)
+		return nil, errors.Wrapf(err, "failed to get cluster state of %s in deploy etcd cert to prometheus", clusterName)
 	}
 
 	crts := make(map[string]map[string]string)
 	if err = json.Unmarshal([]byte(data.Metadata["Certs"]), &crts); err != nil {
-		return nil, errors.Wrapf(err, "failed to decode secret %s:%s cert data to get etcd cert", systemNamespace, rkeCertSecretName)
+		return nil, errors.Wrapf(err, "failed to decode cluster state of %s cert data to get etcd cert", clusterName)
 	}
 
 	secretData := make(map[string][]byte)
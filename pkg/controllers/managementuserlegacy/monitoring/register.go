@@ -3,6 +3,7 @@
 import (
 	"context"
 
+	"github.com/rancher/rancher/pkg/controllers/management/clusterprovisioner"
 	"github.com/rancher/rancher/pkg/monitoring"
 	"github.com/rancher/rancher/pkg/systemaccount"
 	"github.com/rancher/rancher/pkg/types/config"
@@ -70,6 +71,7 @@ func Register(ctx context.Context, agentContext *config.UserContext) {
 		cattleClustersClient: cattleClustersClient,
 		cattleCatalogManager: cattleContext.CatalogManager,
 		agentEndpointsLister: agentClusterMonitoringEndpointLister,
+		clusterStore:         clusterprovisioner.NewPersistentStore(cattleContext.Core.Namespaces(metav1.NamespaceAll), cattleContext.Core),
 		app:                  ah,
 	}
 	cattleClustersClient.AddHandler(ctx, "cluster-monitoring-handler", ch.sync)
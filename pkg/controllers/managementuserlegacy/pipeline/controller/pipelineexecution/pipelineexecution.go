@@ -27,9 +27,7 @@
 	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/rancher/pkg/systemaccount"
 	"github.com/rancher/rancher/pkg/types/config"
-	"github.com/rancher/rancher/pkg/types/config/dialer"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/sync/errgroup"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -99,7 +97,7 @@ type Lifecycle struct {
 	pipelineEngine             engine.PipelineEngine
 	sourceCodeCredentialLister v3.SourceCodeCredentialLister
 
-	DialerFactory dialer.Factory
+	notifierQueue *notifiers.Queue
 }
 
 func Register(ctx context.Context, cluster *config.UserContext) {
@@ -134,6 +132,8 @@ func Register(ctx context.Context, cluster *config.UserContext) {
 	tokenLister := cluster.Management.Management.Tokens("").Controller().Lister()
 
 	pipelineEngine := engine.New(cluster, true)
+	notifierQueue := notifiers.GetQueue(ctx, clusterName, cluster.Management.Management.Notifiers(clusterName),
+		cluster.Management.Core.ConfigMaps(clusterName), cluster.Management.Core.Secrets("").Controller().Lister(), cluster.Management.Dialer)
 	pipelineExecutionLifecycle := &Lifecycle{
 		ctx:                        ctx,
 		systemAccountManager:       systemaccount.NewManager(cluster.Management),
@@ -162,8 +162,7 @@ func Register(ctx context.Context, cluster *config.UserContext) {
 		sourceCodeCredentialLister: sourceCodeCredentialLister,
 		notifierLister:             notifierLister,
 		tokenLister:                tokenLister,
-
-		DialerFactory: cluster.Management.Dialer,
+		notifierQueue:              notifierQueue,
 	}
 	stateSyncer := &ExecutionStateSyncer{
 		clusterName:             clusterName,
@@ -486,30 +485,25 @@ func (l *Lifecycle) doNotify(obj *v3.PipelineExecution) (runtime.Object, error)
 	if err != nil {
 		return obj, err
 	}
-	clusterName, _ := ref.Parse(obj.Spec.ProjectName)
-	clusterDialer, err := l.DialerFactory.ClusterDialer(clusterName)
-	if err != nil {
-		return nil, errors.Wrap(err, "error getting dialer")
-	}
 	if obj.Spec.PipelineConfig.Notification.Message != "" {
 		message = obj.Spec.PipelineConfig.Notification.Message
 	}
-	var g errgroup.Group
 	for i := range toSendRecipients {
 		toSendRecipient := toSendRecipients[i]
 		notifierMessage := &notifiers.Message{
 			Content: message,
+			Labels:  notificationLabels(obj),
 		}
 		if toSendRecipient.Notifier.Spec.SMTPConfig != nil {
 			repoName := getRepoNameFromURL(obj.Spec.RepositoryURL)
 			notifierMessage.Title = fmt.Sprintf("Notification From Rancher: Pipeline #%d build for %s repo %s", obj.Spec.Run, repoName, obj.Status.ExecutionState)
 			notifierMessage.Content = strings.Replace(message, "\n", "<br>\n", -1)
 		}
-		g.Go(func() error {
-			return notifiers.SendMessage(l.ctx, toSendRecipient.Notifier, toSendRecipient.Recipient, notifierMessage, clusterDialer)
-		})
+		if err := l.notifierQueue.Enqueue(toSendRecipient.Notifier, toSendRecipient.Recipient, notifierMessage); err != nil {
+			return obj, err
+		}
 	}
-	return obj, g.Wait()
+	return obj, nil
 }
 
 func (l *Lifecycle) getToSendRecipients(obj *v3.PipelineExecution) ([]notifierRecipient, error) {
@@ -630,6 +624,20 @@ func defaultNotificationMessage(execution *v3.PipelineExecution) (string, error)
 	return buf.String(), nil
 }
 
+// notificationLabels exposes the execution to notifier templates as {{.Labels.<key>}}.
+func notificationLabels(execution *v3.PipelineExecution) map[string]string {
+	return map[string]string{
+		"pipeline":   execution.Spec.PipelineName,
+		"project":    execution.Spec.ProjectName,
+		"run":        strconv.Itoa(execution.Spec.Run),
+		"state":      execution.Status.ExecutionState,
+		"repository": getRepoNameFromURL(execution.Spec.RepositoryURL),
+		"branch":     execution.Spec.Branch,
+		"event":      execution.Spec.Event,
+		"author":     execution.Spec.Author,
+	}
+}
+
 func getRepoNameFromURL(repoURL string) string {
 	reg := regexp.MustCompile(".*/([^/]*?)/([^/]*?).git")
 	match := reg.FindStringSubmatch(repoURL)
@@ -43,6 +43,7 @@ type handler struct {
 	namespaces      corecontrollers.NamespaceCache
 	nodeDriverCache mgmtcontrollers.NodeDriverCache
 	dynamic         *dynamic.Controller
+	hostKeys        *hostKeyCapture
 }
 
 func Register(ctx context.Context, clients *wrangler.Context) {
@@ -62,7 +63,9 @@ func Register(ctx context.Context, clients *wrangler.Context) {
 		nodeDriverCache: clients.Mgmt.NodeDriver().Cache(),
 		namespaces:      clients.Core.Namespace().Cache(),
 		dynamic:         clients.Dynamic,
+		hostKeys:        newHostKeyCapture(clients.Core.Secret().Cache(), clients.Dynamic),
 	}
+	h.hostKeys.start(ctx)
 
 	removeHandler := generic.NewRemoveHandler("machine-provision-remove", clients.Dynamic.Update, h.OnRemove)
 
@@ -287,6 +290,14 @@ func (h *handler) run(obj runtime.Object, create bool) (runtime.Object, error) {
 	}
 
 	if create {
+		if dArgs.RKEMachineStatus.Ready && d.String("status", "sshHostKey") == "" {
+			dArgs.RKEMachineStatus.SSHHostKey = h.hostKeys.get(hostKeyRequest{
+				gvk:             obj.GetObjectKind().GroupVersionKind(),
+				namespace:       meta.GetNamespace(),
+				name:            meta.GetName(),
+				stateSecretName: dArgs.StateSecretName,
+			})
+		}
 		return h.patchStatus(obj, d, dArgs.RKEMachineStatus)
 	}
 
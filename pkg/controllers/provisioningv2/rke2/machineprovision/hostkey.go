@@ -0,0 +1,127 @@
+package machineprovision
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rancher/lasso/pkg/dynamic"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const hostKeyWorkers = 5
+
+// hostKeyRequest is a machine whose SSH host key is captured.
+type hostKeyRequest struct {
+	gvk             schema.GroupVersionKind
+	namespace       string
+	name            string
+	stateSecretName string
+}
+
+// hostKeyCapture captures the SSH host keys of provisioned machines in the background so that the
+// dial to a machine doesn't block the handler. A failed capture is retried with a backoff as long as
+// the machine exists, the machine is enqueued when its key was captured.
+type hostKeyCapture struct {
+	secrets corecontrollers.SecretCache
+	dynamic *dynamic.Controller
+	queue   workqueue.RateLimitingInterface
+
+	lock    sync.Mutex
+	pending map[hostKeyRequest]bool
+	keys    map[hostKeyRequest]string
+}
+
+func newHostKeyCapture(secrets corecontrollers.SecretCache, dynamic *dynamic.Controller) *hostKeyCapture {
+	return &hostKeyCapture{
+		secrets: secrets,
+		dynamic: dynamic,
+		queue: workqueue.NewNamedRateLimitingQueue(
+			workqueue.NewItemExponentialFailureRateLimiter(5*time.Second, 10*time.Minute), "machine-ssh-host-key"),
+		pending: map[hostKeyRequest]bool{},
+		keys:    map[hostKeyRequest]string{},
+	}
+}
+
+func (c *hostKeyCapture) start(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		c.queue.ShutDown()
+	}()
+	for i := 0; i < hostKeyWorkers; i++ {
+		go func() {
+			for c.next() {
+			}
+		}()
+	}
+}
+
+// get returns the captured host key of the machine, the capture is started if there is no key yet.
+func (c *hostKeyCapture) get(req hostKeyRequest) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if key, ok := c.keys[req]; ok {
+		delete(c.keys, req)
+		return key
+	}
+	// a failed capture waits for its backoff, it is not restarted by the next sync
+	if !c.pending[req] {
+		c.pending[req] = true
+		c.queue.Add(req)
+	}
+	return ""
+}
+
+func (c *hostKeyCapture) next() bool {
+	item, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(item)
+
+	req := item.(hostKeyRequest)
+	key, err := c.capture(req)
+	if err != nil {
+		logrus.Errorf("failed to capture SSH host key of machine %s/%s, retrying: %v", req.namespace, req.name, err)
+		c.queue.AddRateLimited(req)
+		return true
+	}
+
+	c.queue.Forget(req)
+	c.lock.Lock()
+	delete(c.pending, req)
+	if key != "" {
+		c.keys[req] = key
+	}
+	c.lock.Unlock()
+
+	if key != "" {
+		if err := c.dynamic.Enqueue(req.gvk, req.namespace, req.name); err != nil {
+			logrus.Errorf("failed to enqueue machine %s/%s: %v", req.namespace, req.name, err)
+		}
+	}
+	return true
+}
+
+// capture dials the machine, the key is empty if the machine is gone.
+func (c *hostKeyCapture) capture(req hostKeyRequest) (string, error) {
+	obj, err := c.dynamic.Get(req.gvk, req.namespace, req.name)
+	if apierror.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if objMeta, err := meta.Accessor(obj); err != nil {
+		return "", err
+	} else if objMeta.GetDeletionTimestamp() != nil {
+		return "", nil
+	}
+
+	return captureSSHHostKey(c.secrets, req.namespace, req.stateSecretName)
+}
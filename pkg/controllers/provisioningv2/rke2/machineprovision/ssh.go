@@ -0,0 +1,133 @@
+package machineprovision
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SSHInfo is the SSH access to a machine that the provisioning job saves in the state secret.
+type SSHInfo struct {
+	IDRSA    []byte
+	IDRSAPub []byte
+	Driver   SSHDriverConfig
+}
+
+type SSHDriverConfig struct {
+	IPAddress   string
+	SSHUser     string
+	SSHPort     int
+	MachineName string
+}
+
+// SSHInfoFromSecret reads the SSH access to a machine from the extracted config of its state secret.
+func SSHInfoFromSecret(secret *corev1.Secret) (*SSHInfo, error) {
+	result := &SSHInfo{}
+
+	gz, err := gzip.NewReader(bytes.NewReader(secret.Data["extractedConfig"]))
+	if err != nil {
+		return nil, err
+	}
+
+	tar := tar.NewReader(gz)
+
+	for {
+		header, err := tar.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadAll(tar)
+		if err != nil {
+			return nil, err
+		}
+		switch filepath.Base(header.Name) {
+		case "id_rsa":
+			result.IDRSA = data
+		case "id_rsa.pub":
+			result.IDRSAPub = data
+		case "config.json":
+			err := json.Unmarshal(data, result)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (s *SSHInfo) Dial(hostKeyCallback ssh.HostKeyCallback, timeout time.Duration) (*ssh.Client, error) {
+	signer, err := ssh.ParsePrivateKey(s.IDRSA)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := fmt.Sprintf("%s:%d", s.Driver.IPAddress, s.Driver.SSHPort)
+	return ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User: s.Driver.SSHUser,
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(signer),
+		},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	})
+}
+
+// PinnedHostKey returns a host key callback that only accepts the host key pinned on a machine.
+func PinnedHostKey(machineName, hostKey string) (ssh.HostKeyCallback, error) {
+	if hostKey == "" {
+		return nil, fmt.Errorf("the SSH host key of machine %s has not been captured yet", machineName)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH host key of machine %s: %w", machineName, err)
+	}
+	fixed := ssh.FixedHostKey(key)
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := fixed(hostname, remote, key); err != nil {
+			return fmt.Errorf("the SSH host key of machine %s does not match the pinned host key, refusing to connect", machineName)
+		}
+		return nil
+	}, nil
+}
+
+// captureSSHHostKey connects to a provisioned machine to read the host key that the SSH access to
+// the machine is pinned to.
+func captureSSHHostKey(secrets corecontrollers.SecretCache, namespace, stateSecretName string) (string, error) {
+	secret, err := secrets.Get(namespace, stateSecretName)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := SSHInfoFromSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	var hostKey ssh.PublicKey
+	client, err := info.Dial(func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		hostKey = key
+		return nil
+	}, 10*time.Second)
+	if err != nil {
+		return "", err
+	}
+	client.Close()
+
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(hostKey))), nil
+}
@@ -32,6 +32,7 @@
 
 const (
 	Provisioned = condition.Cond("Provisioned")
+	Drifted     = condition.Cond("Drifted")
 )
 
 type handler struct {
@@ -212,37 +213,39 @@ func (h *handler) OnChange(key string, machine *capi.Machine) (*capi.Machine, er
 		}
 	}
 
-	if corev1.ConditionStatus(Provisioned.GetStatus(machine)) != status ||
-		Provisioned.GetReason(machine) != string(reason) ||
-		Provisioned.GetMessage(machine) != message {
-		machine := machine.DeepCopy()
+	newMachine := machine.DeepCopy()
+	newCond := capi.Condition{
+		Type:               capi.ConditionType(Provisioned),
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             string(reason),
+		Message:            message,
+	}
+	if status == corev1.ConditionFalse {
+		newCond.Severity = capi.ConditionSeverityError
+	} else {
+		newCond.Severity = capi.ConditionSeverityInfo
+	}
+	changed := setCondition(newMachine, newCond)
+
+	if status, reason, message := planner.GetDriftStatusReasonMessage(plan); status != "" {
 		newCond := capi.Condition{
-			Type:               capi.ConditionType(Provisioned),
+			Type:               capi.ConditionType(Drifted),
 			Status:             status,
 			LastTransitionTime: metav1.Now(),
-			Reason:             string(reason),
+			Reason:             reason,
 			Message:            message,
 		}
-		if status == corev1.ConditionFalse {
-			newCond.Severity = capi.ConditionSeverityError
+		if status == corev1.ConditionTrue {
+			newCond.Severity = capi.ConditionSeverityWarning
 		} else {
 			newCond.Severity = capi.ConditionSeverityInfo
 		}
+		changed = setCondition(newMachine, newCond) || changed
+	}
 
-		set := false
-		for i, cond := range machine.Status.Conditions {
-			if string(cond.Type) == string(Provisioned) {
-				set = true
-				machine.Status.Conditions[i] = newCond
-				break
-			}
-		}
-
-		if !set {
-			machine.Status.Conditions = append(machine.Status.Conditions, newCond)
-		}
-
-		_, err := h.machines.UpdateStatus(machine)
+	if changed {
+		_, err := h.machines.UpdateStatus(newMachine)
 		if err != nil {
 			return machine, err
 		}
@@ -251,6 +254,25 @@ func (h *handler) OnChange(key string, machine *capi.Machine) (*capi.Machine, er
 	return machine, nil
 }
 
+// setCondition sets a condition of the machine and returns if it changed.
+func setCondition(machine *capi.Machine, newCond capi.Condition) bool {
+	cond := condition.Cond(newCond.Type)
+	if corev1.ConditionStatus(cond.GetStatus(machine)) == newCond.Status &&
+		cond.GetReason(machine) == newCond.Reason &&
+		cond.GetMessage(machine) == newCond.Message {
+		return false
+	}
+
+	for i, existing := range machine.Status.Conditions {
+		if existing.Type == newCond.Type {
+			machine.Status.Conditions[i] = newCond
+			return true
+		}
+	}
+	machine.Status.Conditions = append(machine.Status.Conditions, newCond)
+	return true
+}
+
 func (h *handler) getInfraMachineState(capiMachine *capi.Machine) (status corev1.ConditionStatus, reason, message, providerID string, err error) {
 	gvk := schema.FromAPIVersionAndKind(capiMachine.Spec.InfrastructureRef.APIVersion, capiMachine.Spec.InfrastructureRef.Kind)
 	machine, err := h.dynamic.Get(gvk, capiMachine.Namespace, capiMachine.Spec.InfrastructureRef.Name)
@@ -4,6 +4,7 @@
 	"context"
 	"errors"
 
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
 	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/controllers/provisioningv2/rke2/bootstrap"
 	v1 "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
@@ -19,6 +20,7 @@
 
 const (
 	Provisioned = condition.Cond("Provisioned")
+	Drifted     = condition.Cond("Drifted")
 )
 
 type handler struct {
@@ -45,14 +47,33 @@ func Register(ctx context.Context, clients *wrangler.Context, planner *planner.P
 				Namespace: machine.Namespace,
 				Name:      machine.Spec.ClusterName,
 			}}, nil
+		} else if cluster, ok := obj.(*provv1.Cluster); ok {
+			// the snapshots listed in the status of the cluster are pruned by the retention tiers
+			return []relatedresource.Key{{
+				Namespace: cluster.Namespace,
+				Name:      cluster.Name,
+			}}, nil
 		}
 		return nil, nil
-	}, clients.RKE.RKEControlPlane(), clients.Core.Secret(), clients.CAPI.Machine())
+	}, clients.RKE.RKEControlPlane(), clients.Core.Secret(), clients.CAPI.Machine(), clients.Provisioning.Cluster())
 }
 
 func (h *handler) OnChange(cluster *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) (rkev1.RKEControlPlaneStatus, error) {
 	status.ObservedGeneration = cluster.Generation
 
+	if drift, reported, err := h.planner.DriftMessage(cluster); err != nil {
+		logrus.Debugf("rkecluster %s/%s: failed to check for drifted files: %v", cluster.Namespace, cluster.Name, err)
+	} else if !reported {
+		Drifted.Unknown(&status)
+		Drifted.Message(&status, "no machine reports the hashes of its files, enable reconcileDrift to report them")
+	} else if drift != "" {
+		Drifted.True(&status)
+		Drifted.Message(&status, "files changed out-of-band on machine(s) "+drift)
+	} else {
+		Drifted.False(&status)
+		Drifted.Message(&status, "")
+	}
+
 	err := h.planner.Process(cluster)
 	var errWaiting planner.ErrWaiting
 	if errors.As(err, &errWaiting) {
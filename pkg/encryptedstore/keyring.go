@@ -0,0 +1,344 @@
+package encryptedstore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	keyRingName              = "cattle-encryption-keys"
+	currentVersionAnnotation = "encryptedstore.cattle.io/current-version"
+	keyVersionAnnotation     = "encryptedstore.cattle.io/key-version"
+	encryptedLabel           = "encryptedstore.cattle.io/encrypted"
+
+	dataKeySize    = 32
+	kmsCallTimeout = 10 * time.Second
+)
+
+var (
+	keyRingsLock sync.Mutex
+	keyRings     = map[string]*keyRing{}
+)
+
+// keyRing holds the versioned data keys of the stores in a namespace. The data keys are
+// stored in a secret, encrypted with the key encryption key of the KMS plugin.
+type keyRing struct {
+	sync.Mutex
+	namespace string
+	// endpoint of the KMS plugin, the key ring of a namespace is replaced when it changes
+	endpoint     string
+	kms          KeyEncryptionService
+	secrets      v1.SecretInterface
+	secretLister v1.SecretLister
+	// keys caches the decrypted data keys, a version never changes once it is created
+	keys map[int][]byte
+}
+
+// getKeyRing returns the key ring of the namespace, or nil if no KMS plugin is configured
+// and values are stored in plain secrets.
+func getKeyRing(namespace string, secretsGetter v1.SecretsGetter) (*keyRing, error) {
+	endpoint := settings.EncryptionKMSEndpoint.Get()
+	if endpoint == "" {
+		return nil, nil
+	}
+
+	keyRingsLock.Lock()
+	defer keyRingsLock.Unlock()
+	if kr, ok := keyRings[namespace]; ok && kr.endpoint == endpoint {
+		return kr, nil
+	}
+
+	kms, err := NewKMSService(endpoint, kmsCallTimeout)
+	if err != nil {
+		return nil, err
+	}
+	kr := newKeyRing(namespace, kms, secretsGetter.Secrets(namespace), secretsGetter.Secrets(namespace).Controller().Lister())
+	kr.endpoint = endpoint
+	keyRings[namespace] = kr
+	return kr, nil
+}
+
+func newKeyRing(namespace string, kms KeyEncryptionService, secrets v1.SecretInterface, secretLister v1.SecretLister) *keyRing {
+	return &keyRing{
+		namespace:    namespace,
+		kms:          kms,
+		secrets:      secrets,
+		secretLister: secretLister,
+		keys:         map[int][]byte{},
+	}
+}
+
+func (k *keyRing) getSecret() (*corev1.Secret, error) {
+	secret, err := k.secretLister.Get(k.namespace, keyRingName)
+	if errors.IsNotFound(err) {
+		// the cache can be behind right after the key ring is created or rotated
+		secret, err = k.secrets.GetNamespaced(k.namespace, keyRingName, metav1.GetOptions{})
+	}
+	return secret, err
+}
+
+// current returns the version and data key new values are encrypted with, creating the
+// first data key if the key ring doesn't exist yet.
+func (k *keyRing) current(ctx context.Context) (int, []byte, error) {
+	secret, err := k.getSecret()
+	if errors.IsNotFound(err) {
+		secret, err = k.create(ctx)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	version, err := strconv.Atoi(secret.Annotations[currentVersionAnnotation])
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid current version of key ring %s/%s: %v", k.namespace, keyRingName, err)
+	}
+	key, err := k.keyFromSecret(ctx, secret, version)
+	return version, key, err
+}
+
+// key returns the data key of a version.
+func (k *keyRing) key(ctx context.Context, version int) ([]byte, error) {
+	k.Lock()
+	key, ok := k.keys[version]
+	k.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	secret, err := k.getSecret()
+	if err != nil {
+		return nil, err
+	}
+	return k.keyFromSecret(ctx, secret, version)
+}
+
+func (k *keyRing) keyFromSecret(ctx context.Context, secret *corev1.Secret, version int) ([]byte, error) {
+	k.Lock()
+	defer k.Unlock()
+	if key, ok := k.keys[version]; ok {
+		return key, nil
+	}
+
+	data, ok := secret.Data[versionKey(version)]
+	if !ok {
+		return nil, fmt.Errorf("data key version %d not found in key ring %s/%s", version, k.namespace, keyRingName)
+	}
+	encrypted := &EncryptedKey{}
+	if err := json.Unmarshal(data, encrypted); err != nil {
+		return nil, fmt.Errorf("invalid data key version %d: %v", version, err)
+	}
+	key, err := k.kms.Decrypt(ctx, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	k.keys[version] = key
+	return key, nil
+}
+
+func (k *keyRing) create(ctx context.Context) (*corev1.Secret, error) {
+	data, err := k.newDataKey(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      keyRingName,
+			Namespace: k.namespace,
+			Annotations: map[string]string{
+				currentVersionAnnotation: "1",
+			},
+		},
+		Data: map[string][]byte{
+			versionKey(1): data,
+		},
+	}
+	created, err := k.secrets.Create(secret)
+	if errors.IsAlreadyExists(err) {
+		// another server created the key ring first
+		return k.secrets.GetNamespaced(k.namespace, keyRingName, metav1.GetOptions{})
+	}
+	if err == nil {
+		logrus.Infof("[GenericEncryptedStore]: created key ring %s/%s", k.namespace, keyRingName)
+	}
+	return created, err
+}
+
+// rotate adds a new data key to the key ring and makes it the current version. Values
+// encrypted with previous versions can still be decrypted until they are re-encrypted.
+func (k *keyRing) rotate(ctx context.Context) (int, error) {
+	if _, _, err := k.current(ctx); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := k.secrets.GetNamespaced(k.namespace, keyRingName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current, err := strconv.Atoi(secret.Annotations[currentVersionAnnotation])
+		if err != nil {
+			return fmt.Errorf("invalid current version of key ring %s/%s: %v", k.namespace, keyRingName, err)
+		}
+		version = current + 1
+		data, err := k.newDataKey(ctx, version)
+		if err != nil {
+			return err
+		}
+		secret = secret.DeepCopy()
+		secret.Annotations[currentVersionAnnotation] = strconv.Itoa(version)
+		secret.Data[versionKey(version)] = data
+		_, err = k.secrets.Update(secret)
+		return err
+	})
+	return version, err
+}
+
+func (k *keyRing) newDataKey(ctx context.Context, version int) ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	encrypted, err := k.kms.Encrypt(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	k.Lock()
+	k.keys[version] = key
+	k.Unlock()
+	return data, nil
+}
+
+// encrypt encrypts the values of a secret with the current data key and records the
+// version on the secret.
+func (k *keyRing) encrypt(ctx context.Context, secret *corev1.Secret, data map[string]string) error {
+	version, key, err := k.current(ctx)
+	if err != nil {
+		return err
+	}
+
+	secret.Data = map[string][]byte{}
+	for name, value := range data {
+		ciphertext, err := seal(key, []byte(value), additionalData(secret.Name, name))
+		if err != nil {
+			return err
+		}
+		secret.Data[name] = ciphertext
+	}
+	secret.StringData = nil
+
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	secret.Labels[encryptedLabel] = "true"
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[keyVersionAnnotation] = strconv.Itoa(version)
+	return nil
+}
+
+// decrypt returns the values of a secret, secrets written before encryption was enabled
+// are returned as they are.
+func (k *keyRing) decrypt(ctx context.Context, secret *corev1.Secret) (map[string]string, error) {
+	result := map[string]string{}
+	if !isEncrypted(secret) {
+		for name, value := range secret.Data {
+			result[name] = string(value)
+		}
+		return result, nil
+	}
+	if k == nil {
+		return nil, fmt.Errorf("secret %s/%s is encrypted but no KMS plugin is configured", secret.Namespace, secret.Name)
+	}
+
+	version, err := strconv.Atoi(secret.Annotations[keyVersionAnnotation])
+	if err != nil {
+		return nil, fmt.Errorf("invalid key version of secret %s/%s: %v", secret.Namespace, secret.Name, err)
+	}
+	key, err := k.key(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range secret.Data {
+		plaintext, err := open(key, value, additionalData(secret.Name, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s of secret %s/%s: %v", name, secret.Namespace, secret.Name, err)
+		}
+		result[name] = string(plaintext)
+	}
+	return result, nil
+}
+
+func isEncrypted(secret *corev1.Secret) bool {
+	_, ok := secret.Annotations[keyVersionAnnotation]
+	return ok
+}
+
+func keyVersion(secret *corev1.Secret) int {
+	version, _ := strconv.Atoi(secret.Annotations[keyVersionAnnotation])
+	return version
+}
+
+func versionKey(version int) string {
+	return "v" + strconv.Itoa(version)
+}
+
+// additionalData binds a ciphertext to the secret and key it is stored in, so values
+// can't be swapped between entries.
+func additionalData(secretName, key string) []byte {
+	return []byte(secretName + "/" + key)
+}
+
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,140 @@
+package encryptedstore
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// KeyEncryptionService encrypts the data keys of the store with a key encryption key
+// that never leaves the service.
+type KeyEncryptionService interface {
+	Encrypt(ctx context.Context, plaintext []byte) (*EncryptedKey, error)
+	Decrypt(ctx context.Context, key *EncryptedKey) ([]byte, error)
+}
+
+// EncryptedKey is a data key encrypted by a KeyEncryptionService.
+type EncryptedKey struct {
+	Ciphertext  []byte            `json:"ciphertext"`
+	KeyID       string            `json:"keyId"`
+	Annotations map[string][]byte `json:"annotations,omitempty"`
+}
+
+type kmsService struct {
+	conn        *grpc.ClientConn
+	callTimeout time.Duration
+}
+
+// NewKMSService returns a KeyEncryptionService that talks to a KMS v2 plugin listening on
+// a unix socket, e.g. unix:///var/run/kmsplugin/socket.sock.
+func NewKMSService(endpoint string, callTimeout time.Duration) (KeyEncryptionService, error) {
+	addr, err := parseKMSEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.Dial(addr,
+		grpc.WithInsecure(),
+		grpc.WithDefaultCallOptions(grpc.WaitForReady(true)),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to KMS plugin %s: %v", endpoint, err)
+	}
+
+	return &kmsService{
+		conn:        conn,
+		callTimeout: callTimeout,
+	}, nil
+}
+
+// ValidateKMSEndpoint checks a value of the encryption-kms-endpoint setting before it is
+// saved, an empty value disables encryption.
+func ValidateKMSEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+	addr, err := parseKMSEndpoint(endpoint)
+	if err == nil && (addr == "" || addr == "@") {
+		err = fmt.Errorf("KMS plugin endpoint %q has no socket path", endpoint)
+	}
+	return err
+}
+
+func parseKMSEndpoint(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid KMS plugin endpoint %q: %v", endpoint, err)
+	}
+	if u.Scheme != "unix" {
+		return "", fmt.Errorf("unsupported scheme %q for KMS plugin endpoint, only unix is supported", u.Scheme)
+	}
+	// abstract sockets are written as unix:///@name
+	if strings.HasPrefix(u.Path, "/@") {
+		return strings.TrimPrefix(u.Path, "/"), nil
+	}
+	return u.Path, nil
+}
+
+func (k *kmsService) invoke(ctx context.Context, method string, req, resp interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, k.callTimeout)
+	defer cancel()
+	return k.conn.Invoke(ctx, kmsServiceName+method, req, resp)
+}
+
+func (k *kmsService) status(ctx context.Context) (*statusResponse, error) {
+	resp := &statusResponse{}
+	if err := k.invoke(ctx, "Status", &statusRequest{}, resp); err != nil {
+		return nil, fmt.Errorf("failed to get KMS plugin status: %v", err)
+	}
+	if resp.Version != kmsAPIVersion {
+		return nil, fmt.Errorf("KMS plugin API version %s is not supported, only %s is", resp.Version, kmsAPIVersion)
+	}
+	if resp.Healthz != kmsHealthzHealthy {
+		return nil, fmt.Errorf("KMS plugin is not healthy: %s", resp.Healthz)
+	}
+	return resp, nil
+}
+
+func (k *kmsService) Encrypt(ctx context.Context, plaintext []byte) (*EncryptedKey, error) {
+	if _, err := k.status(ctx); err != nil {
+		return nil, err
+	}
+
+	resp := &encryptResponse{}
+	if err := k.invoke(ctx, "Encrypt", &encryptRequest{
+		Plaintext: plaintext,
+		UID:       string(uuid.NewUUID()),
+	}, resp); err != nil {
+		return nil, fmt.Errorf("failed to encrypt data key with KMS plugin: %v", err)
+	}
+	if len(resp.Ciphertext) == 0 || resp.KeyID == "" {
+		return nil, fmt.Errorf("KMS plugin returned an empty ciphertext or key ID")
+	}
+
+	return &EncryptedKey{
+		Ciphertext:  resp.Ciphertext,
+		KeyID:       resp.KeyID,
+		Annotations: resp.Annotations,
+	}, nil
+}
+
+func (k *kmsService) Decrypt(ctx context.Context, key *EncryptedKey) ([]byte, error) {
+	resp := &decryptResponse{}
+	if err := k.invoke(ctx, "Decrypt", &decryptRequest{
+		Ciphertext:  key.Ciphertext,
+		UID:         string(uuid.NewUUID()),
+		KeyID:       key.KeyID,
+		Annotations: key.Annotations,
+	}, resp); err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key with KMS plugin: %v", err)
+	}
+	return resp.Plaintext, nil
+}
@@ -0,0 +1,69 @@
+package encryptedstore
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+// The messages of the KMS v2 gRPC API (k8s.io/kms/apis/v2). They are written out by hand
+// because the API is not available in the vendored Kubernetes version, the protobuf
+// struct tags are all the runtime needs to encode them.
+
+const (
+	kmsAPIVersion     = "v2"
+	kmsServiceName    = "/v2.KeyManagementService/"
+	kmsHealthzHealthy = "ok"
+)
+
+type statusRequest struct{}
+
+func (m *statusRequest) Reset()         { *m = statusRequest{} }
+func (m *statusRequest) String() string { return proto.CompactTextString(m) }
+func (*statusRequest) ProtoMessage()    {}
+
+type statusResponse struct {
+	Version string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Healthz string `protobuf:"bytes,2,opt,name=healthz,proto3" json:"healthz,omitempty"`
+	KeyID   string `protobuf:"bytes,3,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+}
+
+func (m *statusResponse) Reset()         { *m = statusResponse{} }
+func (m *statusResponse) String() string { return proto.CompactTextString(m) }
+func (*statusResponse) ProtoMessage()    {}
+
+type encryptRequest struct {
+	Plaintext []byte `protobuf:"bytes,1,opt,name=plaintext,proto3" json:"plaintext,omitempty"`
+	UID       string `protobuf:"bytes,2,opt,name=uid,proto3" json:"uid,omitempty"`
+}
+
+func (m *encryptRequest) Reset()         { *m = encryptRequest{} }
+func (m *encryptRequest) String() string { return proto.CompactTextString(m) }
+func (*encryptRequest) ProtoMessage()    {}
+
+type encryptResponse struct {
+	Ciphertext  []byte            `protobuf:"bytes,1,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+	KeyID       string            `protobuf:"bytes,2,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	Annotations map[string][]byte `protobuf:"bytes,3,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *encryptResponse) Reset()         { *m = encryptResponse{} }
+func (m *encryptResponse) String() string { return proto.CompactTextString(m) }
+func (*encryptResponse) ProtoMessage()    {}
+
+type decryptRequest struct {
+	Ciphertext  []byte            `protobuf:"bytes,1,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+	UID         string            `protobuf:"bytes,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	KeyID       string            `protobuf:"bytes,3,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	Annotations map[string][]byte `protobuf:"bytes,4,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *decryptRequest) Reset()         { *m = decryptRequest{} }
+func (m *decryptRequest) String() string { return proto.CompactTextString(m) }
+func (*decryptRequest) ProtoMessage()    {}
+
+type decryptResponse struct {
+	Plaintext []byte `protobuf:"bytes,1,opt,name=plaintext,proto3" json:"plaintext,omitempty"`
+}
+
+func (m *decryptResponse) Reset()         { *m = decryptResponse{} }
+func (m *decryptResponse) String() string { return proto.CompactTextString(m) }
+func (*decryptResponse) ProtoMessage()    {}
@@ -0,0 +1,295 @@
+package encryptedstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+)
+
+// RotationStatusName is the name of the config map that reports the progress of a data key
+// rotation. A rotation is started by setting its phase to Requested.
+const RotationStatusName = "cattle-encryption-rotation"
+
+const (
+	RotationPhaseRequested = "Requested"
+	RotationPhaseRotating  = "Rotating"
+	RotationPhaseCompleted = "Completed"
+	RotationPhaseFailed    = "Failed"
+
+	rotationPhaseKey       = "phase"
+	rotationKeyVersionKey  = "keyVersion"
+	rotationTotalKey       = "total"
+	rotationRotatedKey     = "rotated"
+	rotationFailedKey      = "failed"
+	rotationMessageKey     = "message"
+	rotationStartedAtKey   = "startedAt"
+	rotationCompletedAtKey = "completedAt"
+
+	// rotationProgressInterval is the number of secrets re-encrypted between status updates.
+	rotationProgressInterval = 10
+)
+
+// Rotator rotates the data key of the stores in a namespace and re-encrypts their secrets
+// with the new key in the background.
+type Rotator struct {
+	sync.Mutex
+	ctx       context.Context
+	namespace string
+	// prefixes are the prefixes of the stores in the namespace, their secrets that were stored
+	// before encryption was enabled are encrypted by a rotation too
+	prefixes      []string
+	secrets       v1.SecretInterface
+	secretLister  v1.SecretLister
+	secretsGetter v1.SecretsGetter
+	configMaps    v1.ConfigMapInterface
+	running       bool
+	// keyRing is set by tests, the rotator otherwise gets the key ring of the namespace on use
+	keyRing *keyRing
+}
+
+// NewRotator returns a rotator for the stores with the prefixes in the namespace, or nil if no
+// KMS plugin is configured and there are no data keys to rotate.
+func NewRotator(ctx context.Context, namespace string, prefixes []string, secretsGetter v1.SecretsGetter, configMapsGetter v1.ConfigMapsGetter) (*Rotator, error) {
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	keyRing, err := getKeyRing(namespace, secretsGetter)
+	if err != nil || keyRing == nil {
+		return nil, err
+	}
+
+	return &Rotator{
+		ctx:           ctx,
+		namespace:     namespace,
+		prefixes:      prefixes,
+		secrets:       secretsGetter.Secrets(namespace),
+		secretLister:  secretsGetter.Secrets(namespace).Controller().Lister(),
+		secretsGetter: secretsGetter,
+		configMaps:    configMapsGetter.ConfigMaps(namespace),
+	}, nil
+}
+
+// getKeyRing returns the key ring of the namespace for the current KMS plugin.
+func (r *Rotator) getKeyRing() (*keyRing, error) {
+	if r.keyRing != nil {
+		return r.keyRing, nil
+	}
+	keyRing, err := getKeyRing(r.namespace, r.secretsGetter)
+	if err == nil && keyRing == nil {
+		err = fmt.Errorf("no KMS plugin is configured")
+	}
+	return keyRing, err
+}
+
+// EnsureStatus creates the status config map so it can be patched to request a rotation.
+func (r *Rotator) EnsureStatus() error {
+	_, err := r.configMaps.Create(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      RotationStatusName,
+			Namespace: r.namespace,
+		},
+		Data: map[string]string{
+			rotationPhaseKey: "",
+		},
+	})
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// Sync starts a requested rotation and resumes a rotation that was interrupted by a restart.
+func (r *Rotator) Sync(key string, cm *corev1.ConfigMap) (runtime.Object, error) {
+	if cm == nil || cm.DeletionTimestamp != nil || cm.Namespace != r.namespace || cm.Name != RotationStatusName {
+		return cm, nil
+	}
+
+	switch cm.Data[rotationPhaseKey] {
+	case RotationPhaseRequested:
+		keyRing, err := r.getKeyRing()
+		if err != nil {
+			return cm, err
+		}
+		version, err := keyRing.rotate(r.ctx)
+		if err != nil {
+			return cm, err
+		}
+		logrus.Infof("[GenericEncryptedStore]: rotated data key of %s to version %d", r.namespace, version)
+
+		cm = cm.DeepCopy()
+		cm.Data = map[string]string{
+			rotationPhaseKey:      RotationPhaseRotating,
+			rotationKeyVersionKey: strconv.Itoa(version),
+			rotationStartedAtKey:  time.Now().UTC().Format(time.RFC3339),
+		}
+		updated, err := r.configMaps.Update(cm)
+		if err != nil {
+			return cm, err
+		}
+		r.start()
+		return updated, nil
+	case RotationPhaseRotating:
+		r.start()
+	}
+	return cm, nil
+}
+
+func (r *Rotator) start() {
+	r.Lock()
+	defer r.Unlock()
+	if r.running {
+		return
+	}
+	r.running = true
+	go func() {
+		r.reencryptAll()
+		r.Lock()
+		r.running = false
+		r.Unlock()
+	}()
+}
+
+// reencryptAll re-encrypts the secrets that are encrypted with a previous data key and encrypts
+// the secrets of the stores that were stored before encryption was enabled.
+func (r *Rotator) reencryptAll() {
+	keyRing, err := r.getKeyRing()
+	if err != nil {
+		r.finish(0, 0, err)
+		return
+	}
+	version, _, err := keyRing.current(r.ctx)
+	if err != nil {
+		r.finish(0, 0, err)
+		return
+	}
+
+	secrets, err := r.secretLister.List(r.namespace, labels.Everything())
+	if err != nil {
+		r.finish(0, 0, err)
+		return
+	}
+	var stale []string
+	for _, secret := range secrets {
+		if secret.Labels[encryptedLabel] == "true" {
+			if keyVersion(secret) != version {
+				stale = append(stale, secret.Name)
+			}
+		} else if r.isPlainStoreSecret(secret) {
+			stale = append(stale, secret.Name)
+		}
+	}
+
+	var (
+		rotated, failed int
+		lastErr         error
+	)
+	r.updateStatus(map[string]string{
+		rotationTotalKey:   strconv.Itoa(len(stale)),
+		rotationRotatedKey: "0",
+		rotationFailedKey:  "0",
+	})
+	for i, name := range stale {
+		if err := r.reencrypt(keyRing, name, version); err != nil {
+			logrus.Errorf("[GenericEncryptedStore]: failed to re-encrypt secret %s/%s: %v", r.namespace, name, err)
+			failed++
+			lastErr = err
+		} else {
+			rotated++
+		}
+		if (i+1)%rotationProgressInterval == 0 {
+			r.updateStatus(map[string]string{
+				rotationRotatedKey: strconv.Itoa(rotated),
+				rotationFailedKey:  strconv.Itoa(failed),
+			})
+		}
+	}
+	r.finish(rotated, failed, lastErr)
+}
+
+// isPlainStoreSecret returns whether a secret is a secret of a store that was stored before
+// encryption was enabled.
+func (r *Rotator) isPlainStoreSecret(secret *corev1.Secret) bool {
+	if isEncrypted(secret) || secret.Name == keyRingName ||
+		(secret.Type != "" && secret.Type != corev1.SecretTypeOpaque) {
+		return false
+	}
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(secret.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rotator) reencrypt(keyRing *keyRing, name string, version int) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := r.secrets.GetNamespaced(r.namespace, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if isEncrypted(secret) && keyVersion(secret) >= version {
+			return nil
+		}
+
+		data, err := keyRing.decrypt(r.ctx, secret)
+		if err != nil {
+			return err
+		}
+		secret = secret.DeepCopy()
+		if err := keyRing.encrypt(r.ctx, secret, data); err != nil {
+			return err
+		}
+		_, err = r.secrets.Update(secret)
+		return err
+	})
+}
+
+func (r *Rotator) finish(rotated, failed int, err error) {
+	status := map[string]string{
+		rotationPhaseKey:       RotationPhaseCompleted,
+		rotationRotatedKey:     strconv.Itoa(rotated),
+		rotationFailedKey:      strconv.Itoa(failed),
+		rotationMessageKey:     "",
+		rotationCompletedAtKey: time.Now().UTC().Format(time.RFC3339),
+	}
+	if err != nil {
+		status[rotationPhaseKey] = RotationPhaseFailed
+		status[rotationMessageKey] = err.Error()
+	}
+	r.updateStatus(status)
+}
+
+func (r *Rotator) updateStatus(status map[string]string) {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := r.configMaps.GetNamespaced(r.namespace, RotationStatusName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		cm = cm.DeepCopy()
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		for k, v := range status {
+			cm.Data[k] = v
+		}
+		_, err = r.configMaps.Update(cm)
+		return err
+	})
+	if err != nil {
+		logrus.Errorf("[GenericEncryptedStore]: failed to update rotation status %s/%s: %v", r.namespace, RotationStatusName, err)
+	}
+}
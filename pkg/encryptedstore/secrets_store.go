@@ -1,6 +1,7 @@
 package encryptedstore
 
 import (
+	"context"
 	"reflect"
 	"time"
 
@@ -16,11 +17,18 @@
 	defaultNamespace = "cattle-system"
 )
 
+// GenericEncryptedStore stores values in secrets. When a KMS plugin is configured the
+// values are encrypted with a data key from the key ring of the namespace, otherwise they
+// are stored as they are and only protected by the encryption at rest of the apiserver.
+// Secrets that were encrypted can't be read anymore once the KMS plugin is unset.
 type GenericEncryptedStore struct {
-	prefix       string
-	namespace    string
-	secrets      v1.SecretInterface
-	secretLister v1.SecretLister
+	prefix        string
+	namespace     string
+	secrets       v1.SecretInterface
+	secretLister  v1.SecretLister
+	secretsGetter v1.SecretsGetter
+	// keyRing is set by tests, the store otherwise gets the key ring of the namespace on use
+	keyRing *keyRing
 }
 
 func NewGenericEncrypedStore(prefix, namespace string, namespaceInterface v1.NamespaceInterface, secretsGetter v1.SecretsGetter) (*GenericEncryptedStore, error) {
@@ -39,23 +47,50 @@ func NewGenericEncrypedStore(prefix, namespace string, namespaceInterface v1.Nam
 		return nil, err
 	}
 
+	// an invalid KMS configuration fails the calls of the store, not its creation, so it
+	// doesn't stop rancher from starting
+	if _, err := getKeyRing(namespace, secretsGetter); err != nil {
+		logrus.Errorf("[GenericEncryptedStore]: failed to set up encryption of namespace %s: %v", namespace, err)
+	}
+
 	return &GenericEncryptedStore{
-		prefix:       prefix,
-		namespace:    namespace,
-		secrets:      secretsGetter.Secrets(namespace),
-		secretLister: secretsGetter.Secrets(namespace).Controller().Lister(),
+		prefix:        prefix,
+		namespace:     namespace,
+		secrets:       secretsGetter.Secrets(namespace),
+		secretLister:  secretsGetter.Secrets(namespace).Controller().Lister(),
+		secretsGetter: secretsGetter,
 	}, nil
 }
 
+// getKeyRing returns the key ring values are encrypted with, or nil if no KMS plugin is configured.
+func (g *GenericEncryptedStore) getKeyRing() (*keyRing, error) {
+	if g.keyRing != nil || g.secretsGetter == nil {
+		return g.keyRing, nil
+	}
+	return getKeyRing(g.namespace, g.secretsGetter)
+}
+
 func (g *GenericEncryptedStore) Get(name string) (map[string]string, error) {
 	sec, err := g.secretLister.Get(g.namespace, g.getKey(name))
 	if err != nil {
 		return nil, err
 	}
 
-	result := map[string]string{}
-	for k, v := range sec.Data {
-		result[k] = string(v)
+	keyRing, err := g.getKeyRing()
+	if err != nil {
+		return nil, err
+	}
+	result, err := keyRing.decrypt(context.Background(), sec)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyRing != nil && !isEncrypted(sec) {
+		// encrypt values that were stored before encryption was enabled
+		logrus.Debugf("[GenericEncryptedStore]: encrypting secret %v", g.getKey(name))
+		if err := g.set(name, result); err != nil {
+			logrus.Errorf("[GenericEncryptedStore]: failed to encrypt secret %v: %v", g.getKey(name), err)
+		}
 	}
 
 	return result, nil
@@ -71,12 +106,21 @@ func (g *GenericEncryptedStore) Set(name string, data map[string]string) error {
 
 func (g *GenericEncryptedStore) set(name string, data map[string]string) error {
 	logrus.Debugf("[GenericEncryptedStore]: set secret called for %v", g.getKey(name))
+	keyRing, err := g.getKeyRing()
+	if err != nil {
+		return err
+	}
 	sec, err := g.secretLister.Get(g.namespace, g.getKey(name))
 	if errors.IsNotFound(err) {
 		logrus.Debugf("[GenericEncryptedStore]: Creating secret for %v", g.getKey(name))
 		sec = &corev1.Secret{}
 		sec.Name = g.getKey(name)
 		sec.StringData = data
+		if keyRing != nil {
+			if err := keyRing.encrypt(context.Background(), sec, data); err != nil {
+				return err
+			}
+		}
 		if _, err := g.secrets.Create(sec); err != nil {
 			if !errors.IsAlreadyExists(err) {
 				return err
@@ -90,8 +134,11 @@ func (g *GenericEncryptedStore) set(name string, data map[string]string) error {
 		return err
 	}
 
-	secToUpdate := prepareSecretForUpdate(sec, data)
-	if !reflect.DeepEqual(secToUpdate.Data, sec.Data) {
+	secToUpdate, changed, err := g.prepareSecretForUpdate(sec, data)
+	if err != nil {
+		return err
+	}
+	if changed {
 		logrus.Debugf("[GenericEncryptedStore]: updating secret %v", g.getKey(name))
 		_, err = g.secrets.Update(secToUpdate)
 		if err != nil {
@@ -118,8 +165,11 @@ func (g *GenericEncryptedStore) updateSecretWithBackoff(name string, data map[st
 			logrus.Errorf("[GenericEncryptedStore]: error getting secret %v from db: %v", g.getKey(name), err)
 			return false, err
 		}
-		secToUpdate := prepareSecretForUpdate(secret, data)
-		if !reflect.DeepEqual(secToUpdate.Data, secret.Data) {
+		secToUpdate, changed, err := g.prepareSecretForUpdate(secret, data)
+		if err != nil {
+			return false, err
+		}
+		if changed {
 			_, err = g.secrets.Update(secToUpdate)
 			if err != nil {
 				if errors.IsConflict(err) {
@@ -135,15 +185,40 @@ func (g *GenericEncryptedStore) updateSecretWithBackoff(name string, data map[st
 	})
 }
 
-func prepareSecretForUpdate(secret *corev1.Secret, data map[string]string) *corev1.Secret {
+// prepareSecretForUpdate merges data into the values of the secret and reports whether
+// the secret has to be updated.
+func (g *GenericEncryptedStore) prepareSecretForUpdate(secret *corev1.Secret, data map[string]string) (*corev1.Secret, bool, error) {
+	keyRing, err := g.getKeyRing()
+	if err != nil {
+		return nil, false, err
+	}
 	secToUpdate := secret.DeepCopy()
-	if secToUpdate.Data == nil {
-		secToUpdate.Data = map[string][]byte{}
+	if keyRing == nil {
+		if secToUpdate.Data == nil {
+			secToUpdate.Data = map[string][]byte{}
+		}
+		for k, v := range data {
+			secToUpdate.Data[k] = []byte(v)
+		}
+		return secToUpdate, !reflect.DeepEqual(secToUpdate.Data, secret.Data), nil
+	}
+
+	// ciphertexts differ on every encryption, so compare the plaintext values
+	current, err := keyRing.decrypt(context.Background(), secret)
+	if err != nil {
+		return nil, false, err
+	}
+	merged := map[string]string{}
+	for k, v := range current {
+		merged[k] = v
 	}
 	for k, v := range data {
-		secToUpdate.Data[k] = []byte(v)
+		merged[k] = v
+	}
+	if isEncrypted(secret) && reflect.DeepEqual(merged, current) {
+		return secToUpdate, false, nil
 	}
-	return secToUpdate
+	return secToUpdate, true, keyRing.encrypt(context.Background(), secToUpdate, merged)
 }
 
 func (g *GenericEncryptedStore) Remove(name string) error {
@@ -0,0 +1,350 @@
+package encryptedstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	"github.com/rancher/rancher/pkg/generated/norman/core/v1/fakes"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const testNamespace = "cattle-system"
+
+// fakeKMS encrypts data keys with a key derived from its key ID.
+type fakeKMS struct {
+	keyID string
+}
+
+func (f *fakeKMS) kek(keyID string) []byte {
+	sum := sha256.Sum256([]byte(keyID))
+	return sum[:]
+}
+
+func (f *fakeKMS) Encrypt(ctx context.Context, plaintext []byte) (*EncryptedKey, error) {
+	ciphertext, err := seal(f.kek(f.keyID), plaintext, nil)
+	return &EncryptedKey{Ciphertext: ciphertext, KeyID: f.keyID}, err
+}
+
+func (f *fakeKMS) Decrypt(ctx context.Context, key *EncryptedKey) ([]byte, error) {
+	return open(f.kek(key.KeyID), key.Ciphertext, nil)
+}
+
+// fakeSecrets keeps secrets in memory for the secret client and lister mocks.
+type fakeSecrets map[string]*corev1.Secret
+
+func (f fakeSecrets) client() *fakes.SecretInterfaceMock {
+	return &fakes.SecretInterfaceMock{
+		CreateFunc: func(secret *corev1.Secret) (*corev1.Secret, error) {
+			if _, ok := f[secret.Name]; ok {
+				return nil, apierrors.NewAlreadyExists(schema.GroupResource{Resource: "secrets"}, secret.Name)
+			}
+			secret = secret.DeepCopy()
+			if secret.Data == nil {
+				secret.Data = map[string][]byte{}
+			}
+			for k, v := range secret.StringData {
+				secret.Data[k] = []byte(v)
+			}
+			secret.StringData = nil
+			f[secret.Name] = secret
+			return secret, nil
+		},
+		UpdateFunc: func(secret *corev1.Secret) (*corev1.Secret, error) {
+			f[secret.Name] = secret.DeepCopy()
+			return secret, nil
+		},
+		GetNamespacedFunc: func(namespace, name string, opts metav1.GetOptions) (*corev1.Secret, error) {
+			return f.get(namespace, name)
+		},
+	}
+}
+
+func (f fakeSecrets) lister() *fakes.SecretListerMock {
+	return &fakes.SecretListerMock{
+		GetFunc: f.get,
+		ListFunc: func(namespace string, selector labels.Selector) ([]*corev1.Secret, error) {
+			var result []*corev1.Secret
+			for _, secret := range f {
+				if selector.Matches(labels.Set(secret.Labels)) {
+					result = append(result, secret)
+				}
+			}
+			return result, nil
+		},
+	}
+}
+
+func (f fakeSecrets) get(namespace, name string) (*corev1.Secret, error) {
+	secret, ok := f[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+	}
+	return secret, nil
+}
+
+func newTestStore(secrets fakeSecrets, kms KeyEncryptionService) *GenericEncryptedStore {
+	store := &GenericEncryptedStore{
+		prefix:       "c-",
+		namespace:    testNamespace,
+		secrets:      secrets.client(),
+		secretLister: secrets.lister(),
+	}
+	if kms != nil {
+		store.keyRing = newKeyRing(testNamespace, kms, store.secrets, store.secretLister)
+	}
+	return store
+}
+
+func TestStoreEncryptsValues(t *testing.T) {
+	secrets := fakeSecrets{}
+	store := newTestStore(secrets, &fakeKMS{keyID: "kek-1"})
+
+	require.NoError(t, store.Set("c-abc", map[string]string{"cluster": "state"}))
+	secret := secrets["c-c-abc"]
+	assert.NotContains(t, string(secret.Data["cluster"]), "state")
+	assert.Equal(t, "1", secret.Annotations[keyVersionAnnotation])
+	assert.Equal(t, "true", secret.Labels[encryptedLabel])
+	assert.Contains(t, secrets, keyRingName)
+
+	require.NoError(t, store.Set("c-abc", map[string]string{"other": "value"}))
+	data, err := store.Get("c-abc")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"cluster": "state", "other": "value"}, data)
+
+	// values can't be moved to another entry
+	secrets["c-c-def"] = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "c-c-def", Annotations: secret.Annotations},
+		Data:       secrets["c-c-abc"].Data,
+	}
+	_, err = store.Get("c-def")
+	assert.Error(t, err)
+}
+
+func TestStoreMigratesPlainSecrets(t *testing.T) {
+	secrets := fakeSecrets{}
+	require.NoError(t, newTestStore(secrets, nil).Set("c-abc", map[string]string{"cluster": "state"}))
+	assert.Equal(t, "state", string(secrets["c-c-abc"].Data["cluster"]))
+
+	store := newTestStore(secrets, &fakeKMS{keyID: "kek-1"})
+	data, err := store.Get("c-abc")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"cluster": "state"}, data)
+	assert.True(t, isEncrypted(secrets["c-c-abc"]), "plain secrets are encrypted when they are read")
+
+	_, err = newTestStore(secrets, nil).Get("c-abc")
+	assert.Error(t, err, "encrypted secrets can't be read without the KMS plugin")
+}
+
+func TestRotation(t *testing.T) {
+	secrets := fakeSecrets{}
+	kms := &fakeKMS{keyID: "kek-1"}
+	store := newTestStore(secrets, kms)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.Set(strconv.Itoa(i), map[string]string{"cluster": "state" + strconv.Itoa(i)}))
+	}
+
+	// a secret stored before encryption was enabled and a secret of something else
+	require.NoError(t, newTestStore(secrets, nil).Set("plain", map[string]string{"cluster": "plain state"}))
+	secrets["other"] = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "other"},
+		Data:       map[string][]byte{"token": []byte("value")},
+	}
+
+	configMaps := map[string]*corev1.ConfigMap{}
+	r := &Rotator{
+		ctx:          context.Background(),
+		namespace:    testNamespace,
+		prefixes:     []string{"c-"},
+		keyRing:      store.keyRing,
+		secrets:      store.secrets,
+		secretLister: store.secretLister,
+		configMaps: &fakes.ConfigMapInterfaceMock{
+			CreateFunc: func(cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+				configMaps[cm.Name] = cm.DeepCopy()
+				return cm, nil
+			},
+			UpdateFunc: func(cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+				configMaps[cm.Name] = cm.DeepCopy()
+				return cm, nil
+			},
+			GetNamespacedFunc: func(namespace, name string, opts metav1.GetOptions) (*corev1.ConfigMap, error) {
+				return configMaps[name], nil
+			},
+		},
+	}
+	require.NoError(t, r.EnsureStatus())
+
+	// the key encryption key changes too, old data keys stay readable by their key ID
+	kms.keyID = "kek-2"
+	version, err := r.keyRing.rotate(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	r.reencryptAll()
+	status := configMaps[RotationStatusName].Data
+	assert.Equal(t, RotationPhaseCompleted, status[rotationPhaseKey])
+	assert.Equal(t, "4", status[rotationTotalKey])
+	assert.Equal(t, "4", status[rotationRotatedKey])
+	assert.Equal(t, "0", status[rotationFailedKey])
+
+	// a fresh key ring has to decrypt the new data key with the KMS plugin
+	store.keyRing = newKeyRing(testNamespace, kms, store.secrets, store.secretLister)
+	for i := 0; i < 3; i++ {
+		secret := secrets["c-"+strconv.Itoa(i)]
+		assert.Equal(t, "2", secret.Annotations[keyVersionAnnotation])
+		data, err := store.Get(strconv.Itoa(i))
+		require.NoError(t, err)
+		assert.Equal(t, "state"+strconv.Itoa(i), data["cluster"])
+	}
+	assert.Equal(t, "2", secrets["c-plain"].Annotations[keyVersionAnnotation], "plain secrets of the store are encrypted")
+	data, err := store.Get("plain")
+	require.NoError(t, err)
+	assert.Equal(t, "plain state", data["cluster"])
+	assert.Equal(t, "value", string(secrets["other"].Data["token"]), "secrets of other stores are kept as they are")
+	assert.False(t, isEncrypted(secrets["other"]))
+}
+
+func TestGetKeyRingFollowsEndpoint(t *testing.T) {
+	defer settings.EncryptionKMSEndpoint.Set(settings.EncryptionKMSEndpoint.Get())
+	defer func() {
+		keyRingsLock.Lock()
+		delete(keyRings, "test-endpoint")
+		keyRingsLock.Unlock()
+	}()
+	kms := &fakeKMS{keyID: "kek-1"}
+	secretsGetter := &fakes.SecretsGetterMock{
+		SecretsFunc: func(namespace string) v1.SecretInterface {
+			client := fakeSecrets{}.client()
+			client.ControllerFunc = func() v1.SecretController {
+				return &fakes.SecretControllerMock{
+					ListerFunc: func() v1.SecretLister { return fakeSecrets{}.lister() },
+				}
+			}
+			return client
+		},
+	}
+
+	require.NoError(t, settings.EncryptionKMSEndpoint.Set(fakeKMSPlugin(t, kms)))
+	first, err := getKeyRing("test-endpoint", secretsGetter)
+	require.NoError(t, err)
+	again, err := getKeyRing("test-endpoint", secretsGetter)
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+
+	require.NoError(t, settings.EncryptionKMSEndpoint.Set(fakeKMSPlugin(t, kms)))
+	changed, err := getKeyRing("test-endpoint", secretsGetter)
+	require.NoError(t, err)
+	assert.NotSame(t, first, changed, "the key ring is replaced when the KMS plugin endpoint changes")
+
+	require.NoError(t, settings.EncryptionKMSEndpoint.Set(""))
+	none, err := getKeyRing("test-endpoint", secretsGetter)
+	require.NoError(t, err)
+	assert.Nil(t, none)
+}
+
+// fakeKMSPlugin implements the KMS v2 gRPC API for the fake KMS.
+func fakeKMSPlugin(t *testing.T, kms *fakeKMS) string {
+	dir, err := ioutil.TempDir("", "kms")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "kms.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	handler := func(newReq func() interface{}, call func(interface{}) (interface{}, error)) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+		return func(_ interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newReq()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			return call(req)
+		}
+	}
+
+	server := grpc.NewServer()
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: strings.Trim(kmsServiceName, "/"),
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: "Status",
+				Handler: handler(func() interface{} { return &statusRequest{} }, func(interface{}) (interface{}, error) {
+					return &statusResponse{Version: kmsAPIVersion, Healthz: kmsHealthzHealthy, KeyID: kms.keyID}, nil
+				}),
+			},
+			{
+				MethodName: "Encrypt",
+				Handler: handler(func() interface{} { return &encryptRequest{} }, func(req interface{}) (interface{}, error) {
+					if req.(*encryptRequest).UID == "" {
+						return nil, fmt.Errorf("missing uid")
+					}
+					key, err := kms.Encrypt(context.Background(), req.(*encryptRequest).Plaintext)
+					if err != nil {
+						return nil, err
+					}
+					return &encryptResponse{Ciphertext: key.Ciphertext, KeyID: key.KeyID, Annotations: map[string][]byte{"kms.example.com/version": []byte("1")}}, nil
+				}),
+			},
+			{
+				MethodName: "Decrypt",
+				Handler: handler(func() interface{} { return &decryptRequest{} }, func(req interface{}) (interface{}, error) {
+					r := req.(*decryptRequest)
+					if string(r.Annotations["kms.example.com/version"]) != "1" {
+						return nil, fmt.Errorf("missing annotations")
+					}
+					plaintext, err := kms.Decrypt(context.Background(), &EncryptedKey{Ciphertext: r.Ciphertext, KeyID: r.KeyID})
+					return &decryptResponse{Plaintext: plaintext}, err
+				}),
+			},
+		},
+	}, struct{}{})
+	go server.Serve(l)
+	t.Cleanup(server.Stop)
+
+	return "unix://" + socket
+}
+
+func TestKMSService(t *testing.T) {
+	endpoint := fakeKMSPlugin(t, &fakeKMS{keyID: "kek-1"})
+	kms, err := NewKMSService(endpoint, 5*time.Second)
+	require.NoError(t, err)
+
+	key, err := kms.Encrypt(context.Background(), []byte("data key"))
+	require.NoError(t, err)
+	assert.Equal(t, "kek-1", key.KeyID)
+	assert.NotEqual(t, []byte("data key"), key.Ciphertext)
+
+	plaintext, err := kms.Decrypt(context.Background(), key)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data key"), plaintext)
+
+	_, err = NewKMSService("tcp://localhost:1234", time.Second)
+	assert.Error(t, err)
+}
+
+func TestValidateKMSEndpoint(t *testing.T) {
+	assert.NoError(t, ValidateKMSEndpoint(""))
+	assert.NoError(t, ValidateKMSEndpoint("unix:///var/run/kmsplugin/socket.sock"))
+	assert.NoError(t, ValidateKMSEndpoint("unix:///@kmsplugin"))
+	assert.Error(t, ValidateKMSEndpoint("/var/run/kmsplugin/socket.sock"))
+	assert.Error(t, ValidateKMSEndpoint("tcp://localhost:1234"))
+	assert.Error(t, ValidateKMSEndpoint("unix://"))
+}
@@ -0,0 +1,105 @@
+// Package etcddrill restores etcd snapshots into a throwaway etcd and checks the restored data,
+// the restore drills of RKE backups and RKE2 snapshots share it.
+package etcddrill
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const namespaceKeyPrefix = "/registry/namespaces/"
+
+// Script restores /snapshot.db, starts etcd on the restored data and prints the key count and
+// revision followed by the namespace keys. It is run in a container of the etcd image without a
+// network, etcd only listens on the loopback interface of the container.
+const Script = `set -e
+etcdctl snapshot restore /snapshot.db --data-dir=/etcd-drill >&2
+etcd --data-dir=/etcd-drill >/dev/null 2>&1 &
+i=0
+until etcdctl endpoint health >/dev/null 2>&1; do
+  i=$((i+1))
+  if [ $i -ge 60 ]; then echo "restored etcd did not become healthy" >&2; exit 1; fi
+  sleep 1
+done
+etcdctl get "" --prefix --count-only -w json
+etcdctl get ` + namespaceKeyPrefix + ` --prefix --keys-only
+`
+
+var defaultRequiredNamespaces = []string{"kube-system", "default"}
+
+type Result struct {
+	Revision   int64
+	Keys       int64
+	Namespaces []string
+}
+
+// ParseOutput parses the json output of the key count followed by the namespace keys.
+func ParseOutput(output string) (*Result, error) {
+	var (
+		result Result
+		count  bool
+	)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case !count:
+			var response struct {
+				Header struct {
+					Revision int64 `json:"revision"`
+				} `json:"header"`
+				Count int64 `json:"count"`
+			}
+			if err := json.Unmarshal([]byte(line), &response); err != nil {
+				return nil, fmt.Errorf("failed to parse key count of restored data: %v", err)
+			}
+			result.Revision = response.Header.Revision
+			result.Keys = response.Count
+			count = true
+		case strings.HasPrefix(line, namespaceKeyPrefix):
+			result.Namespaces = append(result.Namespaces, strings.TrimPrefix(line, namespaceKeyPrefix))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !count {
+		return nil, fmt.Errorf("restored data has no key count")
+	}
+	return &result, nil
+}
+
+// Check runs the integrity checks of a drill on the restored data, at least one key and the
+// kube-system and default namespaces are required if minKeys and requiredNamespaces are empty.
+func (r *Result) Check(minKeys int, requiredNamespaces []string) error {
+	if r.Revision < 1 {
+		return fmt.Errorf("restored data has no revision")
+	}
+	if minKeys < 1 {
+		minKeys = 1
+	}
+	if r.Keys < int64(minKeys) {
+		return fmt.Errorf("restored data has %d keys, at least %d are required", r.Keys, minKeys)
+	}
+
+	if len(requiredNamespaces) == 0 {
+		requiredNamespaces = defaultRequiredNamespaces
+	}
+	namespaces := map[string]bool{}
+	for _, namespace := range r.Namespaces {
+		namespaces[namespace] = true
+	}
+	var missing []string
+	for _, namespace := range requiredNamespaces {
+		if !namespaces[namespace] {
+			missing = append(missing, namespace)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("restored data is missing the namespaces %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
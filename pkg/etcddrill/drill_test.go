@@ -0,0 +1,44 @@
+package etcddrill
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const drillOutput = `{"header":{"cluster_id":14841639068965178418,"member_id":10276657743932975437,"revision":4711,"raft_term":2},"count":1234}
+/registry/namespaces/cattle-system
+
+/registry/namespaces/default
+
+/registry/namespaces/kube-system
+
+`
+
+func TestParseOutput(t *testing.T) {
+	result, err := ParseOutput(drillOutput)
+	require.NoError(t, err)
+	assert.Equal(t, &Result{
+		Revision:   4711,
+		Keys:       1234,
+		Namespaces: []string{"cattle-system", "default", "kube-system"},
+	}, result)
+
+	_, err = ParseOutput("")
+	assert.Error(t, err)
+	_, err = ParseOutput("Error: context deadline exceeded")
+	assert.Error(t, err)
+}
+
+func TestResultCheck(t *testing.T) {
+	result := &Result{Revision: 4711, Keys: 1234, Namespaces: []string{"default", "kube-system"}}
+	assert.NoError(t, result.Check(0, nil))
+	assert.NoError(t, result.Check(1234, nil))
+	assert.EqualError(t, result.Check(2000, nil), "restored data has 1234 keys, at least 2000 are required")
+	assert.EqualError(t, result.Check(0, []string{"default", "cattle-system", "fleet-system"}),
+		"restored data is missing the namespaces cattle-system, fleet-system")
+
+	assert.Error(t, (&Result{Keys: 1234, Namespaces: result.Namespaces}).Check(0, nil), "no revision")
+	assert.Error(t, (&Result{Revision: 1}).Check(0, nil), "empty snapshot")
+}
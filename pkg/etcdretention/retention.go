@@ -0,0 +1,87 @@
+// Package etcdretention selects the etcd snapshots that are pruned by a tiered
+// (grandfather-father-son) retention policy.
+package etcdretention
+
+import (
+	"sort"
+	"time"
+)
+
+// Tier keeps the newest snapshot of each interval for the last Keep intervals that have a
+// snapshot. Intervals are aligned to UTC, so a tier of 24h keeps one snapshot per day and a
+// tier of 168h one snapshot per week starting on Monday.
+type Tier struct {
+	Interval time.Duration
+	Keep     int
+}
+
+type Snapshot struct {
+	Name    string
+	Created time.Time
+}
+
+// Prune returns the snapshots that are not kept by any of the tiers, in the order they are
+// given. Nothing is pruned if no tier is valid.
+func Prune(tiers []Tier, snapshots []Snapshot) []Snapshot {
+	tiers = validTiers(tiers)
+	if len(tiers) == 0 {
+		return nil
+	}
+
+	newest := make([]int, len(snapshots))
+	for i := range newest {
+		newest[i] = i
+	}
+	sort.SliceStable(newest, func(i, j int) bool {
+		return snapshots[newest[i]].Created.After(snapshots[newest[j]].Created)
+	})
+
+	kept := map[int]bool{}
+	for _, tier := range tiers {
+		var (
+			count      int
+			lastBucket time.Time
+		)
+		for _, i := range newest {
+			if count == tier.Keep {
+				break
+			}
+			bucket := snapshots[i].Created.UTC().Truncate(tier.Interval)
+			if count > 0 && bucket.Equal(lastBucket) {
+				continue
+			}
+			kept[i] = true
+			lastBucket = bucket
+			count++
+		}
+	}
+
+	var pruned []Snapshot
+	for i, snapshot := range snapshots {
+		if !kept[i] {
+			pruned = append(pruned, snapshot)
+		}
+	}
+	return pruned
+}
+
+// MaxAge returns how long the oldest snapshot the tiers can keep is kept.
+func MaxAge(tiers []Tier) time.Duration {
+	var maxAge time.Duration
+	for _, tier := range validTiers(tiers) {
+		if age := tier.Interval * time.Duration(tier.Keep); age > maxAge {
+			maxAge = age
+		}
+	}
+	return maxAge
+}
+
+func validTiers(tiers []Tier) []Tier {
+	var result []Tier
+	for _, tier := range tiers {
+		if tier.Interval > 0 && tier.Keep > 0 {
+			result = append(result, tier)
+		}
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package etcdretention
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hourlySnapshots(now time.Time, hours int) []Snapshot {
+	var snapshots []Snapshot
+	for i := 0; i < hours; i++ {
+		created := now.Add(-time.Duration(i) * time.Hour)
+		snapshots = append(snapshots, Snapshot{Name: fmt.Sprintf("snapshot-%d", i), Created: created})
+	}
+	return snapshots
+}
+
+func names(snapshots []Snapshot) map[string]bool {
+	result := map[string]bool{}
+	for _, snapshot := range snapshots {
+		result[snapshot.Name] = true
+	}
+	return result
+}
+
+func TestPrune(t *testing.T) {
+	// Wednesday
+	now := time.Date(2021, 3, 17, 12, 30, 0, 0, time.UTC)
+	snapshots := hourlySnapshots(now, 24*120)
+	tiers := []Tier{
+		{Interval: time.Hour, Keep: 24},
+		{Interval: 24 * time.Hour, Keep: 7},
+		{Interval: 7 * 24 * time.Hour, Keep: 13},
+	}
+
+	pruned := names(Prune(tiers, snapshots))
+	var kept []Snapshot
+	for _, snapshot := range snapshots {
+		if !pruned[snapshot.Name] {
+			kept = append(kept, snapshot)
+		}
+	}
+
+	// the tiers share snapshots: the newest snapshot is the hourly, daily and weekly snapshot of
+	// today, the last snapshot of yesterday is hourly and daily, the last snapshot of the
+	// previous week is daily and weekly
+	assert.Len(t, kept, 24+5+11)
+	for i := 0; i < 24; i++ {
+		assert.False(t, pruned[fmt.Sprintf("snapshot-%d", i)], "hourly snapshot %d is kept", i)
+	}
+	// the last snapshot of a day is kept as the daily snapshot
+	assert.False(t, pruned["snapshot-37"], "Monday 23:30")
+	assert.True(t, pruned["snapshot-38"], "Monday 22:30")
+	// the last snapshot of a week is kept as the weekly snapshot
+	assert.False(t, pruned["snapshot-229"], "Sunday 23:30 two weeks ago")
+	assert.True(t, pruned["snapshot-157"], "Wednesday 23:30 last week")
+	assert.True(t, pruned[fmt.Sprintf("snapshot-%d", 24*120-1)], "older than the weekly tier")
+}
+
+func TestPruneKeepsIntervalsWithSnapshots(t *testing.T) {
+	now := time.Date(2021, 3, 17, 12, 0, 0, 0, time.UTC)
+	snapshots := []Snapshot{
+		{Name: "today", Created: now},
+		{Name: "last-month", Created: now.Add(-30 * 24 * time.Hour)},
+		{Name: "two-months-ago", Created: now.Add(-60 * 24 * time.Hour)},
+		{Name: "three-months-ago", Created: now.Add(-90 * 24 * time.Hour)},
+	}
+
+	// days without a snapshot don't count, so a daily tier keeps the three newest snapshots
+	pruned := Prune([]Tier{{Interval: 24 * time.Hour, Keep: 3}}, snapshots)
+	assert.Equal(t, []Snapshot{snapshots[3]}, pruned)
+}
+
+func TestPruneInvalidTiers(t *testing.T) {
+	snapshots := hourlySnapshots(time.Now(), 10)
+	assert.Empty(t, Prune(nil, snapshots), "no tiers")
+	assert.Empty(t, Prune([]Tier{{Interval: time.Hour}, {Keep: 3}}, snapshots), "no valid tier")
+	assert.Len(t, Prune([]Tier{{Interval: time.Hour}, {Interval: time.Hour, Keep: 3}}, snapshots), 7)
+}
+
+func TestMaxAge(t *testing.T) {
+	assert.Equal(t, 91*24*time.Hour, MaxAge([]Tier{
+		{Interval: time.Hour, Keep: 24},
+		{Interval: 7 * 24 * time.Hour, Keep: 13},
+		{Interval: 24 * time.Hour},
+	}))
+	assert.Equal(t, time.Duration(0), MaxAge(nil))
+}
@@ -0,0 +1,333 @@
+package logserver
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/lasso/pkg/client"
+	"github.com/rancher/lasso/pkg/controller"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// registrationPackages are skipped to find the package that registered a handler
+var registrationPackages = []string{
+	"github.com/rancher/lasso/",
+	"github.com/rancher/wrangler/",
+	"github.com/rancher/norman/",
+	"github.com/rancher/rancher/pkg/generated/",
+	thisPackage,
+}
+
+var controllers = &registry{
+	controllers: map[string]*controllerInfo{},
+}
+
+// ControllerStatus is the status of a controller of the controllers endpoint.
+type ControllerStatus struct {
+	Name     string          `json:"name"`
+	Queue    string          `json:"queue,omitempty"`
+	Depth    int64           `json:"depth"`
+	Retries  int64           `json:"retries"`
+	Longest  float64         `json:"longestRunningProcessorSeconds"`
+	Handlers []HandlerStatus `json:"handlers"`
+}
+
+// HandlerStatus is the status of a handler of a controller.
+type HandlerStatus struct {
+	Name          string     `json:"name"`
+	Package       string     `json:"package,omitempty"`
+	LastSync      *time.Time `json:"lastSync,omitempty"`
+	LastSyncKey   string     `json:"lastSyncKey,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorKey  string     `json:"lastErrorKey,omitempty"`
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+}
+
+type registry struct {
+	lock        sync.RWMutex
+	controllers map[string]*controllerInfo
+}
+
+// controllerInfo tracks the shared controllers of a resource, the controllers of the factories of
+// the different contexts share a work queue name.
+type controllerInfo struct {
+	name        string
+	gvr         schema.GroupVersionResource
+	kind        string
+	clients     client.SharedClientFactory
+	controllers []controller.SharedController
+	handlers    []*handlerInfo
+}
+
+type handlerInfo struct {
+	lock   sync.Mutex
+	id     int64
+	status HandlerStatus
+}
+
+func (h *handlerInfo) synced(key string, err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	now := time.Now()
+	h.status.LastSync, h.status.LastSyncKey = &now, key
+	if err != nil && !errors.Is(err, controller.ErrIgnore) {
+		h.status.LastError, h.status.LastErrorKey, h.status.LastErrorTime = err.Error(), key, &now
+	}
+}
+
+func (h *handlerInfo) get() HandlerStatus {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.status
+}
+
+// WrapControllerFactory tracks the controllers and handlers of a factory so they are listed and
+// can be resynced through the socket.
+func WrapControllerFactory(factory controller.SharedControllerFactory) controller.SharedControllerFactory {
+	return &controllerFactory{
+		SharedControllerFactory: factory,
+	}
+}
+
+type controllerFactory struct {
+	controller.SharedControllerFactory
+}
+
+func (c *controllerFactory) ForObject(obj k8sruntime.Object) (controller.SharedController, error) {
+	gvk, err := c.SharedCacheFactory().SharedClientFactory().GVKForObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	return c.ForKind(gvk)
+}
+
+func (c *controllerFactory) ForKind(gvk schema.GroupVersionKind) (controller.SharedController, error) {
+	gvr, namespaced, err := c.SharedCacheFactory().SharedClientFactory().ResourceForGVK(gvk)
+	if err != nil {
+		return nil, err
+	}
+	return c.ForResourceKind(gvr, gvk.Kind, namespaced), nil
+}
+
+func (c *controllerFactory) ForResource(gvr schema.GroupVersionResource, namespaced bool) controller.SharedController {
+	return c.ForResourceKind(gvr, "", namespaced)
+}
+
+func (c *controllerFactory) ForResourceKind(gvr schema.GroupVersionResource, kind string, namespaced bool) controller.SharedController {
+	shared := c.SharedControllerFactory.ForResourceKind(gvr, kind, namespaced)
+	info := controllers.add(gvr, kind, c.SharedCacheFactory().SharedClientFactory(), shared)
+	return &sharedController{
+		SharedController: shared,
+		info:             info,
+	}
+}
+
+type sharedController struct {
+	controller.SharedController
+	info *controllerInfo
+}
+
+func (s *sharedController) RegisterHandler(ctx context.Context, name string, handler controller.SharedControllerHandler) {
+	info := controllers.addHandler(ctx, s.info, name, registrationPackage())
+	s.SharedController.RegisterHandler(ctx, name, controller.SharedControllerHandlerFunc(func(key string, obj k8sruntime.Object) (k8sruntime.Object, error) {
+		obj, err := handler.OnChange(key, obj)
+		info.synced(key, err)
+		return obj, err
+	}))
+}
+
+func (r *registry) add(gvr schema.GroupVersionResource, kind string, clients client.SharedClientFactory, shared controller.SharedController) *controllerInfo {
+	name := strings.TrimSuffix(gvr.Resource+"."+gvr.Version+"."+gvr.Group, ".")
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	info, ok := r.controllers[name]
+	if !ok {
+		info = &controllerInfo{
+			name:    name,
+			gvr:     gvr,
+			clients: clients,
+		}
+		r.controllers[name] = info
+	}
+	if info.kind == "" {
+		info.kind = kind
+	}
+	for _, existing := range info.controllers {
+		if existing == shared {
+			return info
+		}
+	}
+	info.controllers = append(info.controllers, shared)
+	return info
+}
+
+var handlerIDs int64
+
+func (r *registry) addHandler(ctx context.Context, info *controllerInfo, name, pkg string) *handlerInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	handlerIDs++
+	handler := &handlerInfo{
+		id: handlerIDs,
+		status: HandlerStatus{
+			Name:    name,
+			Package: pkg,
+		},
+	}
+	info.handlers = append(info.handlers, handler)
+
+	go func() {
+		<-ctx.Done()
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		for i := range info.handlers {
+			if info.handlers[i].id == handler.id {
+				info.handlers = append(info.handlers[:i], info.handlers[i+1:]...)
+				break
+			}
+		}
+	}()
+
+	return handler
+}
+
+// find returns the controllers with the name or with a handler of the name.
+func (r *registry) find(name string) []*controllerInfo {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if info, ok := r.controllers[name]; ok {
+		return []*controllerInfo{info}
+	}
+
+	var result []*controllerInfo
+	for _, info := range r.controllers {
+		for _, handler := range info.handlers {
+			if handler.status.Name == name {
+				result = append(result, info)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// packages returns the packages that registered the handlers of a controller or the handlers of
+// the name.
+func (r *registry) packages(name string) []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	seen := map[string]bool{}
+	var result []string
+	for controllerName, info := range r.controllers {
+		for _, handler := range info.handlers {
+			pkg := handler.status.Package
+			if pkg == "" || seen[pkg] || (controllerName != name && handler.status.Name != name) {
+				continue
+			}
+			seen[pkg] = true
+			result = append(result, pkg)
+		}
+	}
+	return result
+}
+
+// resync enqueues a key into the controllers with the name or with a handler of the name, it
+// returns false if there is no such controller.
+func (r *registry) resync(name, key string) bool {
+	infos := r.find(name)
+	for _, info := range infos {
+		r.lock.RLock()
+		shared := append([]controller.SharedController(nil), info.controllers...)
+		r.lock.RUnlock()
+		for _, c := range shared {
+			c.EnqueueKey(key)
+		}
+	}
+	return len(infos) > 0
+}
+
+func (r *registry) list() []ControllerStatus {
+	r.lock.RLock()
+	infos := make([]*controllerInfo, 0, len(r.controllers))
+	handlers := map[*controllerInfo][]*handlerInfo{}
+	for _, info := range r.controllers {
+		infos = append(infos, info)
+		handlers[info] = append([]*handlerInfo(nil), info.handlers...)
+	}
+	r.lock.RUnlock()
+
+	queues := gatherQueues()
+	var result []ControllerStatus
+	for _, info := range infos {
+		if len(handlers[info]) == 0 {
+			continue
+		}
+		status := ControllerStatus{
+			Name:     info.name,
+			Handlers: []HandlerStatus{},
+		}
+		if queue := info.queueName(); queue != "" {
+			status.Queue = queue
+			stats := queues[queue]
+			status.Depth, status.Retries, status.Longest = stats.depth, stats.retries, stats.longest
+		}
+		for _, handler := range handlers[info] {
+			status.Handlers = append(status.Handlers, handler.get())
+		}
+		result = append(result, status)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// queueName is the name of the work queue of lasso, the string of the kind of the controller.
+func (c *controllerInfo) queueName() string {
+	if c.kind != "" {
+		return c.gvr.GroupVersion().WithKind(c.kind).String()
+	}
+	gvk, err := c.clients.GVKForResource(c.gvr)
+	if err != nil {
+		return ""
+	}
+	return gvk.String()
+}
+
+// registrationPackage returns the package of the function that registered a handler.
+func registrationPackage() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		pkg := functionPackage(frame.Function)
+		if !skipRegistrationPackage(pkg) {
+			return pkg
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func skipRegistrationPackage(pkg string) bool {
+	for _, prefix := range registrationPackages {
+		if strings.HasPrefix(pkg, prefix) {
+			return true
+		}
+	}
+	return false
+}
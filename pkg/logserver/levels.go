@@ -0,0 +1,232 @@
+package logserver
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const logrusPackage = "github.com/sirupsen/logrus"
+
+var levels = &levelFilter{
+	packages:    map[string]logrus.Level{},
+	controllers: map[string]logrus.Level{},
+}
+
+// levelFilter overrides the log level of the standard logger for packages and controllers. The
+// level of the logger is set to the most verbose level and the entries of the packages that log
+// at a less verbose level are dropped by the formatter.
+type levelFilter struct {
+	lock        sync.RWMutex
+	base        logrus.Level
+	packages    map[string]logrus.Level
+	controllers map[string]logrus.Level
+	// effective are the package levels of the package and controller overrides
+	effective map[string]logrus.Level
+	formatter logrus.Formatter
+	installed bool
+}
+
+// Format drops the entries that are below the level of the package that logs them. The package is
+// found by walking the stack of every entry while an override is set, the package of each program
+// counter is cached so that the walk only resolves the call sites that weren't seen before.
+func (l *levelFilter) Format(entry *logrus.Entry) ([]byte, error) {
+	l.lock.RLock()
+	installed, formatter := l.installed, l.formatter
+	l.lock.RUnlock()
+
+	if installed && entry.Level > l.packageLevel(callerPackage()) {
+		return nil, nil
+	}
+	return formatter.Format(entry)
+}
+
+func (l *levelFilter) packageLevel(pkg string) logrus.Level {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.level(pkg)
+}
+
+// level returns the level of the override with the longest package prefix.
+func (l *levelFilter) level(pkg string) logrus.Level {
+	level, match := l.base, ""
+	for prefix, prefixLevel := range l.effective {
+		if len(prefix) > len(match) && (pkg == prefix || strings.HasPrefix(pkg, prefix+"/")) {
+			level, match = prefixLevel, prefix
+		}
+	}
+	return level
+}
+
+func (l *levelFilter) baseLevel() logrus.Level {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if !l.installed {
+		return logrus.GetLevel()
+	}
+	return l.base
+}
+
+func (l *levelFilter) setBase(level logrus.Level) {
+	l.lock.Lock()
+	l.base = level
+	apply := l.apply()
+	l.lock.Unlock()
+	apply()
+}
+
+// setPackage overrides the level of a package and its sub packages, the override is removed if
+// level is nil.
+func (l *levelFilter) setPackage(pkg string, level *logrus.Level) {
+	l.lock.Lock()
+	l.begin()
+	set(l.packages, pkg, level)
+	apply := l.apply()
+	l.lock.Unlock()
+	apply()
+}
+
+// setController overrides the level of the package that registered the handlers of a controller.
+func (l *levelFilter) setController(name string, level *logrus.Level) error {
+	if len(controllers.packages(name)) == 0 {
+		return fmt.Errorf("unknown controller %s", name)
+	}
+
+	l.lock.Lock()
+	l.begin()
+	set(l.controllers, name, level)
+	apply := l.apply()
+	l.lock.Unlock()
+	apply()
+	return nil
+}
+
+func set(overrides map[string]logrus.Level, name string, level *logrus.Level) {
+	if level == nil {
+		delete(overrides, name)
+	} else {
+		overrides[name] = *level
+	}
+}
+
+// begin records the level of the standard logger as the base level before the first override.
+func (l *levelFilter) begin() {
+	if !l.installed {
+		l.base = logrus.GetLevel()
+	}
+}
+
+// apply computes the package levels of the overrides. The returned function sets the level of
+// the standard logger and installs the filter while there are overrides, it must be called without
+// holding the lock because the logger holds its own lock while formatting entries.
+func (l *levelFilter) apply() func() {
+	l.effective = map[string]logrus.Level{}
+	for name, level := range l.controllers {
+		for _, pkg := range controllers.packages(name) {
+			l.effective[pkg] = level
+		}
+	}
+	for pkg, level := range l.packages {
+		l.effective[pkg] = level
+	}
+
+	level := l.base
+	for _, override := range l.effective {
+		if override > level {
+			level = override
+		}
+	}
+
+	logger := logrus.StandardLogger()
+	install := len(l.effective) > 0
+	if install && !l.installed {
+		l.formatter = logger.Formatter
+	}
+	formatter := logrus.Formatter(l)
+	if !install {
+		formatter = l.formatter
+	}
+	changed := install != l.installed
+	l.installed = install
+
+	return func() {
+		logger.SetLevel(level)
+		if changed {
+			logger.SetFormatter(formatter)
+		}
+	}
+}
+
+func (l *levelFilter) String() string {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	lines := []string{fmt.Sprintf("default: %s", l.base)}
+	if !l.installed {
+		lines[0] = fmt.Sprintf("default: %s", logrus.GetLevel())
+	}
+	var overrides []string
+	for pkg, level := range l.packages {
+		overrides = append(overrides, fmt.Sprintf("package %s: %s", pkg, level))
+	}
+	for name, level := range l.controllers {
+		overrides = append(overrides, fmt.Sprintf("controller %s: %s", name, level))
+	}
+	sort.Strings(overrides)
+	return strings.Join(append(lines, overrides...), "\n") + "\n"
+}
+
+// pcPackages caches the package that logged at a program counter by the program counter, it is
+// empty if the function at the program counter is part of logrus or of this package.
+var pcPackages sync.Map
+
+// callerPackage returns the package of the function that logged an entry.
+func callerPackage() string {
+	pcs := make([]uintptr, 32)
+	for _, pc := range pcs[:runtime.Callers(3, pcs)] {
+		if pkg := pcPackage(pc); pkg != "" {
+			return pkg
+		}
+	}
+	return ""
+}
+
+// pcPackage returns the package of the first function at a program counter that isn't part of
+// logrus or of this package, a program counter has a frame for every function inlined there.
+func pcPackage(pc uintptr) string {
+	if pkg, ok := pcPackages.Load(pc); ok {
+		return pkg.(string)
+	}
+
+	result := ""
+	frames := runtime.CallersFrames([]uintptr{pc})
+	for {
+		frame, more := frames.Next()
+		if pkg := functionPackage(frame.Function); pkg != logrusPackage && pkg != thisPackage {
+			result = pkg
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	pcPackages.Store(pc, result)
+	return result
+}
+
+// functionPackage returns the package of a function name like
+// github.com/rancher/rancher/pkg/logserver.(*levelFilter).Format.
+func functionPackage(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+var thisPackage = reflect.TypeOf(levelFilter{}).PkgPath()
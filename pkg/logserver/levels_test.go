@@ -0,0 +1,31 @@
+package logserver
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctionPackage(t *testing.T) {
+	tests := map[string]string{
+		"github.com/rancher/rancher/pkg/logserver.(*levelFilter).Format": "github.com/rancher/rancher/pkg/logserver",
+		"github.com/sirupsen/logrus.(*Entry).Infof":                      "github.com/sirupsen/logrus",
+		"main.main":                    "main",
+		"net/http.(*conn).serve.func1": "net/http",
+		"gopkg.in/yaml%2ev2.Unmarshal": "gopkg.in/yaml%2ev2",
+	}
+	for function, pkg := range tests {
+		assert.Equal(t, pkg, functionPackage(function), function)
+	}
+}
+
+func TestPCPackage(t *testing.T) {
+	pcs := make([]uintptr, 1)
+	runtime.Callers(1, pcs)
+	// the test is part of this package and is skipped like the logger
+	assert.Equal(t, "", pcPackage(pcs[0]))
+	cached, ok := pcPackages.Load(pcs[0])
+	assert.True(t, ok)
+	assert.Equal(t, "", cached)
+}
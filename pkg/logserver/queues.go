@@ -0,0 +1,60 @@
+package logserver
+
+import (
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	depthMetric   = metrics.WorkQueueSubsystem + "_" + metrics.DepthKey
+	retriesMetric = metrics.WorkQueueSubsystem + "_" + metrics.RetriesKey
+	longestMetric = metrics.WorkQueueSubsystem + "_" + metrics.LongestRunningProcessorKey
+)
+
+// queueStats is the depth, retries and the longest running processor of a work queue.
+type queueStats struct {
+	depth   int64
+	retries int64
+	longest float64
+}
+
+// gatherQueues reads the stats of the work queues by name from the metrics of controller-runtime.
+// The metrics provider of the work queues can only be set once and controller-runtime sets it when
+// it is imported, the queues of the same name of the different controller factories share the
+// metrics.
+func gatherQueues() map[string]queueStats {
+	result := map[string]queueStats{}
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		logrus.Errorf("Failed to gather work queue metrics: %v", err)
+		return result
+	}
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			var name string
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "name" {
+					name = label.GetValue()
+				}
+			}
+			if name == "" {
+				continue
+			}
+
+			stats := result[name]
+			switch family.GetName() {
+			case depthMetric:
+				stats.depth = int64(metric.GetGauge().GetValue())
+			case retriesMetric:
+				stats.retries = int64(metric.GetCounter().GetValue())
+			case longestMetric:
+				stats.longest = metric.GetGauge().GetValue()
+			default:
+				continue
+			}
+			result[name] = stats
+		}
+	}
+	return result
+}
@@ -0,0 +1,27 @@
+package logserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestGatherQueues(t *testing.T) {
+	queue := workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Hour, time.Hour), "logserver-test")
+	defer queue.ShutDown()
+
+	queue.Add("a")
+	queue.Add("b")
+	// the retry waits for an hour and doesn't add to the depth
+	queue.AddRateLimited("c")
+
+	stats := gatherQueues()["logserver-test"]
+	assert.Equal(t, int64(2), stats.depth)
+	assert.Equal(t, int64(1), stats.retries)
+
+	item, _ := queue.Get()
+	queue.Done(item)
+	assert.Equal(t, int64(1), gatherQueues()["logserver-test"].depth)
+}
@@ -1,10 +1,14 @@
 package logserver
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
 
 	"github.com/sirupsen/logrus"
 )
@@ -37,8 +41,16 @@ func (s *Server) Start() {
 // start listening on the specified location
 func (s *Server) ListenAndServe() error {
 	logrus.Infof("Listening on %s", s.SocketLocation)
-	server := http.Server{}
-	http.HandleFunc("/v1/loglevel", s.loglevel)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/loglevel", s.loglevel)
+	mux.HandleFunc("/v1/loglevels", s.loglevels)
+	mux.HandleFunc("/v1/pprof/goroutine", s.profile("goroutine"))
+	mux.HandleFunc("/v1/pprof/heap", s.profile("heap"))
+	mux.HandleFunc("/v1/controllers", s.controllers)
+	mux.HandleFunc("/v1/resync", s.resync)
+	server := http.Server{
+		Handler: mux,
+	}
 	socketListener, err := net.Listen("unix", s.SocketLocation)
 	if err != nil {
 		return err
@@ -50,7 +62,7 @@ func (s *Server) loglevel(rw http.ResponseWriter, req *http.Request) {
 	// curl -X POST -d "level=debug" localhost:12345/v1/loglevel
 	logrus.Debugf("Received loglevel request")
 	if req.Method == http.MethodGet {
-		level := logrus.GetLevel().String()
+		level := levels.baseLevel().String()
 		rw.Write([]byte(fmt.Sprintf("%s\n", level)))
 	}
 
@@ -64,8 +76,109 @@ func (s *Server) loglevel(rw http.ResponseWriter, req *http.Request) {
 			rw.WriteHeader(http.StatusInternalServerError)
 			rw.Write([]byte(fmt.Sprintf("Failed to parse loglevel: %v\n", err)))
 		} else {
-			logrus.SetLevel(level)
+			levels.setBase(level)
 			rw.Write([]byte("OK\n"))
 		}
 	}
 }
+
+func (s *Server) loglevels(rw http.ResponseWriter, req *http.Request) {
+	// curl -X POST -d "package=github.com/rancher/rancher/pkg/provisioningv2&level=debug" localhost:12345/v1/loglevels
+	// curl -X POST -d "controller=provisioning-cluster-remove&level=trace" localhost:12345/v1/loglevels
+	// an empty level removes the override
+	if req.Method == http.MethodGet {
+		rw.Write([]byte(levels.String()))
+		return
+	}
+
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(fmt.Sprintf("Failed to parse form: %v\n", err)))
+		return
+	}
+
+	var level *logrus.Level
+	if value := req.Form.Get("level"); value != "" {
+		parsed, err := logrus.ParseLevel(value)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			rw.Write([]byte(fmt.Sprintf("Failed to parse loglevel: %v\n", err)))
+			return
+		}
+		level = &parsed
+	}
+
+	if pkg := req.Form.Get("package"); pkg != "" {
+		levels.setPackage(pkg, level)
+	} else if controller := req.Form.Get("controller"); controller != "" {
+		if err := levels.setController(controller, level); err != nil {
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte(fmt.Sprintf("%v\n", err)))
+			return
+		}
+	} else {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte("package or controller is required\n"))
+		return
+	}
+	rw.Write([]byte("OK\n"))
+}
+
+// profile writes a pprof profile, the debug parameter picks the format like /debug/pprof does.
+func (s *Server) profile(name string) http.HandlerFunc {
+	// curl localhost:12345/v1/pprof/goroutine?debug=2
+	return func(rw http.ResponseWriter, req *http.Request) {
+		debug, _ := strconv.Atoi(req.URL.Query().Get("debug"))
+		if name == "heap" && req.URL.Query().Get("gc") != "" {
+			runtime.GC()
+		}
+		if debug == 0 {
+			rw.Header().Set("Content-Type", "application/octet-stream")
+		} else {
+			rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		if err := pprof.Lookup(name).WriteTo(rw, debug); err != nil {
+			logrus.Errorf("Failed to write %s profile: %v", name, err)
+		}
+	}
+}
+
+func (s *Server) controllers(rw http.ResponseWriter, req *http.Request) {
+	// curl localhost:12345/v1/controllers
+	rw.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(rw)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(controllers.list()); err != nil {
+		logrus.Errorf("Failed to write controllers: %v", err)
+	}
+}
+
+func (s *Server) resync(rw http.ResponseWriter, req *http.Request) {
+	// curl -X POST -d "controller=clusters.v3.management.cattle.io&key=c-xxxxx" localhost:12345/v1/resync
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(fmt.Sprintf("Failed to parse form: %v\n", err)))
+		return
+	}
+
+	controller, key := req.Form.Get("controller"), req.Form.Get("key")
+	if controller == "" || key == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte("controller and key are required\n"))
+		return
+	}
+	if !controllers.resync(controller, key) {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(fmt.Sprintf("unknown controller %s\n", controller)))
+		return
+	}
+	rw.Write([]byte("OK\n"))
+}
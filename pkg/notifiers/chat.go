@@ -0,0 +1,177 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/types/config/dialer"
+)
+
+type mattermostMessage struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+type rocketChatMessage struct {
+	Text    string `json:"text"`
+	Channel string `json:"channel,omitempty"`
+}
+
+type rocketChatResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+type googleChatMessage struct {
+	Text string `json:"text"`
+}
+
+type matrixMessage struct {
+	MsgType string `json:"msgtype"`
+	Body    string `json:"body"`
+}
+
+type matrixResponse struct {
+	Errcode string `json:"errcode"`
+	Error   string `json:"error"`
+}
+
+func TestMattermost(url, channel, username, msg string, cfg *v32.HTTPClientConfig, dialer dialer.Dialer) error {
+	if msg == "" {
+		msg = "Mattermost setting validated"
+	}
+
+	_, err := postJSON(url, &mattermostMessage{
+		Text:     msg,
+		Channel:  channel,
+		Username: username,
+	}, cfg, dialer)
+	return err
+}
+
+func TestRocketChat(url, channel, msg string, cfg *v32.HTTPClientConfig, dialer dialer.Dialer) error {
+	if msg == "" {
+		msg = "Rocket.Chat setting validated"
+	}
+
+	res, err := postJSON(url, &rocketChatMessage{
+		Text:    msg,
+		Channel: channel,
+	}, cfg, dialer)
+	if err != nil {
+		return err
+	}
+
+	var rcResp rocketChatResponse
+	if err := json.Unmarshal(res, &rcResp); err != nil {
+		return err
+	}
+	if !rcResp.Success {
+		return fmt.Errorf("Failed to send Rocket.Chat message. %s", rcResp.Error)
+	}
+	return nil
+}
+
+func TestGoogleChat(url, msg string, cfg *v32.HTTPClientConfig, dialer dialer.Dialer) error {
+	if msg == "" {
+		msg = "Google Chat setting validated"
+	}
+
+	_, err := postJSON(url, &googleChatMessage{Text: msg}, cfg, dialer)
+	return err
+}
+
+// TestMatrix sends a text message to a room through the client-server API of the
+// homeserver. The bot user of the access token has to be a member of the room. The
+// homeserver ignores a second request with the same transaction id, a new one is
+// generated if txnID is empty.
+func TestMatrix(ctx context.Context, homeserverURL, accessToken, roomID, txnID, msg string, cfg *v32.HTTPClientConfig, dialer dialer.Dialer) error {
+	if msg == "" {
+		msg = "Matrix setting validated"
+	}
+	if roomID == "" {
+		return fmt.Errorf("Matrix room is required")
+	}
+
+	data, err := json.Marshal(&matrixMessage{
+		MsgType: "m.text",
+		Body:    msg,
+	})
+	if err != nil {
+		return err
+	}
+
+	if txnID == "" {
+		txnID = strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	sendURL := fmt.Sprintf("%s/_matrix/client/r0/rooms/%s/send/m.room.message/%s",
+		strings.TrimSuffix(homeserverURL, "/"), url.PathEscape(roomID), txnID)
+
+	client, err := NewClientFromConfig(cfg, dialer)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, sendURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentTypeJSON)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		var mxResp matrixResponse
+		res, _ := ioutil.ReadAll(resp.Body)
+		if err := json.Unmarshal(res, &mxResp); err == nil && mxResp.Error != "" {
+			return fmt.Errorf("Failed to send Matrix message. %s: %s", mxResp.Errcode, mxResp.Error)
+		}
+		return fmt.Errorf("HTTP status code is %d, not included in the 2xx success HTTP status codes", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// postJSON posts the payload to an incoming webhook and returns the response body.
+func postJSON(url string, payload interface{}, cfg *v32.HTTPClientConfig, dialer dialer.Dialer) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := NewClientFromConfig(cfg, dialer)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := post(client, url, contentTypeJSON, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	res, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("HTTP status code is %d, not included in the 2xx success HTTP status codes, response: %v", resp.StatusCode, string(res))
+	}
+	return res, nil
+}
@@ -0,0 +1,51 @@
+package notifiers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatNotifiers(t *testing.T) {
+	var (
+		gotPath   string
+		gotAuth   string
+		gotMethod string
+		gotBody   map[string]interface{}
+		response  = `{"success":true}`
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth, gotMethod = r.URL.EscapedPath(), r.Header.Get("Authorization"), r.Method
+		data, _ := ioutil.ReadAll(r.Body)
+		gotBody = map[string]interface{}{}
+		json.Unmarshal(data, &gotBody)
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	assert := assert.New(t)
+
+	assert.Nil(TestMattermost(server.URL+"/hooks/abc", "town-square", "rancher", "hello", nil, nil))
+	assert.Equal(map[string]interface{}{"text": "hello", "channel": "town-square", "username": "rancher"}, gotBody)
+
+	assert.Nil(TestRocketChat(server.URL+"/hooks/abc", "#ops", "", nil, nil))
+	assert.Equal(map[string]interface{}{"text": "Rocket.Chat setting validated", "channel": "#ops"}, gotBody)
+
+	response = `{"success":false,"error":"invalid token"}`
+	assert.EqualError(TestRocketChat(server.URL+"/hooks/abc", "#ops", "hello", nil, nil), "Failed to send Rocket.Chat message. invalid token")
+
+	response = `{}`
+	assert.Nil(TestGoogleChat(server.URL+"/v1/spaces/AAA/messages?key=k", "hello", nil, nil))
+	assert.Equal(map[string]interface{}{"text": "hello"}, gotBody)
+
+	assert.Nil(TestMatrix(context.Background(), server.URL+"/", "token", "!room:example.org", "txn-1", "hello", nil, nil))
+	assert.Equal(http.MethodPut, gotMethod)
+	assert.Equal("Bearer token", gotAuth)
+	assert.Equal("/_matrix/client/r0/rooms/%21room:example.org/send/m.room.message/txn-1", gotPath)
+	assert.Equal(map[string]interface{}{"msgtype": "m.text", "body": "hello"}, gotBody)
+}
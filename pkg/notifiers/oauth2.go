@@ -0,0 +1,104 @@
+package notifiers
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oauth2ClientIDKey     = "clientId"
+	oauth2ClientSecretKey = "clientSecret"
+	oauth2RefreshTokenKey = "refreshToken"
+)
+
+var (
+	tokenSourcesLock sync.Mutex
+	// tokenSources caches a token source per secret version so access tokens are only
+	// refreshed once they expire.
+	tokenSources = map[string]oauth2.TokenSource{}
+)
+
+// xoauth2Auth returns an XOAUTH2 authenticator with an access token obtained with the
+// refresh token from the secret referenced by the SMTP config.
+func xoauth2Auth(namespace string, cfg *v32.SMTPConfig, secrets v1.SecretLister) (smtp.Auth, error) {
+	if secrets == nil {
+		return nil, fmt.Errorf("XOAUTH2 is not supported for this notification")
+	}
+	if err := ValidateTokenURL(cfg.OAuth2.TokenURL); err != nil {
+		return nil, err
+	}
+	secret, err := secrets.Get(namespace, cfg.OAuth2.SecretName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get smtp oauth2 secret %s/%s: %v", namespace, cfg.OAuth2.SecretName, err)
+	}
+
+	key := fmt.Sprintf("%s/%s/%s/%s", namespace, secret.Name, secret.ResourceVersion, cfg.OAuth2.TokenURL)
+	tokenSourcesLock.Lock()
+	ts, ok := tokenSources[key]
+	if !ok {
+		conf := &oauth2.Config{
+			ClientID:     string(secret.Data[oauth2ClientIDKey]),
+			ClientSecret: string(secret.Data[oauth2ClientSecretKey]),
+			Endpoint:     oauth2.Endpoint{TokenURL: cfg.OAuth2.TokenURL},
+			Scopes:       cfg.OAuth2.Scopes,
+		}
+		// the token source outlives the request so it must not use its context
+		ts = conf.TokenSource(context.Background(), &oauth2.Token{
+			RefreshToken: string(secret.Data[oauth2RefreshTokenKey]),
+		})
+		// drop token sources of previous versions of the secret
+		for k := range tokenSources {
+			if strings.HasPrefix(k, namespace+"/"+secret.Name+"/") {
+				delete(tokenSources, k)
+			}
+		}
+		tokenSources[key] = ts
+	}
+	tokenSourcesLock.Unlock()
+
+	token, err := ts.Token()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to refresh smtp oauth2 token: %v", err)
+	}
+	return &xoauth2{username: cfg.Username, token: token.AccessToken}, nil
+}
+
+// ValidateTokenURL rejects token URLs the client secret would be sent to in the clear.
+func ValidateTokenURL(tokenURL string) error {
+	u, err := url.Parse(tokenURL)
+	if err != nil {
+		return fmt.Errorf("invalid token url: %v", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("token url %s is not an https url", tokenURL)
+	}
+	return nil
+}
+
+type xoauth2 struct {
+	username, token string
+}
+
+func (a *xoauth2) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS {
+		return "", nil, fmt.Errorf("unencrypted connection")
+	}
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+// Next answers the error challenge of a rejected token with an empty response so the
+// server finishes the exchange with its error code.
+func (a *xoauth2) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return []byte{}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,466 @@
+package notifiers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/types/config/dialer"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	queueLabel         = "notifier.cattle.io/delivery-queue"
+	queueDataKey       = "queue"
+	queueSyncInterval  = time.Second
+	retryBaseDelay     = 5 * time.Second
+	retryMaxDelay      = 10 * time.Minute
+	defaultMaxRetries  = 10
+	defaultDedupWindow = 5 * time.Minute
+	defaultRatePerMin  = 30
+	// statusInterval is the minimum time between status updates of a notifier, every update
+	// of a notifier triggers its handlers so status changes are batched
+	statusInterval = 30 * time.Second
+)
+
+var (
+	queuesLock sync.Mutex
+	// queues are the running queues by namespace, see GetQueue
+	queues = map[string]*Queue{}
+)
+
+// Delivery is a message waiting in the delivery queue of a notifier.
+type Delivery struct {
+	Recipient   string    `json:"recipient,omitempty"`
+	Message     Message   `json:"message"`
+	Attempts    int       `json:"attempts,omitempty"`
+	NextAttempt time.Time `json:"nextAttempt"`
+}
+
+type notifierQueue struct {
+	deliveries []*Delivery
+	recent     map[string]time.Time
+	limiter    flowcontrol.RateLimiter
+	rate       int
+	sending    bool
+	status     v32.NotifierStatus
+	// statusTime is when the status was last written to the notifier
+	statusTime time.Time
+
+	// version is incremented on every change of the deliveries, persistLock serializes the
+	// writes of the ConfigMap and persisted is the version it holds
+	version     int
+	persistLock sync.Mutex
+	persisted   int
+}
+
+// Queue delivers messages for the notifiers in one namespace in the background. Failed
+// deliveries are retried with exponential backoff, identical messages are only sent
+// once per dedup window and every notifier is rate limited. Queued messages are
+// persisted in a ConfigMap per notifier so they survive restarts.
+type Queue struct {
+	sync.Mutex
+
+	ctx            context.Context
+	namespace      string
+	dialerFactory  dialer.Factory
+	notifiers      v3.NotifierInterface
+	notifierLister v3.NotifierLister
+	configMaps     v1.ConfigMapInterface
+	secrets        v1.SecretLister
+	queues         map[string]*notifierQueue
+
+	send func(ctx context.Context, notifier *v3.Notifier, recipient string, msg *Message, dialer dialer.Dialer, secrets v1.SecretLister) error
+	now  func() time.Time
+}
+
+// GetQueue returns the running queue of the namespace, the queue is created and started
+// if there is none. Callers in the same namespace share the queue, it owns the ConfigMaps
+// the deliveries are persisted in.
+func GetQueue(ctx context.Context, namespace string, notifiers v3.NotifierInterface, configMaps v1.ConfigMapInterface, secrets v1.SecretLister, dialerFactory dialer.Factory) *Queue {
+	queuesLock.Lock()
+	defer queuesLock.Unlock()
+	if q, ok := queues[namespace]; ok {
+		return q
+	}
+
+	q := NewQueue(ctx, namespace, notifiers, configMaps, secrets, dialerFactory)
+	queues[namespace] = q
+	go func() {
+		q.Start()
+		queuesLock.Lock()
+		if queues[namespace] == q {
+			delete(queues, namespace)
+		}
+		queuesLock.Unlock()
+	}()
+	return q
+}
+
+func NewQueue(ctx context.Context, namespace string, notifiers v3.NotifierInterface, configMaps v1.ConfigMapInterface, secrets v1.SecretLister, dialerFactory dialer.Factory) *Queue {
+	return &Queue{
+		ctx:            ctx,
+		namespace:      namespace,
+		dialerFactory:  dialerFactory,
+		notifiers:      notifiers,
+		notifierLister: notifiers.Controller().Lister(),
+		configMaps:     configMaps,
+		secrets:        secrets,
+		queues:         map[string]*notifierQueue{},
+		send:           SendMessage,
+		now:            time.Now,
+	}
+}
+
+// Start restores the persisted queues and delivers queued messages until the context is done.
+func (q *Queue) Start() {
+	if err := q.restore(); err != nil {
+		logrus.Errorf("[notifier queue] failed to restore delivery queues in namespace %s: %v", q.namespace, err)
+	}
+
+	ticker := time.NewTicker(queueSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-ticker.C:
+			q.process()
+			q.flushStatus(false)
+		}
+	}
+}
+
+// Enqueue adds a message to the delivery queue of the notifier. Messages identical to one
+// enqueued within the dedup window are dropped.
+func (q *Queue) Enqueue(notifier *v3.Notifier, recipient string, msg *Message) error {
+	q.Lock()
+	nq := q.getQueue(notifier.Name)
+	now := q.now()
+	window := dedupWindow(notifier)
+	hash := hashKey(fmt.Sprintf("%s\x00%s\x00%s", recipient, msg.Title, msg.Content))
+	for h, t := range nq.recent {
+		if now.Sub(t) >= window {
+			delete(nq.recent, h)
+		}
+	}
+	if _, ok := nq.recent[hash]; ok {
+		q.Unlock()
+		logrus.Debugf("[notifier queue] dropping duplicate message for notifier %s/%s", q.namespace, notifier.Name)
+		return nil
+	}
+	if window > 0 {
+		nq.recent[hash] = now
+	}
+
+	d := &Delivery{
+		Recipient:   recipient,
+		Message:     *msg,
+		NextAttempt: now,
+	}
+	// every attempt to deliver the message uses the same id
+	d.Message.ID = rand.String(16)
+	nq.deliveries = append(nq.deliveries, d)
+	nq.status.QueuedCount = len(nq.deliveries)
+	snapshot := nq.snapshot()
+	q.Unlock()
+
+	if err := q.persist(notifier.Name, nq, snapshot); err != nil {
+		q.Lock()
+		nq.remove(d)
+		delete(nq.recent, hash)
+		nq.status.QueuedCount = len(nq.deliveries)
+		q.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (q *Queue) restore() error {
+	configMaps, err := q.configMaps.List(metav1.ListOptions{LabelSelector: queueLabel})
+	if err != nil {
+		return err
+	}
+
+	q.Lock()
+	defer q.Unlock()
+	for _, cm := range configMaps.Items {
+		name := cm.Labels[queueLabel]
+		var deliveries []*Delivery
+		if err := json.Unmarshal([]byte(cm.Data[queueDataKey]), &deliveries); err != nil {
+			logrus.Errorf("[notifier queue] discarding invalid delivery queue %s/%s: %v", cm.Namespace, cm.Name, err)
+			continue
+		}
+		nq := q.getQueue(name)
+		nq.deliveries = append(deliveries, nq.deliveries...)
+		nq.status.QueuedCount = len(nq.deliveries)
+	}
+	return nil
+}
+
+// getQueue must be called with the lock held.
+func (q *Queue) getQueue(name string) *notifierQueue {
+	nq, ok := q.queues[name]
+	if !ok {
+		nq = &notifierQueue{
+			recent: map[string]time.Time{},
+		}
+		if notifier, err := q.notifierLister.Get(q.namespace, name); err == nil {
+			nq.status = notifier.Status
+		}
+		q.queues[name] = nq
+	}
+	return nq
+}
+
+func (q *Queue) process() {
+	q.Lock()
+	deleted := map[string]*notifierQueue{}
+	now := q.now()
+	for name, nq := range q.queues {
+		if nq.sending || len(nq.deliveries) == 0 {
+			continue
+		}
+		notifier, err := q.notifierLister.Get(q.namespace, name)
+		if apierrors.IsNotFound(err) {
+			delete(q.queues, name)
+			deleted[name] = nq
+			continue
+		} else if err != nil {
+			continue
+		}
+
+		// deliver in order, a message waiting for its retry holds back the ones behind it
+		d := nq.deliveries[0]
+		if d.NextAttempt.After(now) || !nq.allow(notifier) {
+			continue
+		}
+		nq.sending = true
+		go q.deliver(notifier, nq, d)
+	}
+	snapshots := map[string]queueSnapshot{}
+	for name, nq := range deleted {
+		nq.deliveries = nil
+		snapshots[name] = nq.snapshot()
+	}
+	q.Unlock()
+
+	for name, nq := range deleted {
+		if err := q.persist(name, nq, snapshots[name]); err != nil {
+			logrus.Errorf("[notifier queue] failed to remove delivery queue of deleted notifier %s/%s: %v", q.namespace, name, err)
+		}
+	}
+}
+
+func (q *Queue) deliver(notifier *v3.Notifier, nq *notifierQueue, d *Delivery) {
+	var (
+		clusterDialer dialer.Dialer
+		err           error
+	)
+	if q.dialerFactory != nil {
+		clusterDialer, err = q.dialerFactory.ClusterDialer(q.namespace)
+	}
+	if err == nil {
+		msg := d.Message
+		err = q.send(q.ctx, notifier, d.Recipient, &msg, clusterDialer, q.secrets)
+	}
+
+	q.Lock()
+	now := q.now()
+	nq.sending = false
+	if err == nil {
+		nq.remove(d)
+		nq.status.LastSuccessTime = now.UTC().Format(time.RFC3339)
+	} else {
+		d.Attempts++
+		nq.status.LastError = err.Error()
+		nq.status.LastErrorTime = now.UTC().Format(time.RFC3339)
+		if d.Attempts >= maxRetries(notifier) {
+			logrus.Errorf("[notifier queue] dropping message for notifier %s/%s after %d attempts: %v", q.namespace, notifier.Name, d.Attempts, err)
+			nq.remove(d)
+		} else {
+			d.NextAttempt = now.Add(retryDelay(d.Attempts))
+		}
+	}
+	nq.status.QueuedCount = len(nq.deliveries)
+	snapshot := nq.snapshot()
+	q.Unlock()
+
+	if err := q.persist(notifier.Name, nq, snapshot); err != nil {
+		logrus.Errorf("[notifier queue] failed to persist delivery queue of notifier %s/%s: %v", q.namespace, notifier.Name, err)
+	}
+}
+
+// queueSnapshot are the deliveries of a notifier at a version, encoded for the ConfigMap.
+type queueSnapshot struct {
+	version int
+	data    []byte
+}
+
+// snapshot encodes the deliveries for persist, it must be called with the lock held.
+func (nq *notifierQueue) snapshot() queueSnapshot {
+	nq.version++
+	snapshot := queueSnapshot{version: nq.version}
+	if len(nq.deliveries) > 0 {
+		// a Delivery always encodes
+		snapshot.data, _ = json.Marshal(nq.deliveries)
+	}
+	return snapshot
+}
+
+// persist stores the queued deliveries of a notifier, the ConfigMap is removed once the queue
+// is empty. It is called without the lock held, a snapshot older than the one already stored
+// is skipped so writes for a notifier are not reordered.
+func (q *Queue) persist(name string, nq *notifierQueue, snapshot queueSnapshot) error {
+	nq.persistLock.Lock()
+	defer nq.persistLock.Unlock()
+	if snapshot.version <= nq.persisted {
+		return nil
+	}
+	if err := q.writeConfigMap(name, snapshot.data); err != nil {
+		return err
+	}
+	nq.persisted = snapshot.version
+	return nil
+}
+
+func (q *Queue) writeConfigMap(name string, data []byte) error {
+	cmName := name + "-delivery-queue"
+	if len(data) == 0 {
+		err := q.configMaps.Delete(cmName, &metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	cm, err := q.configMaps.Get(cmName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = q.configMaps.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      cmName,
+				Namespace: q.namespace,
+				Labels: map[string]string{
+					queueLabel: name,
+				},
+			},
+			Data: map[string]string{
+				queueDataKey: string(data),
+			},
+		})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	cm = cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[queueDataKey] = string(data)
+	_, err = q.configMaps.Update(cm)
+	return err
+}
+
+// flushStatus writes the changed status of the notifiers. Unless force is set a notifier is
+// updated at most once per statusInterval.
+func (q *Queue) flushStatus(force bool) {
+	statuses := map[string]v32.NotifierStatus{}
+	q.Lock()
+	now := q.now()
+	for name, nq := range q.queues {
+		if !force && now.Sub(nq.statusTime) < statusInterval {
+			continue
+		}
+		statuses[name] = nq.status
+	}
+	q.Unlock()
+
+	for name, status := range statuses {
+		if !q.updateStatus(name, status) {
+			continue
+		}
+		q.Lock()
+		if nq, ok := q.queues[name]; ok {
+			nq.statusTime = now
+		}
+		q.Unlock()
+	}
+}
+
+// updateStatus reports whether the status of the notifier was updated.
+func (q *Queue) updateStatus(name string, status v32.NotifierStatus) bool {
+	notifier, err := q.notifierLister.Get(q.namespace, name)
+	if err != nil || notifier.Status == status {
+		return false
+	}
+	notifier = notifier.DeepCopy()
+	notifier.Status = status
+	if _, err := q.notifiers.Update(notifier); err != nil {
+		logrus.Debugf("[notifier queue] failed to update status of notifier %s/%s: %v", q.namespace, name, err)
+		return false
+	}
+	return true
+}
+
+// allow reports whether the rate limit of the notifier permits another message right now.
+func (nq *notifierQueue) allow(notifier *v3.Notifier) bool {
+	perMinute := defaultRatePerMin
+	if cfg := notifier.Spec.DeliveryConfig; cfg != nil && cfg.RateLimitPerMinute > 0 {
+		perMinute = cfg.RateLimitPerMinute
+	}
+	if nq.limiter == nil || nq.rate != perMinute {
+		nq.limiter = flowcontrol.NewTokenBucketRateLimiter(float32(perMinute)/60, perMinute)
+		nq.rate = perMinute
+	}
+	return nq.limiter.TryAccept()
+}
+
+func (nq *notifierQueue) remove(d *Delivery) {
+	for i := range nq.deliveries {
+		if nq.deliveries[i] == d {
+			nq.deliveries = append(nq.deliveries[:i], nq.deliveries[i+1:]...)
+			return
+		}
+	}
+}
+
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+func maxRetries(notifier *v3.Notifier) int {
+	if cfg := notifier.Spec.DeliveryConfig; cfg != nil && cfg.MaxRetries > 0 {
+		return cfg.MaxRetries
+	}
+	return defaultMaxRetries
+}
+
+// dedupWindow returns the dedup window of the notifier, it is zero if deduplication is disabled
+// by a negative value.
+func dedupWindow(notifier *v3.Notifier) time.Duration {
+	if cfg := notifier.Spec.DeliveryConfig; cfg != nil && cfg.DedupWindowSeconds != 0 {
+		if cfg.DedupWindowSeconds < 0 {
+			return 0
+		}
+		return time.Duration(cfg.DedupWindowSeconds) * time.Second
+	}
+	return defaultDedupWindow
+}
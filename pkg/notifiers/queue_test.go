@@ -0,0 +1,214 @@
+package notifiers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	"github.com/rancher/rancher/pkg/generated/norman/core/v1/fakes"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	mgmtfakes "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	"github.com/rancher/rancher/pkg/types/config/dialer"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newTestQueue(notifier *v3.Notifier, configMaps map[string]*corev1.ConfigMap, sendErr *error) *Queue {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	notFound := apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "")
+	return &Queue{
+		ctx:       context.Background(),
+		namespace: "c-abcde",
+		notifierLister: &mgmtfakes.NotifierListerMock{
+			GetFunc: func(namespace string, name string) (*v3.Notifier, error) {
+				return notifier, nil
+			},
+		},
+		notifiers: &mgmtfakes.NotifierInterfaceMock{
+			UpdateFunc: func(in *v3.Notifier) (*v3.Notifier, error) {
+				notifier.Status = in.Status
+				return in, nil
+			},
+		},
+		configMaps: &fakes.ConfigMapInterfaceMock{
+			GetFunc: func(name string, opts metav1.GetOptions) (*corev1.ConfigMap, error) {
+				if cm, ok := configMaps[name]; ok {
+					return cm, nil
+				}
+				return nil, notFound
+			},
+			CreateFunc: func(in *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+				configMaps[in.Name] = in
+				return in, nil
+			},
+			UpdateFunc: func(in *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+				configMaps[in.Name] = in
+				return in, nil
+			},
+			DeleteFunc: func(name string, options *metav1.DeleteOptions) error {
+				delete(configMaps, name)
+				return nil
+			},
+		},
+		queues: map[string]*notifierQueue{},
+		send: func(ctx context.Context, notifier *v3.Notifier, recipient string, msg *Message, dialer dialer.Dialer, secrets v1.SecretLister) error {
+			return *sendErr
+		},
+		now: func() time.Time { return now },
+	}
+}
+
+func TestQueueDeliveryLifecycle(t *testing.T) {
+	assert := assert.New(t)
+
+	notifier := &v3.Notifier{
+		ObjectMeta: metav1.ObjectMeta{Name: "n-abcde", Namespace: "c-abcde"},
+		Spec: v32.NotifierSpec{
+			DeliveryConfig: &v32.DeliveryConfig{MaxRetries: 2},
+		},
+	}
+	configMaps := map[string]*corev1.ConfigMap{}
+	var sendErr error
+	q := newTestQueue(notifier, configMaps, &sendErr)
+
+	msg := &Message{Title: "build failed", Content: "pipeline #1 failed"}
+	assert.Nil(q.Enqueue(notifier, "#ops", msg))
+	assert.Nil(q.Enqueue(notifier, "#ops", msg), "duplicates are dropped silently")
+	assert.Nil(q.Enqueue(notifier, "#dev", msg))
+
+	nq := q.queues[notifier.Name]
+	assert.Len(nq.deliveries, 2)
+	q.flushStatus(true)
+	assert.Equal(2, notifier.Status.QueuedCount)
+
+	var persisted []*Delivery
+	assert.Nil(json.Unmarshal([]byte(configMaps["n-abcde-delivery-queue"].Data[queueDataKey]), &persisted))
+	assert.Len(persisted, 2)
+
+	assert.NotEmpty(nq.deliveries[0].Message.ID)
+	assert.NotEqual(nq.deliveries[0].Message.ID, nq.deliveries[1].Message.ID)
+
+	// a failed attempt is retried after the backoff
+	sendErr = errors.New("connection refused")
+	q.deliver(notifier, nq, nq.deliveries[0])
+	assert.Len(nq.deliveries, 2)
+	assert.Equal(1, nq.deliveries[0].Attempts)
+	assert.Equal(q.now().Add(retryBaseDelay), nq.deliveries[0].NextAttempt)
+	q.flushStatus(true)
+	assert.Equal("connection refused", notifier.Status.LastError)
+
+	// the message is dropped once max retries is reached
+	q.deliver(notifier, nq, nq.deliveries[0])
+	assert.Len(nq.deliveries, 1)
+	assert.Equal("#dev", nq.deliveries[0].Recipient)
+
+	sendErr = nil
+	q.deliver(notifier, nq, nq.deliveries[0])
+	assert.Len(nq.deliveries, 0)
+	q.flushStatus(true)
+	assert.Equal(0, notifier.Status.QueuedCount)
+	assert.NotEmpty(notifier.Status.LastSuccessTime)
+	assert.NotContains(configMaps, "n-abcde-delivery-queue", "empty queues are not persisted")
+}
+
+func TestQueueStatusIsBatched(t *testing.T) {
+	assert := assert.New(t)
+
+	notifier := &v3.Notifier{
+		ObjectMeta: metav1.ObjectMeta{Name: "n-abcde", Namespace: "c-abcde"},
+	}
+	var sendErr error
+	q := newTestQueue(notifier, map[string]*corev1.ConfigMap{}, &sendErr)
+	updates := 0
+	q.notifiers = &mgmtfakes.NotifierInterfaceMock{
+		UpdateFunc: func(in *v3.Notifier) (*v3.Notifier, error) {
+			updates++
+			notifier.Status = in.Status
+			return in, nil
+		},
+	}
+
+	assert.Nil(q.Enqueue(notifier, "#ops", &Message{Title: "first"}))
+	q.flushStatus(false)
+	assert.Equal(1, updates)
+
+	assert.Nil(q.Enqueue(notifier, "#ops", &Message{Title: "second"}))
+	q.flushStatus(false)
+	assert.Equal(1, updates, "the status is not written again within the status interval")
+
+	now := q.now().Add(statusInterval)
+	q.now = func() time.Time { return now }
+	q.flushStatus(false)
+	assert.Equal(2, updates)
+	assert.Equal(2, notifier.Status.QueuedCount)
+}
+
+func TestQueuePersistSkipsOlderSnapshots(t *testing.T) {
+	assert := assert.New(t)
+
+	notifier := &v3.Notifier{
+		ObjectMeta: metav1.ObjectMeta{Name: "n-abcde", Namespace: "c-abcde"},
+	}
+	configMaps := map[string]*corev1.ConfigMap{}
+	var sendErr error
+	q := newTestQueue(notifier, configMaps, &sendErr)
+
+	nq := q.getQueue(notifier.Name)
+	nq.deliveries = []*Delivery{{Recipient: "#ops"}}
+	older := nq.snapshot()
+	nq.deliveries = nil
+	newer := nq.snapshot()
+
+	assert.Nil(q.persist(notifier.Name, nq, newer))
+	assert.Nil(q.persist(notifier.Name, nq, older))
+	assert.NotContains(configMaps, "n-abcde-delivery-queue", "an older snapshot does not overwrite a newer one")
+}
+
+func TestQueueDedupDisabled(t *testing.T) {
+	assert := assert.New(t)
+
+	notifier := &v3.Notifier{
+		ObjectMeta: metav1.ObjectMeta{Name: "n-abcde", Namespace: "c-abcde"},
+		Spec: v32.NotifierSpec{
+			DeliveryConfig: &v32.DeliveryConfig{DedupWindowSeconds: -1},
+		},
+	}
+	var sendErr error
+	q := newTestQueue(notifier, map[string]*corev1.ConfigMap{}, &sendErr)
+
+	msg := &Message{Title: "build failed", Content: "pipeline #1 failed"}
+	assert.Nil(q.Enqueue(notifier, "#ops", msg))
+	assert.Nil(q.Enqueue(notifier, "#ops", msg))
+	assert.Len(q.queues[notifier.Name].deliveries, 2)
+	assert.Empty(q.queues[notifier.Name].recent)
+}
+
+func TestDedupWindow(t *testing.T) {
+	assert := assert.New(t)
+	window := func(seconds int) time.Duration {
+		return dedupWindow(&v3.Notifier{
+			Spec: v32.NotifierSpec{
+				DeliveryConfig: &v32.DeliveryConfig{DedupWindowSeconds: seconds},
+			},
+		})
+	}
+	assert.Equal(defaultDedupWindow, dedupWindow(&v3.Notifier{}))
+	assert.Equal(defaultDedupWindow, window(0))
+	assert.Equal(time.Minute, window(60))
+	assert.Equal(time.Duration(0), window(-1))
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(5*time.Second, retryDelay(1))
+	assert.Equal(10*time.Second, retryDelay(2))
+	assert.Equal(40*time.Second, retryDelay(4))
+	assert.Equal(retryMaxDelay, retryDelay(20))
+}
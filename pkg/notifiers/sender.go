@@ -6,11 +6,13 @@
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"mime/multipart"
 	"net"
 	"net/http"
@@ -25,6 +27,7 @@
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
+	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/types/config/dialer"
 )
@@ -32,8 +35,13 @@
 const contentTypeJSON = "application/json"
 
 type Message struct {
-	Title   string
-	Content string
+	// ID identifies a queued message across its retries, notifiers that support it use
+	// it to make retries idempotent
+	ID          string            `json:"id,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Content     string            `json:"content,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 type wechatToken struct {
@@ -45,17 +53,37 @@ type wechatResponse struct {
 	Error string `json:"error"`
 }
 
+type dingtalkMessage struct {
+	MsgType string       `json:"msgtype"`
+	Text    dingtalkText `json:"text"`
+	At      dingtalkAt   `json:"at"`
+}
+
+type dingtalkText struct {
+	Content string `json:"content"`
+}
+
+type dingtalkAt struct {
+	IsAtAll bool `json:"isAtAll"`
+}
+
 type dingtalkResponse struct {
 	Errcode int    `json:"errcode"`
 	Errmsg  string `json:"errmsg"`
 }
 
-func SendMessage(ctx context.Context, notifier *v3.Notifier, recipient string, msg *Message, dialer dialer.Dialer) error {
+func SendMessage(ctx context.Context, notifier *v3.Notifier, recipient string, msg *Message, dialer dialer.Dialer, secrets v1.SecretLister) error {
+	msg, err := RenderMessage(notifier.Spec.Template, msg)
+	if err != nil {
+		return err
+	}
+	text := messageText(notifier.Spec.Template, msg)
+
 	if notifier.Spec.SlackConfig != nil {
 		if recipient == "" {
 			recipient = notifier.Spec.SlackConfig.DefaultRecipient
 		}
-		return TestSlack(notifier.Spec.SlackConfig.URL, recipient, msg.Content, notifier.Spec.SlackConfig.HTTPClientConfig, dialer)
+		return TestSlack(notifier.Spec.SlackConfig.URL, recipient, text, notifier.Spec.SlackConfig.HTTPClientConfig, dialer)
 	}
 
 	if notifier.Spec.SMTPConfig != nil {
@@ -63,11 +91,17 @@ func SendMessage(ctx context.Context, notifier *v3.Notifier, recipient string, m
 		if recipient == "" {
 			recipient = s.DefaultRecipient
 		}
-		return TestEmail(ctx, s.Host, s.Password, s.Username, int(s.Port), s.TLS, msg.Title, msg.Content, recipient, s.Sender, dialer)
+		var oauth smtp.Auth
+		if s.OAuth2 != nil {
+			if oauth, err = xoauth2Auth(notifier.Namespace, s, secrets); err != nil {
+				return err
+			}
+		}
+		return TestEmail(ctx, s, oauth, msg.Title, msg.Content, recipient, dialer)
 	}
 
 	if notifier.Spec.PagerdutyConfig != nil {
-		return TestPagerduty(notifier.Spec.PagerdutyConfig.ServiceKey, msg.Content, notifier.Spec.PagerdutyConfig.HTTPClientConfig, dialer)
+		return TestPagerduty(notifier.Spec.PagerdutyConfig.ServiceKey, text, notifier.Spec.PagerdutyConfig.HTTPClientConfig, dialer)
 	}
 
 	if notifier.Spec.WechatConfig != nil {
@@ -76,24 +110,65 @@ func SendMessage(ctx context.Context, notifier *v3.Notifier, recipient string, m
 			recipient = s.DefaultRecipient
 		}
 		return TestWechat(notifier.Spec.WechatConfig.Secret, notifier.Spec.WechatConfig.Agent, notifier.Spec.WechatConfig.Corp, notifier.Spec.WechatConfig.RecipientType,
-			recipient, msg.Content, notifier.Spec.WechatConfig.HTTPClientConfig, dialer)
+			recipient, text, notifier.Spec.WechatConfig.HTTPClientConfig, dialer)
 	}
 
 	if notifier.Spec.WebhookConfig != nil {
-		return TestWebhook(notifier.Spec.WebhookConfig.URL, msg.Content, notifier.Spec.WebhookConfig.HTTPClientConfig, dialer)
+		return TestWebhook(notifier.Spec.WebhookConfig.URL, text, notifier.Spec.WebhookConfig.HTTPClientConfig, dialer)
 	}
 
 	if notifier.Spec.DingtalkConfig != nil {
-		return TestDingtalk(notifier.Spec.DingtalkConfig.URL, notifier.Spec.DingtalkConfig.Secret, msg.Content, notifier.Spec.DingtalkConfig.HTTPClientConfig, dialer)
+		return TestDingtalk(notifier.Spec.DingtalkConfig.URL, notifier.Spec.DingtalkConfig.Secret, text, notifier.Spec.DingtalkConfig.HTTPClientConfig, dialer)
 	}
 
 	if notifier.Spec.MSTeamsConfig != nil {
-		return TestMicrosoftTeams(notifier.Spec.MSTeamsConfig.URL, msg.Content, notifier.Spec.MSTeamsConfig.HTTPClientConfig, dialer)
+		return TestMicrosoftTeams(notifier.Spec.MSTeamsConfig.URL, text, notifier.Spec.MSTeamsConfig.HTTPClientConfig, dialer)
+	}
+
+	if notifier.Spec.MattermostConfig != nil {
+		s := notifier.Spec.MattermostConfig
+		if recipient == "" {
+			recipient = s.DefaultRecipient
+		}
+		return TestMattermost(s.URL, recipient, s.Username, text, s.HTTPClientConfig, dialer)
+	}
+
+	if notifier.Spec.RocketChatConfig != nil {
+		s := notifier.Spec.RocketChatConfig
+		if recipient == "" {
+			recipient = s.DefaultRecipient
+		}
+		return TestRocketChat(s.URL, recipient, text, s.HTTPClientConfig, dialer)
+	}
+
+	if notifier.Spec.GoogleChatConfig != nil {
+		return TestGoogleChat(notifier.Spec.GoogleChatConfig.URL, text, notifier.Spec.GoogleChatConfig.HTTPClientConfig, dialer)
+	}
+
+	if notifier.Spec.MatrixConfig != nil {
+		s := notifier.Spec.MatrixConfig
+		if recipient == "" {
+			recipient = s.DefaultRecipient
+		}
+		return TestMatrix(ctx, s.HomeserverURL, s.AccessToken, recipient, msg.ID, text, s.HTTPClientConfig, dialer)
 	}
 
 	return errors.New("Notifier not configured")
 }
 
+// messageText is the text sent by notifiers that have no separate subject. A title
+// is only prepended when it comes from a template, the default titles are written
+// for email subjects.
+func messageText(tmpl *v32.NotificationTemplate, msg *Message) string {
+	if tmpl == nil || tmpl.Title == "" || msg.Title == "" {
+		return msg.Content
+	}
+	if msg.Content == "" {
+		return msg.Title
+	}
+	return msg.Title + "\n\n" + msg.Content
+}
+
 func TestPagerduty(key, msg string, cfg *v32.HTTPClientConfig, dialer dialer.Dialer) error {
 	if msg == "" {
 		msg = "Pagerduty setting validated"
@@ -229,10 +304,14 @@ func TestDingtalk(url, secret, msg string, cfg *v32.HTTPClientConfig, dialer dia
 		msg = "Dingtalk setting validated"
 	}
 
-	content := `{"msgtype": "text",
-		"text": {"content": "` + msg + `"},
-		"at": {"isAtAll": true}
-	}`
+	content, err := json.Marshal(dingtalkMessage{
+		MsgType: "text",
+		Text:    dingtalkText{Content: msg},
+		At:      dingtalkAt{IsAtAll: true},
+	})
+	if err != nil {
+		return err
+	}
 
 	url = getDingtalkURL(url, secret)
 
@@ -241,7 +320,7 @@ func TestDingtalk(url, secret, msg string, cfg *v32.HTTPClientConfig, dialer dia
 		return err
 	}
 
-	req, err := http.NewRequest("POST", url, strings.NewReader(content))
+	req, err := http.NewRequest("POST", url, bytes.NewReader(content))
 	if err != nil {
 		return err
 	}
@@ -279,14 +358,19 @@ func TestMicrosoftTeams(url, msg string, cfg *v32.HTTPClientConfig, dialer diale
 		msg = "MicrosoftTeams setting validated"
 	}
 
-	content := `{"text":"` + msg + `"}`
+	content, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{Text: msg})
+	if err != nil {
+		return err
+	}
 
 	client, err := NewClientFromConfig(cfg, dialer)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", url, strings.NewReader(content))
+	req, err := http.NewRequest("POST", url, bytes.NewReader(content))
 	if err != nil {
 		return err
 	}
@@ -384,23 +468,56 @@ func TestSlack(url, channel, msg string, cfg *v32.HTTPClientConfig, dialer diale
 	return nil
 }
 
-func TestEmail(ctx context.Context, host, password, username string, port int, requireTLS *bool, title, content, receiver, sender string, dialer dialer.Dialer) error {
+func TestEmail(ctx context.Context, cfg *v32.SMTPConfig, auth smtp.Auth, title, content, receiver string, dialer dialer.Dialer) error {
 	if content == "" {
 		content = "Alert Name: Test SMTP setting"
 	}
-	c, err := smtpInit(ctx, host, port, dialer)
+	tlsConfig, err := smtpTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+	mode := smtpTLSMode(cfg)
+
+	c, err := smtpInit(ctx, cfg.Host, cfg.Port, mode == v32.SMTPTLSModeImplicit, cfg.TLSMode == "", tlsConfig, dialer)
 	if err != nil {
 		return err
 	}
 	defer c.Quit()
-	if err := smtpPrepare(c, host, password, username, port, requireTLS); err != nil {
+	if err := smtpPrepare(c, cfg, mode, tlsConfig, auth); err != nil {
 		return err
 	}
 
-	return smtpSend(c, title, content, receiver, sender)
+	return smtpSend(c, title, content, splitAddresses(receiver), cfg.CC, cfg.BCC, cfg.Sender)
+}
+
+// smtpTLSMode returns the TLS mode of the config, falling back to the legacy TLS flag
+// and implicit TLS on port 465 when no mode is set.
+func smtpTLSMode(cfg *v32.SMTPConfig) string {
+	if cfg.TLSMode != "" {
+		return cfg.TLSMode
+	}
+	if cfg.Port == 465 {
+		return v32.SMTPTLSModeImplicit
+	}
+	if cfg.TLS != nil && *cfg.TLS {
+		return v32.SMTPTLSModeStartTLS
+	}
+	return v32.SMTPTLSModeNone
+}
+
+func smtpTLSConfig(cfg *v32.SMTPConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: cfg.Host}
+	if cfg.CACerts != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(cfg.CACerts)) {
+			return nil, fmt.Errorf("Failed to parse smtp CA certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
 }
 
-func smtpInit(ctx context.Context, host string, port int, dialer dialer.Dialer) (*smtp.Client, error) {
+func smtpInit(ctx context.Context, host string, port int, implicitTLS, legacyTLS bool, tlsConfig *tls.Config, dialer dialer.Dialer) (*smtp.Client, error) {
 	smartHost := host + ":" + strconv.Itoa(port)
 	timeout := 15 * time.Second
 	var (
@@ -408,15 +525,12 @@ func smtpInit(ctx context.Context, host string, port int, dialer dialer.Dialer)
 		err  error
 		c    *smtp.Client
 	)
-	if port == 465 {
+	if implicitTLS {
 		if dialer != nil {
-			dialer = dialerWithTLSConfig(dialer, host, smartHost)
-			if err != nil {
-				return nil, fmt.Errorf("Failed to build dialer %v", err)
-			}
+			dialer = dialerWithTLSConfig(dialer, tlsConfig, smartHost, legacyTLS)
 			conn, err = dialer(ctx, "tcp", smartHost)
 		} else {
-			conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", smartHost, &tls.Config{ServerName: host})
+			conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", smartHost, tlsConfig)
 		}
 		if err != nil {
 			return nil, fmt.Errorf("Failed to connect smtp server: %v", err)
@@ -442,10 +556,15 @@ func smtpInit(ctx context.Context, host string, port int, dialer dialer.Dialer)
 	return c, nil
 }
 
-func dialerWithTLSConfig(dialer dialer.Dialer, host, smartHost string) dialer.Dialer {
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: true,
-		ServerName:         host,
+// dialerWithTLSConfig wraps the cluster dialer with a TLS client. The server certificate
+// is verified against the CA bundle of the config or the system roots, relays with a
+// private CA need the CA bundle. Notifiers without a TLS mode keep working with relays
+// that were set up before CA bundles could be configured, their certificate is not
+// verified unless a CA bundle is configured.
+func dialerWithTLSConfig(dialer dialer.Dialer, tlsConfig *tls.Config, smartHost string, legacyTLS bool) dialer.Dialer {
+	if legacyTLS && tlsConfig.RootCAs == nil {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.InsecureSkipVerify = true
 	}
 
 	return func(ctx context.Context, network, address string) (net.Conn, error) {
@@ -462,41 +581,57 @@ func dialerWithTLSConfig(dialer dialer.Dialer, host, smartHost string) dialer.Di
 	}
 }
 
-func smtpPrepare(c *smtp.Client, host, password, username string, port int, requireTLS *bool) error {
-	smartHost := host + ":" + strconv.Itoa(port)
-	if *requireTLS {
-		if ok, _ := c.Extension("STARTTLS"); !ok {
+func smtpPrepare(c *smtp.Client, cfg *v32.SMTPConfig, mode string, tlsConfig *tls.Config, oauth smtp.Auth) error {
+	smartHost := cfg.Host + ":" + strconv.Itoa(cfg.Port)
+	switch mode {
+	case v32.SMTPTLSModeStartTLS, v32.SMTPTLSModeOpportunistic:
+		ok, _ := c.Extension("STARTTLS")
+		if !ok && mode == v32.SMTPTLSModeStartTLS {
 			return fmt.Errorf("Require TLS but %q does not advertise the STARTTLS extension", smartHost)
 		}
-		tlsConf := &tls.Config{ServerName: host}
-		if err := c.StartTLS(tlsConf); err != nil {
-			return fmt.Errorf("Starttls failed: %v", err)
+		if ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("Starttls failed: %v", err)
+			}
+		}
+	}
+
+	ok, mech := c.Extension("AUTH")
+	if oauth != nil {
+		if !ok || !hasMechanism(mech, "XOAUTH2") {
+			return fmt.Errorf("Authentication failed: SMTP server does not support XOAUTH2 auth")
+		}
+		if err := c.Auth(oauth); err != nil {
+			return fmt.Errorf("Authentication failed: %v", err)
 		}
+		return nil
 	}
 
-	if ok, mech := c.Extension("AUTH"); ok {
-		if password != "" && username != "" {
-			auth, err := auth(mech, username, password)
-			if err != nil {
+	if ok && cfg.Password != "" && cfg.Username != "" {
+		auth, err := auth(mech, cfg.Host, cfg.Username, cfg.Password)
+		if err != nil {
+			return fmt.Errorf("Authentication failed: %v", err)
+		}
+		if auth != nil {
+			if err := c.Auth(auth); err != nil {
 				return fmt.Errorf("Authentication failed: %v", err)
 			}
-			if auth != nil {
-				if err := c.Auth(auth); err != nil {
-					return fmt.Errorf("Authentication failed: %v", err)
-				}
-			}
 		}
 	}
 	return nil
 }
 
-func smtpSend(c *smtp.Client, title, content, receiver, sender string) error {
+func smtpSend(c *smtp.Client, title, content string, to, cc, bcc []string, sender string) error {
 	if err := c.Mail(sender); err != nil {
 		return fmt.Errorf("Failed to set sender: %v", err)
 	}
 
-	if err := c.Rcpt(receiver); err != nil {
-		return fmt.Errorf("Failed to set recipient: %v", err)
+	for _, receivers := range [][]string{to, cc, bcc} {
+		for _, receiver := range receivers {
+			if err := c.Rcpt(receiver); err != nil {
+				return fmt.Errorf("Failed to set recipient %s: %v", receiver, err)
+			}
+		}
 	}
 
 	wc, err := c.Data()
@@ -507,8 +642,11 @@ func smtpSend(c *smtp.Client, title, content, receiver, sender string) error {
 	defer wc.Close()
 
 	fmt.Fprintf(wc, "%s: %s\r\n", "From", sender)
-	fmt.Fprintf(wc, "%s: %s\r\n", "To", receiver)
-	fmt.Fprintf(wc, "%s: %s\r\n", "Subject", title)
+	fmt.Fprintf(wc, "%s: %s\r\n", "To", strings.Join(to, ", "))
+	if len(cc) > 0 {
+		fmt.Fprintf(wc, "%s: %s\r\n", "Cc", strings.Join(cc, ", "))
+	}
+	fmt.Fprintf(wc, "%s: %s\r\n", "Subject", encodeSubject(title))
 
 	buffer := &bytes.Buffer{}
 	multipartWriter := multipart.NewWriter(buffer)
@@ -538,6 +676,13 @@ func smtpSend(c *smtp.Client, title, content, receiver, sender string) error {
 	return nil
 }
 
+// encodeSubject makes a title safe to use as the Subject header. Titles are rendered from
+// alert labels and annotations, line breaks are removed so they can not add headers.
+func encodeSubject(title string) string {
+	title = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(title)
+	return mime.QEncoding.Encode("UTF-8", title)
+}
+
 type pagerDutyEventPayload struct {
 	Summary  string `json:"summary"`
 	Source   string `json:"source"`
@@ -570,19 +715,37 @@ type wechatEvent struct {
 	Text    wechatEventPayload `json:"text"`
 }
 
-func auth(mechs string, username, password string) (smtp.Auth, error) {
+func auth(mechs string, host, username, password string) (smtp.Auth, error) {
+	if password == "" {
+		return nil, fmt.Errorf("SMTP password is empty")
+	}
+	if hasMechanism(mechs, "LOGIN") {
+		return &loginAuth{username, password}, nil
+	}
+	if hasMechanism(mechs, "PLAIN") {
+		return smtp.PlainAuth("", username, password, host), nil
+	}
+	return nil, fmt.Errorf("SMTP server does not support login auth")
+}
 
-	for _, mech := range strings.Split(mechs, " ") {
-		switch mech {
-		case "LOGIN":
-			if password == "" {
-				continue
-			}
+func hasMechanism(mechs, mech string) bool {
+	for _, m := range strings.Split(mechs, " ") {
+		if strings.EqualFold(m, mech) {
+			return true
+		}
+	}
+	return false
+}
 
-			return &loginAuth{username, password}, nil
+// splitAddresses splits a comma separated list of recipients.
+func splitAddresses(addresses string) []string {
+	var result []string
+	for _, address := range strings.Split(addresses, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			result = append(result, address)
 		}
 	}
-	return nil, fmt.Errorf("SMTP server does not support login auth")
+	return result
 }
 
 type loginAuth struct {
@@ -39,3 +39,10 @@ type testCase struct {
 	}
 
 }
+
+func TestEncodeSubject(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("High CPU", encodeSubject("High CPU"))
+	assert.Equal("High CPU Bcc: attacker@example.com", encodeSubject("High CPU\r\nBcc: attacker@example.com"))
+	assert.Equal("=?UTF-8?q?Speicher_f=C3=BCr_node-1?=", encodeSubject("Speicher für node-1"))
+}
@@ -0,0 +1,168 @@
+package notifiers
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTPServer accepts a single session and records the envelope and message.
+func fakeSMTPServer(t *testing.T, extensions ...string) (int, chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := make(chan []string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var received []string
+		r := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "220 localhost ESMTP\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				commands <- received
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			received = append(received, line)
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				fmt.Fprintf(conn, "250-localhost\r\n")
+				for _, ext := range extensions {
+					fmt.Fprintf(conn, "250-%s\r\n", ext)
+				}
+				fmt.Fprintf(conn, "250 HELP\r\n")
+			case line == "DATA":
+				fmt.Fprintf(conn, "354 go ahead\r\n")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					received = append(received, strings.TrimRight(line, "\r\n"))
+				}
+				fmt.Fprintf(conn, "250 OK\r\n")
+			case line == "QUIT":
+				fmt.Fprintf(conn, "221 bye\r\n")
+				commands <- received
+				return
+			default:
+				fmt.Fprintf(conn, "250 OK\r\n")
+			}
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port, commands
+}
+
+func TestEmailRecipients(t *testing.T) {
+	assert := assert.New(t)
+	port, commands := fakeSMTPServer(t)
+
+	cfg := &v32.SMTPConfig{
+		Host:    "127.0.0.1",
+		Port:    port,
+		Sender:  "rancher@example.com",
+		TLSMode: v32.SMTPTLSModeOpportunistic,
+		CC:      []string{"cc@example.com"},
+		BCC:     []string{"bcc@example.com"},
+	}
+	assert.Nil(TestEmail(context.Background(), cfg, nil, "title", "content", "a@example.com, b@example.com", nil))
+
+	received := <-commands
+	assert.Contains(received, "RCPT TO:<a@example.com>")
+	assert.Contains(received, "RCPT TO:<b@example.com>")
+	assert.Contains(received, "RCPT TO:<cc@example.com>")
+	assert.Contains(received, "RCPT TO:<bcc@example.com>")
+	assert.Contains(received, "To: a@example.com, b@example.com")
+	assert.Contains(received, "Cc: cc@example.com")
+	for _, line := range received {
+		assert.NotContains(line, "Bcc", "bcc recipients must not be in the headers")
+	}
+}
+
+func TestEmailRequiresStartTLS(t *testing.T) {
+	port, _ := fakeSMTPServer(t)
+	cfg := &v32.SMTPConfig{
+		Host:    "127.0.0.1",
+		Port:    port,
+		Sender:  "rancher@example.com",
+		TLSMode: v32.SMTPTLSModeStartTLS,
+	}
+	err := TestEmail(context.Background(), cfg, nil, "title", "content", "a@example.com", nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not advertise the STARTTLS extension")
+}
+
+func TestSMTPTLSMode(t *testing.T) {
+	yes, no := true, false
+	assert := assert.New(t)
+	assert.Equal(v32.SMTPTLSModeImplicit, smtpTLSMode(&v32.SMTPConfig{Port: 465, TLS: &yes}))
+	assert.Equal(v32.SMTPTLSModeStartTLS, smtpTLSMode(&v32.SMTPConfig{Port: 587, TLS: &yes}))
+	assert.Equal(v32.SMTPTLSModeNone, smtpTLSMode(&v32.SMTPConfig{Port: 25, TLS: &no}))
+	assert.Equal(v32.SMTPTLSModeOpportunistic, smtpTLSMode(&v32.SMTPConfig{Port: 465, TLSMode: v32.SMTPTLSModeOpportunistic}))
+}
+
+func TestXOAUTH2(t *testing.T) {
+	assert := assert.New(t)
+	a := &xoauth2{username: "alerts@example.com", token: "token"}
+
+	_, _, err := a.Start(&smtp.ServerInfo{Name: "smtp.example.com"})
+	assert.Error(err, "tokens must not be sent over unencrypted connections")
+
+	mech, resp, err := a.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true})
+	assert.Nil(err)
+	assert.Equal("XOAUTH2", mech)
+	assert.Equal("user=alerts@example.com\x01auth=Bearer token\x01\x01", string(resp))
+}
+
+func TestValidateTokenURL(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(ValidateTokenURL("https://oauth2.googleapis.com/token"))
+	assert.Error(ValidateTokenURL("http://oauth2.googleapis.com/token"), "the client secret must not be sent in the clear")
+	assert.Error(ValidateTokenURL("oauth2.googleapis.com/token"))
+	assert.Error(ValidateTokenURL("https:///token"))
+}
+
+func TestDialerWithTLSConfigVerification(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	address := server.Listener.Addr().String()
+	clusterDialer := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return net.Dial(network, address)
+	}
+	tlsConfig := &tls.Config{ServerName: "127.0.0.1"}
+
+	conn, err := dialerWithTLSConfig(clusterDialer, tlsConfig, address, true)(context.Background(), "tcp", address)
+	if assert.NoError(t, err, "notifiers without a TLS mode don't verify the server") {
+		conn.Close()
+	}
+
+	_, err = dialerWithTLSConfig(clusterDialer, tlsConfig, address, false)(context.Background(), "tcp", address)
+	assert.Error(t, err, "notifiers with a TLS mode verify the server")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	conn, err = dialerWithTLSConfig(clusterDialer, &tls.Config{ServerName: "127.0.0.1", RootCAs: pool}, address, false)(context.Background(), "tcp", address)
+	if assert.NoError(t, err, "the server is verified against the CA bundle") {
+		conn.Close()
+	}
+	assert.False(t, tlsConfig.InsecureSkipVerify, "the TLS config of the notifier is not changed")
+}
@@ -0,0 +1,144 @@
+package notifiers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+)
+
+// maxRenderedSize caps the output of a template so a runaway range cannot build
+// arbitrarily large messages.
+const maxRenderedSize = 64 * 1024
+
+// templateFuncs is the function set available to notification templates. It only
+// contains pure string helpers, templates can not reach the filesystem, network or
+// environment.
+var templateFuncs = template.FuncMap{
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"title":      strings.Title,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"replace":    func(old, new, s string) string { return strings.Replace(s, old, new, -1) },
+	"join":       func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+	"truncate": func(length int, s string) string {
+		if length >= 0 && len(s) > length {
+			return s[:length]
+		}
+		return s
+	},
+	"default": func(def string, s string) string {
+		if s == "" {
+			return def
+		}
+		return s
+	},
+	"toJson": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// RenderMessage returns the message produced by the title and body templates. The
+// templates see the fields of the message, so {{.Content}} is the unrendered body
+// and {{.Labels.pipeline}} the value of a label.
+func RenderMessage(tmpl *v32.NotificationTemplate, msg *Message) (*Message, error) {
+	if tmpl == nil {
+		return msg, nil
+	}
+
+	rendered := *msg
+	if tmpl.Title != "" {
+		title, err := renderTemplate("title", tmpl.Title, msg)
+		if err != nil {
+			return nil, err
+		}
+		rendered.Title = title
+	}
+	if tmpl.Body != "" {
+		body, err := renderTemplate("body", tmpl.Body, msg)
+		if err != nil {
+			return nil, err
+		}
+		rendered.Content = body
+	}
+	return &rendered, nil
+}
+
+// ValidateTemplate reports whether the title and body templates parse.
+func ValidateTemplate(tmpl *v32.NotificationTemplate) error {
+	if tmpl == nil {
+		return nil
+	}
+	for name, text := range map[string]string{"title": tmpl.Title, "body": tmpl.Body} {
+		if _, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text); err != nil {
+			return fmt.Errorf("invalid %s template: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func renderTemplate(name, text string, msg *Message) (string, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %v", name, err)
+	}
+
+	out := &limitedBuffer{limit: maxRenderedSize}
+	if err := t.Execute(out, msg); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %v", name, err)
+	}
+	return out.String(), nil
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("rendered message exceeds %d bytes", b.limit)
+	}
+	return b.Buffer.Write(p)
+}
+
+// AlertsSentByRancher reports whether rancher renders and sends the alerts of a notifier
+// instead of alert manager. Alert manager renders messages with its own templates, it can
+// not render the template of a notifier, it has no Google Chat or Matrix integration and
+// it does not support XOAUTH2 or every TLS setting of an SMTP notifier.
+func AlertsSentByRancher(spec *v32.NotifierSpec) bool {
+	if spec.GoogleChatConfig != nil || spec.MatrixConfig != nil {
+		return true
+	}
+	if spec.SMTPConfig != nil && (spec.SMTPConfig.OAuth2 != nil || !alertManagerSupportsTLS(spec.SMTPConfig)) {
+		return true
+	}
+	return spec.Template != nil && (spec.Template.Title != "" || spec.Template.Body != "")
+}
+
+// alertManagerSupportsTLS reports whether alert manager can send email with the TLS settings
+// of the config. Alert manager only connects with TLS on port 465 and otherwise either
+// requires STARTTLS or sends in plain text, it can not use an inline CA bundle.
+func alertManagerSupportsTLS(cfg *v32.SMTPConfig) bool {
+	if cfg.CACerts != "" {
+		return false
+	}
+	switch cfg.TLSMode {
+	case v32.SMTPTLSModeImplicit:
+		return cfg.Port == 465
+	case v32.SMTPTLSModeOpportunistic:
+		return false
+	case v32.SMTPTLSModeStartTLS, v32.SMTPTLSModeNone:
+		return cfg.Port != 465
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package notifiers
+
+import (
+	"strings"
+	"testing"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMessage(t *testing.T) {
+	msg := &Message{
+		Title:   "Pipeline #3 failed",
+		Content: "build \"api\" failed",
+		Labels: map[string]string{
+			"pipeline": "api",
+			"state":    "Failed",
+		},
+	}
+
+	tests := []struct {
+		name      string
+		template  *v32.NotificationTemplate
+		wantTitle string
+		wantBody  string
+		wantErr   string
+	}{
+		{
+			name:      "no template",
+			wantTitle: msg.Title,
+			wantBody:  msg.Content,
+		},
+		{
+			name: "title only",
+			template: &v32.NotificationTemplate{
+				Title: `[{{ .Labels.state | upper }}] {{ .Labels.pipeline }}`,
+			},
+			wantTitle: "[FAILED] api",
+			wantBody:  msg.Content,
+		},
+		{
+			name: "body with functions",
+			template: &v32.NotificationTemplate{
+				Body: `{{ .Content | truncate 5 }} {{ .Labels.missing | default "n/a" }} {{ toJson .Labels }}`,
+			},
+			wantTitle: msg.Title,
+			wantBody:  `build n/a {"pipeline":"api","state":"Failed"}`,
+		},
+		{
+			name: "unknown function",
+			template: &v32.NotificationTemplate{
+				Body: `{{ env "HOME" }}`,
+			},
+			wantErr: `function "env" not defined`,
+		},
+		{
+			name: "output too large",
+			template: &v32.NotificationTemplate{
+				Body: `{{ range split "" "` + strings.Repeat("x", 1024) + `" }}` + strings.Repeat("y", 100) + `{{ end }}`,
+			},
+			wantErr: "rendered message exceeds",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderMessage(tt.template, msg)
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantTitle, got.Title)
+			assert.Equal(t, tt.wantBody, got.Content)
+		})
+	}
+}
+
+func TestMessageText(t *testing.T) {
+	msg := &Message{Title: "title", Content: "content"}
+	assert.Equal(t, "content", messageText(nil, msg), "default titles are only used as email subjects")
+	assert.Equal(t, "title\n\ncontent", messageText(&v32.NotificationTemplate{Title: "{{ .Title }}"}, msg))
+}
+
+func TestAlertsSentByRancherSMTP(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  v32.SMTPConfig
+		want bool
+	}{
+		{name: "legacy", cfg: v32.SMTPConfig{Port: 587}, want: false},
+		{name: "starttls", cfg: v32.SMTPConfig{Port: 587, TLSMode: v32.SMTPTLSModeStartTLS}, want: false},
+		{name: "implicit on 465", cfg: v32.SMTPConfig{Port: 465, TLSMode: v32.SMTPTLSModeImplicit}, want: false},
+		{name: "implicit on another port", cfg: v32.SMTPConfig{Port: 2465, TLSMode: v32.SMTPTLSModeImplicit}, want: true},
+		{name: "none on 465", cfg: v32.SMTPConfig{Port: 465, TLSMode: v32.SMTPTLSModeNone}, want: true},
+		{name: "opportunistic", cfg: v32.SMTPConfig{Port: 587, TLSMode: v32.SMTPTLSModeOpportunistic}, want: true},
+		{name: "ca bundle", cfg: v32.SMTPConfig{Port: 587, TLSMode: v32.SMTPTLSModeStartTLS, CACerts: "pem"}, want: true},
+		{name: "oauth2", cfg: v32.SMTPConfig{Port: 587, OAuth2: &v32.SMTPOAuth2Config{}}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			assert.Equal(t, tt.want, AlertsSentByRancher(&v32.NotifierSpec{SMTPConfig: &cfg}))
+		})
+	}
+}
@@ -2,18 +2,25 @@
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
 	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/etcdretention"
+	provisioningcontrollers "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
 	rkecontroller "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/wrangler"
 	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	capi "sigs.k8s.io/cluster-api/api/v1alpha4"
 )
 
 type etcdCreate struct {
 	controlPlane rkecontroller.RKEControlPlaneClient
+	clusters     provisioningcontrollers.ClusterCache
 	secrets      corecontrollers.SecretCache
 	store        *PlanStore
 	s3Args       *s3Args
@@ -22,6 +29,7 @@ type etcdCreate struct {
 func newETCDCreate(clients *wrangler.Context, store *PlanStore) *etcdCreate {
 	return &etcdCreate{
 		controlPlane: clients.RKE.RKEControlPlane(),
+		clusters:     clients.Provisioning.Cluster().Cache(),
 		secrets:      clients.Core.Secret().Cache(),
 		store:        store,
 		s3Args: &s3Args{
@@ -107,12 +115,212 @@ func (e *etcdCreate) createPlan(controlPlane *rkev1.RKEControlPlane, snapshot *r
 	})
 }
 
+// prunePlan deletes snapshots on a node, the local snapshots of the node and the snapshots in
+// the S3 bucket of the scheduled snapshots.
+func (e *etcdCreate) prunePlan(controlPlane *rkev1.RKEControlPlane, names []string) (plan.NodePlan, error) {
+	s3Args, s3Env, s3Files, err := e.s3Args.ToArgs(controlPlane.Spec.ETCD.S3, controlPlane)
+	if err != nil {
+		return plan.NodePlan{}, err
+	}
+
+	args := append([]string{"etcd-snapshot", "delete"}, s3Args...)
+	return commonNodePlan(e.secrets, controlPlane, plan.NodePlan{
+		Files: s3Files,
+		Instructions: []plan.Instruction{{
+			Name:    "prune",
+			Image:   getInstallerImage(controlPlane),
+			Command: GetRuntimeCommand(controlPlane.Spec.KubernetesVersion),
+			Env:     s3Env,
+			Args:    append(args, names...),
+		}},
+	})
+}
+
+// prune deletes the snapshots of the preview on the etcd nodes, the local snapshots on the node
+// that took them and the snapshots in S3 on the first etcd node. The pruned snapshots are kept
+// in the status until the snapshots of the cluster no longer list them so they are pruned once.
+// Every prune replaces the plans of the etcd nodes, so snapshots are pruned in batches at most
+// once per the longest interval of the retention tiers.
+func (e *etcdCreate) prune(controlPlane *rkev1.RKEControlPlane, clusterPlan *plan.Plan) error {
+	if len(controlPlane.Status.ETCDSnapshotsToPrune) == 0 || !pruneDue(controlPlane, time.Now()) {
+		return nil
+	}
+	snapshots, err := e.getSnapshots(controlPlane)
+	if err != nil {
+		return err
+	}
+	toPrune := map[string]bool{}
+	for _, name := range controlPlane.Status.ETCDSnapshotsToPrune {
+		toPrune[name] = true
+	}
+
+	servers := collect(clusterPlan, func(machine *capi.Machine) bool {
+		return isEtcd(machine) && machine.Status.NodeRef != nil
+	})
+	for i, server := range servers {
+		var names []string
+		for _, snapshot := range snapshots {
+			if !toPrune[snapshot.Name] {
+				continue
+			}
+			if (snapshot.S3 != nil && i == 0) || (snapshot.S3 == nil && snapshot.NodeName == server.Machine.Status.NodeRef.Name) {
+				names = append(names, snapshot.Name)
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		sort.Strings(names)
+		prunePlan, err := e.prunePlan(controlPlane, names)
+		if err != nil {
+			return err
+		}
+		if err := assignAndCheckPlan(e.store, "etcd snapshot prune", server, prunePlan); err != nil {
+			return err
+		}
+	}
+
+	controlPlane = controlPlane.DeepCopy()
+	controlPlane.Status.ETCDSnapshotsPruned = append(controlPlane.Status.ETCDSnapshotsPruned, controlPlane.Status.ETCDSnapshotsToPrune...)
+	sort.Strings(controlPlane.Status.ETCDSnapshotsPruned)
+	controlPlane.Status.ETCDSnapshotsToPrune = nil
+	controlPlane.Status.ETCDSnapshotsPrunedAt = &metav1.Time{Time: time.Now()}
+	if _, err := e.controlPlane.UpdateStatus(controlPlane); err != nil {
+		return err
+	}
+	return ErrWaiting("refreshing etcd snapshot prune state")
+}
+
+// pruneDue returns whether the longest interval of the retention tiers has passed since the
+// snapshots were last pruned.
+func pruneDue(controlPlane *rkev1.RKEControlPlane, now time.Time) bool {
+	prunedAt := controlPlane.Status.ETCDSnapshotsPrunedAt
+	if prunedAt == nil || controlPlane.Spec.ETCD == nil {
+		return true
+	}
+	var interval time.Duration
+	for _, tier := range controlPlane.Spec.ETCD.SnapshotRetentionTiers {
+		if tierInterval := time.Duration(tier.IntervalHours) * time.Hour; tier.Keep > 0 && tierInterval > interval {
+			interval = tierInterval
+		}
+	}
+	return now.Sub(prunedAt.Time) >= interval
+}
+
+func (e *etcdCreate) getSnapshots(controlPlane *rkev1.RKEControlPlane) ([]rkev1.ETCDSnapshot, error) {
+	return getETCDSnapshots(e.clusters, controlPlane)
+}
+
+// getETCDSnapshots returns the snapshots listed in the status of the cluster of the control plane.
+func getETCDSnapshots(clusters provisioningcontrollers.ClusterCache, controlPlane *rkev1.RKEControlPlane) ([]rkev1.ETCDSnapshot, error) {
+	cluster, err := clusters.Get(controlPlane.Namespace, controlPlane.Spec.ClusterName)
+	if apierror.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return cluster.Status.ETCDSnapshots, nil
+}
+
+// updatePrunePreview sets the snapshots the retention tiers prune in the status of the control
+// plane and returns the updated control plane. Snapshots that were pruned but are still listed
+// in the status of the cluster are not pruned again.
+func (e *etcdCreate) updatePrunePreview(controlPlane *rkev1.RKEControlPlane) (*rkev1.RKEControlPlane, error) {
+	var toPrune, pruned []string
+	if controlPlane.Spec.ETCD != nil && len(controlPlane.Spec.ETCD.SnapshotRetentionTiers) > 0 {
+		snapshots, err := e.getSnapshots(controlPlane)
+		if err != nil {
+			return controlPlane, err
+		}
+		listed := map[string]bool{}
+		for _, snapshot := range snapshots {
+			listed[snapshot.Name] = true
+		}
+		alreadyPruned := map[string]bool{}
+		for _, name := range controlPlane.Status.ETCDSnapshotsPruned {
+			if listed[name] {
+				pruned = append(pruned, name)
+				alreadyPruned[name] = true
+			}
+		}
+		for _, name := range snapshotsToPrune(controlPlane.Spec.ETCD.SnapshotRetentionTiers, snapshots) {
+			if !alreadyPruned[name] {
+				toPrune = append(toPrune, name)
+			}
+		}
+	}
+
+	if equality.Semantic.DeepEqual(toPrune, controlPlane.Status.ETCDSnapshotsToPrune) &&
+		equality.Semantic.DeepEqual(pruned, controlPlane.Status.ETCDSnapshotsPruned) {
+		return controlPlane, nil
+	}
+	controlPlane = controlPlane.DeepCopy()
+	controlPlane.Status.ETCDSnapshotsToPrune = toPrune
+	controlPlane.Status.ETCDSnapshotsPruned = pruned
+	return e.controlPlane.UpdateStatus(controlPlane)
+}
+
+// snapshotsToPrune returns the names of the snapshots the retention tiers prune. The snapshots of
+// each node and the snapshots in S3 are separate series, every etcd node takes the scheduled
+// snapshots.
+func snapshotsToPrune(tiers []rkev1.ETCDSnapshotRetentionTier, snapshots []rkev1.ETCDSnapshot) []string {
+	retentionTiers := make([]etcdretention.Tier, 0, len(tiers))
+	for _, tier := range tiers {
+		retentionTiers = append(retentionTiers, etcdretention.Tier{
+			Interval: time.Duration(tier.IntervalHours) * time.Hour,
+			Keep:     tier.Keep,
+		})
+	}
+
+	series := map[string][]etcdretention.Snapshot{}
+	for _, snapshot := range snapshots {
+		if snapshot.CreatedAt == nil {
+			continue
+		}
+		key := "node:" + snapshot.NodeName
+		if snapshot.S3 != nil {
+			key = "s3"
+		}
+		series[key] = append(series[key], etcdretention.Snapshot{
+			Name:    snapshot.Name,
+			Created: snapshot.CreatedAt.Time,
+		})
+	}
+
+	prune := map[string]bool{}
+	for _, snapshots := range series {
+		for _, snapshot := range etcdretention.Prune(retentionTiers, snapshots) {
+			prune[snapshot.Name] = true
+		}
+	}
+	var result []string
+	for name := range prune {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Create takes the snapshot of the ETCDSnapshotCreate spec and prunes the snapshots by the
+// retention tiers. Scheduled and created snapshots are pruned once the status of the cluster
+// lists them, never while a snapshot is created.
 func (e *etcdCreate) Create(controlPlane *rkev1.RKEControlPlane, clusterPlan *plan.Plan) error {
+	if controlPlane.Status.ETCDSnapshotCreatePhase != rkev1.ETCDSnapshotPhaseStarted {
+		var err error
+		if controlPlane, err = e.updatePrunePreview(controlPlane); err != nil {
+			return err
+		}
+	}
+
 	if controlPlane.Spec.ETCDSnapshotCreate == nil {
-		return e.resetEtcdCreateState(controlPlane)
+		if err := e.resetEtcdCreateState(controlPlane); err != nil {
+			return err
+		}
+	} else if err := e.createSnapshot(controlPlane, clusterPlan, controlPlane.Spec.ETCDSnapshotCreate); err != nil {
+		return err
 	}
 
-	return e.createSnapshot(controlPlane, clusterPlan, controlPlane.Spec.ETCDSnapshotCreate)
+	return e.prune(controlPlane, clusterPlan)
 }
 
 func (e *etcdCreate) createSnapshot(controlPlane *rkev1.RKEControlPlane, clusterPlan *plan.Plan, snapshot *rkev1.ETCDSnapshotCreate) error {
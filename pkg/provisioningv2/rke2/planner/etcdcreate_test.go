@@ -0,0 +1,29 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPruneDue(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	controlPlane := &rkev1.RKEControlPlane{}
+	controlPlane.Spec.ETCD = &rkev1.ETCD{
+		SnapshotRetentionTiers: []rkev1.ETCDSnapshotRetentionTier{
+			{IntervalHours: 1, Keep: 24},
+			{IntervalHours: 24, Keep: 7},
+			{IntervalHours: 720, Keep: 0},
+		},
+	}
+	assert.True(t, pruneDue(controlPlane, now), "never pruned")
+
+	controlPlane.Status.ETCDSnapshotsPrunedAt = &metav1.Time{Time: now.Add(-23 * time.Hour)}
+	assert.False(t, pruneDue(controlPlane, now), "pruned within the longest interval")
+
+	controlPlane.Status.ETCDSnapshotsPrunedAt = &metav1.Time{Time: now.Add(-24 * time.Hour)}
+	assert.True(t, pruneDue(controlPlane, now))
+}
@@ -0,0 +1,235 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/etcddrill"
+	provisioningcontrollers "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
+	rkecontroller "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/wrangler"
+	"github.com/rancher/wrangler/pkg/condition"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha4"
+)
+
+const (
+	restoreDrillInstruction     = "restore-drill"
+	defaultRestoreDrillInterval = 24 * time.Hour
+	// restoreDrillFailed prefixes the output of a drill that failed on the node
+	restoreDrillFailed = "restore drill failed: "
+	// restoreDrillMemoryOverhead is added to the size of the snapshot for the memory limit of the
+	// drill container
+	restoreDrillMemoryOverhead = 512 * 1024 * 1024
+)
+
+var (
+	// RestoreDrilled is the result of the last restore drill of the control plane
+	RestoreDrilled = condition.Cond("RestoreDrilled")
+	// RestoreDrillAlerted is set to Unknown when a drill finished, the alert watcher of the
+	// cluster sets it once the result was alerted
+	RestoreDrillAlerted = condition.Cond("RestoreDrillAlerted")
+)
+
+// restoreDrillCommand restores the local snapshot $SNAPSHOT into a container of the etcd image of
+// the node, started through the containerd of RKE2 and running $SCRIPT. The container has no
+// network and its memory is limited to the size of the snapshot plus an overhead. The command
+// doesn't fail so the plan is applied and its output saved, a failed drill prints the failure
+// prefix and the last line of the error instead of the restored data.
+var restoreDrillCommand = fmt.Sprintf(`fail() { echo "%[1]s$1"; exit 0; }
+snapshot=/var/lib/rancher/rke2/server/db/snapshots/$SNAPSHOT
+[ -f "$snapshot" ] || fail "snapshot $SNAPSHOT not found"
+image=$(sed -n 's/^ *image: *//p' /var/lib/rancher/rke2/agent/pod-manifests/etcd.yaml | head -n 1)
+[ -n "$image" ] || fail "etcd image not found"
+memory=$(($(stat -c %%s "$snapshot") + %[2]d))
+errors=$(mktemp)
+trap 'rm -f "$errors"' EXIT
+if ! output=$(/var/lib/rancher/rke2/bin/ctr --address /run/k3s/containerd/containerd.sock -n k8s.io run --rm \
+  --memory-limit "$memory" --cpus 1 --env ETCDCTL_API=3 \
+  --mount "type=bind,src=$snapshot,dst=/snapshot.db,options=rbind:ro" \
+  "$image" "etcd-restore-drill-$$" /bin/sh -c "$SCRIPT" 2>"$errors"); then
+  fail "$(tail -n 1 "$errors")"
+fi
+echo "$output"
+`, restoreDrillFailed, restoreDrillMemoryOverhead)
+
+type etcdDrill struct {
+	controlPlane rkecontroller.RKEControlPlaneClient
+	clusters     provisioningcontrollers.ClusterCache
+	secrets      corecontrollers.SecretCache
+	store        *PlanStore
+}
+
+func newETCDDrill(clients *wrangler.Context, store *PlanStore) *etcdDrill {
+	return &etcdDrill{
+		controlPlane: clients.RKE.RKEControlPlane(),
+		clusters:     clients.Provisioning.Cluster().Cache(),
+		secrets:      clients.Core.Secret().Cache(),
+		store:        store,
+	}
+}
+
+func (e *etcdDrill) setState(controlPlane *rkev1.RKEControlPlane, snapshot *rkev1.ETCDSnapshot, phase rkev1.ETCDSnapshotPhase) error {
+	controlPlane = controlPlane.DeepCopy()
+	controlPlane.Status.ETCDSnapshotRestoreDrillPhase = phase
+	controlPlane.Status.ETCDSnapshotRestoreDrill = snapshot
+	_, err := e.controlPlane.UpdateStatus(controlPlane)
+	if err != nil {
+		return err
+	}
+	return ErrWaiting("refreshing etcd restore drill state")
+}
+
+// Drill restores the newest local snapshot of a provisioned cluster when a drill is due. The
+// drill replaces the plan of the etcd node that took the snapshot until the drill finished, like
+// creating a snapshot does.
+func (e *etcdDrill) Drill(controlPlane *rkev1.RKEControlPlane, clusterPlan *plan.Plan) error {
+	if controlPlane.Status.ETCDSnapshotRestoreDrillPhase == rkev1.ETCDSnapshotPhaseStarted {
+		return e.runDrill(controlPlane, clusterPlan, controlPlane.Status.ETCDSnapshotRestoreDrill)
+	}
+
+	drill := restoreDrill(controlPlane)
+	if !drill.Enabled || !controlPlane.Status.Ready || controlPlane.Spec.ETCDSnapshotRestore != nil {
+		return nil
+	}
+	if GetRuntime(controlPlane.Spec.KubernetesVersion) != RuntimeRKE2 {
+		return e.setUnsupported(controlPlane)
+	}
+
+	snapshots, err := getETCDSnapshots(e.clusters, controlPlane)
+	if err != nil {
+		return err
+	}
+	snapshot := nextRestoreDrill(controlPlane, restoreDrillInterval(drill), snapshots, time.Now())
+	if snapshot == nil {
+		return nil
+	}
+	return e.setState(controlPlane, snapshot, rkev1.ETCDSnapshotPhaseStarted)
+}
+
+func (e *etcdDrill) setUnsupported(controlPlane *rkev1.RKEControlPlane) error {
+	const message = "restore drills are only supported for RKE2"
+	if RestoreDrilled.IsUnknown(controlPlane) && RestoreDrilled.GetMessage(controlPlane) == message {
+		return nil
+	}
+	controlPlane = controlPlane.DeepCopy()
+	RestoreDrilled.Unknown(controlPlane)
+	RestoreDrilled.Message(controlPlane, message)
+	_, err := e.controlPlane.UpdateStatus(controlPlane)
+	return err
+}
+
+// runDrill assigns the drill plan to the etcd node of the snapshot and records the result once
+// the node applied it.
+func (e *etcdDrill) runDrill(controlPlane *rkev1.RKEControlPlane, clusterPlan *plan.Plan, snapshot *rkev1.ETCDSnapshot) error {
+	servers := collect(clusterPlan, func(machine *capi.Machine) bool {
+		return isEtcd(machine) && machine.Status.NodeRef != nil &&
+			machine.Status.NodeRef.Name == snapshot.NodeName
+	})
+	if len(servers) == 0 {
+		return e.finish(controlPlane, snapshot, nil, fmt.Errorf("etcd node [%s] of the snapshot no longer exists", snapshot.NodeName))
+	}
+
+	drillPlan, err := e.drillPlan(controlPlane, snapshot)
+	if err != nil {
+		return err
+	}
+	if err := assignAndCheckPlan(e.store, "etcd restore drill", servers[0], drillPlan); err != nil {
+		return err
+	}
+
+	result, err := parseRestoreDrillOutput(servers[0].Plan.Output[restoreDrillInstruction])
+	if err == nil {
+		drill := restoreDrill(controlPlane)
+		err = result.Check(drill.MinKeys, drill.RequiredNamespaces)
+	}
+	return e.finish(controlPlane, snapshot, result, err)
+}
+
+func (e *etcdDrill) drillPlan(controlPlane *rkev1.RKEControlPlane, snapshot *rkev1.ETCDSnapshot) (plan.NodePlan, error) {
+	if snapshot.Name == "" || strings.Contains(snapshot.Name, "/") {
+		return plan.NodePlan{}, fmt.Errorf("invalid etcd snapshot name [%s]", snapshot.Name)
+	}
+	return commonNodePlan(e.secrets, controlPlane, plan.NodePlan{
+		Instructions: []plan.Instruction{{
+			Name:    restoreDrillInstruction,
+			Image:   getInstallerImage(controlPlane),
+			Command: "sh",
+			Args:    []string{"-c", restoreDrillCommand},
+			Env: []string{
+				"SNAPSHOT=" + snapshot.Name,
+				"SCRIPT=" + etcddrill.Script,
+			},
+			SaveOutput: true,
+		}},
+	})
+}
+
+// finish records the result of a drill in the RestoreDrilled condition, the alert watcher of the
+// cluster alerts on it.
+func (e *etcdDrill) finish(controlPlane *rkev1.RKEControlPlane, snapshot *rkev1.ETCDSnapshot, result *etcddrill.Result, drillErr error) error {
+	controlPlane = controlPlane.DeepCopy()
+	if drillErr != nil {
+		RestoreDrilled.False(controlPlane)
+		RestoreDrilled.Message(controlPlane, fmt.Sprintf("restore drill of snapshot %s failed: %v", snapshot.Name, drillErr))
+	} else {
+		RestoreDrilled.True(controlPlane)
+		RestoreDrilled.Message(controlPlane, fmt.Sprintf("restored %d keys of snapshot %s at revision %d", result.Keys, snapshot.Name, result.Revision))
+	}
+	RestoreDrilled.LastUpdated(controlPlane, time.Now().UTC().Format(time.RFC3339))
+	RestoreDrillAlerted.Unknown(controlPlane)
+	return e.setState(controlPlane, snapshot, rkev1.ETCDSnapshotPhaseFinished)
+}
+
+func parseRestoreDrillOutput(output []byte) (*etcddrill.Result, error) {
+	if len(output) == 0 {
+		return nil, fmt.Errorf("restore drill has no output")
+	}
+	if message := strings.TrimSpace(string(output)); strings.HasPrefix(message, restoreDrillFailed) {
+		return nil, fmt.Errorf("%s", strings.TrimPrefix(message, restoreDrillFailed))
+	}
+	return etcddrill.ParseOutput(string(output))
+}
+
+func restoreDrill(controlPlane *rkev1.RKEControlPlane) *rkev1.ETCDRestoreDrill {
+	if controlPlane.Spec.ETCD == nil || controlPlane.Spec.ETCD.RestoreDrill == nil {
+		return &rkev1.ETCDRestoreDrill{}
+	}
+	return controlPlane.Spec.ETCD.RestoreDrill
+}
+
+func restoreDrillInterval(drill *rkev1.ETCDRestoreDrill) time.Duration {
+	if drill.IntervalHours < 1 {
+		return defaultRestoreDrillInterval
+	}
+	return time.Duration(drill.IntervalHours) * time.Hour
+}
+
+// nextRestoreDrill returns the newest local snapshot if it wasn't drilled yet and the last drill
+// of the control plane is older than the interval. Snapshots in S3 are uploads of the local
+// snapshots and are not drilled.
+func nextRestoreDrill(controlPlane *rkev1.RKEControlPlane, interval time.Duration, snapshots []rkev1.ETCDSnapshot, now time.Time) *rkev1.ETCDSnapshot {
+	if drilled, err := time.Parse(time.RFC3339, RestoreDrilled.GetLastUpdated(controlPlane)); err == nil && now.Sub(drilled) < interval {
+		return nil
+	}
+
+	var newest *rkev1.ETCDSnapshot
+	for i, snapshot := range snapshots {
+		if snapshot.S3 != nil || snapshot.NodeName == "" || snapshot.CreatedAt == nil {
+			continue
+		}
+		if newest == nil || snapshot.CreatedAt.After(newest.CreatedAt.Time) {
+			newest = &snapshots[i]
+		}
+	}
+	if newest == nil {
+		return nil
+	}
+	if last := controlPlane.Status.ETCDSnapshotRestoreDrill; last != nil && last.Name == newest.Name && last.NodeName == newest.NodeName {
+		return nil
+	}
+	return newest.DeepCopy()
+}
@@ -0,0 +1,71 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNextRestoreDrill(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	snapshot := func(name, nodeName string, age time.Duration, s3 bool) rkev1.ETCDSnapshot {
+		result := rkev1.ETCDSnapshot{
+			Name:      name,
+			NodeName:  nodeName,
+			CreatedAt: &metav1.Time{Time: now.Add(-age)},
+		}
+		if s3 {
+			result.S3 = &rkev1.ETCDSnapshotS3{Bucket: "snapshots"}
+		}
+		return result
+	}
+	snapshots := []rkev1.ETCDSnapshot{
+		snapshot("old", "etcd-1", 2*time.Hour, false),
+		snapshot("newest", "etcd-2", time.Hour, false),
+		snapshot("uploaded", "etcd-2", 30*time.Minute, true),
+		{Name: "pending", NodeName: "etcd-1"},
+	}
+
+	controlPlane := &rkev1.RKEControlPlane{}
+	next := nextRestoreDrill(controlPlane, 24*time.Hour, snapshots, now)
+	require.NotNil(t, next)
+	assert.Equal(t, "newest", next.Name)
+	assert.Equal(t, "etcd-2", next.NodeName)
+
+	controlPlane.Status.ETCDSnapshotRestoreDrill = next
+	assert.Nil(t, nextRestoreDrill(controlPlane, 24*time.Hour, snapshots, now), "snapshot was drilled")
+
+	controlPlane.Status.ETCDSnapshotRestoreDrill = snapshots[0].DeepCopy()
+	RestoreDrilled.True(controlPlane)
+	RestoreDrilled.LastUpdated(controlPlane, now.Add(-2*time.Hour).Format(time.RFC3339))
+	assert.Nil(t, nextRestoreDrill(controlPlane, 24*time.Hour, snapshots, now), "interval has not passed")
+	assert.NotNil(t, nextRestoreDrill(controlPlane, time.Hour, snapshots, now))
+
+	assert.Nil(t, nextRestoreDrill(&rkev1.RKEControlPlane{}, time.Hour, snapshots[2:], now), "no local snapshot")
+}
+
+func TestParseRestoreDrillOutput(t *testing.T) {
+	result, err := parseRestoreDrillOutput([]byte(`{"header":{"revision":4711},"count":1234}
+/registry/namespaces/default
+/registry/namespaces/kube-system
+`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4711), result.Revision)
+	assert.Equal(t, int64(1234), result.Keys)
+	assert.Equal(t, []string{"default", "kube-system"}, result.Namespaces)
+
+	_, err = parseRestoreDrillOutput([]byte(restoreDrillFailed + "snapshot etcd-snapshot-1 not found\n"))
+	assert.EqualError(t, err, "snapshot etcd-snapshot-1 not found")
+	_, err = parseRestoreDrillOutput(nil)
+	assert.Error(t, err)
+}
+
+func TestDrillPlan(t *testing.T) {
+	e := &etcdDrill{}
+	_, err := e.drillPlan(&rkev1.RKEControlPlane{}, &rkev1.ETCDSnapshot{Name: "../../etc/shadow"})
+	assert.Error(t, err)
+}
@@ -2,11 +2,15 @@
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/rancher/channelserver/pkg/model"
 	"github.com/rancher/norman/types/convert"
 	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/channelserver"
+	"k8s.io/apimachinery/pkg/api/equality"
 	capi "sigs.k8s.io/cluster-api/api/v1alpha4"
 )
 
@@ -57,3 +61,71 @@ func filterField(isServer bool, k string, v interface{}, release model.Release)
 
 	return v, true
 }
+
+// ValidateConfig returns an error naming the keys of the machine config of the control plane that
+// are not arguments of its runtime release. The keys can't be validated if the release data of the
+// runtime isn't loaded. On an update old is the stored control plane and only the keys the update
+// adds or changes are checked, keys the stored config already has with the same value are kept
+// even if the release doesn't list them.
+//
+// The validation only runs in the steve API of rancher, clusters written to the Kubernetes API
+// directly, e.g. with kubectl, are not validated and the planner drops their unknown keys.
+func ValidateConfig(controlPlane, old *rkev1.RKEControlPlane) error {
+	release := channelserver.GetReleaseConfigByRuntimeAndVersion(context.TODO(),
+		GetRuntime(controlPlane.Spec.KubernetesVersion),
+		controlPlane.Spec.KubernetesVersion)
+	return validateConfigKeys(controlPlane, old, release)
+}
+
+func validateConfigKeys(controlPlane, old *rkev1.RKEControlPlane, release model.Release) error {
+	if len(release.AgentArgs) == 0 && len(release.ServerArgs) == 0 {
+		return nil
+	}
+
+	var stored []map[string]interface{}
+	if old != nil {
+		stored = append(stored, old.Spec.MachineGlobalConfig.Data)
+		for _, opts := range old.Spec.MachineSelectorConfig {
+			stored = append(stored, opts.Config.Data)
+		}
+	}
+	unchanged := func(k string, v interface{}) bool {
+		for _, data := range stored {
+			if storedValue, ok := data[k]; ok && equality.Semantic.DeepEqual(storedValue, v) {
+				return true
+			}
+		}
+		return false
+	}
+
+	unknown := map[string]bool{}
+	check := func(data map[string]interface{}) {
+		for k, v := range data {
+			if unchanged(k, v) {
+				continue
+			}
+			if _, ok := release.AgentArgs[k]; ok {
+				continue
+			}
+			if _, ok := release.ServerArgs[k]; ok {
+				continue
+			}
+			unknown[k] = true
+		}
+	}
+	check(controlPlane.Spec.MachineGlobalConfig.Data)
+	for _, opts := range controlPlane.Spec.MachineSelectorConfig {
+		check(opts.Config.Data)
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	var keys []string
+	for k := range unknown {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return fmt.Errorf("unknown config keys for %s %s: %s", GetRuntime(controlPlane.Spec.KubernetesVersion),
+		controlPlane.Spec.KubernetesVersion, strings.Join(keys, ", "))
+}
@@ -0,0 +1,81 @@
+package planner
+
+import (
+	"testing"
+
+	"github.com/rancher/channelserver/pkg/model"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfigKeys(t *testing.T) {
+	release := model.Release{
+		Version:    "v1.21.5+rke2r2",
+		AgentArgs:  map[string]schemas.Field{"node-label": {Type: "array[string]"}, "protect-kernel-defaults": {Type: "boolean"}},
+		ServerArgs: map[string]schemas.Field{"cni": {Type: "string"}, "disable": {Type: "array[string]"}},
+	}
+	tests := []struct {
+		name       string
+		global     map[string]interface{}
+		selector   map[string]interface{}
+		stored     map[string]interface{}
+		release    model.Release
+		errMessage string
+	}{
+		{
+			name:     "agent and server args",
+			global:   map[string]interface{}{"cni": "calico", "protect-kernel-defaults": true},
+			selector: map[string]interface{}{"node-label": []interface{}{"a=b"}},
+			release:  release,
+		},
+		{
+			name:       "unknown keys of the global and selector config",
+			global:     map[string]interface{}{"cni": "calico", "kube-apiserver-arg-typo": "x"},
+			selector:   map[string]interface{}{"node-labels": []interface{}{"a=b"}},
+			release:    release,
+			errMessage: "unknown config keys for rke2 v1.21.5+rke2r2: kube-apiserver-arg-typo, node-labels",
+		},
+		{
+			name:     "unknown keys that are stored already",
+			global:   map[string]interface{}{"cni": "calico", "kube-apiserver-arg-typo": "x"},
+			selector: map[string]interface{}{"node-labels": []interface{}{"a=b"}},
+			stored:   map[string]interface{}{"kube-apiserver-arg-typo": "x", "node-labels": []interface{}{"a=b"}},
+			release:  release,
+		},
+		{
+			name:       "unknown keys that the update changes",
+			global:     map[string]interface{}{"cni": "calico", "kube-apiserver-arg-typo": "y"},
+			selector:   map[string]interface{}{"node-labels": []interface{}{"a=b"}},
+			stored:     map[string]interface{}{"kube-apiserver-arg-typo": "x", "node-labels": []interface{}{"a=b"}},
+			release:    release,
+			errMessage: "unknown config keys for rke2 v1.21.5+rke2r2: kube-apiserver-arg-typo",
+		},
+		{
+			name:    "release data isn't loaded",
+			global:  map[string]interface{}{"kube-apiserver-arg-typo": "x"},
+			release: model.Release{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controlPlane := &rkev1.RKEControlPlane{}
+			controlPlane.Spec.KubernetesVersion = "v1.21.5+rke2r2"
+			controlPlane.Spec.MachineGlobalConfig = rkev1.GenericMap{Data: tt.global}
+			controlPlane.Spec.MachineSelectorConfig = []rkev1.RKESystemConfig{{Config: rkev1.GenericMap{Data: tt.selector}}}
+
+			var old *rkev1.RKEControlPlane
+			if tt.stored != nil {
+				old = &rkev1.RKEControlPlane{}
+				old.Spec.MachineGlobalConfig = rkev1.GenericMap{Data: tt.stored}
+			}
+
+			err := validateConfigKeys(controlPlane, old, tt.release)
+			if tt.errMessage == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.errMessage)
+			}
+		})
+	}
+}
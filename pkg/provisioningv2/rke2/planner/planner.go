@@ -12,6 +12,7 @@
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/moby/locker"
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
@@ -65,6 +66,10 @@
 	SecretTypeMachinePlan = "rke.cattle.io/machine-plan"
 
 	authnWebhookFileName = "/var/lib/rancher/%s/kube-api-authn-webhook.yaml"
+
+	// tieredSnapshotRetention is high enough that the etcd nodes don't prune snapshots that the
+	// retention tiers keep
+	tieredSnapshotRetention = 100000
 )
 
 var (
@@ -74,6 +79,7 @@
 		"private-registry",
 		"flannel-conf",
 	}
+	indexersOnce sync.Once
 	AuthnWebhook = []byte(`
 apiVersion: v1
 kind: Config
@@ -112,7 +118,7 @@ func (e errIgnore) Error() string {
 type Planner struct {
 	ctx                           context.Context
 	store                         *PlanStore
-	rkeControlPlanes              rkecontrollers.RKEControlPlaneClient
+	rkeControlPlanes              rkecontrollers.RKEControlPlaneController
 	secretClient                  corecontrollers.SecretClient
 	secretCache                   corecontrollers.SecretCache
 	machines                      capicontrollers.MachineClient
@@ -123,13 +129,12 @@ type Planner struct {
 	locker                        locker.Locker
 	etcdRestore                   *etcdRestore
 	etcdCreate                    *etcdCreate
+	etcdDrill                     *etcdDrill
 	etcdArgs                      s3Args
 }
 
 func New(ctx context.Context, clients *wrangler.Context) *Planner {
-	clients.Mgmt.ClusterRegistrationToken().Cache().AddIndexer(clusterRegToken, func(obj *v3.ClusterRegistrationToken) ([]string, error) {
-		return []string{obj.Spec.ClusterName}, nil
-	})
+	registerIndexers(clients)
 	store := NewStore(clients.Core.Secret(),
 		clients.CAPI.Machine().Cache())
 	return &Planner{
@@ -145,9 +150,20 @@ func New(ctx context.Context, clients *wrangler.Context) *Planner {
 		kubeconfig:                    kubeconfig.New(clients),
 		etcdRestore:                   newETCDRestore(clients, store),
 		etcdCreate:                    newETCDCreate(clients, store),
+		etcdDrill:                     newETCDDrill(clients, store),
 	}
 }
 
+// registerIndexers adds the indexes of the planner to the caches, the planner may be created by
+// both the controllers and the API.
+func registerIndexers(clients *wrangler.Context) {
+	indexersOnce.Do(func() {
+		clients.Mgmt.ClusterRegistrationToken().Cache().AddIndexer(clusterRegToken, func(obj *v3.ClusterRegistrationToken) ([]string, error) {
+			return []string{obj.Spec.ClusterName}, nil
+		})
+	})
+}
+
 func PlanSecretFromBootstrapName(bootstrapName string) string {
 	return name.SafeConcatName(bootstrapName, "machine", "plan")
 }
@@ -197,6 +213,10 @@ func (p *Planner) Process(controlPlane *rkev1.RKEControlPlane) error {
 		return err
 	}
 
+	if err := p.etcdDrill.Drill(controlPlane, plan); err != nil {
+		return err
+	}
+
 	if _, err := p.electInitNode(controlPlane, plan); err != nil {
 		return err
 	}
@@ -254,6 +274,32 @@ func (p *Planner) Process(controlPlane *rkev1.RKEControlPlane) error {
 	return nil
 }
 
+// DriftMessage lists the machines of the control plane with files that were changed out-of-band,
+// it is empty if no files drifted. Reported is false if no machine reports the hashes of its files.
+func (p *Planner) DriftMessage(controlPlane *rkev1.RKEControlPlane) (message string, reported bool, _ error) {
+	cluster, err := p.getCAPICluster(controlPlane)
+	if err != nil {
+		return "", false, err
+	}
+
+	plan, err := p.store.Load(cluster)
+	if err != nil {
+		return "", false, err
+	}
+
+	var drifted []string
+	for _, entry := range collect(plan, func(*capi.Machine) bool { return true }) {
+		if fileHashes(entry.Plan) == nil {
+			continue
+		}
+		reported = true
+		if files := DriftedFiles(entry.Plan); len(files) > 0 {
+			drifted = append(drifted, fmt.Sprintf("%s (%s)", entry.Machine.Name, strings.Join(files, ", ")))
+		}
+	}
+	return strings.Join(drifted, ", "), reported, nil
+}
+
 func ignoreErrors(firstIgnoreError error, err error) (error, error) {
 	var errIgnore errIgnore
 	if errors.As(err, &errIgnore) {
@@ -421,6 +467,8 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, secret plan.Sec
 		errMachines []string
 		draining    []string
 		uncordoned  []string
+		pending     []string
+		included    []planEntry
 		messages    = map[string]string{}
 	)
 
@@ -430,12 +478,14 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, secret plan.Sec
 	if err != nil {
 		return err
 	}
+	batch := rolloutBatch(controlPlane, tierName)
 
 	for _, entry := range entries {
 		// we exclude here and not in collect to ensure that include matched at least one node
 		if exclude(entry.Machine) {
 			continue
 		}
+		included = append(included, entry)
 
 		summary := summary.Summarize(entry.Machine)
 		if summary.Error {
@@ -463,7 +513,14 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, secret plan.Sec
 			//    the node will have already been considered unavailable.
 			// 2. concurrency == 0 which means infinite concurrency.
 			// 3. unavailable < concurrency meaning we have capacity to make something unavailable
-			if !entry.Plan.InSync || concurrency == 0 || unavailable < concurrency {
+			// With a rollout strategy the machines of the current batch are upgraded instead of
+			// conditions 2 and 3.
+			pending = append(pending, entry.Machine.Name)
+			upgrade := !entry.Plan.InSync || concurrency == 0 || unavailable < concurrency
+			if batch != nil {
+				upgrade = !entry.Plan.InSync || batch[entry.Machine.Name]
+			}
+			if upgrade {
 				if entry.Plan.InSync {
 					unavailable++
 				}
@@ -479,6 +536,21 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, secret plan.Sec
 			}
 		} else if !entry.Plan.InSync {
 			outOfSync = append(outOfSync, entry.Machine.Name)
+		} else if controlPlane.Spec.ReconcileDrift && len(DriftedFiles(entry.Plan)) > 0 {
+			// a node with drifted files waits for the concurrency or the rollout like a plan change,
+			// applying the plan again makes the node unavailable like an upgrade
+			pending = append(pending, entry.Machine.Name)
+			reset := concurrency == 0 || unavailable < concurrency
+			if batch != nil {
+				reset = batch[entry.Machine.Name]
+			}
+			if reset {
+				unavailable++
+				outOfSync = append(outOfSync, entry.Machine.Name)
+				if err := p.store.ResetAppliedPlan(entry.Machine); err != nil {
+					return err
+				}
+			}
 		} else {
 			if ok, err := p.undrain(entry.Machine); err != nil {
 				return err
@@ -492,6 +564,12 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, secret plan.Sec
 		return ErrWaiting("waiting for at least one " + tierName + " node")
 	}
 
+	if batch != nil {
+		if err := p.rollout(controlPlane, tierName, included, pending, concurrency); err != nil {
+			return err
+		}
+	}
+
 	errMachines = atMostThree(errMachines)
 	if len(errMachines) > 0 {
 		// we want these errors to get reported, but not block the process
@@ -541,7 +619,10 @@ func (p *Planner) addETCD(config map[string]interface{}, controlPlane *rkev1.RKE
 	if controlPlane.Spec.ETCD.DisableSnapshots {
 		config["etcd-disable-snapshot"] = true
 	}
-	if controlPlane.Spec.ETCD.SnapshotRetention > 0 {
+	if len(controlPlane.Spec.ETCD.SnapshotRetentionTiers) > 0 {
+		// the snapshots are pruned by the retention tiers, not by the etcd nodes
+		config["etcd-snapshot-retention"] = tieredSnapshotRetention
+	} else if controlPlane.Spec.ETCD.SnapshotRetention > 0 {
 		config["etcd-snapshot-retention"] = controlPlane.Spec.ETCD.SnapshotRetention
 	}
 	if controlPlane.Spec.ETCD.SnapshotScheduleCron != "" {
@@ -815,6 +896,10 @@ func configFile(controlPlane *rkev1.RKEControlPlane, filename string) string {
 		GetRuntime(controlPlane.Spec.KubernetesVersion), filename)
 }
 
+func rancherConfigFile(controlPlane *rkev1.RKEControlPlane) string {
+	return fmt.Sprintf("/etc/rancher/%s/config.yaml.d/50-rancher.yaml", GetRuntime(controlPlane.Spec.KubernetesVersion))
+}
+
 func (p *Planner) addConfigFile(nodePlan plan.NodePlan, controlPlane *rkev1.RKEControlPlane, machine *capi.Machine, secret plan.Secret,
 	initNode bool, joinServer string) (plan.NodePlan, error) {
 	config := map[string]interface{}{}
@@ -871,7 +956,7 @@ func (p *Planner) addConfigFile(nodePlan plan.NodePlan, controlPlane *rkev1.RKEC
 
 	nodePlan.Files = append(nodePlan.Files, plan.File{
 		Content: base64.StdEncoding.EncodeToString(configData),
-		Path:    fmt.Sprintf("/etc/rancher/%s/config.yaml.d/50-rancher.yaml", GetRuntime(controlPlane.Spec.KubernetesVersion)),
+		Path:    rancherConfigFile(controlPlane),
 	})
 
 	return nodePlan, nil
@@ -903,6 +988,10 @@ func (p *Planner) desiredPlan(controlPlane *rkev1.RKEControlPlane, secret plan.S
 		if err != nil {
 			return nodePlan, err
 		}
+
+		if controlPlane.Spec.ReconcileDrift {
+			nodePlan = addFileHashes(nodePlan)
+		}
 	}
 
 	nodePlan, err = p.addProbes(nodePlan, controlPlane, entry.Machine)
@@ -1011,7 +1100,7 @@ func (p *Planner) ensureRKEStateSecret(controlPlane *rkev1.RKEControlPlane, full
 		return "", plan.Secret{}, nil
 	}
 
-	name := name.SafeConcatName(controlPlane.Name, "rke", "state")
+	name := rkeStateSecretName(controlPlane)
 	secret, err := p.secretCache.Get(controlPlane.Namespace, name)
 	if apierror.IsNotFound(err) {
 		serverToken, err := randomtoken.Generate()
@@ -1059,6 +1148,10 @@ func (p *Planner) ensureRKEStateSecret(controlPlane *rkev1.RKEControlPlane, full
 	}, nil
 }
 
+func rkeStateSecretName(controlPlane *rkev1.RKEControlPlane) string {
+	return name.SafeConcatName(controlPlane.Name, "rke", "state")
+}
+
 type helmChartConfig struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -0,0 +1,266 @@
+package planner
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/wrangler"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// PlanPreview lists the machines whose plan changes if the spec of a control plane is applied.
+type PlanPreview struct {
+	Machines []MachinePlanPreview `json:"machines,omitempty"`
+}
+
+// MachinePlanPreview describes the changes of the plan of a machine. Only the names of the config
+// keys are given because the values may be secret.
+type MachinePlanPreview struct {
+	Machine string `json:"machine,omitempty"`
+	Role    string `json:"role,omitempty"`
+	// Batch is the order in which the machines are upgraded, the machines of a batch are upgraded
+	// at the same time
+	Batch               int      `json:"batch,omitempty"`
+	New                 bool     `json:"new,omitempty"`
+	Restart             bool     `json:"restart,omitempty"`
+	ChangedConfigKeys   []string `json:"changedConfigKeys,omitempty"`
+	AddedFiles          []string `json:"addedFiles,omitempty"`
+	RemovedFiles        []string `json:"removedFiles,omitempty"`
+	ChangedFiles        []string `json:"changedFiles,omitempty"`
+	ChangedInstructions []string `json:"changedInstructions,omitempty"`
+}
+
+// Previewer computes the plans of a control plane without applying them.
+type Previewer struct {
+	planner *Planner
+}
+
+func NewPreviewer(ctx context.Context, clients *wrangler.Context) *Previewer {
+	return &Previewer{
+		planner: New(ctx, clients),
+	}
+}
+
+// Preview compares the plans of the machines of the control plane to the plans the planner would
+// apply for the spec of the control plane. The machines are ordered like the planner upgrades
+// them: the bootstrap node, the etcd, control plane and worker nodes, each role in batches of its
+// concurrency.
+func (p *Previewer) Preview(controlPlane *rkev1.RKEControlPlane) (*PlanPreview, error) {
+	cluster, err := p.planner.getCAPICluster(controlPlane)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterPlan, err := p.planner.store.Load(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := p.planner.getRKEStateSecret(controlPlane)
+	if err != nil {
+		return nil, err
+	}
+
+	// unlike the planner the init node is not elected, it is known once the cluster is running
+	initJoinServer := ""
+	for _, entry := range collect(clusterPlan, isInitNode) {
+		initJoinServer = entry.Machine.Annotations[JoinURLAnnotation]
+	}
+
+	tiers := []struct {
+		name             string
+		include, exclude roleFilter
+		maxUnavailable   string
+		joinServer       string
+	}{
+		{"bootstrap", isInitNode, none, controlPlane.Spec.UpgradeStrategy.ControlPlaneConcurrency, ""},
+		{"etcd", isEtcd, isInitNode, controlPlane.Spec.UpgradeStrategy.ControlPlaneConcurrency, initJoinServer},
+		{"control plane", isControlPlane, isInitNode, controlPlane.Spec.UpgradeStrategy.ControlPlaneConcurrency, initJoinServer},
+		{"worker", isOnlyWorker, isInitNode, controlPlane.Spec.UpgradeStrategy.WorkerConcurrency, p.planner.getControlPlaneJoinURL(clusterPlan)},
+	}
+
+	var (
+		result  = &PlanPreview{}
+		batches int
+	)
+	for _, tier := range tiers {
+		entries := collect(clusterPlan, tier.include)
+		concurrency, _, err := calculateConcurrency(tier.maxUnavailable, entries, tier.exclude)
+		if err != nil {
+			return nil, err
+		}
+
+		var changed []MachinePlanPreview
+		for _, entry := range entries {
+			if tier.exclude(entry.Machine) {
+				continue
+			}
+			desired, err := p.planner.desiredPlan(controlPlane, secret, entry, isInitNode(entry.Machine), tier.joinServer)
+			if err != nil {
+				return nil, err
+			}
+			if entry.Plan != nil && equality.Semantic.DeepEqual(entry.Plan.Plan, desired) {
+				continue
+			}
+			preview := diffPlans(controlPlane, entry.Plan, desired)
+			preview.Machine = entry.Machine.Name
+			preview.Role = tier.name
+			changed = append(changed, preview)
+		}
+
+		rollout := controlPlane.Spec.UpgradeStrategy.Rollout
+		changed, batches = previewBatches(changed, concurrency, rollout != nil && rollout.Canary, batches)
+		result.Machines = append(result.Machines, changed...)
+	}
+
+	return result, nil
+}
+
+// previewBatches orders the changed machines of a role by name like the rollout and numbers the
+// batches they are upgraded in, continuing after the given number of batches of the previous
+// roles. The batches are of the concurrency, the first batch is a single canary if enabled. It
+// returns the number of batches including the ones of the role.
+func previewBatches(changed []MachinePlanPreview, concurrency int, canary bool, batches int) ([]MachinePlanPreview, int) {
+	sort.Slice(changed, func(i, j int) bool {
+		return changed[i].Machine < changed[j].Machine
+	})
+
+	size := concurrency
+	if canary {
+		size = 1
+	}
+	for i := 0; i < len(changed); {
+		if size <= 0 || size > len(changed)-i {
+			size = len(changed) - i
+		}
+		batches++
+		for j := i; j < i+size; j++ {
+			changed[j].Batch = batches
+		}
+		i += size
+		size = concurrency
+	}
+	return changed, batches
+}
+
+// getRKEStateSecret returns the tokens of the control plane, they are empty if the planner has not
+// generated them yet.
+func (p *Planner) getRKEStateSecret(controlPlane *rkev1.RKEControlPlane) (plan.Secret, error) {
+	if controlPlane.Spec.UnmanagedConfig {
+		return plan.Secret{}, nil
+	}
+
+	secret, err := p.secretCache.Get(controlPlane.Namespace, rkeStateSecretName(controlPlane))
+	if apierror.IsNotFound(err) {
+		return plan.Secret{}, nil
+	} else if err != nil {
+		return plan.Secret{}, err
+	}
+
+	return plan.Secret{
+		ServerToken: string(secret.Data["serverToken"]),
+		AgentToken:  string(secret.Data["agentToken"]),
+	}, nil
+}
+
+func diffPlans(controlPlane *rkev1.RKEControlPlane, node *plan.Node, desired plan.NodePlan) MachinePlanPreview {
+	var (
+		result  MachinePlanPreview
+		current plan.NodePlan
+	)
+	if node == nil {
+		result.New = true
+	} else {
+		current = node.Plan
+	}
+
+	currentFiles := map[string]plan.File{}
+	for _, file := range current.Files {
+		currentFiles[file.Path] = file
+	}
+	desiredFiles := map[string]plan.File{}
+	for _, file := range desired.Files {
+		desiredFiles[file.Path] = file
+		if currentFile, ok := currentFiles[file.Path]; !ok {
+			result.AddedFiles = append(result.AddedFiles, file.Path)
+		} else if currentFile != file {
+			result.ChangedFiles = append(result.ChangedFiles, file.Path)
+		}
+	}
+	for _, file := range current.Files {
+		if _, ok := desiredFiles[file.Path]; !ok {
+			result.RemovedFiles = append(result.RemovedFiles, file.Path)
+		}
+	}
+	sort.Strings(result.AddedFiles)
+	sort.Strings(result.ChangedFiles)
+	sort.Strings(result.RemovedFiles)
+
+	configFile := rancherConfigFile(controlPlane)
+	result.ChangedConfigKeys = diffConfigKeys(currentFiles[configFile].Content, desiredFiles[configFile].Content)
+
+	currentInstructions := map[string]plan.Instruction{}
+	for _, instruction := range current.Instructions {
+		currentInstructions[instructionName(instruction)] = instruction
+	}
+	desiredInstructions := map[string]bool{}
+	for _, instruction := range desired.Instructions {
+		name := instructionName(instruction)
+		desiredInstructions[name] = true
+		if currentInstruction, ok := currentInstructions[name]; !ok || !equality.Semantic.DeepEqual(currentInstruction, instruction) {
+			result.ChangedInstructions = append(result.ChangedInstructions, name)
+		}
+	}
+	for name := range currentInstructions {
+		if !desiredInstructions[name] {
+			result.ChangedInstructions = append(result.ChangedInstructions, name)
+		}
+	}
+	sort.Strings(result.ChangedInstructions)
+
+	// the install instruction restarts the runtime when its image or restart stamp changes
+	result.Restart = !result.New && len(result.ChangedInstructions) > 0
+	return result
+}
+
+func instructionName(instruction plan.Instruction) string {
+	if instruction.Name == "" {
+		return "install"
+	}
+	return instruction.Name
+}
+
+// diffConfigKeys returns the keys whose values differ between two base64 encoded config files.
+func diffConfigKeys(current, desired string) []string {
+	currentConfig := decodeConfig(current)
+	desiredConfig := decodeConfig(desired)
+
+	var keys []string
+	for k, v := range desiredConfig {
+		if currentValue, ok := currentConfig[k]; !ok || !equality.Semantic.DeepEqual(currentValue, v) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range currentConfig {
+		if _, ok := desiredConfig[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func decodeConfig(content string) map[string]interface{} {
+	config := map[string]interface{}{}
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return config
+	}
+	_ = json.Unmarshal(data, &config)
+	return config
+}
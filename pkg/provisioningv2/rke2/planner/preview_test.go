@@ -0,0 +1,105 @@
+package planner
+
+import (
+	"testing"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func previewMachines(names ...string) (result []MachinePlanPreview) {
+	for _, name := range names {
+		result = append(result, MachinePlanPreview{Machine: name})
+	}
+	return result
+}
+
+func TestPreviewBatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		changed     []MachinePlanPreview
+		concurrency int
+		canary      bool
+		batches     int
+		// expected maps the machines to their batches in the order of the preview
+		expected     []MachinePlanPreview
+		totalBatches int
+	}{
+		{
+			name:        "batches of the concurrency in the order of the names",
+			changed:     previewMachines("m3", "m1", "m5", "m2", "m4"),
+			concurrency: 2,
+			expected: []MachinePlanPreview{
+				{Machine: "m1", Batch: 1}, {Machine: "m2", Batch: 1},
+				{Machine: "m3", Batch: 2}, {Machine: "m4", Batch: 2},
+				{Machine: "m5", Batch: 3},
+			},
+			totalBatches: 3,
+		},
+		{
+			name:        "canary is the first batch",
+			changed:     previewMachines("m3", "m1", "m2"),
+			concurrency: 2,
+			canary:      true,
+			expected: []MachinePlanPreview{
+				{Machine: "m1", Batch: 1},
+				{Machine: "m2", Batch: 2}, {Machine: "m3", Batch: 2},
+			},
+			totalBatches: 2,
+		},
+		{
+			name:        "unlimited concurrency upgrades all machines at once",
+			changed:     previewMachines("m2", "m1", "m3"),
+			concurrency: 0,
+			expected: []MachinePlanPreview{
+				{Machine: "m1", Batch: 1}, {Machine: "m2", Batch: 1}, {Machine: "m3", Batch: 1},
+			},
+			totalBatches: 1,
+		},
+		{
+			name:        "batches continue after the previous roles",
+			changed:     previewMachines("w2", "w1"),
+			concurrency: 1,
+			canary:      true,
+			batches:     3,
+			expected: []MachinePlanPreview{
+				{Machine: "w1", Batch: 4},
+				{Machine: "w2", Batch: 5},
+			},
+			totalBatches: 5,
+		},
+		{
+			name:         "role without changes",
+			concurrency:  1,
+			batches:      3,
+			totalBatches: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machines, batches := previewBatches(tt.changed, tt.concurrency, tt.canary, tt.batches)
+			assert.Equal(t, tt.expected, machines)
+			assert.Equal(t, tt.totalBatches, batches)
+		})
+	}
+}
+
+func TestPreviewBatchesMatchRollout(t *testing.T) {
+	// the preview upgrades the machines in the batches the rollout picks from the pending machines
+	controlPlanes := &fakeControlPlanes{}
+	p := &Planner{rkeControlPlanes: controlPlanes}
+	controlPlane := &rkev1.RKEControlPlane{}
+	controlPlane.Spec.UpgradeStrategy.Rollout = &rkev1.RolloutStrategy{Canary: true}
+	assert.Error(t, p.rollout(controlPlane, "worker", nil, []string{"m3", "m1", "m2"}, 2))
+	require.NotNil(t, controlPlanes.updated)
+
+	machines, _ := previewBatches(previewMachines("m3", "m1", "m2"), 2, true, 0)
+	var firstBatch []string
+	for _, machine := range machines {
+		if machine.Batch == 1 {
+			firstBatch = append(firstBatch, machine.Machine)
+		}
+	}
+	assert.Equal(t, controlPlanes.updated.Status.Rollout.Batch, firstBatch, "the canary of the preview is the canary of the rollout")
+}
@@ -0,0 +1,142 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rolloutBatch returns the machines of a role that may be upgraded by the rollout strategy of the
+// control plane, or nil if the control plane has no rollout strategy.
+func rolloutBatch(controlPlane *rkev1.RKEControlPlane, tierName string) map[string]bool {
+	if controlPlane.Spec.UpgradeStrategy.Rollout == nil {
+		return nil
+	}
+	batch := map[string]bool{}
+	if status := controlPlane.Status.Rollout; status != nil && status.Role == tierName {
+		for _, name := range status.Batch {
+			batch[name] = true
+		}
+	}
+	return batch
+}
+
+// rollout advances the rollout of plan changes to the machines of a role. The machines waiting for
+// the changes are upgraded in batches of the concurrency, the first batch is a single canary if
+// enabled. Once the machines of a batch are in sync their probes must pass for the soak time
+// before the next batch starts, a rollout halts if the probes fail while soaking.
+func (p *Planner) rollout(controlPlane *rkev1.RKEControlPlane, tierName string, entries []planEntry, pending []string, concurrency int) error {
+	strategy := controlPlane.Spec.UpgradeStrategy.Rollout
+	status := controlPlane.Status.Rollout
+	if status != nil && status.Role != tierName {
+		if len(pending) == 0 {
+			return nil
+		}
+		// the rollout of the previous role finished
+		status = nil
+	}
+	if status == nil {
+		if len(pending) == 0 {
+			return nil
+		}
+		status = &rkev1.RolloutStatus{Role: tierName}
+	} else {
+		status = status.DeepCopy()
+	}
+
+	if strategy.Paused {
+		if status.Halted {
+			status.Halted = false
+			status.HaltedMessage = ""
+			return p.setRolloutStatus(controlPlane, status)
+		}
+		return ErrWaiting("rollout of " + tierName + " node(s) is paused")
+	}
+	if status.Halted {
+		return ErrWaiting("rollout of " + tierName + " node(s) halted, pause and resume the rollout to continue: " + status.HaltedMessage)
+	}
+
+	isPending := map[string]bool{}
+	for _, name := range pending {
+		isPending[name] = true
+	}
+	byName := map[string]planEntry{}
+	for _, entry := range entries {
+		byName[entry.Machine.Name] = entry
+	}
+
+	var (
+		applying []string
+		failing  []string
+	)
+	for _, name := range status.Batch {
+		entry, ok := byName[name]
+		if !ok {
+			// deleted machines don't hold up the rollout
+			continue
+		}
+		switch {
+		case isPending[name] || entry.Plan == nil:
+			applying = append(applying, name)
+		case status.SoakStarted != nil && !entry.Plan.Healthy:
+			failing = append(failing, name+" "+probesMessage(entry.Plan))
+		case !entry.Plan.InSync:
+			applying = append(applying, name)
+		}
+	}
+
+	if len(failing) > 0 {
+		sort.Strings(failing)
+		status.Halted = true
+		status.HaltedMessage = strings.Join(failing, ", ")
+		return p.setRolloutStatus(controlPlane, status)
+	}
+	if len(applying) > 0 {
+		// the machines of the batch are reported as out of sync
+		return nil
+	}
+
+	if len(status.Batch) > 0 {
+		if status.SoakStarted == nil {
+			now := metav1.Now()
+			status.SoakStarted = &now
+			return p.setRolloutStatus(controlPlane, status)
+		}
+		soak := time.Duration(strategy.SoakSeconds) * time.Second
+		if remaining := soak - time.Since(status.SoakStarted.Time); remaining > 0 {
+			p.rkeControlPlanes.EnqueueAfter(controlPlane.Namespace, controlPlane.Name, remaining)
+			return ErrWaiting(fmt.Sprintf("soaking %s node(s) %s for %s", tierName,
+				strings.Join(atMostThree(append([]string(nil), status.Batch...)), ","), remaining.Round(time.Second)))
+		}
+	}
+
+	if len(pending) == 0 {
+		return p.setRolloutStatus(controlPlane, nil)
+	}
+
+	size := concurrency
+	if strategy.Canary && status.Batches == 0 {
+		size = 1
+	}
+	if size <= 0 || size > len(pending) {
+		size = len(pending)
+	}
+	sort.Strings(pending)
+	status.Batch = pending[:size]
+	status.Batches++
+	status.SoakStarted = nil
+	return p.setRolloutStatus(controlPlane, status)
+}
+
+func (p *Planner) setRolloutStatus(controlPlane *rkev1.RKEControlPlane, status *rkev1.RolloutStatus) error {
+	controlPlane = controlPlane.DeepCopy()
+	controlPlane.Status.Rollout = status
+	if _, err := p.rkeControlPlanes.UpdateStatus(controlPlane); err != nil {
+		return err
+	}
+	return ErrWaiting("refreshing rollout state")
+}
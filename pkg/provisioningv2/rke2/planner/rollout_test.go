@@ -0,0 +1,301 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	rkecontrollers "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha4"
+)
+
+// fakeControlPlanes records the rollout status the planner updates and the requeue of the
+// control plane.
+type fakeControlPlanes struct {
+	rkecontrollers.RKEControlPlaneController
+	updated  *rkev1.RKEControlPlane
+	enqueued time.Duration
+}
+
+func (f *fakeControlPlanes) UpdateStatus(controlPlane *rkev1.RKEControlPlane) (*rkev1.RKEControlPlane, error) {
+	f.updated = controlPlane
+	return controlPlane, nil
+}
+
+func (f *fakeControlPlanes) EnqueueAfter(namespace, name string, duration time.Duration) {
+	f.enqueued = duration
+}
+
+var (
+	inSync    = &plan.Node{InSync: true, Healthy: true}
+	outOfSync = &plan.Node{Healthy: true}
+	unhealthy = &plan.Node{InSync: true, ProbeStatus: map[string]plan.ProbeStatus{
+		"kubelet": {Healthy: false},
+		"etcd":    {Healthy: true},
+	}}
+)
+
+func rolloutEntries(nodes map[string]*plan.Node) (result []planEntry) {
+	for name, node := range nodes {
+		result = append(result, planEntry{
+			Machine: &capi.Machine{ObjectMeta: metav1.ObjectMeta{Name: name}},
+			Plan:    node,
+		})
+	}
+	return result
+}
+
+func soakStarted(ago time.Duration) *metav1.Time {
+	t := metav1.NewTime(time.Now().Add(-ago))
+	return &t
+}
+
+func TestRollout(t *testing.T) {
+	tests := []struct {
+		name        string
+		tierName    string
+		strategy    rkev1.RolloutStrategy
+		status      *rkev1.RolloutStatus
+		nodes       map[string]*plan.Node
+		pending     []string
+		concurrency int
+		// updated is the rollout status the planner stores, nil if it isn't updated
+		updated *rkev1.RolloutStatus
+		// cleared is set if the planner removes the rollout status
+		cleared    bool
+		soaking    bool
+		requeued   bool
+		errMessage string
+	}{
+		{
+			name:        "canary is the first batch",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{Canary: true, SoakSeconds: 60},
+			nodes:       map[string]*plan.Node{"m1": outOfSync, "m2": outOfSync, "m3": outOfSync},
+			pending:     []string{"m3", "m1", "m2"},
+			concurrency: 2,
+			updated:     &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}},
+			errMessage:  "refreshing rollout state",
+		},
+		{
+			name:        "batches of the concurrency follow the canary",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{Canary: true, SoakSeconds: 60},
+			status:      &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}, SoakStarted: soakStarted(2 * time.Minute)},
+			nodes:       map[string]*plan.Node{"m1": inSync, "m2": outOfSync, "m3": outOfSync, "m4": outOfSync},
+			pending:     []string{"m4", "m3", "m2"},
+			concurrency: 2,
+			updated:     &rkev1.RolloutStatus{Role: "worker", Batches: 2, Batch: []string{"m2", "m3"}},
+			errMessage:  "refreshing rollout state",
+		},
+		{
+			name:        "first batch is of the concurrency without canary",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{},
+			nodes:       map[string]*plan.Node{"m1": outOfSync, "m2": outOfSync, "m3": outOfSync},
+			pending:     []string{"m1", "m2", "m3"},
+			concurrency: 2,
+			updated:     &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1", "m2"}},
+			errMessage:  "refreshing rollout state",
+		},
+		{
+			name:        "unlimited concurrency upgrades all pending nodes",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{},
+			nodes:       map[string]*plan.Node{"m1": outOfSync, "m2": outOfSync, "m3": outOfSync},
+			pending:     []string{"m1", "m2", "m3"},
+			concurrency: 0,
+			updated:     &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1", "m2", "m3"}},
+			errMessage:  "refreshing rollout state",
+		},
+		{
+			name:        "batch is applying",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{SoakSeconds: 60},
+			status:      &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}},
+			nodes:       map[string]*plan.Node{"m1": outOfSync, "m2": outOfSync},
+			pending:     []string{"m2"},
+			concurrency: 1,
+		},
+		{
+			name:        "soak starts once the batch is in sync",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{SoakSeconds: 60},
+			status:      &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}},
+			nodes:       map[string]*plan.Node{"m1": inSync, "m2": outOfSync},
+			pending:     []string{"m2"},
+			concurrency: 1,
+			updated:     &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}},
+			soaking:     true,
+			errMessage:  "refreshing rollout state",
+		},
+		{
+			name:        "soaking batch requeues the control plane",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{SoakSeconds: 60},
+			status:      &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}, SoakStarted: soakStarted(10 * time.Second)},
+			nodes:       map[string]*plan.Node{"m1": inSync, "m2": outOfSync},
+			pending:     []string{"m2"},
+			concurrency: 1,
+			requeued:    true,
+			errMessage:  "soaking worker node(s) m1 for 50s",
+		},
+		{
+			name:        "probe failure while soaking halts the rollout",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{SoakSeconds: 60},
+			status:      &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}, SoakStarted: soakStarted(10 * time.Second)},
+			nodes:       map[string]*plan.Node{"m1": unhealthy, "m2": outOfSync},
+			pending:     []string{"m2"},
+			concurrency: 1,
+			updated: &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}, Halted: true,
+				HaltedMessage: "m1 waiting on probes: kubelet"},
+			soaking:    true,
+			errMessage: "refreshing rollout state",
+		},
+		{
+			name:        "halted rollout waits",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{SoakSeconds: 60},
+			status:      &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}, Halted: true, HaltedMessage: "m1 waiting on probes: kubelet"},
+			nodes:       map[string]*plan.Node{"m1": inSync, "m2": outOfSync},
+			pending:     []string{"m2"},
+			concurrency: 1,
+			errMessage:  "rollout of worker node(s) halted, pause and resume the rollout to continue: m1 waiting on probes: kubelet",
+		},
+		{
+			name:        "pausing clears the halt",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{SoakSeconds: 60, Paused: true},
+			status:      &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}, Halted: true, HaltedMessage: "m1 waiting on probes: kubelet"},
+			nodes:       map[string]*plan.Node{"m1": inSync, "m2": outOfSync},
+			pending:     []string{"m2"},
+			concurrency: 1,
+			updated:     &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}},
+			errMessage:  "refreshing rollout state",
+		},
+		{
+			name:        "paused rollout waits",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{SoakSeconds: 60, Paused: true},
+			status:      &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}},
+			nodes:       map[string]*plan.Node{"m1": inSync, "m2": outOfSync},
+			pending:     []string{"m2"},
+			concurrency: 1,
+			errMessage:  "rollout of worker node(s) is paused",
+		},
+		{
+			name:        "resumed rollout soaks the batch again",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{SoakSeconds: 60},
+			status:      &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}},
+			nodes:       map[string]*plan.Node{"m1": inSync, "m2": outOfSync},
+			pending:     []string{"m2"},
+			concurrency: 1,
+			updated:     &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"m1"}},
+			soaking:     true,
+			errMessage:  "refreshing rollout state",
+		},
+		{
+			name:        "deleted machines don't hold up the rollout",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{SoakSeconds: 60},
+			status:      &rkev1.RolloutStatus{Role: "worker", Batches: 1, Batch: []string{"deleted", "m1"}, SoakStarted: soakStarted(2 * time.Minute)},
+			nodes:       map[string]*plan.Node{"m1": inSync, "m2": outOfSync},
+			pending:     []string{"m2"},
+			concurrency: 2,
+			updated:     &rkev1.RolloutStatus{Role: "worker", Batches: 2, Batch: []string{"m2"}},
+			errMessage:  "refreshing rollout state",
+		},
+		{
+			name:        "rollout of a role finishes",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{SoakSeconds: 60},
+			status:      &rkev1.RolloutStatus{Role: "worker", Batches: 2, Batch: []string{"m2"}, SoakStarted: soakStarted(2 * time.Minute)},
+			nodes:       map[string]*plan.Node{"m1": inSync, "m2": inSync},
+			concurrency: 1,
+			cleared:     true,
+			errMessage:  "refreshing rollout state",
+		},
+		{
+			name:        "next role takes over with its own canary",
+			tierName:    "control-plane",
+			strategy:    rkev1.RolloutStrategy{Canary: true, SoakSeconds: 60},
+			status:      &rkev1.RolloutStatus{Role: "etcd", Batches: 3, Batch: []string{"e3"}, SoakStarted: soakStarted(2 * time.Minute)},
+			nodes:       map[string]*plan.Node{"c1": outOfSync, "c2": outOfSync},
+			pending:     []string{"c2", "c1"},
+			concurrency: 2,
+			updated:     &rkev1.RolloutStatus{Role: "control-plane", Batches: 1, Batch: []string{"c1"}},
+			errMessage:  "refreshing rollout state",
+		},
+		{
+			name:        "role without pending nodes leaves the rollout of another role",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{SoakSeconds: 60},
+			status:      &rkev1.RolloutStatus{Role: "etcd", Batches: 1, Batch: []string{"e1"}},
+			nodes:       map[string]*plan.Node{"m1": inSync},
+			concurrency: 1,
+		},
+		{
+			name:        "nothing to roll out",
+			tierName:    "worker",
+			strategy:    rkev1.RolloutStrategy{SoakSeconds: 60},
+			nodes:       map[string]*plan.Node{"m1": inSync},
+			concurrency: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controlPlanes := &fakeControlPlanes{}
+			p := &Planner{rkeControlPlanes: controlPlanes}
+			strategy := tt.strategy
+			controlPlane := &rkev1.RKEControlPlane{
+				Status: rkev1.RKEControlPlaneStatus{Rollout: tt.status},
+			}
+			controlPlane.Spec.UpgradeStrategy.Rollout = &strategy
+
+			err := p.rollout(controlPlane, tt.tierName, rolloutEntries(tt.nodes), tt.pending, tt.concurrency)
+			if tt.errMessage == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.errMessage)
+				assert.IsType(t, ErrWaiting(""), err)
+			}
+
+			switch {
+			case tt.cleared:
+				if assert.NotNil(t, controlPlanes.updated) {
+					assert.Nil(t, controlPlanes.updated.Status.Rollout)
+				}
+			case tt.updated == nil:
+				assert.Nil(t, controlPlanes.updated, "rollout status is not updated")
+			case assert.NotNil(t, controlPlanes.updated) && assert.NotNil(t, controlPlanes.updated.Status.Rollout):
+				status := controlPlanes.updated.Status.Rollout
+				assert.Equal(t, tt.soaking, status.SoakStarted != nil, "soak started")
+				status = status.DeepCopy()
+				status.SoakStarted = nil
+				assert.Equal(t, tt.updated, status)
+			}
+			assert.Equal(t, tt.requeued, controlPlanes.enqueued > 0, "requeued")
+			assert.Equal(t, tt.status, controlPlane.Status.Rollout, "status of the control plane is not modified")
+		})
+	}
+}
+
+func TestRolloutBatch(t *testing.T) {
+	controlPlane := &rkev1.RKEControlPlane{
+		Status: rkev1.RKEControlPlaneStatus{
+			Rollout: &rkev1.RolloutStatus{Role: "worker", Batch: []string{"m1", "m2"}},
+		},
+	}
+	assert.Nil(t, rolloutBatch(controlPlane, "worker"), "no rollout strategy")
+
+	controlPlane.Spec.UpgradeStrategy.Rollout = &rkev1.RolloutStrategy{}
+	assert.Equal(t, map[string]bool{"m1": true, "m2": true}, rolloutBatch(controlPlane, "worker"))
+	assert.Empty(t, rolloutBatch(controlPlane, "etcd"), "batch is of another role")
+	assert.NotNil(t, rolloutBatch(controlPlane, "etcd"), "rollout strategy holds back all machines of the role")
+}
@@ -3,6 +3,9 @@
 import (
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -30,6 +33,11 @@
 	InSyncPlanStatus         = "InSync"
 	InSyncPlanStatusMessage  = "plan applied"
 	ErrorStatus              = "Error"
+	DriftedStatus            = "Drifted"
+
+	// fileHashesInstruction is the periodic instruction that reports the hashes of the files of the
+	// plan for drift detection
+	fileHashesInstruction = "file-hashes"
 )
 
 type PlanStore struct {
@@ -146,6 +154,7 @@ func SecretToNode(secret *corev1.Secret) (*plan.Node, error) {
 	appliedPlanData := secret.Data["appliedPlan"]
 	output := secret.Data["applied-output"]
 	probes := secret.Data["probe-statuses"]
+	periodicOutput := secret.Data["applied-periodic-output"]
 
 	if len(probes) > 0 {
 		result.ProbeStatus = map[string]plan.ProbeStatus{}
@@ -176,16 +185,15 @@ func SecretToNode(secret *corev1.Secret) (*plan.Node, error) {
 	}
 
 	if len(output) > 0 {
-		gz, err := gzip.NewReader(bytes.NewBuffer(output))
-		if err != nil {
-			return nil, err
-		}
-		output, err = ioutil.ReadAll(gz)
-		if err != nil {
+		result.Output = map[string][]byte{}
+		if err := gunzipJSON(output, &result.Output); err != nil {
 			return nil, err
 		}
-		result.Output = map[string][]byte{}
-		if err := json.Unmarshal(output, &result.Output); err != nil {
+	}
+
+	if len(periodicOutput) > 0 {
+		result.PeriodicOutput = map[string]plan.PeriodicInstructionOutput{}
+		if err := gunzipJSON(periodicOutput, &result.PeriodicOutput); err != nil {
 			return nil, err
 		}
 	}
@@ -194,6 +202,18 @@ func SecretToNode(secret *corev1.Secret) (*plan.Node, error) {
 	return result, nil
 }
 
+func gunzipJSON(data []byte, obj interface{}) error {
+	gz, err := gzip.NewReader(bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	data, err = ioutil.ReadAll(gz)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, obj)
+}
+
 func (p *PlanStore) getSecrets(machines []*capi.Machine) (map[string]*corev1.Secret, error) {
 	result := map[string]*corev1.Secret{}
 	for _, machine := range machines {
@@ -239,6 +259,121 @@ func (p *PlanStore) UpdatePlan(machine *capi.Machine, plan plan.NodePlan) error
 	return err
 }
 
+// ResetAppliedPlan removes the applied plan of a machine so that the agent applies the plan again.
+func (p *PlanStore) ResetAppliedPlan(machine *capi.Machine) error {
+	secret, err := p.secrets.Get(machine.Namespace, PlanSecretFromBootstrapName(machine.Spec.Bootstrap.ConfigRef.Name), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	delete(secret.Data, "appliedPlan")
+	delete(secret.Data, "applied-checksum")
+	delete(secret.Data, "applied-periodic-output")
+	_, err = p.secrets.Update(secret)
+	return err
+}
+
+// addFileHashes adds the periodic instruction that reports the hashes of the files of the plan for
+// drift detection. The first line of the output is the stamp of the files, the output of a run for
+// another plan is ignored.
+func addFileHashes(nodePlan plan.NodePlan) plan.NodePlan {
+	var paths []string
+	for _, file := range nodePlan.Files {
+		if !file.Dynamic {
+			paths = append(paths, "'"+strings.ReplaceAll(file.Path, "'", `'\''`)+"'")
+		}
+	}
+	if len(paths) == 0 {
+		return nodePlan
+	}
+	nodePlan.PeriodicInstructions = append(nodePlan.PeriodicInstructions, plan.PeriodicInstruction{
+		Name:    fileHashesInstruction,
+		Command: "sh",
+		Args: []string{
+			"-c",
+			// a removed file is missing in the output of sha256sum and reported as drifted
+			fmt.Sprintf("echo %s; sha256sum %s", filesStamp(nodePlan.Files), strings.Join(paths, " ")),
+		},
+		PeriodSeconds: 300,
+	})
+	return nodePlan
+}
+
+func filesStamp(files []plan.File) string {
+	stamp := sha256.New()
+	for _, file := range files {
+		if file.Dynamic {
+			continue
+		}
+		stamp.Write([]byte(file.Path))
+		stamp.Write([]byte(file.Content))
+	}
+	return hex.EncodeToString(stamp.Sum(nil))
+}
+
+// fileHashes returns the hashes of the files of the applied plan of a node as reported by the
+// agent, nil if the agent didn't report them for the applied plan.
+func fileHashes(node *plan.Node) map[string]string {
+	if node == nil || node.AppliedPlan == nil {
+		return nil
+	}
+	output, ok := node.PeriodicOutput[fileHashesInstruction]
+	if !ok {
+		return nil
+	}
+	lines := strings.Split(string(output.Stdout), "\n")
+	if strings.TrimSpace(lines[0]) != filesStamp(node.AppliedPlan.Files) {
+		return nil
+	}
+
+	hashes := map[string]string{}
+	for _, line := range lines[1:] {
+		// sha256sum prints the hash and the path separated by two spaces
+		parts := strings.SplitN(line, "  ", 2)
+		if len(parts) == 2 {
+			hashes[parts[1]] = parts[0]
+		}
+	}
+	return hashes
+}
+
+// DriftedFiles returns the files of the applied plan of a node that were changed out-of-band, the
+// hashes the agent reports don't match the planned content. Nothing drifted if the agent doesn't
+// report hashes for the applied plan.
+func DriftedFiles(node *plan.Node) []string {
+	hashes := fileHashes(node)
+	if hashes == nil {
+		return nil
+	}
+
+	var drifted []string
+	for _, file := range node.AppliedPlan.Files {
+		if file.Dynamic {
+			continue
+		}
+		content, err := base64.StdEncoding.DecodeString(file.Content)
+		if err != nil {
+			continue
+		}
+		hash := sha256.Sum256(content)
+		if hashes[file.Path] != hex.EncodeToString(hash[:]) {
+			drifted = append(drifted, file.Path)
+		}
+	}
+	sort.Strings(drifted)
+	return drifted
+}
+
+func GetDriftStatusReasonMessage(plan *plan.Node) (corev1.ConditionStatus, string, string) {
+	if fileHashes(plan) == nil {
+		return "", "", ""
+	}
+	if drifted := DriftedFiles(plan); len(drifted) > 0 {
+		return corev1.ConditionTrue, DriftedStatus, "files changed out-of-band: " + strings.Join(drifted, ", ")
+	}
+	return corev1.ConditionFalse, "", ""
+}
+
 func assignAndCheckPlan(store *PlanStore, msg string, server planEntry, newPlan plan.NodePlan) error {
 	if server.Plan == nil || !equality.Semantic.DeepEqual(server.Plan.Plan, newPlan) {
 		if err := store.UpdatePlan(server.Machine, newPlan); err != nil {
@@ -0,0 +1,167 @@
+package planner
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha4"
+)
+
+// fakeSecrets serves a single plan secret and records its updates.
+type fakeSecrets struct {
+	corecontrollers.SecretClient
+	secret  *corev1.Secret
+	updated *corev1.Secret
+}
+
+func (f *fakeSecrets) Get(namespace, name string, opts metav1.GetOptions) (*corev1.Secret, error) {
+	return f.secret.DeepCopy(), nil
+}
+
+func (f *fakeSecrets) Update(secret *corev1.Secret) (*corev1.Secret, error) {
+	f.updated = secret
+	return secret, nil
+}
+
+func fileHash(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+// hashesOutput is the output of the file-hashes instruction for the files of a plan.
+func hashesOutput(files []plan.File, hashes map[string]string) map[string]plan.PeriodicInstructionOutput {
+	stdout := filesStamp(files) + "\n"
+	for path, hash := range hashes {
+		stdout += hash + "  " + path + "\n"
+	}
+	return map[string]plan.PeriodicInstructionOutput{
+		fileHashesInstruction: {Name: fileHashesInstruction, Stdout: []byte(stdout)},
+	}
+}
+
+func gzipJSON(t *testing.T, obj interface{}) []byte {
+	data, err := json.Marshal(obj)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err = gz.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestAddFileHashes(t *testing.T) {
+	assert.Empty(t, addFileHashes(plan.NodePlan{}).PeriodicInstructions, "nothing to hash")
+
+	files := []plan.File{
+		{Path: "/etc/rancher/rke2/config.yaml.d/50-rancher.yaml", Content: "dG9rZW46IGFiYw=="},
+		{Path: "/var/lib/rancher/rke2/server/manifests/it's.yaml", Content: "e30="},
+		{Path: "/var/lib/rancher/rke2/agent/dynamic", Dynamic: true},
+	}
+	nodePlan := addFileHashes(plan.NodePlan{Files: files})
+	require.Len(t, nodePlan.PeriodicInstructions, 1)
+	instruction := nodePlan.PeriodicInstructions[0]
+	assert.Equal(t, fileHashesInstruction, instruction.Name)
+	assert.Equal(t, []string{"-c", "echo " + filesStamp(files) + "; sha256sum " +
+		"'/etc/rancher/rke2/config.yaml.d/50-rancher.yaml' " +
+		`'/var/lib/rancher/rke2/server/manifests/it'\''s.yaml'`}, instruction.Args, "dynamic files are not hashed")
+}
+
+func TestDriftedFiles(t *testing.T) {
+	applied := &plan.NodePlan{
+		Files: []plan.File{
+			{Path: "/etc/rancher/rke2/config.yaml.d/50-rancher.yaml", Content: base64.StdEncoding.EncodeToString([]byte("token: abc"))},
+			{Path: "/etc/rancher/rke2/registries.yaml", Content: base64.StdEncoding.EncodeToString([]byte("mirrors: {}"))},
+			{Path: "/var/lib/rancher/rke2/invalid", Content: "not base64!"},
+		},
+	}
+	matching := map[string]string{
+		"/etc/rancher/rke2/config.yaml.d/50-rancher.yaml": fileHash("token: abc"),
+		"/etc/rancher/rke2/registries.yaml":               fileHash("mirrors: {}"),
+	}
+	tests := []struct {
+		name    string
+		node    *plan.Node
+		drifted []string
+	}{
+		{
+			name: "no node",
+		},
+		{
+			name: "agent doesn't report hashes",
+			node: &plan.Node{AppliedPlan: applied},
+		},
+		{
+			name: "nothing applied",
+			node: &plan.Node{PeriodicOutput: hashesOutput(applied.Files, nil)},
+		},
+		{
+			name: "files match",
+			node: &plan.Node{AppliedPlan: applied, PeriodicOutput: hashesOutput(applied.Files, matching)},
+		},
+		{
+			name: "files changed or removed",
+			node: &plan.Node{AppliedPlan: applied, PeriodicOutput: hashesOutput(applied.Files, map[string]string{
+				"/etc/rancher/rke2/config.yaml.d/50-rancher.yaml": fileHash("token: changed"),
+			})},
+			drifted: []string{"/etc/rancher/rke2/config.yaml.d/50-rancher.yaml", "/etc/rancher/rke2/registries.yaml"},
+		},
+		{
+			name: "hashes reported for another plan",
+			node: &plan.Node{AppliedPlan: applied, PeriodicOutput: hashesOutput(applied.Files[:1], map[string]string{
+				"/etc/rancher/rke2/config.yaml.d/50-rancher.yaml": fileHash("token: changed"),
+			})},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.drifted, DriftedFiles(tt.node))
+		})
+	}
+}
+
+func TestResetAppliedPlan(t *testing.T) {
+	files := []plan.File{{Path: "/etc/rancher/rke2/registries.yaml", Content: base64.StdEncoding.EncodeToString([]byte("mirrors: {}"))}}
+	nodePlan, err := json.Marshal(addFileHashes(plan.NodePlan{Files: files}))
+	require.NoError(t, err)
+	secrets := &fakeSecrets{secret: &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "custom-abcde-machine-plan"},
+		Data: map[string][]byte{
+			"plan":                    nodePlan,
+			"appliedPlan":             nodePlan,
+			"applied-checksum":        []byte("checksum"),
+			"applied-periodic-output": gzipJSON(t, hashesOutput(files, map[string]string{"/etc/rancher/rke2/registries.yaml": fileHash("changed")})),
+		},
+	}}
+
+	node, err := SecretToNode(secrets.secret)
+	require.NoError(t, err)
+	assert.True(t, node.InSync)
+	assert.Equal(t, []string{"/etc/rancher/rke2/registries.yaml"}, DriftedFiles(node))
+
+	store := &PlanStore{secrets: secrets}
+	require.NoError(t, store.ResetAppliedPlan(&capi.Machine{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "custom-abcde"},
+		Spec: capi.MachineSpec{
+			Bootstrap: capi.Bootstrap{ConfigRef: &corev1.ObjectReference{Kind: "RKEBootstrap", Name: "custom-abcde"}},
+		},
+	}))
+	require.NotNil(t, secrets.updated)
+	assert.Equal(t, map[string][]byte{"plan": nodePlan}, secrets.updated.Data, "only the plan is kept")
+
+	node, err = SecretToNode(secrets.updated)
+	require.NoError(t, err)
+	assert.False(t, node.InSync, "the agent applies the plan again")
+	assert.Empty(t, DriftedFiles(node))
+}
@@ -245,6 +245,8 @@ func clusterTypes(schemas *types.Schemas) *types.Schemas {
 		MustImport(&Version, v3.RotateCertificateInput{}).
 		MustImport(&Version, v3.RotateCertificateOutput{}).
 		MustImport(&Version, v3.RotateEncryptionKeyOutput{}).
+		MustImport(&Version, v3.PreviewEtcdRetentionInput{}).
+		MustImport(&Version, v3.PreviewEtcdRetentionOutput{}).
 		MustImport(&Version, v3.ImportYamlOutput{}).
 		MustImport(&Version, v3.ExportOutput{}).
 		MustImport(&Version, v3.MonitoringInput{}).
@@ -303,6 +305,10 @@ func clusterTypes(schemas *types.Schemas) *types.Schemas {
 			schema.ResourceActions[v3.ClusterActionRotateEncryptionKey] = types.Action{
 				Output: "rotateEncryptionKeyOutput",
 			}
+			schema.ResourceActions[v3.ClusterActionPreviewEtcdRetention] = types.Action{
+				Input:  "previewEtcdRetentionInput",
+				Output: "previewEtcdRetentionOutput",
+			}
 			schema.ResourceActions[v3.ClusterActionRunSecurityScan] = types.Action{
 				Input: "cisScanConfig",
 			}
@@ -442,9 +448,15 @@ func nodeTypes(schemas *types.Schemas) *types.Schemas {
 
 func tokens(schemas *types.Schemas) *types.Schemas {
 	return schemas.
+		MustImport(&Version, v3.TokenUsageInput{}).
+		MustImport(&Version, v3.TokenUsageOutput{}).
 		MustImportAndCustomize(&Version, v3.Token{}, func(schema *types.Schema) {
 			schema.CollectionActions = map[string]types.Action{
 				"logout": {},
+				"usage": {
+					Input:  "tokenUsageInput",
+					Output: "tokenUsageOutput",
+				},
 			}
 		})
 }
@@ -748,16 +760,26 @@ func alertTypes(schema *types.Schemas) *types.Schemas {
 		MustImport(&Version, v3.ClusterAlert{}).
 		MustImport(&Version, v3.ProjectAlert{}).
 		MustImport(&Version, v3.Notification{}).
+		MustImport(&Version, v3.NotificationPreviewInput{}).
+		MustImport(&Version, v3.NotificationPreviewOutput{}).
 		MustImportAndCustomize(&Version, v3.Notifier{}, func(schema *types.Schema) {
 			schema.CollectionActions = map[string]types.Action{
 				"send": {
 					Input: "notification",
 				},
+				"preview": {
+					Input:  "notificationPreviewInput",
+					Output: "notificationPreviewOutput",
+				},
 			}
 			schema.ResourceActions = map[string]types.Action{
 				"send": {
 					Input: "notification",
 				},
+				"preview": {
+					Input:  "notificationPreviewInput",
+					Output: "notificationPreviewOutput",
+				},
 			}
 		}).
 		MustImport(&Version, v3.AlertStatus{}).
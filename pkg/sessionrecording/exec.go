@@ -0,0 +1,230 @@
+package sessionrecording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ExecResponseWriter records a Kubernetes exec stream that is proxied to the client over the
+// hijacked connection of the response writer. The connection is closed when the recorder is done.
+func (r *Recorder) ExecResponseWriter(rw http.ResponseWriter) http.ResponseWriter {
+	if r == nil {
+		return rw
+	}
+	return &execResponseWriter{
+		ResponseWriter: rw,
+		recorder:       r,
+	}
+}
+
+type execResponseWriter struct {
+	http.ResponseWriter
+	recorder *Recorder
+}
+
+func (e *execResponseWriter) Flush() {
+	if f, ok := e.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (e *execResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := e.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer of type %T can't be hijacked", e.ResponseWriter)
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	recorded := &recordedConn{
+		Conn: conn,
+		input: &execStream{
+			recorder:   e.recorder,
+			headerDone: true,
+			input:      true,
+		},
+		output: &execStream{
+			recorder: e.recorder,
+		},
+		closed: make(chan struct{}),
+	}
+	// the client sends no frames before the protocol is switched, data that was read with the
+	// request is recorded to be sure
+	buffered, _ := brw.Reader.Peek(brw.Reader.Buffered())
+	recorded.input.Write(buffered)
+	go func() {
+		select {
+		case <-e.recorder.Done():
+			recorded.Close()
+		case <-recorded.closed:
+		}
+	}()
+	return recorded, bufio.NewReadWriter(bufio.NewReader(io.MultiReader(bytes.NewReader(buffered), recorded)), bufio.NewWriter(recorded)), nil
+}
+
+type recordedConn struct {
+	net.Conn
+	input     *execStream
+	output    *execStream
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (r *recordedConn) Read(data []byte) (int, error) {
+	n, err := r.Conn.Read(data)
+	r.input.Write(data[:n])
+	return n, err
+}
+
+func (r *recordedConn) Write(data []byte) (int, error) {
+	n, err := r.Conn.Write(data)
+	r.output.Write(data[:n])
+	return n, err
+}
+
+func (r *recordedConn) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closed)
+	})
+	return r.Conn.Close()
+}
+
+// execStream reads the websocket frames of one direction of the connection. The output are the
+// frames the server writes to the client after the response that switches the protocol, the
+// stdout and stderr channels are recorded. The input are the frames the client writes, the stdin
+// and resize channels are recorded.
+type execStream struct {
+	recorder   *Recorder
+	input      bool
+	buf        []byte
+	headerDone bool
+	message    []byte
+	text       bool
+}
+
+func (e *execStream) Write(data []byte) {
+	e.buf = append(e.buf, data...)
+	if !e.headerDone {
+		i := bytes.Index(e.buf, []byte("\r\n\r\n"))
+		if i < 0 {
+			return
+		}
+		e.buf = e.buf[i+4:]
+		e.headerDone = true
+	}
+
+	for {
+		fin, opcode, payload, n := parseFrame(e.buf)
+		if n == 0 {
+			break
+		}
+		e.buf = e.buf[n:]
+
+		switch opcode {
+		case 0:
+			e.message = append(e.message, payload...)
+		case 1, 2:
+			e.text = opcode == 1
+			e.message = payload
+		default:
+			// control frames
+			continue
+		}
+		if fin {
+			e.record(e.message)
+			e.message = nil
+		}
+	}
+	e.buf = append([]byte(nil), e.buf...)
+}
+
+// record writes the channels of a message to the recording. The first byte of a message is the
+// channel, with the base64.channel.k8s.io protocol it is a digit followed by base64 data.
+func (e *execStream) record(message []byte) {
+	if len(message) == 0 {
+		return
+	}
+	channel, data := message[0], message[1:]
+	if e.text {
+		channel -= '0'
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil {
+			return
+		}
+		data = decoded
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	switch {
+	case e.input && channel == 0:
+		e.recorder.Input(data)
+	case e.input && channel == 4:
+		var size struct {
+			Width  int
+			Height int
+		}
+		if err := json.Unmarshal(data, &size); err == nil {
+			e.recorder.Resize(size.Width, size.Height)
+		}
+	case !e.input && (channel == 1 || channel == 2):
+		e.recorder.Write(data)
+	}
+}
+
+// parseFrame parses the websocket frame at the start of buf, n is zero if the frame is incomplete.
+func parseFrame(buf []byte) (fin bool, opcode byte, payload []byte, n int) {
+	if len(buf) < 2 {
+		return
+	}
+	fin = buf[0]&0x80 != 0
+	opcode = buf[0] & 0x0f
+	masked := buf[1]&0x80 != 0
+	length := uint64(buf[1] & 0x7f)
+	offset := 2
+
+	switch length {
+	case 126:
+		if len(buf) < offset+2 {
+			return false, 0, nil, 0
+		}
+		length = uint64(binary.BigEndian.Uint16(buf[offset:]))
+		offset += 2
+	case 127:
+		if len(buf) < offset+8 {
+			return false, 0, nil, 0
+		}
+		length = binary.BigEndian.Uint64(buf[offset:])
+		offset += 8
+	}
+
+	var mask []byte
+	if masked {
+		if len(buf) < offset+4 {
+			return false, 0, nil, 0
+		}
+		mask = buf[offset : offset+4]
+		offset += 4
+	}
+
+	if uint64(len(buf)-offset) < length {
+		return false, 0, nil, 0
+	}
+	payload = append([]byte(nil), buf[offset:offset+int(length)]...)
+	for i := range mask {
+		for j := i; j < len(payload); j += 4 {
+			payload[j] ^= mask[i]
+		}
+	}
+	return fin, opcode, payload, offset + int(length)
+}
@@ -0,0 +1,194 @@
+package sessionrecording
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// closeReserve is kept free below the size limit of a recording for the event that records why
+	// the session was closed
+	closeReserve = 256
+	saveTimeout  = 2 * time.Minute
+)
+
+// Recorder writes a terminal session in the asciicast v2 format of asciinema. The methods of a nil
+// Recorder do nothing so sessions that aren't recorded don't need to check.
+//
+// A session must not continue unrecorded, the recorder stops and closes Done if the recording
+// reaches the size limit of the store or fails to be written. The session is closed when Done is.
+type Recorder struct {
+	lock      sync.Mutex
+	recording recording
+	name      string
+	meta      map[string]string
+	limit     int
+	size      int
+	start     time.Time
+	pending   map[string][]byte
+	stopped   bool
+	closed    string
+	done      chan struct{}
+}
+
+type header struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title,omitempty"`
+}
+
+func newRecorder(store store, name string, meta map[string]string, title string, width, height int, now time.Time) *Recorder {
+	recording, limit := store.create(name, meta)
+	r := &Recorder{
+		recording: recording,
+		name:      name,
+		meta:      meta,
+		limit:     limit,
+		start:     now,
+		pending:   map[string][]byte{},
+		done:      make(chan struct{}),
+	}
+	data, _ := json.Marshal(header{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: now.Unix(),
+		Title:     title,
+	})
+	if err := r.write(append(data, '\n')); err != nil {
+		r.stop(now, fmt.Sprintf("recording failed: %v", err))
+	}
+	return r
+}
+
+// Done is closed when the recording stopped before the session ended, the session must be closed.
+func (r *Recorder) Done() <-chan struct{} {
+	if r == nil {
+		return nil
+	}
+	return r.done
+}
+
+// Write records the output of the session. It never fails so that writing to the recorder doesn't
+// interrupt the session, a failed recording closes Done.
+func (r *Recorder) Write(data []byte) (int, error) {
+	if r == nil {
+		return len(data), nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.text(time.Now(), "o", data)
+	return len(data), nil
+}
+
+// Input records the input of the session.
+func (r *Recorder) Input(data []byte) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.text(time.Now(), "i", data)
+}
+
+// Resize records a change of the terminal size.
+func (r *Recorder) Resize(width, height int) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.event(time.Now(), "r", fmt.Sprintf("%dx%d", width, height))
+}
+
+// text records data as events of the code. An event must not end in an incomplete UTF-8 sequence,
+// it is completed by the next data of the same code.
+func (r *Recorder) text(now time.Time, code string, data []byte) {
+	data = append(r.pending[code], data...)
+	end := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	r.pending[code] = append([]byte(nil), data[end:]...)
+	if end > 0 {
+		r.event(now, code, string(data[:end]))
+	}
+}
+
+func (r *Recorder) event(now time.Time, code, data string) {
+	if r.stopped {
+		return
+	}
+	event := r.marshal(now, code, data)
+	if r.limit > 0 && r.size+len(event) > r.limit-closeReserve {
+		r.stop(now, "recording size limit reached")
+		return
+	}
+	if err := r.write(event); err != nil {
+		r.stop(now, fmt.Sprintf("recording failed: %v", err))
+	}
+}
+
+func (r *Recorder) marshal(now time.Time, code, data string) []byte {
+	event, _ := json.Marshal([]interface{}{
+		float64(now.Sub(r.start).Milliseconds()) / 1000,
+		code,
+		data,
+	})
+	return append(event, '\n')
+}
+
+func (r *Recorder) write(data []byte) error {
+	n, err := r.recording.Write(data)
+	r.size += n
+	return err
+}
+
+// stop ends the recording with an event that tells why the session was closed and closes Done.
+func (r *Recorder) stop(now time.Time, reason string) {
+	r.stopped = true
+	r.closed = reason
+	event := r.marshal(now, "o", "\r\n["+reason+", the session is closed]\r\n")
+	if r.limit == 0 || r.size+len(event) <= r.limit {
+		_ = r.write(event)
+	}
+	logrus.Warnf("closing session of recording %s: %s", r.name, reason)
+	close(r.done)
+}
+
+// Close saves the recording.
+func (r *Recorder) Close() {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stopped = true
+
+	meta := map[string]string{}
+	for k, v := range r.meta {
+		meta[k] = v
+	}
+	if r.closed != "" {
+		meta["closed"] = r.closed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+	defer cancel()
+	if err := r.recording.save(ctx, meta); err != nil {
+		logrus.Errorf("failed to save session recording %s: %v", r.name, err)
+	}
+}
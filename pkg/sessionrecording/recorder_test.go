@@ -0,0 +1,217 @@
+package sessionrecording
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	limit int
+	meta  map[string]string
+	data  []byte
+}
+
+func (m *memoryStore) link(name string) string {
+	return "memory://" + name
+}
+
+func (m *memoryStore) create(_ string, _ map[string]string) (recording, int) {
+	return &memoryRecording{store: m}, m.limit
+}
+
+type memoryRecording struct {
+	bytes.Buffer
+	store *memoryStore
+}
+
+func (m *memoryRecording) save(_ context.Context, meta map[string]string) error {
+	m.store.meta = meta
+	m.store.data = append([]byte(nil), m.Bytes()...)
+	return nil
+}
+
+func readEvents(t *testing.T, data []byte) (header, []string) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxSecretSize)
+	require.True(t, scanner.Scan())
+	var h header
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &h))
+
+	var output []string
+	for scanner.Scan() {
+		var event []interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		require.Len(t, event, 3)
+		output = append(output, event[1].(string)+":"+event[2].(string))
+	}
+	return h, output
+}
+
+func TestRecorder(t *testing.T) {
+	store := &memoryStore{}
+	now := time.Date(2021, 3, 17, 12, 30, 0, 0, time.UTC)
+	r := newRecorder(store, "session", map[string]string{"user": "admin"}, "ssh machine", 80, 20, now)
+
+	r.Write([]byte("$ ls\r\n"))
+	// the euro sign is split across two writes
+	euro := []byte("€")
+	r.Write(append([]byte("price: "), euro[:2]...))
+	r.Write(append(euro[2:], '\n'))
+	r.Resize(120, 40)
+	r.Close()
+
+	h, output := readEvents(t, store.data)
+	assert.Equal(t, header{Version: 2, Width: 80, Height: 20, Timestamp: now.Unix(), Title: "ssh machine"}, h)
+	assert.Equal(t, []string{"o:$ ls\r\n", "o:price: ", "o:€\n", "r:120x40"}, output)
+	assert.Equal(t, map[string]string{"user": "admin"}, store.meta)
+
+	var nilRecorder *Recorder
+	n, err := nilRecorder.Write([]byte("not recorded"))
+	assert.NoError(t, err)
+	assert.Equal(t, 12, n)
+	nilRecorder.Close()
+}
+
+func TestRecorderLimit(t *testing.T) {
+	store := &memoryStore{limit: 4096}
+	r := newRecorder(store, "session", nil, "", 80, 20, time.Now())
+	line := []byte(strings.Repeat("x", 1024))
+	for i := 0; i < 3; i++ {
+		r.Write(line)
+	}
+	select {
+	case <-r.Done():
+		t.Fatal("recorder is done below the limit")
+	default:
+	}
+	// the fourth line exceeds the limit
+	r.Write(line)
+	<-r.Done()
+	r.Write(line)
+	r.Close()
+
+	assert.LessOrEqual(t, len(store.data), store.limit)
+	assert.Equal(t, "recording size limit reached", store.meta["closed"])
+	_, events := readEvents(t, store.data)
+	require.Len(t, events, 4)
+	assert.Equal(t, "o:\r\n[recording size limit reached, the session is closed]\r\n", events[3])
+}
+
+func TestS3Recording(t *testing.T) {
+	r := &s3Recording{
+		done: make(chan struct{}),
+	}
+	r.cond = sync.NewCond(&r.lock)
+
+	var uploaded []byte
+	go func() {
+		defer close(r.done)
+		uploaded, _ = ioutil.ReadAll(r)
+		r.lock.Lock()
+		r.err = errUploaded
+		r.lock.Unlock()
+	}()
+
+	_, err := r.Write([]byte("hello "))
+	require.NoError(t, err)
+	_, err = r.Write([]byte("world"))
+	require.NoError(t, err)
+	require.NoError(t, r.save(context.Background(), nil))
+	assert.Equal(t, "hello world", string(uploaded))
+
+	blocked := &s3Recording{}
+	blocked.cond = sync.NewCond(&blocked.lock)
+	_, err = blocked.Write(make([]byte, s3MaxBacklog))
+	require.NoError(t, err)
+	_, err = blocked.Write([]byte("x"))
+	assert.Error(t, err)
+}
+
+func frame(opcode byte, fin bool, payload []byte) []byte {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	result := []byte{b0}
+	switch {
+	case len(payload) < 126:
+		result = append(result, byte(len(payload)))
+	default:
+		result = append(result, 126, byte(len(payload)>>8), byte(len(payload)))
+	}
+	return append(result, payload...)
+}
+
+func TestExecOutput(t *testing.T) {
+	store := &memoryStore{}
+	r := newRecorder(store, "session", nil, "", 80, 20, time.Now())
+	output := &execStream{recorder: r}
+
+	var stream []byte
+	stream = append(stream, "HTTP/1.1 101 Switching Protocols\r\nSec-Websocket-Protocol: base64.channel.k8s.io\r\n\r\n"...)
+	stream = append(stream, frame(1, true, []byte("1"))...)
+	stream = append(stream, frame(1, true, []byte("1"+base64.StdEncoding.EncodeToString([]byte("hello"))))...)
+	stream = append(stream, frame(9, true, nil)...)
+	long := "1" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("y", 200)))
+	stream = append(stream, frame(1, false, []byte(long[:100]))...)
+	stream = append(stream, frame(0, true, []byte(long[100:]))...)
+	stream = append(stream, frame(1, true, []byte("3"+base64.StdEncoding.EncodeToString([]byte("status"))))...)
+
+	// the stream is written in small chunks to split the frames
+	for len(stream) > 0 {
+		n := 7
+		if n > len(stream) {
+			n = len(stream)
+		}
+		output.Write(stream[:n])
+		stream = stream[n:]
+	}
+	r.Close()
+
+	_, events := readEvents(t, store.data)
+	assert.Equal(t, []string{"o:hello", "o:" + strings.Repeat("y", 200)}, events)
+
+	store = &memoryStore{}
+	r = newRecorder(store, "session", nil, "", 80, 20, time.Now())
+	output = &execStream{recorder: r, headerDone: true}
+	output.Write(frame(2, true, append([]byte{2}, "error"...)))
+	r.Close()
+	_, events = readEvents(t, store.data)
+	assert.Equal(t, []string{"o:error"}, events)
+}
+
+func maskedFrame(payload []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	result := []byte{0x81, 0x80 | byte(len(payload))}
+	result = append(result, mask...)
+	for i, b := range payload {
+		result = append(result, b^mask[i%4])
+	}
+	return result
+}
+
+func TestExecInput(t *testing.T) {
+	store := &memoryStore{}
+	r := newRecorder(store, "session", nil, "", 80, 20, time.Now())
+	input := &execStream{recorder: r, headerDone: true, input: true}
+
+	input.Write(maskedFrame([]byte("0" + base64.StdEncoding.EncodeToString([]byte("ls\r")))))
+	input.Write(maskedFrame([]byte("4" + base64.StdEncoding.EncodeToString([]byte(`{"Width":100,"Height":30}`)))))
+	// output channels written by the client are not recorded
+	input.Write(maskedFrame([]byte("1" + base64.StdEncoding.EncodeToString([]byte("ignored")))))
+	r.Close()
+
+	_, events := readEvents(t, store.data)
+	assert.Equal(t, []string{"i:ls\r", "r:100x30"}, events)
+}
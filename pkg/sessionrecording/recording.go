@@ -0,0 +1,276 @@
+package sessionrecording
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pborman/uuid"
+	namespaces "github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/settings"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const (
+	// Header is the response header that links a shell session to its recording, the audit log
+	// of the request that opened the session contains it.
+	Header = "X-Rancher-Session-Recording"
+
+	SecretTypeSessionRecording = "management.cattle.io/session-recording"
+	recordingKey               = "recording.cast"
+	annotationPrefix           = "sessionrecording.cattle.io/"
+
+	// maxSecretSize limits the size of a recording that is saved as a secret
+	maxSecretSize = 768 * 1024
+	// s3PartSize is the size of the parts the recordings are uploaded to s3 in, the smallest part
+	// size s3 allows
+	s3PartSize = 5 * 1024 * 1024
+	// s3MaxBacklog limits the data of a recording that is buffered for the upload to s3, the
+	// recording fails if the upload falls further behind the session
+	s3MaxBacklog = 32 * 1024 * 1024
+)
+
+var errUploaded = errors.New("recording is uploaded")
+
+type store interface {
+	link(name string) string
+	// create starts a recording, limit is the largest size of the recording or zero if the store
+	// doesn't limit it
+	create(name string, meta map[string]string) (r recording, limit int)
+}
+
+// recording is written while the session runs, save is called when the session ended with the meta
+// data of the whole session.
+type recording interface {
+	io.Writer
+	save(ctx context.Context, meta map[string]string) error
+}
+
+// Recordings records the shell sessions to the store picked by the shell-session-recording setting.
+type Recordings struct {
+	secrets corecontrollers.SecretClient
+}
+
+func New(secrets corecontrollers.SecretClient) *Recordings {
+	return &Recordings{
+		secrets: secrets,
+	}
+}
+
+// Start starts the recording of a terminal session of the request, the recording is linked from
+// the response header. The recorder is nil if sessions are not recorded.
+func (r *Recordings) Start(rw http.ResponseWriter, req *http.Request, title string, width, height int) (*Recorder, error) {
+	store, err := r.store()
+	if err != nil || store == nil {
+		return nil, err
+	}
+
+	meta := map[string]string{
+		"title": title,
+	}
+	if user, ok := request.UserFrom(req.Context()); ok {
+		meta["user"] = user.GetName()
+	}
+
+	name := "session-" + uuid.NewRandom().String()
+	rw.Header().Set(Header, store.link(name))
+	return newRecorder(store, name, meta, title, width, height, time.Now()), nil
+}
+
+func (r *Recordings) store() (store, error) {
+	switch settings.ShellSessionRecording.Get() {
+	case "":
+		return nil, nil
+	case "secret":
+		return &secretStore{
+			secrets: r.secrets,
+		}, nil
+	case "s3":
+		return r.s3Store()
+	default:
+		return nil, fmt.Errorf("invalid %s setting %q, must be secret or s3", settings.ShellSessionRecording.Name,
+			settings.ShellSessionRecording.Get())
+	}
+}
+
+// secretStore saves the recordings as secrets in the system namespace.
+type secretStore struct {
+	secrets corecontrollers.SecretClient
+}
+
+func (s *secretStore) link(name string) string {
+	return fmt.Sprintf("secret://%s/%s", namespaces.System, name)
+}
+
+func (s *secretStore) create(name string, _ map[string]string) (recording, int) {
+	return &secretRecording{
+		secrets: s.secrets,
+		name:    name,
+	}, maxSecretSize
+}
+
+// secretRecording keeps the recording in memory until the session ended.
+type secretRecording struct {
+	bytes.Buffer
+	secrets corecontrollers.SecretClient
+	name    string
+}
+
+func (s *secretRecording) save(_ context.Context, meta map[string]string) error {
+	annotations := map[string]string{}
+	for k, v := range meta {
+		annotations[annotationPrefix+k] = v
+	}
+	_, err := s.secrets.Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        s.name,
+			Namespace:   namespaces.System,
+			Annotations: annotations,
+		},
+		Type: SecretTypeSessionRecording,
+		Data: map[string][]byte{
+			recordingKey: s.Bytes(),
+		},
+	})
+	return err
+}
+
+// s3Store saves the recordings to a bucket of an S3 compatible object store.
+type s3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+func (r *Recordings) s3Store() (store, error) {
+	bucket, endpoint := settings.ShellSessionRecordingS3Bucket.Get(), settings.ShellSessionRecordingS3Endpoint.Get()
+	if bucket == "" || endpoint == "" {
+		return nil, fmt.Errorf("the %s and %s settings are required to record sessions to s3",
+			settings.ShellSessionRecordingS3Bucket.Name, settings.ShellSessionRecordingS3Endpoint.Name)
+	}
+
+	var creds *credentials.Credentials
+	if ref := settings.ShellSessionRecordingS3Secret.Get(); ref != "" {
+		namespace, name := namespaces.System, ref
+		if i := strings.Index(ref, ":"); i >= 0 {
+			namespace, name = ref[:i], ref[i+1:]
+		}
+		secret, err := r.secrets.Get(namespace, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewStaticV4(string(secret.Data["accessKey"]), string(secret.Data["secretKey"]), "")
+	} else {
+		creds = credentials.NewIAM("")
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Store{
+		client: client,
+		bucket: bucket,
+	}, nil
+}
+
+func (s *s3Store) link(name string) string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, objectName(name))
+}
+
+// create streams the recording to the bucket while the session runs, the size of the recording is not
+// limited. The meta data of the object is the one known when the session starts.
+func (s *s3Store) create(name string, meta map[string]string) (recording, int) {
+	r := &s3Recording{
+		done: make(chan struct{}),
+	}
+	r.cond = sync.NewCond(&r.lock)
+	go func() {
+		defer close(r.done)
+		_, err := s.client.PutObject(context.Background(), s.bucket, objectName(name), r, -1, minio.PutObjectOptions{
+			ContentType:  "application/x-asciicast",
+			UserMetadata: meta,
+			PartSize:     s3PartSize,
+		})
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.err = err
+		if err == nil {
+			r.err = errUploaded
+		}
+	}()
+	return r, 0
+}
+
+// s3Recording buffers the recording until it is read by the upload.
+type s3Recording struct {
+	lock   sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	closed bool
+	err    error
+	done   chan struct{}
+}
+
+func (s *s3Recording) Write(data []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	if s.buf.Len()+len(data) > s3MaxBacklog {
+		return 0, fmt.Errorf("upload is more than %d bytes behind", s3MaxBacklog)
+	}
+	s.buf.Write(data)
+	s.cond.Broadcast()
+	return len(data), nil
+}
+
+// Read is called by the upload, it blocks until the session writes data or ends.
+func (s *s3Recording) Read(data []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for s.buf.Len() == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if s.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return s.buf.Read(data)
+}
+
+func (s *s3Recording) save(ctx context.Context, _ map[string]string) error {
+	s.lock.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.lock.Unlock()
+
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err == errUploaded {
+		return nil
+	}
+	return s.err
+}
+
+func objectName(name string) string {
+	return "recordings/" + name + ".cast"
+}
@@ -0,0 +1,56 @@
+package sessionrecording
+
+import (
+	"context"
+	"time"
+
+	namespaces "github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/settings"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/pkg/ticker"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const retentionInterval = time.Hour
+
+// Register deletes the recordings saved as secrets once they are older than the days of the
+// shell-session-recording-retention-days setting, 0 keeps them. Recordings in s3 are kept by the
+// lifecycle rules of the bucket.
+func Register(ctx context.Context, secrets corecontrollers.SecretController) {
+	cache := secrets.Cache()
+	go func() {
+		for range ticker.Context(ctx, retentionInterval) {
+			list, err := cache.List(namespaces.System, labels.Everything())
+			if err != nil {
+				logrus.Errorf("failed to list session recordings: %v", err)
+				continue
+			}
+			retention := time.Duration(settings.ShellSessionRecordingRetention.GetInt()) * 24 * time.Hour
+			for _, secret := range expired(list, retention, time.Now()) {
+				err := secrets.Delete(secret.Namespace, secret.Name, &metav1.DeleteOptions{})
+				if err != nil && !apierrors.IsNotFound(err) {
+					logrus.Errorf("failed to delete session recording %s: %v", secret.Name, err)
+				}
+			}
+		}
+	}()
+}
+
+// expired returns the recordings that were created more than retention before now.
+func expired(secrets []*corev1.Secret, retention time.Duration, now time.Time) []*corev1.Secret {
+	if retention <= 0 {
+		return nil
+	}
+
+	var result []*corev1.Secret
+	for _, secret := range secrets {
+		if secret.Type == SecretTypeSessionRecording && secret.CreationTimestamp.Time.Add(retention).Before(now) {
+			result = append(result, secret)
+		}
+	}
+	return result
+}
@@ -0,0 +1,35 @@
+package sessionrecording
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2021, 3, 17, 12, 30, 0, 0, time.UTC)
+	secret := func(name string, secretType corev1.SecretType, age time.Duration) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Type: secretType,
+		}
+	}
+	secrets := []*corev1.Secret{
+		secret("old", SecretTypeSessionRecording, 31*24*time.Hour),
+		secret("new", SecretTypeSessionRecording, 29*24*time.Hour),
+		secret("other", corev1.SecretTypeOpaque, 31*24*time.Hour),
+	}
+
+	var names []string
+	for _, secret := range expired(secrets, 30*24*time.Hour, now) {
+		names = append(names, secret.Name)
+	}
+	assert.Equal(t, []string{"old"}, names)
+	assert.Empty(t, expired(secrets, 0, now))
+}
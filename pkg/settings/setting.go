@@ -20,6 +20,14 @@
 	InjectDefaults string
 
 	AgentImage                        = NewSetting("agent-image", "rancher/rancher-agent:master-head")
+	AuditLogCheckpointInterval        = NewSetting("audit-log-checkpoint-interval", "1000") // number of hash chained records between signed checkpoints, 0 disables checkpoints
+	AuditLogHashChain                 = NewSetting("audit-log-hash-chain", "false")
+	AuditLogPolicy                    = NewSetting("audit-log-policy", "")         // JSON list of rules picking the audit level per request, the first matching rule wins. Ignored if --audit-level is 0
+	AuditLogSinks                     = NewSetting("audit-log-sinks", "")          // comma separated list of additional audit log sinks: stdout, syslog, webhook. Used without a log file if --audit-log-path is empty, changes require a restart
+	AuditLogSyslogAddress             = NewSetting("audit-log-syslog-address", "") // e.g. tcp://syslog.example.com:514 or udp://10.0.0.1:514, changes require a restart
+	AuditLogWebhookURL                = NewSetting("audit-log-webhook-url", "")    // changes to the webhook settings require a restart
+	AuditLogWebhookBatchSize          = NewSetting("audit-log-webhook-batch-size", "100")
+	AuditLogWebhookFlushSeconds       = NewSetting("audit-log-webhook-flush-seconds", "5")
 	AuthImage                         = NewSetting("auth-image", v32.ToolsSystemImages.AuthSystemImages.KubeAPIAuth)
 	AuthTokenMaxTTLMinutes            = NewSetting("auth-token-max-ttl-minutes", "0") // never expire
 	AuthorizationCacheTTLSeconds      = NewSetting("authorization-cache-ttl-seconds", "10")
@@ -30,10 +38,12 @@
 	CLIURLLinux                       = NewSetting("cli-url-linux", "https://releases.rancher.com/cli/v1.0.0-alpha8/rancher-linux-amd64-v1.0.0-alpha8.tar.gz")
 	CLIURLWindows                     = NewSetting("cli-url-windows", "https://releases.rancher.com/cli/v1.0.0-alpha8/rancher-windows-386-v1.0.0-alpha8.zip")
 	ClusterControllerStartCount       = NewSetting("cluster-controller-start-count", "50")
+	EncryptionKMSEndpoint             = NewSetting("encryption-kms-endpoint", "") // KMS v2 plugin socket, e.g. unix:///var/run/kmsplugin/socket.sock, secrets encrypted with it can't be read once it is cleared
 	EngineInstallURL                  = NewSetting("engine-install-url", "https://releases.rancher.com/install-docker/20.10.sh")
 	EngineISOURL                      = NewSetting("engine-iso-url", "https://releases.rancher.com/os/latest/rancheros-vmware.iso")
 	EngineNewestVersion               = NewSetting("engine-newest-version", "v17.12.0")
 	EngineSupportedRange              = NewSetting("engine-supported-range", "~v1.11.2 || ~v1.12.0 || ~v1.13.0 || ~v17.03.0 || ~v17.06.0 || ~v17.09.0 || ~v18.06.0 || ~v18.09.0 || ~v19.03.0 || ~v20.10.0 ")
+	EtcdBackupFilesystemBaseDir       = NewSetting("etcd-backup-filesystem-base-dir", "") // filesystem etcd backup targets must be under this directory, unset disables them
 	FirstLogin                        = NewSetting("first-login", "true")
 	GlobalRegistryEnabled             = NewSetting("global-registry-enabled", "false")
 	GithubProxyAPIURL                 = NewSetting("github-proxy-api-url", "https://api.github.com")
@@ -69,6 +79,9 @@
 	SystemNamespaces                  = NewSetting("system-namespaces", "kube-system,kube-public,cattle-system,cattle-alerting,cattle-logging,cattle-pipeline,cattle-prometheus,ingress-nginx,cattle-global-data,cattle-istio,kube-node-lease,cert-manager,cattle-global-nt,security-scan,cattle-fleet-system,calico-system,tigera-operator")
 	TelemetryOpt                      = NewSetting("telemetry-opt", "")
 	TokenHashing                      = NewSetting("token-hashing", "true")
+	TokenIdleTimeoutDays              = NewSetting("token-idle-timeout-days", "0")     // never expire idle tokens
+	TokenUsageTrackingStarted         = NewSetting("token-usage-tracking-started", "") // set by rancher, tokens that have not been used since count as idle from then
+	TrustedProxies                    = NewSetting("trusted-proxies", "")              // comma separated addresses and CIDRs of the proxies whose X-Forwarded-For header is trusted
 	TLSMinVersion                     = NewSetting("tls-min-version", "1.2")
 	TLSCiphers                        = NewSetting("tls-ciphers", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305")
 	UIBanners                         = NewSetting("ui-banners", "{}")
@@ -110,6 +123,12 @@
 	GKEUpstreamRefresh                = NewSetting("gke-refresh", "300")
 	HideLocalCluster                  = NewSetting("hide-local-cluster", "false")
 	MachineProvisionImage             = NewSetting("machine-provision-image", "rancher/machine:v0.15.0-rancher60")
+	ShellProfiles                     = NewSetting("shell-profiles", "")          // JSON list of profiles that customize the kubectl shell pods of users and groups
+	ShellSessionRecording             = NewSetting("shell-session-recording", "") // where SSH and cluster shell sessions are recorded: secret or s3, empty disables the recording
+	ShellSessionRecordingRetention    = NewSetting("shell-session-recording-retention-days", "30")
+	ShellSessionRecordingS3Bucket     = NewSetting("shell-session-recording-s3-bucket", "")
+	ShellSessionRecordingS3Endpoint   = NewSetting("shell-session-recording-s3-endpoint", "")
+	ShellSessionRecordingS3Secret     = NewSetting("shell-session-recording-s3-secret", "") // namespace:name of the secret with the accessKey and secretKey of the bucket
 
 	FleetMinVersion          = NewSetting("fleet-min-version", "")
 	RancherWebhookMinVersion = NewSetting("rancher-webhook-min-version", "")
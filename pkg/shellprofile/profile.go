@@ -0,0 +1,155 @@
+package shellprofile
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rancher/rancher/pkg/settings"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// PluginsPath is the directory of the shell pod the plugins are downloaded into.
+const PluginsPath = "/opt/shell/plugins"
+
+var (
+	pluginNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	sha256Regexp     = regexp.MustCompile(`^[a-f0-9]{64}$`)
+)
+
+// Profile customizes the shell pods of the users and groups it applies to. The profiles are
+// configured by the shell-profiles setting, the first profile that applies to a user is used and a
+// profile without users and groups applies to everyone.
+type Profile struct {
+	Name      string                  `json:"name,omitempty"`
+	Users     []string                `json:"users,omitempty"`
+	Groups    []string                `json:"groups,omitempty"`
+	Image     string                  `json:"image,omitempty"`
+	Env       []v1.EnvVar             `json:"env,omitempty"`
+	Plugins   []Plugin                `json:"plugins,omitempty"`
+	Resources v1.ResourceRequirements `json:"resources,omitempty"`
+	// HomeVolume keeps the home directory of a user on a persistent volume claim
+	HomeVolume *HomeVolume `json:"homeVolume,omitempty"`
+	// ReattachGraceSeconds keeps the shell pod running after a disconnect, the user reattaches
+	// to the session if the shell is opened again within the grace period
+	ReattachGraceSeconds int `json:"reattachGraceSeconds,omitempty"`
+}
+
+// Plugin is a kubectl plugin that is downloaded into the shell pod.
+type Plugin struct {
+	Name   string `json:"name,omitempty"`
+	URL    string `json:"url,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+type HomeVolume struct {
+	Size             string `json:"size,omitempty"`
+	StorageClassName string `json:"storageClassName,omitempty"`
+}
+
+// Get returns the profile of the shell pods of a user.
+func Get(user user.Info) (*Profile, error) {
+	profiles, err := unmarshal(settings.ShellProfiles.Get())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range profiles {
+		if profiles[i].appliesTo(user) {
+			return &profiles[i], profiles[i].validate()
+		}
+	}
+	return &Profile{}, nil
+}
+
+// Validate checks a value of the shell-profiles setting before it is saved.
+func Validate(value string) error {
+	profiles, err := unmarshal(value)
+	if err != nil {
+		return err
+	}
+	for i := range profiles {
+		if err := profiles[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshal(value string) ([]Profile, error) {
+	var profiles []Profile
+	if value == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(value), &profiles); err != nil {
+		return nil, fmt.Errorf("invalid %s setting: %w", settings.ShellProfiles.Name, err)
+	}
+	return profiles, nil
+}
+
+func (p *Profile) appliesTo(user user.Info) bool {
+	if len(p.Users) == 0 && len(p.Groups) == 0 {
+		return true
+	}
+	for _, name := range p.Users {
+		if name == user.GetName() {
+			return true
+		}
+	}
+	for _, group := range p.Groups {
+		for _, userGroup := range user.GetGroups() {
+			if group == userGroup {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Profile) validate() error {
+	for _, plugin := range p.Plugins {
+		if !pluginNameRegexp.MatchString(plugin.Name) {
+			return fmt.Errorf("invalid name of plugin %q in shell profile %s", plugin.Name, p.Name)
+		}
+		if plugin.URL == "" || !sha256Regexp.MatchString(plugin.SHA256) {
+			return fmt.Errorf("plugin %s in shell profile %s requires a url and a sha256 checksum", plugin.Name, p.Name)
+		}
+	}
+	if p.HomeVolume != nil {
+		if _, err := resource.ParseQuantity(p.HomeVolume.Size); err != nil {
+			return fmt.Errorf("invalid home volume size in shell profile %s: %w", p.Name, err)
+		}
+	}
+	if p.ReattachGraceSeconds < 0 {
+		return fmt.Errorf("negative reattach grace period in shell profile %s", p.Name)
+	}
+	return nil
+}
+
+// ShellImage is the image of the shell pod.
+func (p *Profile) ShellImage() string {
+	if p.Image == "" {
+		return settings.FullShellImage()
+	}
+	return settings.PrefixPrivateRegistry(p.Image)
+}
+
+// PluginsScript downloads the plugins of the profile and verifies their checksums.
+func (p *Profile) PluginsScript() string {
+	script := []string{"set -e"}
+	for _, plugin := range p.Plugins {
+		file := fmt.Sprintf("%s/kubectl-%s", PluginsPath, plugin.Name)
+		script = append(script,
+			fmt.Sprintf("curl -fsSL -o %s %s", file, shellQuote(plugin.URL)),
+			fmt.Sprintf("echo '%s  %s' | sha256sum -c -", plugin.SHA256, file),
+			fmt.Sprintf("chmod +x %s", file))
+	}
+	return strings.Join(script, "\n")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,149 @@
+package shellprofile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const checksum = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestAppliesTo(t *testing.T) {
+	admin := &user.DefaultInfo{Name: "u-admin", Groups: []string{"system:authenticated", "okta_group://ops"}}
+
+	tests := []struct {
+		name    string
+		profile Profile
+		want    bool
+	}{
+		{
+			name:    "everyone",
+			profile: Profile{},
+			want:    true,
+		},
+		{
+			name:    "user",
+			profile: Profile{Users: []string{"u-other", "u-admin"}},
+			want:    true,
+		},
+		{
+			name:    "group",
+			profile: Profile{Groups: []string{"okta_group://ops"}},
+			want:    true,
+		},
+		{
+			name:    "other user and group",
+			profile: Profile{Users: []string{"u-other"}, Groups: []string{"okta_group://dev"}},
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.profile.appliesTo(admin))
+		})
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		wantErr string
+	}{
+		{
+			name: "valid",
+			profile: Profile{
+				Plugins:              []Plugin{{Name: "ns", URL: "https://example.com/ns", SHA256: checksum}},
+				HomeVolume:           &HomeVolume{Size: "1Gi"},
+				ReattachGraceSeconds: 60,
+			},
+		},
+		{
+			name:    "plugin name",
+			profile: Profile{Plugins: []Plugin{{Name: "../ns", URL: "https://example.com/ns", SHA256: checksum}}},
+			wantErr: "invalid name of plugin",
+		},
+		{
+			name:    "plugin url",
+			profile: Profile{Plugins: []Plugin{{Name: "ns", SHA256: checksum}}},
+			wantErr: "requires a url and a sha256 checksum",
+		},
+		{
+			name:    "plugin checksum",
+			profile: Profile{Plugins: []Plugin{{Name: "ns", URL: "https://example.com/ns", SHA256: "abc"}}},
+			wantErr: "requires a url and a sha256 checksum",
+		},
+		{
+			name:    "home volume size",
+			profile: Profile{HomeVolume: &HomeVolume{Size: "big"}},
+			wantErr: "invalid home volume size",
+		},
+		{
+			name:    "grace period",
+			profile: Profile{ReattachGraceSeconds: -1},
+			wantErr: "negative reattach grace period",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.profile.validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(""))
+	assert.NoError(t, Validate(`[{"name":"ops","groups":["okta_group://ops"],"homeVolume":{"size":"1Gi"}}]`))
+	assert.Error(t, Validate(`{"name":"ops"}`))
+	// every profile is validated, not only the first one
+	assert.Error(t, Validate(`[{"name":"ops"},{"name":"dev","homeVolume":{"size":"big"}}]`))
+}
+
+func TestPluginsScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		plugins []Plugin
+		want    []string
+	}{
+		{
+			name: "no plugins",
+			want: []string{"set -e"},
+		},
+		{
+			name:    "plugin",
+			plugins: []Plugin{{Name: "ns", URL: "https://example.com/ns", SHA256: checksum}},
+			want: []string{
+				"set -e",
+				"curl -fsSL -o /opt/shell/plugins/kubectl-ns 'https://example.com/ns'",
+				"echo '" + checksum + "  /opt/shell/plugins/kubectl-ns' | sha256sum -c -",
+				"chmod +x /opt/shell/plugins/kubectl-ns",
+			},
+		},
+		{
+			name:    "quoted url",
+			plugins: []Plugin{{Name: "ns", URL: "https://example.com/it's", SHA256: checksum}},
+			want: []string{
+				"set -e",
+				`curl -fsSL -o /opt/shell/plugins/kubectl-ns 'https://example.com/it'\''s'`,
+				"echo '" + checksum + "  /opt/shell/plugins/kubectl-ns' | sha256sum -c -",
+				"chmod +x /opt/shell/plugins/kubectl-ns",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := Profile{Plugins: tt.plugins}
+			assert.Equal(t, tt.want, strings.Split(profile.PluginsScript(), "\n"))
+		})
+	}
+}
@@ -36,6 +36,7 @@
 	provisioningv1 "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io"
 	rkecontrollers "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/logserver"
 	"github.com/rancher/rancher/pkg/peermanager"
 	"github.com/rancher/rancher/pkg/tunnelserver"
 	"github.com/rancher/remotedialer"
@@ -186,6 +187,7 @@ func NewContext(ctx context.Context, clientConfig clientcmd.ClientConfig, restCo
 	if err != nil {
 		return nil, err
 	}
+	controllerFactory = logserver.WrapControllerFactory(controllerFactory)
 
 	opts := &generic.FactoryOptions{
 		SharedControllerFactory: controllerFactory,